@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/go-spatial/geocatalogo"
 	"github.com/go-spatial/geocatalogo/helpers"
 	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/webui"
@@ -44,8 +45,19 @@ func main() {
 		log.Printf("   - V6 READMEs: %d files", len(meta.V6READMEs))
 	}
 
+	// Collection descriptions come from the catalogue repository,
+	// when one is configured
+	var cat *geocatalogo.GeoCatalogue
+	if os.Getenv("GEOCATALOGO_REPOSITORY_URL") != "" {
+		cat, err = geocatalogo.NewFromEnv()
+		if err != nil {
+			log.Printf("⚠️  Warning: Could not open catalogue: %v", err)
+			cat = nil
+		}
+	}
+
 	// Create app and router
-	app := webui.NewApp(tc, meta)
+	app := webui.NewApp(tc, meta, cat)
 	mux := webui.NewMux(app)
 
 	// Start server
@@ -27,8 +27,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -44,8 +46,10 @@ import (
 
 	"github.com/go-spatial/geocatalogo"
 	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/metadata/parsers"
 	"github.com/go-spatial/geocatalogo/repository"
+	"github.com/go-spatial/geocatalogo/search"
 	"github.com/go-spatial/geocatalogo/web"
 )
 
@@ -53,7 +57,7 @@ func main() {
 	var router *mux.Router
 	var plural = ""
 	var bbox []float64
-	var timeVal []time.Time
+	var temporal *search.TemporalFilter
 	var collections []string
 	var fileCount = 0
 	var fileCounter = 1
@@ -62,32 +66,69 @@ func main() {
 	if len(os.Args) == 1 {
 		fmt.Printf("Usage: %s <command> [<args>]\n", os.Args[0])
 		fmt.Println("Commands: ")
-		fmt.Println(" createindex: add a metadata record to the index")
+		fmt.Println(" createindex: create the index and its alias")
+		fmt.Println(" reindex: copy the index into a new version and switch to it")
+		fmt.Println(" migrate-mapping: reindex if the index mapping is out of date")
+		fmt.Println(" migrate: copy all records from one repository to another")
 		fmt.Println(" index: add a metadata record to the index")
+		fmt.Println(" update: replace an existing metadata record in the index")
+		fmt.Println(" delete: remove a metadata record from the index")
 		fmt.Println(" search: search the index")
 		fmt.Println(" get: get metadata record by id")
+		fmt.Println(" collection: list, add, delete or refresh collections")
 		fmt.Println(" serve: run web server")
 		fmt.Println(" version: geocatalogo version")
 		return
 	}
 
 	createIndexCommand := flag.NewFlagSet("createindex", flag.ExitOnError)
+	shardsFlag := createIndexCommand.Int("shards", 0, "Number of primary shards (default=Elasticsearch default)")
+	replicasFlag := createIndexCommand.Int("replicas", 0, "Number of replicas (default=Elasticsearch default)")
+	printFlag := createIndexCommand.Bool("print", false, "Print the index mapping without creating the index")
+
+	reindexCommand := flag.NewFlagSet("reindex", flag.ExitOnError)
+	reindexShardsFlag := reindexCommand.Int("shards", 0, "Number of primary shards (default=current)")
+	reindexReplicasFlag := reindexCommand.Int("replicas", 0, "Number of replicas (default=current)")
+	reindexDeleteFlag := reindexCommand.Bool("delete-old", false, "Delete the previous index once switched")
+
+	migrateMappingCommand := flag.NewFlagSet("migrate-mapping", flag.ExitOnError)
+	dryRunFlag := migrateMappingCommand.Bool("dry-run", false, "List mapping changes without reindexing")
+	migrateDeleteFlag := migrateMappingCommand.Bool("delete-old", false, "Delete the previous index once switched")
+
+	migrateCommand := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateFromFlag := migrateCommand.String("from", "", "Path to configuration of the source repository")
+	migrateToFlag := migrateCommand.String("to", "", "Path to configuration of the destination repository")
+	batchFlag := migrateCommand.Int("batch", 500, "Number of records copied at a time (default=500)")
+	migrateDryRunFlag := migrateCommand.Bool("dry-run", false, "Read and checksum the source without writing")
 
 	indexCommand := flag.NewFlagSet("index", flag.ExitOnError)
 	fileFlag := indexCommand.String("file", "", "Path to metadata file")
 	dirFlag := indexCommand.String("dir", "", "Path to directory of metadata files")
 
+	updateCommand := flag.NewFlagSet("update", flag.ExitOnError)
+	updateFileFlag := updateCommand.String("file", "", "Path to metadata file")
+
+	deleteCommand := flag.NewFlagSet("delete", flag.ExitOnError)
+	deleteIdFlag := deleteCommand.String("id", "", "list of identifiers (comma-separated)")
+
 	searchCommand := flag.NewFlagSet("search", flag.ExitOnError)
 	collectionsFlag := searchCommand.String("collections", "", "Collections")
 	termFlag := searchCommand.String("term", "", "Search term(s)")
 	bboxFlag := searchCommand.String("bbox", "", "Bounding box (minx,miny,maxx,maxy)")
-	timeFlag := searchCommand.String("time", "", "Time (t1[,t2]), RFC3339 format")
+	relationFlag := searchCommand.String("relation", "", "Spatial relation of records to bbox (intersects (default), within, contains, disjoint)")
+	timeFlag := searchCommand.String("time", "", "Time instant or interval (t, t1/t2, ../t2, t1/..), RFC3339 format")
 	fromFlag := searchCommand.Int("from", 0, "Start position / offset (default=0)")
 	sizeFlag := searchCommand.Int("size", 10, "Number of results to return (default=10)")
+	sortFlag := searchCommand.String("sort", "", "Sort keys (e.g. -datetime,title)")
 
 	getCommand := flag.NewFlagSet("get", flag.ExitOnError)
 	idFlag := getCommand.String("id", "", "list of identifiers (comma-separated)")
 
+	collectionCommand := flag.NewFlagSet("collection", flag.ExitOnError)
+	collectionFileFlag := collectionCommand.String("file", "", "Path to JSON file of a collection or an array of collections to add")
+	collectionDeleteFlag := collectionCommand.String("delete", "", "Identifier of collection to delete")
+	collectionRefreshFlag := collectionCommand.String("refresh", "", "Identifier of collection to recompute the extent of")
+
 	serveCommand := flag.NewFlagSet("serve", flag.ExitOnError)
 	portFlag := serveCommand.Int("port", 8000, "port")
 	apiFlag := serveCommand.String("api", "default", "API to serve (default, stac, gro)")
@@ -97,12 +138,24 @@ func main() {
 	switch os.Args[1] {
 	case "createindex":
 		createIndexCommand.Parse(os.Args[2:])
+	case "reindex":
+		reindexCommand.Parse(os.Args[2:])
+	case "migrate-mapping":
+		migrateMappingCommand.Parse(os.Args[2:])
+	case "migrate":
+		migrateCommand.Parse(os.Args[2:])
 	case "index":
 		indexCommand.Parse(os.Args[2:])
+	case "update":
+		updateCommand.Parse(os.Args[2:])
+	case "delete":
+		deleteCommand.Parse(os.Args[2:])
 	case "search":
 		searchCommand.Parse(os.Args[2:])
 	case "get":
 		getCommand.Parse(os.Args[2:])
+	case "collection":
+		collectionCommand.Parse(os.Args[2:])
 	case "serve":
 		serveCommand.Parse(os.Args[2:])
 	case "version":
@@ -112,6 +165,8 @@ func main() {
 		os.Exit(10001)
 	}
 
+	ctx := context.Background()
+
 	if versionCommand.Parsed() {
 		osinfo := runtime.GOOS + "/" + runtime.GOARCH
 
@@ -123,8 +178,15 @@ func main() {
 		testLog := logrus.New()
 
 		testConfig := config.LoadFromEnv()
+		options := repository.IndexOptions{Shards: *shardsFlag, Replicas: *replicasFlag}
+
+		if *printFlag {
+			b, _ := json.MarshalIndent(repository.IndexMapping(testConfig, options), "", "    ")
+			fmt.Printf("%s\n", b)
+			return
+		}
 
-		err := repository.New(testConfig, testLog)
+		err := repository.NewWithOptions(testConfig, testLog, options)
 
 		if err != nil {
 			fmt.Println("Repository not created")
@@ -134,6 +196,92 @@ func main() {
 		return
 	}
 
+	if reindexCommand.Parsed() {
+		options := repository.IndexOptions{Shards: *reindexShardsFlag, Replicas: *reindexReplicasFlag}
+		indexName, err := repository.Reindex(config.LoadFromEnv(), logrus.New(), options, *reindexDeleteFlag)
+		if err != nil {
+			fmt.Printf("Reindex failed: %s\n", err)
+			os.Exit(10016)
+		}
+		fmt.Printf("Reindexed into %s\n", indexName)
+		return
+	}
+
+	if migrateMappingCommand.Parsed() {
+		migrateConfig := config.LoadFromEnv()
+		migrateLog := logrus.New()
+		changes, err := repository.MappingChanges(migrateConfig, migrateLog)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(10017)
+		}
+		if len(changes) == 0 {
+			fmt.Println("Index mapping is up to date")
+			return
+		}
+		fmt.Printf("Index mapping differs in %d fields:\n", len(changes))
+		for _, change := range changes {
+			fmt.Printf("    %s\n", change)
+		}
+		if *dryRunFlag {
+			return
+		}
+		indexName, err := repository.Reindex(migrateConfig, migrateLog, repository.IndexOptions{}, *migrateDeleteFlag)
+		if err != nil {
+			fmt.Printf("Reindex failed: %s\n", err)
+			os.Exit(10016)
+		}
+		fmt.Printf("Reindexed into %s\n", indexName)
+		return
+	}
+
+	if migrateCommand.Parsed() {
+		if *migrateFromFlag == "" || *migrateToFlag == "" {
+			fmt.Println("Please supply source and destination configuration via -from and -to")
+			os.Exit(10018)
+		}
+		migrateLog := logrus.New()
+		var repos []repository.Repository
+		for _, filename := range []string{*migrateFromFlag, *migrateToFlag} {
+			repoConfig, err := config.LoadFromFile(filename)
+			if err != nil {
+				fmt.Printf("Could not load configuration %s: %s\n", filename, err)
+				os.Exit(10019)
+			}
+			repo, err := repository.OpenBackend(repoConfig, migrateLog)
+			if err != nil {
+				fmt.Printf("Could not open repository of %s: %s\n", filename, err)
+				os.Exit(10019)
+			}
+			repos = append(repos, repo)
+		}
+
+		start := time.Now()
+		result, err := repository.Migrate(ctx, repos[0], repos[1], repository.MigrateOptions{
+			BatchSize: *batchFlag,
+			DryRun:    *migrateDryRunFlag,
+			Progress: func(migrated int, total int) {
+				fmt.Printf("Read %d of %d records\n", migrated, total)
+			},
+		})
+		for _, repo := range repos {
+			if closer, ok := repo.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+		if err != nil {
+			fmt.Printf("Migration failed: %s\n", err)
+			os.Exit(10020)
+		}
+		if *migrateDryRunFlag {
+			fmt.Printf("Dry run: %d records and %d collections would be migrated (checksum %s)\n", result.Records, result.Collections, result.Checksum)
+		} else {
+			fmt.Printf("Migrated and verified %d records (checksum %s) and %d collections\n", result.Records, result.Checksum, result.Collections)
+		}
+		fmt.Printf("Function took %s\n", time.Since(start))
+		return
+	}
+
 	cat, err := geocatalogo.NewFromEnv()
 
 	if err != nil {
@@ -169,30 +317,90 @@ func main() {
 
 		fmt.Printf("Indexing %d file%s\n", len(fileList), plural)
 
+		// records are indexed every batchSize, so that large
+		// directories are not held in memory
+		batchSize := cat.Config.Repository.BulkSize
+		if batchSize <= 0 {
+			batchSize = 500
+		}
+		var parseElapsed, indexElapsed time.Duration
+		var parsed, failed int
+		records := make([]metadata.Record, 0, batchSize)
+		flush := func() {
+			indexStart := time.Now()
+			failures := cat.BulkIndex(ctx, records)
+			for _, failure := range failures {
+				fmt.Printf("Error Indexing %s: %s\n", failure.Identifier, failure.Reason)
+			}
+			failed += len(failures)
+			records = records[:0]
+			indexElapsed += time.Since(indexStart)
+		}
+
+		start := time.Now()
 		for _, file := range fileList {
-			start := time.Now()
 			parseStart := time.Now()
-			fmt.Printf("Indexing file %d of %d: %q\n", fileCounter, fileCount, file)
+			fmt.Printf("Parsing file %d of %d: %q\n", fileCounter, fileCount, file)
+			fileCounter++
 			source, err := ioutil.ReadFile(file)
 			if err != nil {
 				fmt.Printf("Could not read file: %s\n", err)
 				continue
 			}
 			metadataRecord, err := parsers.ParseCSWRecord(source)
+			parseElapsed += time.Since(parseStart)
 			if err != nil {
 				fmt.Printf("Could not parse metadata: %s\n", err)
 				continue
 			}
-			parseElapsed := time.Since(parseStart)
-			indexStart := time.Now()
-			result := cat.Index(metadataRecord)
-			if !result {
-				fmt.Println("Error Indexing")
+			records = append(records, metadataRecord)
+			parsed++
+			if len(records) >= batchSize {
+				flush()
 			}
-			indexElapsed := time.Since(indexStart)
-			elapsed := time.Since(start)
-			fmt.Printf("Function took %s (parse: %s, index: %s)\n", elapsed, parseElapsed, indexElapsed)
-			fileCounter++
+		}
+		if len(records) > 0 {
+			flush()
+		}
+		elapsed := time.Since(start)
+		fmt.Printf("Indexed %d of %d records\n", parsed-failed, parsed)
+		fmt.Printf("Function took %s (parse: %s, index: %s)\n", elapsed, parseElapsed, indexElapsed)
+	} else if updateCommand.Parsed() {
+		if *updateFileFlag == "" {
+			fmt.Println("Please supply path to metadata file via -file")
+			os.Exit(10010)
+		}
+		source, err := ioutil.ReadFile(*updateFileFlag)
+		if err != nil {
+			fmt.Printf("Could not read file: %s\n", err)
+			os.Exit(10011)
+		}
+		metadataRecord, err := parsers.ParseCSWRecord(source)
+		if err != nil {
+			fmt.Printf("Could not parse metadata: %s\n", err)
+			os.Exit(10012)
+		}
+		if !cat.ReIndex(ctx, metadataRecord) {
+			fmt.Println("Error Updating")
+			os.Exit(10013)
+		}
+		fmt.Printf("Updated %s\n", metadataRecord.Identifier)
+	} else if deleteCommand.Parsed() {
+		if *deleteIdFlag == "" {
+			fmt.Println("Please provide identifier")
+			os.Exit(10014)
+		}
+		failed := false
+		for _, id := range strings.Split(*deleteIdFlag, ",") {
+			if cat.UnIndex(ctx, id) {
+				fmt.Printf("Deleted %s\n", id)
+			} else {
+				fmt.Printf("Error Deleting %s\n", id)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(10029)
 		}
 	} else if searchCommand.Parsed() {
 		if *collectionsFlag != "" {
@@ -210,17 +418,39 @@ func main() {
 			}
 		}
 		if *timeFlag != "" {
-			for _, t := range strings.Split(*timeFlag, "/") {
-				timestep, err := time.Parse(time.RFC3339, t)
-				if err != nil {
-					fmt.Println("time format error (should be ISO 8601/RFC3339)")
-					os.Exit(10007)
-				}
-				timeVal = append(timeVal, timestep)
+			var err error
+			temporal, err = search.ParseTemporalFilter(*timeFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(10007)
+			}
+		}
+		sortBy, err := search.ParseSort(*sortFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(10015)
+		}
+		req := search.Request{
+			Collections: collections,
+			Term:        *termFlag,
+			Temporal:    temporal,
+			Sort:        sortBy,
+			From:        *fromFlag,
+			Size:        *sizeFlag,
+		}
+		if len(bbox) == 4 {
+			relation, err := search.ParseRelation(*relationFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(10022)
 			}
+			req.Spatial = &search.SpatialFilter{BBox: bbox, Relation: relation}
+		}
+		results, err := cat.Search(ctx, req)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(10021)
 		}
-		propertyFilters := make(map[string]string)
-		results := cat.Search(collections, *termFlag, bbox, timeVal, *fromFlag, *sizeFlag, propertyFilters)
 		fmt.Printf("Found %d records\n", results.Matches)
 		for _, result := range results.Records {
 			fmt.Printf("    %s - %s\n", result.Identifier, result.Properties.Title)
@@ -244,11 +474,71 @@ func main() {
 			os.Exit(10009)
 		}
 		recordids := strings.Split(*idFlag, ",")
-		results := cat.Get(recordids)
+		results, err := cat.Get(ctx, recordids)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(10021)
+		}
 		for _, result := range results.Records {
 			b, _ := json.MarshalIndent(result, "", "    ")
 			fmt.Printf("%s\n", b)
 		}
+	} else if collectionCommand.Parsed() {
+		if *collectionFileFlag != "" {
+			source, err := ioutil.ReadFile(*collectionFileFlag)
+			if err != nil {
+				fmt.Printf("Could not read file: %s\n", err)
+				os.Exit(10023)
+			}
+			var collections []metadata.Collection
+			if strings.HasPrefix(strings.TrimSpace(string(source)), "[") {
+				err = json.Unmarshal(source, &collections)
+			} else {
+				collections = make([]metadata.Collection, 1)
+				err = json.Unmarshal(source, &collections[0])
+			}
+			if err != nil {
+				fmt.Printf("Could not parse collection: %s\n", err)
+				os.Exit(10024)
+			}
+			for _, collection := range collections {
+				if err := cat.InsertCollection(ctx, collection); err != nil {
+					fmt.Printf("Error Adding %s: %s\n", collection.Identifier, err)
+					os.Exit(10025)
+				}
+				fmt.Printf("Added %s\n", collection.Identifier)
+			}
+		} else if *collectionDeleteFlag != "" {
+			if err := cat.DeleteCollection(ctx, *collectionDeleteFlag); err != nil {
+				fmt.Printf("Error Deleting %s: %s\n", *collectionDeleteFlag, err)
+				os.Exit(10026)
+			}
+			fmt.Printf("Deleted %s\n", *collectionDeleteFlag)
+		} else if *collectionRefreshFlag != "" {
+			if err := cat.RefreshCollection(ctx, *collectionRefreshFlag); err != nil {
+				fmt.Printf("Error Refreshing %s: %s\n", *collectionRefreshFlag, err)
+				os.Exit(10027)
+			}
+			fmt.Printf("Refreshed %s\n", *collectionRefreshFlag)
+		} else {
+			collections, err := cat.Collections(ctx)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(10028)
+			}
+			fmt.Printf("Found %d collections\n", len(collections))
+			for _, collection := range collections {
+				fmt.Printf("    %s - %s\n", collection.Identifier, collection.DisplayTitle())
+			}
+			return
+		}
+		// file backed repositories persist changes when flushed
+		if flusher, ok := cat.Repository.(repository.Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				fmt.Printf("Error Saving collections: %s\n", err)
+				os.Exit(10029)
+			}
+		}
 	}
 	return
 }
@@ -27,6 +27,7 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
@@ -43,11 +44,12 @@ import (
 func main() {
 	var acquisitionDateLayout = "2006-01-02 15:04:05"
 	if len(os.Args) == 1 {
-		fmt.Printf("Usage: %s -file </path/to/scene-list>\n", os.Args[0])
+		fmt.Printf("Usage: %s -file </path/to/scene-list> [-batch <size>]\n", os.Args[0])
 		return
 	}
 
 	sceneListFlag := flag.String("file", "", "Path to scene_list csv")
+	batchFlag := flag.Int("batch", 10000, "Number of scenes to index per batch")
 	flag.Parse()
 
 	if *sceneListFlag == "" {
@@ -68,6 +70,18 @@ func main() {
 		panic(err)
 	}
 
+	records := []metadata.Record{}
+	indexed := 0
+
+	bulkIndex := func() {
+		failures := cat.BulkIndex(context.Background(), records)
+		for _, failure := range failures {
+			fmt.Printf("ERROR Indexing %s: %s\n", failure.Identifier, failure.Reason)
+		}
+		indexed += len(records) - len(failures)
+		records = records[:0]
+	}
+
 	for lineno, line := range lines {
 		if lineno == 0 { // skip header
 			continue
@@ -118,18 +132,7 @@ func main() {
 
 		metadataRecord.Properties.Geocatalogo.Inserted = time.Now()
 
-		metadataRecord.Geometry.Type = "Polygon"
-
-		var coordinates = [][][2]float64{{
-			{minLon, minLat},
-			{minLon, maxLat},
-			{maxLon, maxLat},
-			{maxLon, minLat},
-			{minLon, minLat},
-		}}
-
-		metadataRecord.Geometry.Coordinates = coordinates
-		metadataRecord.BoundingBox = metadataRecord.Geometry.Bounds()
+		metadataRecord.SetGeometry(metadata.NewBBoxPolygon([4]float64{minLon, minLat, maxLon, maxLat}))
 
 		metadataRecord.Properties.Geocatalogo.Schema = "local"
 		metadataRecord.Properties.Geocatalogo.Source = metadataURL
@@ -137,10 +140,13 @@ func main() {
 		res, _ := json.Marshal(metadataRecord)
 		fmt.Println(string(res))
 
-		result := cat.Index(metadataRecord)
-		if !result {
-			fmt.Println("ERROR Indexing " + metadataRecord.Identifier)
+		records = append(records, metadataRecord)
+		if len(records) >= *batchFlag {
+			bulkIndex()
 		}
 	}
+	bulkIndex()
+
+	fmt.Printf("Indexed %d of %d scenes\n", indexed, len(lines)-1)
 	return
 }
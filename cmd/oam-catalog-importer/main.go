@@ -27,6 +27,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -34,6 +35,7 @@ import (
 	"os"
 
 	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/metadata/parsers"
 )
 
@@ -64,15 +66,20 @@ func main() {
 
 	json.Unmarshal(raw, &results)
 
+	records := []metadata.Record{}
 	for _, res := range results.Result {
 		rec, err := parsers.ParseOAMCatalogResult(res)
 		if err != nil {
 			fmt.Println(err)
+			continue
 		}
-		result := cat.Index(rec)
-		if !result {
-			fmt.Println("ERROR Indexing " + rec.Identifier)
-		}
+		records = append(records, rec)
+	}
+
+	failures := cat.BulkIndex(context.Background(), records)
+	for _, failure := range failures {
+		fmt.Println("ERROR Indexing " + failure.Identifier + ": " + failure.Reason)
 	}
+	fmt.Printf("Indexed %d of %d records\n", len(records)-len(failures), len(records))
 	return
 }
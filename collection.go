@@ -0,0 +1,271 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Collections of the catalogue and their extents
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package geocatalogo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/repository"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// extentBatchSize is the number of records read at a time when
+// computing the extent of a collection
+const extentBatchSize = 500
+
+// InsertCollection adds a collection, replacing any with the same
+// identifier.  Its extent is computed from the records already in it
+func (c *GeoCatalogue) InsertCollection(ctx context.Context, collection metadata.Collection) error {
+	if collection.Identifier == "" {
+		return errors.New("collection has no identifier")
+	}
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Info("Inserting collection " + collection.Identifier)
+	extent, err := c.collectionExtent(ctx, collection.Identifier, nil)
+	if err != nil {
+		log.Errorf("Computing the extent of %s failed: %v", collection.Identifier, err)
+		return err
+	}
+	collection.Extent = extent
+	if err := c.Repository.InsertCollection(ctx, collection); err != nil {
+		log.Errorf("Inserting collection failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateCollection replaces the description of an existing collection.
+// The extent is kept, as it is computed from the records
+func (c *GeoCatalogue) UpdateCollection(ctx context.Context, collection metadata.Collection) error {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Info("Updating collection " + collection.Identifier)
+	existing, err := c.Repository.GetCollection(ctx, collection.Identifier)
+	if err != nil {
+		log.Errorf("Updating collection failed: %v", err)
+		return err
+	}
+	collection.Extent = existing.Extent
+	if err := c.Repository.UpdateCollection(ctx, collection); err != nil {
+		log.Errorf("Updating collection failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteCollection removes a collection.  Its records are kept
+func (c *GeoCatalogue) DeleteCollection(ctx context.Context, identifier string) error {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Info("Deleting collection " + identifier)
+	if err := c.Repository.DeleteCollection(ctx, identifier); err != nil {
+		log.Errorf("Deleting collection failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetCollection retrieves a collection.  The error wraps
+// repository.ErrCollectionNotFound when there is none
+func (c *GeoCatalogue) GetCollection(ctx context.Context, identifier string) (metadata.Collection, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.GetTimeout)
+	defer cancel()
+
+	collection, err := c.Repository.GetCollection(ctx, identifier)
+	if err != nil && !errors.Is(err, repository.ErrCollectionNotFound) {
+		log.Warn(err)
+	}
+	return collection, err
+}
+
+// Collections retrieves all collections, ordered by identifier
+func (c *GeoCatalogue) Collections(ctx context.Context) ([]metadata.Collection, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.QueryTimeout)
+	defer cancel()
+
+	collections, err := c.Repository.ListCollections(ctx)
+	if err != nil {
+		log.Warn(err)
+	}
+	return collections, err
+}
+
+// CollectionExtent computes the extent of the records of a collection,
+// whether or not the collection is stored
+func (c *GeoCatalogue) CollectionExtent(ctx context.Context, identifier string) (metadata.Extent, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.QueryTimeout)
+	defer cancel()
+
+	extent, err := c.collectionExtent(ctx, identifier, nil)
+	if err != nil {
+		log.Warn(err)
+	}
+	return extent, err
+}
+
+// RefreshCollection recomputes the extent of a collection from its
+// records.  Extents follow the records indexed, re-indexed and
+// un-indexed through the catalogue; a refresh is needed after records
+// are changed in the repository directly
+func (c *GeoCatalogue) RefreshCollection(ctx context.Context, identifier string) error {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	collection, err := c.Repository.GetCollection(ctx, identifier)
+	if err != nil {
+		return err
+	}
+	if collection.Extent, err = c.collectionExtent(ctx, identifier, nil); err != nil {
+		return err
+	}
+	log.Debugf("Refreshed the extent of collection %s", identifier)
+	return c.Repository.UpdateCollection(ctx, collection)
+}
+
+// collectionExtent computes the extent of the records of a collection.
+// Records in changed, by identifier, are read from it rather than the
+// repository (nil for those removed), so that changes are accounted
+// for whether or not searches see them yet
+func (c *GeoCatalogue) collectionExtent(ctx context.Context, identifier string, changed map[string]*metadata.Record) (metadata.Extent, error) {
+	var extent metadata.Extent
+
+	req := search.Request{Collections: []string{identifier}, Size: extentBatchSize}
+	for {
+		sr := search.Results{}
+		if err := c.Repository.Query(ctx, req, &sr); err != nil {
+			return extent, fmt.Errorf("reading the records of %s: %w", identifier, err)
+		}
+		for _, member := range sr.Records {
+			if _, ok := changed[member.Identifier]; !ok {
+				extent.Add(member)
+			}
+		}
+		if len(sr.After) == 0 || len(sr.Records) == 0 {
+			break
+		}
+		req.After, req.From = sr.After, sr.NextRecord
+	}
+	for _, record := range changed {
+		if record != nil && record.Properties.Collection == identifier {
+			extent.Add(*record)
+		}
+	}
+	return extent, nil
+}
+
+// storedCollections returns the stored collections by identifier, read
+// before records change so that catalogues without collections skip
+// keeping extents
+func (c *GeoCatalogue) storedCollections(ctx context.Context) map[string]metadata.Collection {
+	collections, err := c.Repository.ListCollections(ctx)
+	if err != nil {
+		log.Warnf("Listing collections failed: %v", err)
+		return nil
+	}
+	stored := make(map[string]metadata.Collection, len(collections))
+	for _, collection := range collections {
+		stored[collection.Identifier] = collection
+	}
+	return stored
+}
+
+// previousRecords returns the stored versions of records by identifier,
+// when there are collections whose extents they may be part of
+func (c *GeoCatalogue) previousRecords(ctx context.Context, stored map[string]metadata.Collection, identifiers []string) map[string]metadata.Record {
+	if len(stored) == 0 || len(identifiers) == 0 {
+		return nil
+	}
+	sr := search.Results{}
+	if err := c.Repository.Get(ctx, identifiers, &sr); err != nil {
+		log.Warnf("Reading the records replaced failed: %v", err)
+		return nil
+	}
+	previous := make(map[string]metadata.Record, len(sr.Records))
+	for _, record := range sr.Records {
+		previous[record.Identifier] = record
+	}
+	return previous
+}
+
+// syncCollections keeps the extents of the stored collections up to
+// date after records changed: changed holds the new versions by
+// identifier (nil for records removed) and previous those they
+// replaced.  An extent is extended to cover new versions, and only
+// recomputed from the records, once per collection, when a version
+// replaced reached its edge
+func (c *GeoCatalogue) syncCollections(ctx context.Context, stored map[string]metadata.Collection, previous map[string]metadata.Record, changed map[string]*metadata.Record) {
+	if len(stored) == 0 {
+		return
+	}
+	refresh := make(map[string]bool)
+	added := make(map[string][]metadata.Record)
+	for identifier, record := range changed {
+		if old, ok := previous[identifier]; ok {
+			if collection, ok := stored[old.Properties.Collection]; ok && collection.Extent.Reaches(old) {
+				refresh[collection.Identifier] = true
+			}
+		}
+		if record != nil {
+			if _, ok := stored[record.Properties.Collection]; ok {
+				added[record.Properties.Collection] = append(added[record.Properties.Collection], *record)
+			}
+		}
+	}
+
+	for identifier := range refresh {
+		collection := stored[identifier]
+		extent, err := c.collectionExtent(ctx, identifier, changed)
+		if err != nil {
+			log.Warnf("Refreshing collection %s failed: %v", identifier, err)
+			continue
+		}
+		collection.Extent = extent
+		if err := c.Repository.UpdateCollection(ctx, collection); err != nil {
+			log.Warnf("Refreshing collection %s failed: %v", identifier, err)
+			continue
+		}
+		log.Debugf("Refreshed the extent of collection %s", identifier)
+	}
+	for identifier, records := range added {
+		if refresh[identifier] {
+			continue
+		}
+		collection := stored[identifier]
+		extended := false
+		for _, record := range records {
+			if !collection.Extent.Covers(record) {
+				collection.Extent.Add(record)
+				extended = true
+			}
+		}
+		if !extended {
+			continue
+		}
+		if err := c.Repository.UpdateCollection(ctx, collection); err != nil {
+			log.Warnf("Extending collection %s failed: %v", identifier, err)
+		}
+	}
+}
+
+// indexedRecords returns records by identifier, leaving out those which
+// failed to be indexed
+func indexedRecords(records []metadata.Record, failed map[string]bool) map[string]*metadata.Record {
+	indexed := make(map[string]*metadata.Record, len(records))
+	for i := range records {
+		if !failed[records[i].Identifier] {
+			indexed[records[i].Identifier] = &records[i]
+		}
+	}
+	return indexed
+}
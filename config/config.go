@@ -30,17 +30,32 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Repository provides an object model for backends.
 type Repository struct {
-	Type     string
-	URL      string
-	Username string
-	Password string
-	Mappings map[string]string
+	Type          string
+	URL           string
+	Username      string
+	Password      string
+	Mappings      map[string]string
+	BulkSize      int
+	FlushInterval time.Duration
+	// CompactionThreshold is the number of logged changes after which
+	// the file backend compacts its log into a snapshot
+	CompactionThreshold int
+	// QueryTimeout, GetTimeout and WriteTimeout bound the time spent on
+	// searches (including facet counts), retrievals by identifier and
+	// changes respectively; zero means no limit
+	QueryTimeout time.Duration
+	GetTimeout   time.Duration
+	WriteTimeout time.Duration
+	// WatchInterval, when set, is how often the memory backend checks
+	// its source file for changes and reloads it
+	WatchInterval time.Duration
 }
 
 // Config provides an object model for configuration.
@@ -54,6 +69,16 @@ type Config struct {
 		PrettyPrint bool
 		Limit       int
 		CORS        bool
+		// TokenSecret signs page tokens; when unset tokens are signed
+		// with a random key and expire when the server restarts
+		TokenSecret string
+		// Transactions enables the CSW Transaction and Harvest
+		// operations, which change the catalogue
+		Transactions bool
+		// HarvestHosts lists the hosts Harvest may fetch from and
+		// notify.  When empty any host is allowed except at loopback,
+		// private and link-local addresses
+		HarvestHosts []string
 	}
 	Logging struct {
 		Level   string
@@ -121,6 +146,12 @@ func LoadFromEnv() Config {
 			cfg.Server.Limit, _ = strconv.Atoi(pair[1])
 		case "GEOCATALOGO_SERVER_CORS":
 			cfg.Server.CORS, _ = strconv.ParseBool(pair[1])
+		case "GEOCATALOGO_SERVER_TOKEN_SECRET":
+			cfg.Server.TokenSecret = pair[1]
+		case "GEOCATALOGO_SERVER_TRANSACTIONS":
+			cfg.Server.Transactions, _ = strconv.ParseBool(pair[1])
+		case "GEOCATALOGO_SERVER_HARVEST_HOSTS":
+			cfg.Server.HarvestHosts = strings.Split(pair[1], ",")
 		case "GEOCATALOGO_LOGGING_LEVEL":
 			cfg.Logging.Level = pair[1]
 		case "GEOCATALOGO_LOGGING_LOGFILE":
@@ -183,6 +214,20 @@ func LoadFromEnv() Config {
 			cfg.Repository.Username = pair[1]
 		case "GEOCATALOGO_REPOSITORY_PASSWORD":
 			cfg.Repository.Password = pair[1]
+		case "GEOCATALOGO_REPOSITORY_BULK_SIZE":
+			cfg.Repository.BulkSize, _ = strconv.Atoi(pair[1])
+		case "GEOCATALOGO_REPOSITORY_FLUSH_INTERVAL":
+			cfg.Repository.FlushInterval, _ = time.ParseDuration(pair[1])
+		case "GEOCATALOGO_REPOSITORY_COMPACTION_THRESHOLD":
+			cfg.Repository.CompactionThreshold, _ = strconv.Atoi(pair[1])
+		case "GEOCATALOGO_REPOSITORY_QUERY_TIMEOUT":
+			cfg.Repository.QueryTimeout, _ = time.ParseDuration(pair[1])
+		case "GEOCATALOGO_REPOSITORY_GET_TIMEOUT":
+			cfg.Repository.GetTimeout, _ = time.ParseDuration(pair[1])
+		case "GEOCATALOGO_REPOSITORY_WRITE_TIMEOUT":
+			cfg.Repository.WriteTimeout, _ = time.ParseDuration(pair[1])
+		case "GEOCATALOGO_REPOSITORY_WATCH_INTERVAL":
+			cfg.Repository.WatchInterval, _ = time.ParseDuration(pair[1])
 		default:
 			if strings.HasPrefix(pair[0], "GEOCATALOGO_REPOSITORY_MAPPINGS") {
 				tokens := strings.Split(pair[0], "GEOCATALOGO_REPOSITORY_MAPPINGS_")
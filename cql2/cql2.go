@@ -0,0 +1,467 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// OGC Common Query Language (CQL2) filter expressions
+//
+///////////////////////////////////////////////////////////////////////////////
+
+// Package cql2 parses OGC Common Query Language (CQL2) filters, in the
+// text and JSON encodings, and OGC Filter Encoding filters into
+// expressions over the queryable properties of records, and evaluates
+// them against records
+package cql2
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+// Filter languages
+const (
+	LangText = "cql2-text"
+	LangJSON = "cql2-json"
+)
+
+// Parse parses a filter in the given language (LangText when empty)
+func Parse(filter string, lang string) (Expr, error) {
+	switch strings.ToLower(strings.TrimSpace(lang)) {
+	case "", LangText:
+		return ParseText(filter)
+	case LangJSON:
+		return ParseJSON([]byte(filter))
+	}
+	return nil, fmt.Errorf("unsupported filter-lang %s (should be %s or %s)", lang, LangText, LangJSON)
+}
+
+// Expr is a boolean CQL2 expression.  Expressions encode as CQL2 JSON
+type Expr interface {
+	json.Marshaler
+	expr()
+}
+
+// Logical combines expressions with and or or
+type Logical struct {
+	Op   string
+	Args []Expr
+}
+
+// Not negates an expression
+type Not struct {
+	Arg Expr
+}
+
+// Comparison compares a property with a value (a string, float64 or
+// time.Time) using =, <>, <, <=, > or >=
+type Comparison struct {
+	Op       string
+	Property string
+	Value    interface{}
+}
+
+// Like matches a string property against a pattern, in which % matches
+// any characters, _ a single character and \ escapes either
+type Like struct {
+	Property        string
+	Pattern         string
+	CaseInsensitive bool
+}
+
+// In matches a property equal to any of a list of values
+type In struct {
+	Property string
+	Values   []interface{}
+}
+
+// Between matches a property between two values, inclusive
+type Between struct {
+	Property string
+	Low      interface{}
+	High     interface{}
+}
+
+// IsNull matches records without a value for a property
+type IsNull struct {
+	Property string
+}
+
+// SpatialPredicate relates the geometry of a record to a geometry:
+// s_intersects, s_disjoint, s_within (the record lies inside it) or
+// s_contains (the record encloses it)
+type SpatialPredicate struct {
+	Op       string
+	Property string
+	Geometry metadata.Geometry
+}
+
+// TemporalPredicate relates a timestamp property, or the interval
+// between two (Property to EndProperty), to the interval Start to End,
+// where zero times and missing property values are open ends
+type TemporalPredicate struct {
+	Op          string
+	Property    string
+	EndProperty string
+	Start       time.Time
+	End         time.Time
+}
+
+func (Logical) expr()           {}
+func (Not) expr()               {}
+func (Comparison) expr()        {}
+func (Like) expr()              {}
+func (In) expr()                {}
+func (Between) expr()           {}
+func (IsNull) expr()            {}
+func (SpatialPredicate) expr()  {}
+func (TemporalPredicate) expr() {}
+
+// Spatial and temporal operators
+const (
+	SpatialIntersects  = "s_intersects"
+	SpatialDisjoint    = "s_disjoint"
+	SpatialWithin      = "s_within"
+	SpatialContains    = "s_contains"
+	TemporalIntersects = "t_intersects"
+)
+
+// comparisonOps maps each comparison operator to its converse, used
+// when the value precedes the property
+var comparisonOps = map[string]string{
+	"=": "=", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<=",
+}
+
+// spatialOps maps each spatial operator to its converse
+var spatialOps = map[string]string{
+	SpatialIntersects: SpatialIntersects,
+	SpatialDisjoint:   SpatialDisjoint,
+	SpatialWithin:     SpatialContains,
+	SpatialContains:   SpatialWithin,
+}
+
+// Operands of predicates while parsing, besides strings, float64s,
+// bools, time.Times and geometries
+
+// property is a property reference
+type property string
+
+// propertyInterval is an interval between the values of two properties
+type propertyInterval [2]string
+
+// date is a calendar date, which is an instant at midnight UTC in
+// comparisons and the whole day in temporal predicates
+type date time.Time
+
+// interval is a time interval; zero times are open ends
+type interval [2]time.Time
+
+// casei is an operand compared case-insensitively
+type casei struct {
+	operand interface{}
+}
+
+// queryable resolves a property operand
+func queryable(operand interface{}) (Queryable, bool, error) {
+	if _, ok := operand.(casei); ok {
+		return Queryable{}, false, fmt.Errorf("casei is only supported in =, <> and like")
+	}
+	p, ok := operand.(property)
+	if !ok {
+		return Queryable{}, false, nil
+	}
+	q, found := Lookup(string(p))
+	if !found {
+		return Queryable{}, true, fmt.Errorf("unknown property %s (see /queryables)", string(p))
+	}
+	return q, true, nil
+}
+
+// value converts a literal to the type of a queryable
+func value(q Queryable, operand interface{}) (interface{}, error) {
+	switch q.Type {
+	case TypeString:
+		if s, ok := operand.(string); ok {
+			return s, nil
+		}
+	case TypeNumber:
+		if f, ok := operand.(float64); ok {
+			return f, nil
+		}
+	case TypeTimestamp:
+		switch t := operand.(type) {
+		case time.Time:
+			return t, nil
+		case date:
+			return time.Time(t), nil
+		case string:
+			// as OGC CQL and Filter Encoding give them
+			if t, err := parseInstant(t, false); err == nil && !t.IsZero() {
+				return t, nil
+			}
+		}
+	case TypeGeometry:
+		return nil, fmt.Errorf("%s can only be used in spatial predicates", q.Name)
+	}
+	return nil, fmt.Errorf("%s expects a %s value, not %s", q.Name, q.Type, describe(operand))
+}
+
+// describe names the kind of an operand for error messages
+func describe(operand interface{}) string {
+	switch operand.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case time.Time, date:
+		return "a timestamp"
+	case interval:
+		return "an interval"
+	case metadata.Geometry:
+		return "a geometry"
+	case property:
+		return "a property"
+	case propertyInterval:
+		return "a property interval"
+	case casei:
+		return "casei()"
+	}
+	return fmt.Sprintf("%v", operand)
+}
+
+// newComparison creates a comparison between a property and a value,
+// given in either order
+func newComparison(op string, a interface{}, b interface{}) (Expr, error) {
+	if e, ok, err := caseiComparison(op, a, b); ok {
+		return e, err
+	}
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty {
+		if q, isProperty, err = queryable(b); err != nil {
+			return nil, err
+		}
+		if !isProperty {
+			return nil, fmt.Errorf("%s must compare a property with a value", op)
+		}
+		op, b = comparisonOps[op], a
+	}
+	v, err := value(q, b)
+	if err != nil {
+		return nil, err
+	}
+	return Comparison{Op: op, Property: q.Name, Value: v}, nil
+}
+
+// caseiComparison makes = and <> on an operand wrapped in casei a LIKE
+// of the value, which is how string comparisons are made
+// case-insensitive
+func caseiComparison(op string, a interface{}, b interface{}) (Expr, bool, error) {
+	ca, aIsCasei := a.(casei)
+	cb, bIsCasei := b.(casei)
+	if !aIsCasei && !bIsCasei {
+		return nil, false, nil
+	}
+	if op != "=" && op != "<>" {
+		return nil, true, fmt.Errorf("casei is only supported in =, <> and like")
+	}
+	if aIsCasei {
+		a = ca.operand
+	}
+	if bIsCasei {
+		b = cb.operand
+	}
+	if _, isProperty, _ := queryable(a); !isProperty {
+		a, b = b, a
+	}
+	s, ok := b.(string)
+	if !ok {
+		return nil, true, fmt.Errorf("casei requires a string, not %s", describe(b))
+	}
+	e, err := newLike(casei{a}, escapeLike(s))
+	if op == "<>" && err == nil {
+		e = Not{Arg: e}
+	}
+	return e, true, err
+}
+
+// newLike creates a LIKE predicate, which is case-insensitive when
+// either operand is wrapped in casei
+func newLike(a interface{}, pattern interface{}) (Expr, error) {
+	insensitive := false
+	if c, ok := a.(casei); ok {
+		a, insensitive = c.operand, true
+	}
+	if c, ok := pattern.(casei); ok {
+		pattern, insensitive = c.operand, true
+	}
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty || q.Type != TypeString {
+		return nil, fmt.Errorf("like requires a string property")
+	}
+	p, ok := pattern.(string)
+	if !ok {
+		return nil, fmt.Errorf("like requires a string pattern, not %s", describe(pattern))
+	}
+	return Like{Property: q.Name, Pattern: p, CaseInsensitive: insensitive}, nil
+}
+
+func newBetween(a interface{}, low interface{}, high interface{}) (Expr, error) {
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty || (q.Type != TypeNumber && q.Type != TypeTimestamp) {
+		return nil, fmt.Errorf("between requires a number or timestamp property")
+	}
+	l, err := value(q, low)
+	if err != nil {
+		return nil, err
+	}
+	h, err := value(q, high)
+	if err != nil {
+		return nil, err
+	}
+	return Between{Property: q.Name, Low: l, High: h}, nil
+}
+
+func newIn(a interface{}, list []interface{}) (Expr, error) {
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty {
+		return nil, fmt.Errorf("in requires a property")
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("in requires a list of values")
+	}
+	values := make([]interface{}, len(list))
+	for i, operand := range list {
+		if values[i], err = value(q, operand); err != nil {
+			return nil, err
+		}
+	}
+	return In{Property: q.Name, Values: values}, nil
+}
+
+func newIsNull(a interface{}) (Expr, error) {
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty {
+		return nil, fmt.Errorf("is null requires a property")
+	}
+	return IsNull{Property: q.Name}, nil
+}
+
+func newSpatialPredicate(op string, a interface{}, b interface{}) (Expr, error) {
+	q, isProperty, err := queryable(a)
+	if err != nil {
+		return nil, err
+	}
+	if !isProperty {
+		if q, isProperty, err = queryable(b); err != nil {
+			return nil, err
+		}
+		op, b = spatialOps[op], a
+	}
+	if !isProperty || q.Type != TypeGeometry {
+		return nil, fmt.Errorf("%s requires a geometry property", op)
+	}
+	g, ok := b.(metadata.Geometry)
+	if !ok || g.IsEmpty() {
+		return nil, fmt.Errorf("%s requires a geometry, not %s", op, describe(b))
+	}
+	return SpatialPredicate{Op: op, Property: q.Name, Geometry: spatial.Normalize(g)}, nil
+}
+
+func newTemporalPredicate(op string, a interface{}, b interface{}) (Expr, error) {
+	if _, ok := a.(property); !ok {
+		if _, ok := a.(propertyInterval); !ok {
+			a, b = b, a
+		}
+	}
+
+	var tp TemporalPredicate
+	switch p := a.(type) {
+	case property:
+		tp.Property = string(p)
+	case propertyInterval:
+		tp.Property, tp.EndProperty = p[0], p[1]
+	default:
+		return nil, fmt.Errorf("%s requires a timestamp property or an interval of them", op)
+	}
+	for _, name := range []*string{&tp.Property, &tp.EndProperty} {
+		if *name == "" {
+			continue
+		}
+		q, _, err := queryable(property(*name))
+		if err != nil {
+			return nil, err
+		}
+		if q.Type != TypeTimestamp {
+			return nil, fmt.Errorf("%s requires a timestamp property, not %s", op, *name)
+		}
+		*name = q.Name
+	}
+
+	switch t := b.(type) {
+	case time.Time:
+		tp.Start, tp.End = t, t
+	case date:
+		tp.Start, tp.End = time.Time(t), time.Time(t).AddDate(0, 0, 1).Add(-time.Nanosecond)
+	case interval:
+		tp.Start, tp.End = t[0], t[1]
+	default:
+		return nil, fmt.Errorf("%s requires a timestamp, date or interval, not %s", op, describe(b))
+	}
+	tp.Op = op
+	return tp, nil
+}
+
+// parseInstant parses the timestamp or date of an interval end, where
+// .. (or an empty string) leaves the end open
+func parseInstant(value string, end bool) (time.Time, error) {
+	if value == ".." || value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(value)); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp or date %s", value)
+	}
+	if end {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return t, nil
+}
+
+// parseTimestamp parses the string of a TIMESTAMP literal
+func parseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s (should be RFC 3339)", value)
+	}
+	return t, nil
+}
+
+// parseDate parses the string of a DATE literal
+func parseDate(value string) (date, error) {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return date{}, fmt.Errorf("invalid date %s (should be YYYY-MM-DD)", value)
+	}
+	return date(t), nil
+}
@@ -0,0 +1,213 @@
+package cql2
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+func testRecords(t *testing.T) map[string]metadata.Record {
+	var records []metadata.Record
+	err := json.Unmarshal([]byte(`[
+		{"id": "dem", "geometry": {"type": "Polygon", "coordinates": [[[-80, 40], [-70, 40], [-70, 50], [-80, 50], [-80, 40]]]},
+		 "properties": {"title": "Digital elevation model", "collection": "elevation", "datetime": "2018-05-01T00:00:00Z",
+		  "keywords": [{"Keyword": ["elevation", "terrain"]}],
+		  "product_info": {"platform": "landsat-8", "cloud_cover": 12.5},
+		  "gro_metadata": {"country": "Canada", "data_format": "GeoTIFF"}}},
+		{"id": "roads", "geometry": {"type": "LineString", "coordinates": [[10, 50], [11, 51]]},
+		 "properties": {"title": "Road network", "collection": "transport",
+		  "temporal_extent": {"begin": "2000-01-01T00:00:00Z", "end": "2005-01-01T00:00:00Z"},
+		  "gro_metadata": {"country": "Germany", "data_format": "CSV"}}},
+		{"id": "untitled", "bbox": [100, -10, 110, 0],
+		 "properties": {"collection": "misc", "product_info": {"platform": "sentinel-2", "cloud_cover": 80}}}
+	]`), &records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]metadata.Record)
+	for _, record := range records {
+		byID[record.Identifier] = record
+	}
+	return byID
+}
+
+func matches(e Expr, records map[string]metadata.Record) []string {
+	var ids []string
+	match := Matcher(e)
+	for _, id := range []string{"dem", "roads", "untitled"} {
+		if match(records[id]) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestParseText(t *testing.T) {
+	records := testRecords(t)
+	tests := []struct {
+		filter   string
+		expected []string
+	}{
+		{"collection = 'elevation'", []string{"dem"}},
+		{"'elevation' = collection", []string{"dem"}},
+		{"properties.collection <> 'elevation'", []string{"roads", "untitled"}},
+		{"title <> 'Road network'", []string{"dem"}},
+		{"eo:cloud_cover < 50", []string{"dem"}},
+		{"\"eo:cloud_cover\" >= 12.5", []string{"dem", "untitled"}},
+		{"50 > eo:cloud_cover", []string{"dem"}},
+		{"eo:cloud_cover BETWEEN 10 AND 20", []string{"dem"}},
+		{"eo:cloud_cover NOT BETWEEN 10 AND 20", []string{"roads", "untitled"}},
+		{"title LIKE 'Road%'", []string{"roads"}},
+		{"title LIKE '_oad network'", []string{"roads"}},
+		{"title NOT LIKE '%model'", []string{"roads", "untitled"}},
+		{"country IN ('Canada', 'France')", []string{"dem"}},
+		{"data_format NOT IN ('CSV')", []string{"dem", "untitled"}},
+		{"keywords = 'terrain'", []string{"dem"}},
+		{"title IS NULL", []string{"untitled"}},
+		{"title IS NOT NULL", []string{"dem", "roads"}},
+		{"collection = 'elevation' OR collection = 'misc'", []string{"dem", "untitled"}},
+		{"collection = 'elevation' AND NOT (eo:cloud_cover > 10)", nil},
+		{"(country = 'Canada' OR country = 'Germany') and data_format = 'CSV'", []string{"roads"}},
+		{"S_INTERSECTS(geometry, POINT(-75 45))", []string{"dem"}},
+		{"S_INTERSECTS(geometry, BBOX(0, 40, 20, 60))", []string{"roads"}},
+		{"S_INTERSECTS(geometry, BBOX(170, -20, 105, -5))", []string{"untitled"}},
+		{"S_WITHIN(geometry, POLYGON((-90 30, -60 30, -60 60, -90 60, -90 30)))", []string{"dem"}},
+		{"S_CONTAINS(geometry, POINT(105 -5))", []string{"untitled"}},
+		{"S_DISJOINT(geometry, BBOX(-180, -90, 180, 0))", []string{"dem", "roads"}},
+		{"datetime > TIMESTAMP('2018-01-01T00:00:00Z')", []string{"dem"}},
+		{"datetime = DATE('2018-05-01')", []string{"dem"}},
+		{"T_INTERSECTS(datetime, DATE('2018-05-01'))", []string{"dem"}},
+		{"T_INTERSECTS(datetime, INTERVAL('2018-01-01', '..'))", []string{"dem"}},
+		{"T_INTERSECTS(INTERVAL(start_datetime, end_datetime), INTERVAL('2004-01-01', '2010-01-01'))", []string{"roads"}},
+		{"T_INTERSECTS(INTERVAL(start_datetime, end_datetime), TIMESTAMP('2006-01-01T00:00:00Z'))", nil},
+	}
+	for _, test := range tests {
+		e, err := ParseText(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if ids := matches(e, records); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s matched %v, expected %v", test.filter, ids, test.expected)
+		}
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"colour = 'red'",
+		"eo:cloud_cover = 'low'",
+		"collection = 'a' AND",
+		"collection LIKE 10",
+		"title = 'unterminated",
+		"collection IN ()",
+		"S_INTERSECTS(geometry, 'somewhere')",
+		"S_INTERSECTS(geometry, POINT(1))",
+		"S_INTERSECTS(geometry, POLYGON((1 2)))",
+		"T_INTERSECTS(datetime, TIMESTAMP('yesterday'))",
+		"T_INTERSECTS(title, DATE('2018-05-01'))",
+		"collection = 'a' collection = 'b'",
+	} {
+		if _, err := ParseText(filter); err == nil {
+			t.Errorf("expected %q to fail", filter)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	records := testRecords(t)
+	tests := []struct {
+		filter   string
+		expected []string
+	}{
+		{`{"op": "=", "args": [{"property": "collection"}, "transport"]}`, []string{"roads"}},
+		{`{"op": "and", "args": [
+			{"op": "<", "args": [{"property": "eo:cloud_cover"}, 90]},
+			{"op": "not", "args": [{"op": "isNull", "args": [{"property": "title"}]}]}]}`, []string{"dem"}},
+		{`{"op": "in", "args": [{"property": "platform"}, ["landsat-8", "sentinel-2"]]}`, []string{"dem", "untitled"}},
+		{`{"op": "between", "args": [{"property": "eo:cloud_cover"}, 50, 100]}`, []string{"untitled"}},
+		{`{"op": "like", "args": [{"property": "title"}, "%network"]}`, []string{"roads"}},
+		{`{"op": "s_intersects", "args": [{"property": "geometry"}, {"type": "Point", "coordinates": [10.5, 50.5]}]}`, []string{"roads"}},
+		{`{"op": "s_intersects", "args": [{"property": "geometry"}, {"bbox": [-100, 30, -60, 60]}]}`, []string{"dem"}},
+		{`{"op": "t_intersects", "args": [{"property": "datetime"}, {"interval": ["2018-01-01T00:00:00Z", ".."]}]}`, []string{"dem"}},
+		{`{"op": "t_intersects", "args": [{"interval": [{"property": "start_datetime"}, {"property": "end_datetime"}]}, {"date": "2001-06-01"}]}`, []string{"roads"}},
+	}
+	for _, test := range tests {
+		e, err := ParseJSON([]byte(test.filter))
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if ids := matches(e, records); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s matched %v, expected %v", test.filter, ids, test.expected)
+		}
+	}
+
+	for _, filter := range []string{
+		`not json`,
+		`{"op": "=", "args": [{"property": "colour"}, "red"]}`,
+		`{"op": "and", "args": [{"op": "=", "args": [{"property": "title"}, "a"]}]}`,
+		`{"op": "between", "args": [{"property": "eo:cloud_cover"}, 50]}`,
+		`{"op": "matches", "args": [{"property": "title"}, "a"]}`,
+	} {
+		if _, err := ParseJSON([]byte(filter)); err == nil {
+			t.Errorf("expected %s to fail", filter)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	records := testRecords(t)
+	for _, filter := range []string{
+		"collection = 'elevation' OR NOT (title LIKE 'Road%')",
+		"eo:cloud_cover BETWEEN 10 AND 20 AND country IN ('Canada', 'Germany')",
+		"title IS NULL",
+		"S_INTERSECTS(geometry, BBOX(0, 40, 20, 60))",
+		"T_INTERSECTS(INTERVAL(start_datetime, end_datetime), INTERVAL('..', '2001-01-01T00:00:00Z'))",
+		"datetime >= TIMESTAMP('2018-01-01T00:00:00Z')",
+	} {
+		e, err := ParseText(filter)
+		if err != nil {
+			t.Fatalf("%s: %v", filter, err)
+		}
+		encoded, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("%s: %v", filter, err)
+		}
+		decoded, err := ParseJSON(encoded)
+		if err != nil {
+			t.Fatalf("%s: %s: %v", filter, encoded, err)
+		}
+		if !reflect.DeepEqual(matches(e, records), matches(decoded, records)) {
+			t.Errorf("%s does not survive encoding as %s", filter, encoded)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("collection = 'elevation'", ""); err != nil {
+		t.Error(err)
+	}
+	if _, err := Parse(`{"op": "isNull", "args": [{"property": "title"}]}`, "CQL2-JSON"); err != nil {
+		t.Error(err)
+	}
+	if _, err := Parse("collection = 'elevation'", "ecql"); err == nil {
+		t.Error("expected an unsupported filter-lang to fail")
+	}
+}
+
+func TestTemporalLiterals(t *testing.T) {
+	e, err := ParseText("T_INTERSECTS(datetime, DATE('2018-05-01'))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := e.(TemporalPredicate)
+	day := time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)
+	if !tp.Start.Equal(day) || !tp.End.Equal(day.AddDate(0, 0, 1).Add(-time.Nanosecond)) {
+		t.Errorf("date parsed as %v/%v", tp.Start, tp.End)
+	}
+}
@@ -0,0 +1,404 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// OGC Filter Encoding (1.1 and 2.0) filters
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package cql2
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+// fesNode is an element of a filter, matched by local name so that
+// the ogc (1.1) and fes (2.0) namespaces and GML 2 and 3 are all read
+type fesNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []fesNode  `xml:",any"`
+}
+
+func (n fesNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n fesNode) child(name string) (fesNode, bool) {
+	for _, c := range n.Children {
+		if c.XMLName.Local == name {
+			return c, true
+		}
+	}
+	return fesNode{}, false
+}
+
+// fesComparisonOps maps the binary comparison operators of Filter
+// Encoding to those of CQL2
+var fesComparisonOps = map[string]string{
+	"PropertyIsEqualTo":              "=",
+	"PropertyIsNotEqualTo":           "<>",
+	"PropertyIsLessThan":             "<",
+	"PropertyIsLessThanOrEqualTo":    "<=",
+	"PropertyIsGreaterThan":          ">",
+	"PropertyIsGreaterThanOrEqualTo": ">=",
+}
+
+// fesSpatialOps maps the spatial operators of Filter Encoding to
+// those of CQL2
+var fesSpatialOps = map[string]string{
+	"BBOX":       SpatialIntersects,
+	"Intersects": SpatialIntersects,
+	"Disjoint":   SpatialDisjoint,
+	"Within":     SpatialWithin,
+	"Contains":   SpatialContains,
+}
+
+// ParseFES parses an OGC Filter Encoding (1.1 or 2.0) filter
+func ParseFES(filter []byte) (Expr, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(filter))
+	decoder.CharsetReader = charset.NewReaderLabel
+	var root fesNode
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	return parseFESFilter(root)
+}
+
+// DecodeFES decodes the Filter Encoding filter starting with start,
+// as an xml.Unmarshaler of an element holding a filter does
+func DecodeFES(decoder *xml.Decoder, start xml.StartElement) (Expr, error) {
+	var root fesNode
+	if err := decoder.DecodeElement(&root, &start); err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	return parseFESFilter(root)
+}
+
+func parseFESFilter(root fesNode) (Expr, error) {
+	if root.XMLName.Local != "Filter" {
+		return nil, fmt.Errorf("expected a Filter, found %s", root.XMLName.Local)
+	}
+	if len(root.Children) != 1 {
+		return nil, fmt.Errorf("a Filter must hold one predicate")
+	}
+	return parseFESExpr(root.Children[0])
+}
+
+// parseFESExpr parses a logical, comparison or spatial operator.
+// PropertyIsLike is case-insensitive unless matchCase is true, as
+// catalogue clients expect of text searches; other comparisons are
+// case-sensitive unless matchCase is false
+func parseFESExpr(n fesNode) (Expr, error) {
+	op := n.XMLName.Local
+	switch op {
+	case "And", "Or":
+		if len(n.Children) < 2 {
+			return nil, fmt.Errorf("%s requires at least two operands", op)
+		}
+		args := make([]Expr, len(n.Children))
+		for i, c := range n.Children {
+			e, err := parseFESExpr(c)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = e
+		}
+		return Logical{Op: strings.ToLower(op), Args: args}, nil
+	case "Not":
+		if len(n.Children) != 1 {
+			return nil, fmt.Errorf("Not requires one operand")
+		}
+		e, err := parseFESExpr(n.Children[0])
+		if err != nil {
+			return nil, err
+		}
+		return Not{Arg: e}, nil
+	case "PropertyIsLike":
+		name, literal, err := fesOperands(n)
+		if err != nil {
+			return nil, err
+		}
+		pattern := likeFromFES(literal, n.attr("wildCard"), n.attr("singleChar"), n.attr("escapeChar")+n.attr("escape"))
+		var a interface{} = property(name)
+		if !strings.EqualFold(n.attr("matchCase"), "true") {
+			a = casei{a}
+		}
+		return newLike(a, pattern)
+	case "PropertyIsNull", "PropertyIsNil":
+		name, err := fesPropertyName(n)
+		if err != nil {
+			return nil, err
+		}
+		return newIsNull(property(name))
+	case "PropertyIsBetween":
+		name, err := fesPropertyName(n)
+		if err != nil {
+			return nil, err
+		}
+		q, _, err := queryable(property(name))
+		if err != nil {
+			return nil, err
+		}
+		var bounds [2]interface{}
+		for i, boundary := range []string{"LowerBoundary", "UpperBoundary"} {
+			b, ok := n.child(boundary)
+			if !ok {
+				return nil, fmt.Errorf("PropertyIsBetween requires a %s", boundary)
+			}
+			bounds[i] = fesLiteral(q, strings.TrimSpace(fesText(b)))
+		}
+		return newBetween(property(name), bounds[0], bounds[1])
+	}
+
+	if cqlOp, ok := fesComparisonOps[op]; ok {
+		name, literal, err := fesOperands(n)
+		if err != nil {
+			return nil, err
+		}
+		q, _, err := queryable(property(name))
+		if err != nil {
+			return nil, err
+		}
+		if q.Type == TypeString && strings.EqualFold(n.attr("matchCase"), "false") && (cqlOp == "=" || cqlOp == "<>") {
+			e, _, err := caseiComparison(cqlOp, casei{property(name)}, literal)
+			return e, err
+		}
+		return newComparison(cqlOp, property(name), fesLiteral(q, literal))
+	}
+
+	if cqlOp, ok := fesSpatialOps[op]; ok {
+		name := "geometry"
+		var g metadata.Geometry
+		found := false
+		for _, c := range n.Children {
+			switch c.XMLName.Local {
+			case "PropertyName", "ValueReference":
+				name = strings.TrimSpace(c.Content)
+			default:
+				var err error
+				if g, err = parseGML(c); err != nil {
+					return nil, err
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s requires a geometry", op)
+		}
+		return newSpatialPredicate(cqlOp, property(name), g)
+	}
+	return nil, fmt.Errorf("unsupported filter operator %s", op)
+}
+
+// fesPropertyName returns the property an operator applies to
+func fesPropertyName(n fesNode) (string, error) {
+	for _, name := range []string{"PropertyName", "ValueReference"} {
+		if c, ok := n.child(name); ok {
+			return strings.TrimSpace(c.Content), nil
+		}
+	}
+	return "", fmt.Errorf("%s requires a PropertyName", n.XMLName.Local)
+}
+
+// fesOperands returns the property and literal of a binary operator
+func fesOperands(n fesNode) (string, string, error) {
+	name, err := fesPropertyName(n)
+	if err != nil {
+		return "", "", err
+	}
+	literal, ok := n.child("Literal")
+	if !ok {
+		return "", "", fmt.Errorf("%s requires a Literal", n.XMLName.Local)
+	}
+	return name, fesText(literal), nil
+}
+
+// fesText returns the text of an element, or of its Literal child
+func fesText(n fesNode) string {
+	if literal, ok := n.child("Literal"); ok {
+		return literal.Content
+	}
+	return n.Content
+}
+
+// fesLiteral converts the text of a literal to the type of a queryable
+func fesLiteral(q Queryable, text string) interface{} {
+	if q.Type == TypeNumber {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return f
+		}
+	}
+	if q.Type == TypeTimestamp {
+		return strings.TrimSpace(text)
+	}
+	return text
+}
+
+// likeFromFES translates a PropertyIsLike pattern into a LIKE pattern
+func likeFromFES(pattern string, wildCard string, singleChar string, escapeChar string) string {
+	if wildCard == "" {
+		wildCard = "*"
+	}
+	if singleChar == "" {
+		singleChar = "?"
+	}
+	if escapeChar == "" {
+		escapeChar = "\\"
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		rest := pattern[i:]
+		switch {
+		case strings.HasPrefix(rest, escapeChar) && len(rest) > len(escapeChar):
+			i += len(escapeChar)
+			next := pattern[i : i+1]
+			b.WriteString(escapeLike(next))
+			i++
+		case strings.HasPrefix(rest, wildCard):
+			b.WriteByte('%')
+			i += len(wildCard)
+		case strings.HasPrefix(rest, singleChar):
+			b.WriteByte('_')
+			i += len(singleChar)
+		default:
+			b.WriteString(escapeLike(pattern[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// parseGML parses a GML 2 or 3 envelope, box, point, line string or
+// polygon, reprojecting it from its srsName (CRS84 when none is given)
+func parseGML(n fesNode) (metadata.Geometry, error) {
+	crs, err := spatial.ParseCRS(n.attr("srsName"))
+	if err != nil {
+		return metadata.Geometry{}, err
+	}
+
+	var g metadata.Geometry
+	switch n.XMLName.Local {
+	case "Envelope", "Box":
+		var corners []metadata.Position
+		if lower, ok := n.child("lowerCorner"); ok {
+			upper, _ := n.child("upperCorner")
+			corners, err = gmlPositions(lower.Content + " " + upper.Content)
+		} else {
+			corners, err = gmlCoordinates(n)
+		}
+		if err != nil {
+			return g, err
+		}
+		if len(corners) != 2 {
+			return g, fmt.Errorf("%s requires two corners", n.XMLName.Local)
+		}
+		bbox := crs.BBoxToCRS84([4]float64{corners[0][0], corners[0][1], corners[1][0], corners[1][1]})
+		return metadata.NewBBoxPolygon(spatial.NormalizeBBox(bbox)), nil
+	case "Point":
+		positions, err := gmlCoordinates(n)
+		if err != nil {
+			return g, err
+		}
+		if len(positions) != 1 {
+			return g, fmt.Errorf("Point requires one position")
+		}
+		g = metadata.NewPoint(positions[0][0], positions[0][1])
+	case "LineString":
+		positions, err := gmlCoordinates(n)
+		if err != nil {
+			return g, err
+		}
+		g = metadata.Geometry{Type: metadata.GeometryLineString, LineString: positions}
+	case "Polygon":
+		var rings [][]metadata.Position
+		for _, boundary := range n.Children {
+			switch boundary.XMLName.Local {
+			case "exterior", "interior", "outerBoundaryIs", "innerBoundaryIs":
+			default:
+				continue
+			}
+			ring, ok := boundary.child("LinearRing")
+			if !ok {
+				return g, fmt.Errorf("%s requires a LinearRing", boundary.XMLName.Local)
+			}
+			positions, err := gmlCoordinates(ring)
+			if err != nil {
+				return g, err
+			}
+			if len(positions) < 4 {
+				return g, fmt.Errorf("a LinearRing requires at least four positions")
+			}
+			rings = append(rings, positions)
+		}
+		if len(rings) == 0 {
+			return g, fmt.Errorf("Polygon requires an exterior")
+		}
+		g = metadata.NewPolygon(rings...)
+	default:
+		return g, fmt.Errorf("unsupported geometry %s", n.XMLName.Local)
+	}
+	return crs.Transform(g), nil
+}
+
+// gmlCoordinates returns the positions of a GML 3 posList or pos
+// elements, or of GML 2 coordinates or coord elements
+func gmlCoordinates(n fesNode) ([]metadata.Position, error) {
+	if posList, ok := n.child("posList"); ok {
+		return gmlPositions(posList.Content)
+	}
+	if coordinates, ok := n.child("coordinates"); ok {
+		return gmlPositions(strings.Replace(coordinates.Content, ",", " ", -1))
+	}
+	var text []string
+	for _, c := range n.Children {
+		switch c.XMLName.Local {
+		case "pos":
+			text = append(text, c.Content)
+		case "coord":
+			x, _ := c.child("X")
+			y, _ := c.child("Y")
+			text = append(text, x.Content+" "+y.Content)
+		}
+	}
+	if len(text) == 0 {
+		return nil, fmt.Errorf("%s has no coordinates", n.XMLName.Local)
+	}
+	return gmlPositions(strings.Join(text, " "))
+}
+
+// gmlPositions parses whitespace separated coordinates as 2D positions
+func gmlPositions(text string) ([]metadata.Position, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid coordinates %q", text)
+	}
+	positions := make([]metadata.Position, len(fields)/2)
+	for i := range positions {
+		x, errX := strconv.ParseFloat(fields[2*i], 64)
+		y, errY := strconv.ParseFloat(fields[2*i+1], 64)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid coordinates %q", text)
+		}
+		positions[i] = metadata.Position{x, y}
+	}
+	return positions, nil
+}
@@ -0,0 +1,116 @@
+package cql2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFES(t *testing.T) {
+	records := testRecords(t)
+	tests := []struct {
+		filter   string
+		expected []string
+	}{
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc">
+			<ogc:PropertyIsEqualTo><ogc:PropertyName>dc:relation</ogc:PropertyName><ogc:Literal>x</ogc:Literal></ogc:PropertyIsEqualTo>
+		</ogc:Filter>`, nil},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc">
+			<ogc:PropertyIsLike wildCard="%" singleChar="_" escapeChar="\">
+				<ogc:PropertyName>csw:AnyText</ogc:PropertyName><ogc:Literal>%TERRAIN%</ogc:Literal>
+			</ogc:PropertyIsLike>
+		</ogc:Filter>`, []string{"dem"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc">
+			<ogc:PropertyIsLike wildCard="*" singleChar="." escapeChar="!" matchCase="true">
+				<ogc:PropertyName>dc:title</ogc:PropertyName><ogc:Literal>road*</ogc:Literal>
+			</ogc:PropertyIsLike>
+		</ogc:Filter>`, nil},
+		{`<fes:Filter xmlns:fes="http://www.opengis.net/fes/2.0">
+			<fes:PropertyIsEqualTo matchCase="false">
+				<fes:ValueReference>dc:title</fes:ValueReference><fes:Literal>road NETWORK</fes:Literal>
+			</fes:PropertyIsEqualTo>
+		</fes:Filter>`, []string{"roads"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc">
+			<ogc:And>
+				<ogc:PropertyIsGreaterThanOrEqualTo><ogc:PropertyName>apiso:CloudCoverPercentage</ogc:PropertyName><ogc:Literal>10</ogc:Literal></ogc:PropertyIsGreaterThanOrEqualTo>
+				<ogc:Not><ogc:PropertyIsNull><ogc:PropertyName>dc:title</ogc:PropertyName></ogc:PropertyIsNull></ogc:Not>
+			</ogc:And>
+		</ogc:Filter>`, []string{"dem"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc">
+			<ogc:PropertyIsBetween><ogc:PropertyName>dc:date</ogc:PropertyName>
+				<ogc:LowerBoundary><ogc:Literal>2018-01-01</ogc:Literal></ogc:LowerBoundary>
+				<ogc:UpperBoundary><ogc:Literal>2018-12-31T00:00:00Z</ogc:Literal></ogc:UpperBoundary>
+			</ogc:PropertyIsBetween>
+		</ogc:Filter>`, []string{"dem"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc" xmlns:gml="http://www.opengis.net/gml">
+			<ogc:BBOX><ogc:PropertyName>ows:BoundingBox</ogc:PropertyName>
+				<gml:Envelope><gml:lowerCorner>0 40</gml:lowerCorner><gml:upperCorner>20 60</gml:upperCorner></gml:Envelope>
+			</ogc:BBOX>
+		</ogc:Filter>`, []string{"roads"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc" xmlns:gml="http://www.opengis.net/gml">
+			<ogc:BBOX><ogc:PropertyName>ows:BoundingBox</ogc:PropertyName>
+				<gml:Envelope srsName="urn:ogc:def:crs:EPSG::4326"><gml:lowerCorner>40 0</gml:lowerCorner><gml:upperCorner>60 20</gml:upperCorner></gml:Envelope>
+			</ogc:BBOX>
+		</ogc:Filter>`, []string{"roads"}},
+		{`<fes:Filter xmlns:fes="http://www.opengis.net/fes/2.0" xmlns:gml="http://www.opengis.net/gml/3.2">
+			<fes:Within><fes:ValueReference>ows:BoundingBox</fes:ValueReference>
+				<gml:Polygon><gml:exterior><gml:LinearRing><gml:posList>-90 30 -60 30 -60 60 -90 60 -90 30</gml:posList></gml:LinearRing></gml:exterior></gml:Polygon>
+			</fes:Within>
+		</fes:Filter>`, []string{"dem"}},
+		{`<ogc:Filter xmlns:ogc="http://www.opengis.net/ogc" xmlns:gml="http://www.opengis.net/gml">
+			<ogc:Intersects><ogc:PropertyName>ows:BoundingBox</ogc:PropertyName>
+				<gml:Point><gml:coordinates>105,-5</gml:coordinates></gml:Point>
+			</ogc:Intersects>
+		</ogc:Filter>`, []string{"untitled"}},
+	}
+	for _, test := range tests {
+		e, err := ParseFES([]byte(test.filter))
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if ids := matches(e, records); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s matched %v, expected %v", test.filter, ids, test.expected)
+		}
+	}
+
+	for _, filter := range []string{
+		`not xml`,
+		`<Query/>`,
+		`<Filter><PropertyIsEqualTo><PropertyName>colour</PropertyName><Literal>red</Literal></PropertyIsEqualTo></Filter>`,
+		`<Filter><PropertyIsEqualTo><PropertyName>title</PropertyName></PropertyIsEqualTo></Filter>`,
+		`<Filter><And><PropertyIsNull><PropertyName>title</PropertyName></PropertyIsNull></And></Filter>`,
+		`<Filter><BBOX><PropertyName>geometry</PropertyName><Envelope><lowerCorner>0 0</lowerCorner></Envelope></BBOX></Filter>`,
+		`<Filter><PropertyIsSimilarTo/></Filter>`,
+	} {
+		if _, err := ParseFES([]byte(filter)); err == nil {
+			t.Errorf("expected %s to fail", filter)
+		}
+	}
+}
+
+func TestCSWText(t *testing.T) {
+	records := testRecords(t)
+	tests := []struct {
+		filter   string
+		expected []string
+	}{
+		{"csw:AnyText ILIKE '%network%'", []string{"roads"}},
+		{"dc:title LIKE 'Road%'", []string{"roads"}},
+		{"CASEI(dc:title) = CASEI('ROAD NETWORK')", []string{"roads"}},
+		{"dct:modified IS NULL", []string{"dem", "roads", "untitled"}},
+		{"dc:date > '2018-01-01T00:00:00Z'", []string{"dem"}},
+		{"BBOX(ows:BoundingBox, 0, 40, 20, 60)", []string{"roads"}},
+		{"BBOX(ows:BoundingBox, 40, 0, 60, 20, 'urn:ogc:def:crs:EPSG::4326')", []string{"roads"}},
+		{"INTERSECTS(ows:BoundingBox, POINT(-75 45))", []string{"dem"}},
+	}
+	for _, test := range tests {
+		e, err := ParseText(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if ids := matches(e, records); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s matched %v, expected %v", test.filter, ids, test.expected)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// CQL2 JSON encoding
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package cql2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// ParseJSON parses a filter in the CQL2 JSON encoding
+func ParseJSON(filter []byte) (Expr, error) {
+	var node interface{}
+	decoder := json.NewDecoder(bytes.NewReader(filter))
+	decoder.UseNumber()
+	if err := decoder.Decode(&node); err != nil {
+		return nil, fmt.Errorf("invalid CQL2 JSON: %v", err)
+	}
+	return parseJSONExpr(node)
+}
+
+// parseJSONExpr parses a boolean expression: an object with op and args
+func parseJSONExpr(node interface{}) (Expr, error) {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an expression object, found %v", node)
+	}
+	op, ok := object["op"].(string)
+	if !ok {
+		return nil, fmt.Errorf("expression has no op")
+	}
+	op = strings.ToLower(op)
+	rawArgs, ok := object["args"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s has no args", op)
+	}
+
+	switch op {
+	case "and", "or":
+		if len(rawArgs) < 2 {
+			return nil, fmt.Errorf("%s requires at least two args", op)
+		}
+		args := make([]Expr, len(rawArgs))
+		for i, arg := range rawArgs {
+			e, err := parseJSONExpr(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = e
+		}
+		return Logical{Op: op, Args: args}, nil
+	case "not":
+		if len(rawArgs) != 1 {
+			return nil, fmt.Errorf("not requires one arg")
+		}
+		e, err := parseJSONExpr(rawArgs[0])
+		if err != nil {
+			return nil, err
+		}
+		return Not{Arg: e}, nil
+	case "isnull":
+		if len(rawArgs) != 1 {
+			return nil, fmt.Errorf("isNull requires one arg")
+		}
+		a, err := parseJSONOperand(rawArgs[0])
+		if err != nil {
+			return nil, err
+		}
+		return newIsNull(a)
+	case "between":
+		if len(rawArgs) != 3 {
+			return nil, fmt.Errorf("between requires three args")
+		}
+		operands, err := parseJSONOperands(rawArgs)
+		if err != nil {
+			return nil, err
+		}
+		return newBetween(operands[0], operands[1], operands[2])
+	case "in":
+		if len(rawArgs) != 2 {
+			return nil, fmt.Errorf("in requires two args")
+		}
+		a, err := parseJSONOperand(rawArgs[0])
+		if err != nil {
+			return nil, err
+		}
+		rawList, ok := rawArgs[1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in requires a list of values")
+		}
+		list, err := parseJSONOperands(rawList)
+		if err != nil {
+			return nil, err
+		}
+		return newIn(a, list)
+	}
+
+	if len(rawArgs) != 2 {
+		return nil, fmt.Errorf("%s requires two args", op)
+	}
+	operands, err := parseJSONOperands(rawArgs)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := comparisonOps[op]; ok {
+		return newComparison(op, operands[0], operands[1])
+	}
+	if _, ok := spatialOps[op]; ok {
+		return newSpatialPredicate(op, operands[0], operands[1])
+	}
+	switch op {
+	case "like":
+		return newLike(operands[0], operands[1])
+	case TemporalIntersects:
+		return newTemporalPredicate(op, operands[0], operands[1])
+	}
+	return nil, fmt.Errorf("unsupported operator %s", op)
+}
+
+func parseJSONOperands(nodes []interface{}) ([]interface{}, error) {
+	operands := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		operand, err := parseJSONOperand(node)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = operand
+	}
+	return operands, nil
+}
+
+// parseJSONOperand parses a literal, property, temporal literal, bbox
+// or GeoJSON geometry
+func parseJSONOperand(node interface{}) (interface{}, error) {
+	switch v := node.(type) {
+	case string, bool:
+		return v, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", v)
+		}
+		return f, nil
+	case map[string]interface{}:
+	default:
+		return nil, fmt.Errorf("unsupported operand %v", node)
+	}
+
+	object := node.(map[string]interface{})
+	if name, ok := object["property"].(string); ok {
+		return property(name), nil
+	}
+	if s, ok := object["timestamp"].(string); ok {
+		return parseTimestamp(s)
+	}
+	if s, ok := object["date"].(string); ok {
+		return parseDate(s)
+	}
+	if ends, ok := object["interval"].([]interface{}); ok {
+		if len(ends) != 2 {
+			return nil, fmt.Errorf("interval requires two instants")
+		}
+		start, err := parseJSONOperand(ends[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseJSONOperand(ends[1])
+		if err != nil {
+			return nil, err
+		}
+		return newInterval(start, end)
+	}
+	if values, ok := object["bbox"].([]interface{}); ok {
+		bbox := make([]float64, len(values))
+		for i, value := range values {
+			n, ok := value.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("bbox must be an array of numbers")
+			}
+			f, err := n.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s", n)
+			}
+			bbox[i] = f
+		}
+		return bboxGeometry(bbox)
+	}
+	if _, ok := object["type"]; ok {
+		data, _ := json.Marshal(object)
+		var g metadata.Geometry
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	if op, ok := object["op"].(string); ok {
+		args, _ := object["args"].([]interface{})
+		if strings.ToLower(op) != "casei" || len(args) != 1 {
+			return nil, fmt.Errorf("functions other than casei and nested expressions are not supported as operands")
+		}
+		operand, err := parseJSONOperand(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return casei{operand}, nil
+	}
+	return nil, fmt.Errorf("unsupported operand %v", node)
+}
+
+// encodeValue encodes a literal as CQL2 JSON
+func encodeValue(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return map[string]string{"timestamp": t.Format(time.RFC3339Nano)}
+	}
+	return v
+}
+
+// encodeInstant encodes an interval end, where the zero time is open
+func encodeInstant(t time.Time) string {
+	if t.IsZero() {
+		return ".."
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func propertyRef(name string) map[string]string {
+	return map[string]string{"property": name}
+}
+
+func encode(op string, args ...interface{}) ([]byte, error) {
+	return json.Marshal(struct {
+		Op   string        `json:"op"`
+		Args []interface{} `json:"args"`
+	}{op, args})
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Logical) MarshalJSON() ([]byte, error) {
+	args := make([]interface{}, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg
+	}
+	return encode(e.Op, args...)
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Not) MarshalJSON() ([]byte, error) {
+	return encode("not", e.Arg)
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Comparison) MarshalJSON() ([]byte, error) {
+	return encode(e.Op, propertyRef(e.Property), encodeValue(e.Value))
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Like) MarshalJSON() ([]byte, error) {
+	if e.CaseInsensitive {
+		return encode("like",
+			map[string]interface{}{"op": "casei", "args": []interface{}{propertyRef(e.Property)}},
+			map[string]interface{}{"op": "casei", "args": []interface{}{e.Pattern}})
+	}
+	return encode("like", propertyRef(e.Property), e.Pattern)
+}
+
+// MarshalJSON implements json.Marshaler
+func (e In) MarshalJSON() ([]byte, error) {
+	values := make([]interface{}, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = encodeValue(v)
+	}
+	return encode("in", propertyRef(e.Property), values)
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Between) MarshalJSON() ([]byte, error) {
+	return encode("between", propertyRef(e.Property), encodeValue(e.Low), encodeValue(e.High))
+}
+
+// MarshalJSON implements json.Marshaler
+func (e IsNull) MarshalJSON() ([]byte, error) {
+	return encode("isNull", propertyRef(e.Property))
+}
+
+// MarshalJSON implements json.Marshaler
+func (e SpatialPredicate) MarshalJSON() ([]byte, error) {
+	return encode(e.Op, propertyRef(e.Property), e.Geometry)
+}
+
+// MarshalJSON implements json.Marshaler
+func (e TemporalPredicate) MarshalJSON() ([]byte, error) {
+	var subject interface{} = propertyRef(e.Property)
+	if e.EndProperty != "" {
+		subject = map[string]interface{}{"interval": []interface{}{propertyRef(e.Property), propertyRef(e.EndProperty)}}
+	}
+	var object interface{}
+	if e.Start.Equal(e.End) && !e.Start.IsZero() {
+		object = encodeValue(e.Start)
+	} else {
+		object = map[string]interface{}{"interval": []string{encodeInstant(e.Start), encodeInstant(e.End)}}
+	}
+	return encode(e.Op, subject, object)
+}
@@ -0,0 +1,227 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Evaluation of CQL2 expressions against records
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package cql2
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+// Match reports whether a record satisfies an expression.  A predicate
+// on a property with several values (keywords) holds when any value
+// satisfies it, and predicates on null properties do not hold; <> holds
+// when the property has values and none are equal.  String comparisons
+// are case-sensitive unless a LIKE is made case-insensitive
+func Match(e Expr, record metadata.Record) bool {
+	return Matcher(e)(record)
+}
+
+// Matcher prepares an expression for matching against many records,
+// compiling its LIKE patterns once rather than per record
+func Matcher(e Expr) func(record metadata.Record) bool {
+	patterns := make(map[Like]*regexp.Regexp)
+	compileLikes(e, patterns)
+	return func(record metadata.Record) bool {
+		return match(e, record, patterns)
+	}
+}
+
+// compileLikes compiles the pattern of every LIKE in an expression
+func compileLikes(e Expr, patterns map[Like]*regexp.Regexp) {
+	switch e := e.(type) {
+	case Logical:
+		for _, arg := range e.Args {
+			compileLikes(arg, patterns)
+		}
+	case Not:
+		compileLikes(e.Arg, patterns)
+	case Like:
+		if _, ok := patterns[e]; !ok {
+			patterns[e] = likePattern(e.Pattern, e.CaseInsensitive)
+		}
+	}
+}
+
+func match(e Expr, record metadata.Record, patterns map[Like]*regexp.Regexp) bool {
+	switch e := e.(type) {
+	case Logical:
+		for _, arg := range e.Args {
+			if match(arg, record, patterns) != (e.Op == "and") {
+				return e.Op != "and"
+			}
+		}
+		return e.Op == "and"
+	case Not:
+		return !match(e.Arg, record, patterns)
+	case Comparison:
+		vs := values(e.Property, record)
+		if e.Op == "<>" {
+			return len(vs) > 0 && !some(vs, func(v interface{}) bool { return compare(v, e.Value) == 0 })
+		}
+		return some(vs, func(v interface{}) bool {
+			c := compare(v, e.Value)
+			switch e.Op {
+			case "=":
+				return c == 0
+			case "<":
+				return c == -1
+			case "<=":
+				return c == -1 || c == 0
+			case ">":
+				return c == 1
+			case ">=":
+				return c == 1 || c == 0
+			}
+			return false
+		})
+	case Like:
+		re := patterns[e]
+		return some(values(e.Property, record), func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		})
+	case In:
+		return some(values(e.Property, record), func(v interface{}) bool {
+			for _, candidate := range e.Values {
+				if compare(v, candidate) == 0 {
+					return true
+				}
+			}
+			return false
+		})
+	case Between:
+		return some(values(e.Property, record), func(v interface{}) bool {
+			low, high := compare(v, e.Low), compare(v, e.High)
+			return (low == 0 || low == 1) && (high == 0 || high == -1)
+		})
+	case IsNull:
+		return len(values(e.Property, record)) == 0
+	case SpatialPredicate:
+		return some(values(e.Property, record), func(v interface{}) bool {
+			g := v.(metadata.Geometry)
+			switch e.Op {
+			case SpatialIntersects:
+				return spatial.Intersects(g, e.Geometry)
+			case SpatialDisjoint:
+				return spatial.Disjoint(g, e.Geometry)
+			case SpatialWithin:
+				return spatial.Within(g, e.Geometry)
+			case SpatialContains:
+				return spatial.Contains(g, e.Geometry)
+			}
+			return false
+		})
+	case TemporalPredicate:
+		return temporalMatch(e, record)
+	}
+	return false
+}
+
+func values(name string, record metadata.Record) []interface{} {
+	q, ok := Lookup(name)
+	if !ok {
+		return nil
+	}
+	return q.Values(record)
+}
+
+func some(values []interface{}, fn func(v interface{}) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders two values of the same type, returning -1, 0 or 1,
+// or 2 when they cannot be compared
+func compare(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	}
+	return 2
+}
+
+// likePattern translates a LIKE pattern into an anchored regular
+// expression
+func likePattern(pattern string, caseInsensitive bool) *regexp.Regexp {
+	var b strings.Builder
+	if caseInsensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^(?s:")
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.MustCompile(b.String())
+}
+
+// temporalMatch reports whether the instant or interval of a record
+// intersects the interval of a temporal predicate
+func temporalMatch(e TemporalPredicate, record metadata.Record) bool {
+	begin := values(e.Property, record)
+	if e.EndProperty == "" {
+		return some(begin, func(v interface{}) bool {
+			t := v.(time.Time)
+			return (e.Start.IsZero() || !t.Before(e.Start)) && (e.End.IsZero() || !t.After(e.End))
+		})
+	}
+
+	end := values(e.EndProperty, record)
+	if len(begin) == 0 && len(end) == 0 {
+		return false
+	}
+	if len(begin) > 0 && !e.End.IsZero() && begin[0].(time.Time).After(e.End) {
+		return false
+	}
+	if len(end) > 0 && !e.Start.IsZero() && end[0].(time.Time).Before(e.Start) {
+		return false
+	}
+	return true
+}
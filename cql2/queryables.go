@@ -0,0 +1,256 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Queryable record properties
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package cql2
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// Queryable types
+const (
+	TypeString    = "string"
+	TypeNumber    = "number"
+	TypeTimestamp = "timestamp"
+	TypeGeometry  = "geometry"
+)
+
+// Queryable is a record property filters can refer to
+type Queryable struct {
+	Name  string
+	Title string
+	Type  string
+	// Field is the Elasticsearch field holding the property
+	Field string
+	// values returns the values of the property in a record (strings,
+	// float64s, time.Times or geometries), none when it is null
+	values func(record metadata.Record) []interface{}
+}
+
+// Values returns the values of a property in a record: for AnyText,
+// those of the queryables it stands for
+func (q Queryable) Values(record metadata.Record) []interface{} {
+	if q.Name != AnyText {
+		return q.values(record)
+	}
+	var vs []interface{}
+	for _, name := range anyTextParts {
+		part, _ := Lookup(name)
+		vs = append(vs, part.values(record)...)
+	}
+	return vs
+}
+
+// Fields returns the Elasticsearch fields holding a property: those of
+// the queryables AnyText stands for, or Field
+func (q Queryable) Fields() []string {
+	if q.Name != AnyText {
+		return []string{q.Field}
+	}
+	fields := make([]string, len(anyTextParts))
+	for i, name := range anyTextParts {
+		part, _ := Lookup(name)
+		fields[i] = part.Field
+	}
+	return fields
+}
+
+func stringValue(s string) []interface{} {
+	if s == "" {
+		return nil
+	}
+	return []interface{}{s}
+}
+
+func timeValue(t *time.Time) []interface{} {
+	if t == nil {
+		return nil
+	}
+	return []interface{}{*t}
+}
+
+func stringProperty(name string, title string, field string, fn func(p metadata.Properties) string) Queryable {
+	return Queryable{name, title, TypeString, field, func(r metadata.Record) []interface{} {
+		return stringValue(fn(r.Properties))
+	}}
+}
+
+func timestampProperty(name string, title string, field string, fn func(p metadata.Properties) *time.Time) Queryable {
+	return Queryable{name, title, TypeTimestamp, field, func(r metadata.Record) []interface{} {
+		return timeValue(fn(r.Properties))
+	}}
+}
+
+func groProperty(name string, title string, fn func(g *metadata.GROMetadata) string) Queryable {
+	return stringProperty(name, title, "properties.gro_metadata."+name, func(p metadata.Properties) string {
+		if p.GROMetadata == nil {
+			return ""
+		}
+		return fn(p.GROMetadata)
+	})
+}
+
+func temporalExtent(p metadata.Properties) metadata.Temporal {
+	if p.TemporalExtent == nil {
+		return metadata.Temporal{}
+	}
+	return *p.TemporalExtent
+}
+
+var queryables = []Queryable{
+	{"id", "Identifier", TypeString, "id", func(r metadata.Record) []interface{} {
+		return stringValue(r.Identifier)
+	}},
+	{"geometry", "Geometry", TypeGeometry, "geometry", func(r metadata.Record) []interface{} {
+		if !r.Geometry.IsEmpty() {
+			return []interface{}{r.Geometry}
+		}
+		if r.BoundingBox != nil {
+			return []interface{}{metadata.NewBBoxPolygon(*r.BoundingBox)}
+		}
+		return nil
+	}},
+	stringProperty("collection", "Collection", "properties.collection", func(p metadata.Properties) string { return p.Collection }),
+	stringProperty("type", "Type", "properties.type", func(p metadata.Properties) string { return p.Type }),
+	stringProperty("title", "Title", "properties.title.keyword", func(p metadata.Properties) string { return p.Title }),
+	stringProperty("abstract", "Abstract", "properties.abstract.keyword", func(p metadata.Properties) string { return p.Abstract }),
+	stringProperty("description", "Description", "properties.description.keyword", func(p metadata.Properties) string { return p.Description }),
+	stringProperty("owner", "Owner", "properties.owner.keyword", func(p metadata.Properties) string { return p.Owner }),
+	stringProperty("license", "License", "properties.license", func(p metadata.Properties) string { return p.License }),
+	stringProperty("language", "Language", "properties.language", func(p metadata.Properties) string { return p.Language }),
+	stringProperty("crs", "Native CRS", "properties.crs", func(p metadata.Properties) string { return p.CRS }),
+	{"keywords", "Keywords", TypeString, "properties.keywords.Keyword", func(r metadata.Record) []interface{} {
+		var values []interface{}
+		for _, set := range r.Properties.KeywordsSets {
+			for _, keyword := range set.Keyword {
+				values = append(values, keyword)
+			}
+		}
+		return values
+	}},
+	timestampProperty("datetime", "Date and time", "properties.datetime", func(p metadata.Properties) *time.Time { return p.Datetime }),
+	timestampProperty("start_datetime", "Start of the temporal extent", "properties.temporal_extent.begin", func(p metadata.Properties) *time.Time { return temporalExtent(p).Begin }),
+	timestampProperty("end_datetime", "End of the temporal extent", "properties.temporal_extent.end", func(p metadata.Properties) *time.Time { return temporalExtent(p).End }),
+	timestampProperty("created", "Created", "properties.created", func(p metadata.Properties) *time.Time { return p.Created }),
+	timestampProperty("modified", "Modified", "properties.modified", func(p metadata.Properties) *time.Time { return p.Modified }),
+	stringProperty("platform", "Platform", "properties.product_info.platform", func(p metadata.Properties) string {
+		if p.ProductInfo == nil {
+			return ""
+		}
+		return p.ProductInfo.Platform
+	}),
+	{"eo:cloud_cover", "Cloud cover (%)", TypeNumber, "properties.product_info.cloud_cover", func(r metadata.Record) []interface{} {
+		if r.Properties.ProductInfo == nil {
+			return nil
+		}
+		return []interface{}{r.Properties.ProductInfo.CloudCover}
+	}},
+	{AnyText, "Any text", TypeString, "", nil},
+	groProperty("continent", "Continent", func(g *metadata.GROMetadata) string { return g.Continent }),
+	groProperty("country", "Country", func(g *metadata.GROMetadata) string { return g.Country }),
+	groProperty("state_province", "State or province", func(g *metadata.GROMetadata) string { return g.StateProvince }),
+	groProperty("admin2", "County", func(g *metadata.GROMetadata) string { return g.Admin2 }),
+	groProperty("city", "City", func(g *metadata.GROMetadata) string { return g.City }),
+	groProperty("data_format", "Data format", func(g *metadata.GROMetadata) string { return g.DataFormat }),
+	groProperty("implementation_status", "Implementation status", func(g *metadata.GROMetadata) string { return g.ImplementationStatus }),
+	groProperty("geographic_scope", "Geographic scope", func(g *metadata.GROMetadata) string { return g.GeographicScope }),
+}
+
+// AnyText is the queryable matching the text of a record: a predicate
+// on it holds when it holds for any of anyTextParts
+const AnyText = "anytext"
+
+// anyTextParts lists the queryables AnyText stands for
+var anyTextParts = []string{"id", "title", "abstract", "description", "keywords", "owner"}
+
+// aliases maps the names catalogue protocols give queryables (the
+// Dublin Core and ISO queryables of CSW) to them.  Keys are lowercase
+var aliases = map[string]string{
+	"dc:identifier":              "id",
+	"apiso:identifier":           "id",
+	"dc:title":                   "title",
+	"apiso:title":                "title",
+	"dc:type":                    "type",
+	"apiso:type":                 "type",
+	"dc:subject":                 "keywords",
+	"apiso:subject":              "keywords",
+	"dct:abstract":               "abstract",
+	"apiso:abstract":             "abstract",
+	"dc:description":             "description",
+	"dc:creator":                 "owner",
+	"dc:publisher":               "owner",
+	"apiso:organisationname":     "owner",
+	"dc:rights":                  "license",
+	"dc:language":                "language",
+	"apiso:language":             "language",
+	"dc:date":                    "datetime",
+	"dct:modified":               "modified",
+	"apiso:modified":             "modified",
+	"apiso:creationdate":         "created",
+	"apiso:tempextent_begin":     "start_datetime",
+	"apiso:tempextent_end":       "end_datetime",
+	"dc:format":                  "data_format",
+	"dc:relation":                "collection",
+	"apiso:format":               "data_format",
+	"ows:boundingbox":            "geometry",
+	"apiso:boundingbox":          "geometry",
+	"csw:anytext":                AnyText,
+	"apiso:anytext":              AnyText,
+	"apiso:crs":                  "crs",
+	"apiso:parentidentifier":     "collection",
+	"apiso:platform":             "platform",
+	"apiso:cloudcoverpercentage": "eo:cloud_cover",
+}
+
+// Queryables returns the properties filters can refer to
+func Queryables() []Queryable {
+	return append([]Queryable{}, queryables...)
+}
+
+// Lookup finds a queryable by name, optionally prefixed by properties.,
+// or by the name a catalogue protocol gives it (e.g. dc:title)
+func Lookup(name string) (Queryable, bool) {
+	name = strings.TrimPrefix(name, "properties.")
+	if alias, ok := aliases[strings.ToLower(name)]; ok {
+		name = alias
+	}
+	for _, q := range queryables {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return Queryable{}, false
+}
+
+// Schema returns the JSON Schema describing the queryables, as served
+// by the /queryables endpoint of OGC API Features Part 3 and STAC
+func Schema(id string) map[string]interface{} {
+	properties := make(map[string]interface{}, len(queryables))
+	for _, q := range queryables {
+		var schema map[string]interface{}
+		switch q.Type {
+		case TypeTimestamp:
+			schema = map[string]interface{}{"type": "string", "format": "date-time"}
+		case TypeGeometry:
+			schema = map[string]interface{}{"$ref": "https://geojson.org/schema/Geometry.json"}
+		default:
+			schema = map[string]interface{}{"type": q.Type}
+		}
+		schema["title"] = q.Title
+		properties[q.Name] = schema
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2019-09/schema",
+		"$id":                  id,
+		"type":                 "object",
+		"title":                "Queryables",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
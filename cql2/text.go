@@ -0,0 +1,605 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// CQL2 text encoding
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package cql2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	start int
+	end   int
+}
+
+// is reports whether a token is the given keyword or punctuation
+func (t token) is(text string) bool {
+	switch t.kind {
+	case tokenIdent:
+		return strings.EqualFold(t.text, text)
+	case tokenOp, tokenPunct:
+		return t.text == text
+	}
+	return false
+}
+
+// lex splits a CQL2 text filter into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '(' || c == ')' || c == ',':
+			i++
+			tokens = append(tokens, token{tokenPunct, string(c), start, i})
+		case c == '=':
+			i++
+			tokens = append(tokens, token{tokenOp, "=", start, i})
+		case c == '<' || c == '>':
+			i++
+			if i < len(input) && (input[i] == '=' || (c == '<' && input[i] == '>')) {
+				i++
+			}
+			tokens = append(tokens, token{tokenOp, input[start:i], start, i})
+		case c == '\'':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("unterminated string at %d", start)
+				}
+				if input[i] == '\'' {
+					if i+1 < len(input) && input[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, token{tokenString, b.String(), start, i})
+		case c == '"':
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated identifier at %d", start)
+			}
+			i += end + 2
+			tokens = append(tokens, token{tokenQuotedIdent, input[start+1 : i-1], start, i})
+		case unicode.IsDigit(c) || c == '.' || ((c == '-' || c == '+') && i+1 < len(input) && (unicode.IsDigit(rune(input[i+1])) || input[i+1] == '.')):
+			i++
+			for i < len(input) && (unicode.IsDigit(rune(input[i])) || strings.ContainsRune(".eE", rune(input[i])) ||
+				((input[i] == '-' || input[i] == '+') && (input[i-1] == 'e' || input[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, input[start:i], start, i})
+		case unicode.IsLetter(c) || c == '_':
+			for i < len(input) && (unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i])) || strings.ContainsRune("_:.", rune(input[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, input[start:i], start, i})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", c, start)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, start: len(input), end: len(input)}), nil
+}
+
+// textParser is a recursive descent parser of CQL2 text
+type textParser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+// ParseText parses a filter in the CQL2 text encoding
+func ParseText(filter string) (Expr, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &textParser{input: filter, tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return e, nil
+}
+
+func (p *textParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *textParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given keyword or
+// punctuation
+func (p *textParser) accept(text string) bool {
+	if p.peek().is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *textParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %s, found %s", text, p.describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *textParser) describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of filter"
+	}
+	return fmt.Sprintf("%q at %d", p.input[t.start:t.end], t.start)
+}
+
+func (p *textParser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s", p.describe(t))
+}
+
+func (p *textParser) or() (Expr, error) {
+	return p.logical("or", p.and)
+}
+
+func (p *textParser) and() (Expr, error) {
+	return p.logical("and", p.not)
+}
+
+// logical parses operands joined by an operator
+func (p *textParser) logical(op string, operand func() (Expr, error)) (Expr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	args := []Expr{first}
+	for p.accept(op) {
+		e, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return Logical{Op: op, Args: args}, nil
+}
+
+func (p *textParser) not() (Expr, error) {
+	if p.accept("not") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Arg: e}, nil
+	}
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return p.predicate()
+}
+
+// predicate parses a spatial or temporal function, or a scalar
+// followed by a comparison, LIKE, BETWEEN, IN or IS NULL
+func (p *textParser) predicate() (Expr, error) {
+	t := p.peek()
+	if t.kind == tokenIdent && p.tokens[p.pos+1].is("(") {
+		op := strings.ToLower(t.text)
+		if cqlOp, ok := cqlSpatialOps[op]; ok {
+			op = cqlOp
+		}
+		if op == "bbox" && (p.tokens[p.pos+2].kind == tokenIdent || p.tokens[p.pos+2].kind == tokenQuotedIdent) {
+			return p.bboxPredicate()
+		}
+		if _, ok := spatialOps[op]; ok || op == TemporalIntersects {
+			p.pos += 2
+			a, err := p.scalar()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			b, err := p.scalar()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			if op == TemporalIntersects {
+				return newTemporalPredicate(op, a, b)
+			}
+			return newSpatialPredicate(op, a, b)
+		}
+	}
+
+	a, err := p.scalar()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind == tokenOp {
+		p.pos++
+		b, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return newComparison(t.text, a, b)
+	}
+
+	if p.accept("is") {
+		negate := p.accept("not")
+		if err := p.expect("null"); err != nil {
+			return nil, err
+		}
+		return negated(newIsNull(a))(negate)
+	}
+
+	negate := p.accept("not")
+	switch {
+	case p.accept("like"):
+		pattern, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return negated(newLike(a, pattern))(negate)
+	case p.accept("ilike"):
+		pattern, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return negated(newLike(casei{a}, pattern))(negate)
+	case p.accept("between"):
+		low, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		high, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return negated(newBetween(a, low, high))(negate)
+	case p.accept("in"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []interface{}
+		for {
+			v, err := p.scalar()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return negated(newIn(a, list))(negate)
+	}
+	return nil, fmt.Errorf("expected a comparison, LIKE, BETWEEN, IN or IS NULL, found %s", p.describe(p.peek()))
+}
+
+// cqlSpatialOps maps the spatial operators of OGC CQL, as CSW clients
+// use them, to those of CQL2
+var cqlSpatialOps = map[string]string{
+	"intersects": SpatialIntersects,
+	"disjoint":   SpatialDisjoint,
+	"within":     SpatialWithin,
+	"contains":   SpatialContains,
+}
+
+// bboxPredicate parses the OGC CQL BBOX(property, minx, miny, maxx,
+// maxy[, crs]) predicate as s_intersects, reprojecting the bbox from
+// the given CRS
+func (p *textParser) bboxPredicate() (Expr, error) {
+	p.pos += 2
+	a, err := p.scalar()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	values, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("bbox must have 4 numbers")
+	}
+	bbox := [4]float64{values[0], values[1], values[2], values[3]}
+	if p.accept(",") {
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, fmt.Errorf("expected a CRS, found %s", p.describe(t))
+		}
+		crs, err := spatial.ParseCRS(t.text)
+		if err != nil {
+			return nil, err
+		}
+		bbox = crs.BBoxToCRS84(bbox)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	g, _ := bboxGeometry(bbox[:])
+	return newSpatialPredicate(SpatialIntersects, a, g)
+}
+
+// negated wraps the result of a predicate constructor in Not when
+// negate is set
+func negated(e Expr, err error) func(negate bool) (Expr, error) {
+	return func(negate bool) (Expr, error) {
+		if err != nil || !negate {
+			return e, err
+		}
+		return Not{Arg: e}, nil
+	}
+}
+
+// wktTypes lists the geometry keywords of WKT literals
+var wktTypes = map[string]bool{
+	"POINT": true, "LINESTRING": true, "POLYGON": true, "MULTIPOINT": true,
+	"MULTILINESTRING": true, "MULTIPOLYGON": true, "GEOMETRYCOLLECTION": true,
+}
+
+// scalar parses a literal or property
+func (p *textParser) scalar() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return f, nil
+	case tokenQuotedIdent:
+		return property(t.text), nil
+	case tokenIdent:
+	default:
+		return nil, p.unexpected(t)
+	}
+
+	keyword := strings.ToUpper(t.text)
+	switch keyword {
+	case "TRUE", "FALSE":
+		return keyword == "TRUE", nil
+	case "TIMESTAMP", "DATE":
+		s, err := p.stringArgument()
+		if err != nil {
+			return nil, err
+		}
+		if keyword == "DATE" {
+			return parseDate(s)
+		}
+		return parseTimestamp(s)
+	case "INTERVAL":
+		return p.interval()
+	case "BBOX":
+		return p.bbox()
+	case "CASEI":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		v, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return casei{v}, p.expect(")")
+	}
+	if wktTypes[keyword] {
+		return p.wkt(t)
+	}
+	switch keyword {
+	case "AND", "OR", "NOT", "LIKE", "ILIKE", "BETWEEN", "IN", "IS", "NULL":
+		return nil, p.unexpected(t)
+	}
+	return property(t.text), nil
+}
+
+// stringArgument parses a parenthesized string
+func (p *textParser) stringArgument() (string, error) {
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	t := p.next()
+	if t.kind != tokenString {
+		return "", fmt.Errorf("expected a string, found %s", p.describe(t))
+	}
+	return t.text, p.expect(")")
+}
+
+// interval parses the arguments of INTERVAL: instants given as strings
+// (.. for an open end), TIMESTAMP or DATE literals, or properties
+func (p *textParser) interval() (interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var ends [2]interface{}
+	for i := range ends {
+		if i == 1 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		v, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		ends[i] = v
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return newInterval(ends[0], ends[1])
+}
+
+// newInterval creates an interval between two instants or properties
+func newInterval(start interface{}, end interface{}) (interface{}, error) {
+	if s, ok := start.(property); ok {
+		e, ok := end.(property)
+		if !ok {
+			return nil, fmt.Errorf("an interval must be between two properties or two instants")
+		}
+		return propertyInterval{string(s), string(e)}, nil
+	}
+
+	var iv interval
+	for i, end := range []interface{}{start, end} {
+		switch v := end.(type) {
+		case string:
+			t, err := parseInstant(v, i == 1)
+			if err != nil {
+				return nil, err
+			}
+			iv[i] = t
+		case date:
+			iv[i] = time.Time(v)
+			if i == 1 {
+				iv[i] = iv[i].AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case time.Time:
+			iv[i] = v
+		default:
+			return nil, fmt.Errorf("an interval must be between two properties or two instants")
+		}
+	}
+	if !iv[0].IsZero() && !iv[1].IsZero() && iv[0].After(iv[1]) {
+		return nil, fmt.Errorf("interval start is after its end")
+	}
+	return iv, nil
+}
+
+// bbox parses the arguments of BBOX: minx, miny, maxx, maxy, where
+// minx greater than maxx crosses the antimeridian
+func (p *textParser) bbox() (interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	values, err := p.numbers()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return bboxGeometry(values)
+}
+
+// numbers parses a comma separated list of numbers, leaving a comma
+// followed by anything else
+func (p *textParser) numbers() ([]float64, error) {
+	var values []float64
+	for {
+		t := p.next()
+		f, err := strconv.ParseFloat(t.text, 64)
+		if t.kind != tokenNumber || err != nil {
+			return nil, fmt.Errorf("expected a number, found %s", p.describe(t))
+		}
+		values = append(values, f)
+		if !p.peek().is(",") || p.tokens[p.pos+1].kind != tokenNumber {
+			return values, nil
+		}
+		p.pos++
+	}
+}
+
+// bboxGeometry creates the geometry of a 2D or 3D bbox
+func bboxGeometry(values []float64) (interface{}, error) {
+	switch len(values) {
+	case 4:
+		return metadata.NewBBoxPolygon(spatial.NormalizeBBox([4]float64{values[0], values[1], values[2], values[3]})), nil
+	case 6:
+		return metadata.NewBBoxPolygon(spatial.NormalizeBBox([4]float64{values[0], values[1], values[3], values[4]})), nil
+	}
+	return nil, fmt.Errorf("bbox must have 4 or 6 numbers")
+}
+
+// wkt parses a WKT geometry starting with the type keyword t
+func (p *textParser) wkt(t token) (interface{}, error) {
+	end := t.end
+	for p.peek().is("Z") || p.peek().is("M") || p.peek().is("ZM") {
+		end = p.next().end
+	}
+	if p.peek().is("EMPTY") {
+		end = p.next().end
+	} else {
+		depth := 0
+		for {
+			next := p.next()
+			switch {
+			case next.kind == tokenEOF:
+				return nil, fmt.Errorf("unterminated %s", t.text)
+			case next.is("("):
+				depth++
+			case next.is(")"):
+				depth--
+			}
+			end = next.end
+			if depth <= 0 {
+				break
+			}
+		}
+	}
+	g, err := spatial.ParseWKT(p.input[t.start:end])
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -28,6 +28,8 @@
 package geocatalogo
 
 import (
+	"context"
+	"errors"
 	"os"
 	"time"
 
@@ -37,6 +39,7 @@ import (
 	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/repository"
 	"github.com/go-spatial/geocatalogo/search"
+	"github.com/go-spatial/geocatalogo/spatial"
 )
 
 // VERSION provides the geocatalogo version installed.
@@ -48,6 +51,7 @@ var log = logrus.New()
 type GeoCatalogue struct {
 	Config     config.Config
 	Repository repository.Repository
+	tokenKey   []byte
 }
 
 // New provides the initializing functionality
@@ -55,6 +59,7 @@ func New(cfg *config.Config) (*GeoCatalogue, error) {
 
 	c := GeoCatalogue{}
 	c.Config = *cfg
+	c.tokenKey = newTokenKey(cfg.Server.TokenSecret)
 
 	// setup logging
 	InitLog(&c.Config, log)
@@ -64,24 +69,10 @@ func New(cfg *config.Config) (*GeoCatalogue, error) {
 
 	log.Info("Loading repository")
 
-	// Select backend based on configuration
-	var repo repository.Repository
-
-	if cfg.Repository.Type == "memory" {
-		memRepo, memErr := repository.OpenMemory(c.Config, log)
-		if memErr != nil {
-			return &c, memErr
-		}
-		repo = memRepo
-	} else {
-		// Default to Elasticsearch
-		esRepo, esErr := repository.Open(c.Config, log)
-		if esErr != nil {
-			return &c, esErr
-		}
-		repo = &esRepo
+	repo, err := repository.OpenBackend(c.Config, log)
+	if err != nil {
+		return &c, err
 	}
-
 	c.Repository = repo
 
 	return &c, nil
@@ -94,42 +85,212 @@ func NewFromEnv() (*GeoCatalogue, error) {
 	return New(&cfg)
 }
 
+// withTimeout bounds a repository operation by the configured timeout
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // Index adds a metadata record to the Index
-func (c *GeoCatalogue) Index(record metadata.Record) bool {
+func (c *GeoCatalogue) Index(ctx context.Context, record metadata.Record) bool {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
 	log.Info("Indexing " + record.Identifier)
-	err := c.Repository.Insert(record)
+	spatial.NormalizeRecord(&record)
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, []string{record.Identifier})
+	err := c.Repository.Insert(ctx, record)
 	if err != nil {
 		log.Errorf("Indexing failed: %v", err)
 		return false
 	}
+	c.syncCollections(ctx, stored, previous, map[string]*metadata.Record{record.Identifier: &record})
+	return true
+}
+
+// BulkIndex adds a set of metadata records to the Index and returns
+// the records which could not be indexed
+func (c *GeoCatalogue) BulkIndex(ctx context.Context, records []metadata.Record) []repository.BulkFailure {
+	var bulkErr *repository.BulkError
+
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Infof("Bulk indexing %d records", len(records))
+	identifiers := make([]string, len(records))
+	for i := range records {
+		spatial.NormalizeRecord(&records[i])
+		identifiers[i] = records[i].Identifier
+	}
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, identifiers)
+	err := c.Repository.BulkInsert(ctx, records)
+	if err == nil {
+		c.syncCollections(ctx, stored, previous, indexedRecords(records, nil))
+		return nil
+	}
+	if errors.As(err, &bulkErr) {
+		failed := make(map[string]bool, len(bulkErr.Failures))
+		for _, failure := range bulkErr.Failures {
+			log.Errorf("Indexing %s failed: %s", failure.Identifier, failure.Reason)
+			failed[failure.Identifier] = true
+		}
+		c.syncCollections(ctx, stored, previous, indexedRecords(records, failed))
+		return bulkErr.Failures
+	}
+
+	log.Errorf("Bulk indexing failed: %v", err)
+	failures := make([]repository.BulkFailure, len(records))
+	for i, record := range records {
+		failures[i] = repository.BulkFailure{Identifier: record.Identifier, Reason: err.Error()}
+	}
+	return failures
+}
+
+// ReIndex replaces an existing metadata record in the Index
+func (c *GeoCatalogue) ReIndex(ctx context.Context, record metadata.Record) bool {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Info("Re-indexing " + record.Identifier)
+	spatial.NormalizeRecord(&record)
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, []string{record.Identifier})
+	err := c.Repository.Update(ctx, record)
+	if err != nil {
+		log.Errorf("Re-indexing failed: %v", err)
+		return false
+	}
+	c.syncCollections(ctx, stored, previous, map[string]*metadata.Record{record.Identifier: &record})
+	return true
+}
+
+// UnIndex removes a metadata record from the Index
+func (c *GeoCatalogue) UnIndex(ctx context.Context, identifier string) bool {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Info("Un-indexing " + identifier)
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, []string{identifier})
+	err := c.Repository.Delete(ctx, identifier)
+	if err != nil {
+		log.Errorf("Un-indexing failed: %v", err)
+		return false
+	}
+	c.syncCollections(ctx, stored, previous, map[string]*metadata.Record{identifier: nil})
 	return true
 }
 
-// UnIndex removes a metadata record to the Index
-func (c *GeoCatalogue) UnIndex() bool {
-	return c.Repository.Delete()
+// BulkReIndex replaces a set of existing metadata records and returns
+// the records which could not be re-indexed.  Collection extents are
+// brought up to date once for the whole set
+func (c *GeoCatalogue) BulkReIndex(ctx context.Context, records []metadata.Record) []repository.BulkFailure {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Infof("Bulk re-indexing %d records", len(records))
+	identifiers := make([]string, len(records))
+	for i := range records {
+		spatial.NormalizeRecord(&records[i])
+		identifiers[i] = records[i].Identifier
+	}
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, identifiers)
+	var failures []repository.BulkFailure
+	failed := make(map[string]bool)
+	for _, record := range records {
+		if err := c.Repository.Update(ctx, record); err != nil {
+			log.Errorf("Re-indexing %s failed: %v", record.Identifier, err)
+			failures = append(failures, repository.BulkFailure{Identifier: record.Identifier, Reason: err.Error()})
+			failed[record.Identifier] = true
+		}
+	}
+	c.syncCollections(ctx, stored, previous, indexedRecords(records, failed))
+	return failures
 }
 
-// Search performs a search/query against the Index
-func (c *GeoCatalogue) Search(collections []string, term string, bbox []float64, timeVal []time.Time, from int, size int, propertyFilters map[string]string) search.Results {
+// BulkUnIndex removes a set of metadata records and returns the records
+// which could not be un-indexed.  Collection extents are brought up to
+// date once for the whole set
+func (c *GeoCatalogue) BulkUnIndex(ctx context.Context, identifiers []string) []repository.BulkFailure {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.WriteTimeout)
+	defer cancel()
+
+	log.Infof("Bulk un-indexing %d records", len(identifiers))
+	stored := c.storedCollections(ctx)
+	previous := c.previousRecords(ctx, stored, identifiers)
+	var failures []repository.BulkFailure
+	removed := make(map[string]*metadata.Record, len(identifiers))
+	for _, identifier := range identifiers {
+		if err := c.Repository.Delete(ctx, identifier); err != nil {
+			log.Errorf("Un-indexing %s failed: %v", identifier, err)
+			failures = append(failures, repository.BulkFailure{Identifier: identifier, Reason: err.Error()})
+			continue
+		}
+		removed[identifier] = nil
+	}
+	c.syncCollections(ctx, stored, previous, removed)
+	return failures
+}
+
+// Search performs a search/query against the Index.  Errors, including
+// context.DeadlineExceeded when the query timeout passes, are returned
+// along with any partial results
+func (c *GeoCatalogue) Search(ctx context.Context, req search.Request) (search.Results, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.QueryTimeout)
+	defer cancel()
+
 	sr := search.Results{}
 	log.Info("Searching index")
-	err := c.Repository.Query(collections, term, bbox, timeVal, from, size, propertyFilters, &sr)
+	err := c.Repository.Query(ctx, req, &sr)
 	if err != nil {
 		log.Warn(err)
-		return sr
+		return sr, err
+	}
+	if len(req.Facets) > 0 {
+		fr := search.Results{}
+		if err := c.Repository.Facets(ctx, req, req.Facets, &fr); err != nil {
+			log.Warn(err)
+			return sr, err
+		}
+		sr.Facets = fr.Facets
 	}
-	return sr
+	if len(sr.After) > 0 {
+		sr.NextToken = c.encodeToken(req, &sr)
+	}
+	sr.Project(req.Fields)
+	return sr, nil
+}
+
+// Facets counts the values of the given fields across all records
+// matching a search
+func (c *GeoCatalogue) Facets(ctx context.Context, req search.Request, fields []string) (search.Results, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.QueryTimeout)
+	defer cancel()
+
+	sr := search.Results{}
+	log.Info("Counting facets")
+	err := c.Repository.Facets(ctx, req, fields, &sr)
+	if err != nil {
+		log.Warn(err)
+	}
+	return sr, err
 }
 
 // Get retrieves a single metadata record from the Index
-func (c *GeoCatalogue) Get(identifiers []string) search.Results {
+func (c *GeoCatalogue) Get(ctx context.Context, identifiers []string) (search.Results, error) {
+	ctx, cancel := withTimeout(ctx, c.Config.Repository.GetTimeout)
+	defer cancel()
+
 	sr := search.Results{}
 	log.Info("Searching index")
-	err := c.Repository.Get(identifiers, &sr)
+	err := c.Repository.Get(ctx, identifiers, &sr)
 	if err != nil {
 		log.Warn(err)
-		return sr
 	}
-	return sr
+	return sr, err
 }
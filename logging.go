@@ -65,3 +65,8 @@ func InitLog(cfg *config.Config, log *logrus.Logger) error {
 
 	return nil
 }
+
+// Logger returns the logger of the catalogue
+func (c *GeoCatalogue) Logger() *logrus.Logger {
+	return log
+}
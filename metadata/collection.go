@@ -0,0 +1,193 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Collections of metadata records
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package metadata
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Provider describes an organisation capturing, processing or hosting
+// the records of a collection
+type Provider struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Roles are any of licensor, producer, processor and host
+	Roles []string `json:"roles,omitempty"`
+	URL   string   `json:"url,omitempty"`
+}
+
+// Extent describes the spatial and temporal extent of the records of a
+// collection.  BBox is nil until a record with a geometry or bounding
+// box is added, and Temporal until a record with a datetime or temporal
+// extent is; within Temporal, a nil Begin or End is open
+type Extent struct {
+	BBox     *[4]float64 `json:"bbox,omitempty"`
+	Temporal *Temporal   `json:"temporal,omitempty"`
+}
+
+// Add extends an extent to cover a record
+func (e *Extent) Add(record Record) {
+	if bbox, ok := record.Bounds(); ok {
+		if e.BBox != nil {
+			bbox = UnionBBox(*e.BBox, bbox)
+		}
+		e.BBox = &bbox
+	}
+
+	begin, end, ok := interval(record)
+	if !ok {
+		return
+	}
+	if e.Temporal == nil {
+		e.Temporal = &Temporal{Begin: begin, End: end}
+		return
+	}
+	if begin == nil || (e.Temporal.Begin != nil && begin.Before(*e.Temporal.Begin)) {
+		e.Temporal.Begin = begin
+	}
+	if end == nil || (e.Temporal.End != nil && end.After(*e.Temporal.End)) {
+		e.Temporal.End = end
+	}
+}
+
+// Covers reports whether an extent already covers a record, so that
+// adding it would not change the extent
+func (e Extent) Covers(record Record) bool {
+	extended := e.clone()
+	extended.Add(record)
+	return extended.equal(e)
+}
+
+// Reaches reports whether a record reaches an edge of an extent, so
+// that the extent may shrink without it.  An extent around the world
+// is reached by any record with a bounding box, as records joined over
+// the antimeridian may make it so
+func (e Extent) Reaches(record Record) bool {
+	if bbox, ok := record.Bounds(); ok && e.BBox != nil {
+		if e.BBox[0] == -180 && e.BBox[2] == 180 {
+			return true
+		}
+		for i := range bbox {
+			if bbox[i] == e.BBox[i] {
+				return true
+			}
+		}
+	}
+	if begin, end, ok := interval(record); ok && e.Temporal != nil {
+		return equalTimes(begin, e.Temporal.Begin) || equalTimes(end, e.Temporal.End)
+	}
+	return false
+}
+
+// interval returns the datetime or temporal extent of a record,
+// reporting false when it has neither
+func interval(record Record) (*time.Time, *time.Time, bool) {
+	if t := record.Properties.Datetime; t != nil {
+		return t, t, true
+	}
+	t := record.Properties.TemporalExtent
+	if t == nil || (t.Begin == nil && t.End == nil) {
+		return nil, nil, false
+	}
+	return t.Begin, t.End, true
+}
+
+func (e Extent) clone() Extent {
+	c := Extent{}
+	if e.BBox != nil {
+		bbox := *e.BBox
+		c.BBox = &bbox
+	}
+	if e.Temporal != nil {
+		temporal := *e.Temporal
+		c.Temporal = &temporal
+	}
+	return c
+}
+
+func (e Extent) equal(o Extent) bool {
+	if (e.BBox == nil) != (o.BBox == nil) || (e.BBox != nil && *e.BBox != *o.BBox) {
+		return false
+	}
+	if e.Temporal == nil || o.Temporal == nil {
+		return e.Temporal == o.Temporal
+	}
+	return equalTimes(e.Temporal.Begin, o.Temporal.Begin) && equalTimes(e.Temporal.End, o.Temporal.End)
+}
+
+// UnionBBox returns the smallest bounding box covering two bounding
+// boxes, either of which may cross the antimeridian.  The union crosses
+// the antimeridian when that makes it narrower
+func UnionBBox(a [4]float64, b [4]float64) [4]float64 {
+	var spans [][2]float64
+	for _, bbox := range [][4]float64{a, b} {
+		if bbox[0] > bbox[2] {
+			spans = append(spans, [2]float64{bbox[0], 180}, [2]float64{-180, bbox[2]})
+		} else {
+			spans = append(spans, [2]float64{bbox[0], bbox[2]})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var merged [][2]float64
+	for _, span := range spans {
+		if n := len(merged); n > 0 && span[0] <= merged[n-1][1] {
+			merged[n-1][1] = math.Max(merged[n-1][1], span[1])
+		} else {
+			merged = append(merged, span)
+		}
+	}
+
+	// leave out the widest range of longitudes not covered, which is
+	// around the antimeridian unless the union crosses it
+	last := len(merged) - 1
+	west, east := merged[0][0], merged[last][1]
+	gap := merged[0][0] + 360 - merged[last][1]
+	for i := 0; i < last; i++ {
+		if g := merged[i+1][0] - merged[i][1]; g > gap {
+			west, east, gap = merged[i+1][0], merged[i][1], g
+		}
+	}
+	return [4]float64{west, math.Min(a[1], b[1]), east, math.Max(a[3], b[3])}
+}
+
+// Collection describes a collection of metadata records: those whose
+// collection property is its identifier.  The extent is computed from
+// the records, the other fields are descriptive
+type Collection struct {
+	Identifier  string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Icon is a short symbol, such as an emoji, shown with the title
+	Icon      string                 `json:"icon,omitempty"`
+	License   string                 `json:"license,omitempty"`
+	Providers []Provider             `json:"providers,omitempty"`
+	Keywords  []string               `json:"keywords,omitempty"`
+	Summaries map[string]interface{} `json:"summaries,omitempty"`
+	Extent    Extent                 `json:"extent"`
+	Links     []Link                 `json:"links,omitempty"`
+}
+
+// DisplayTitle returns the title of a collection, or its identifier
+// when it has none
+func (c Collection) DisplayTitle() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return c.Identifier
+}
+
+// equalTimes reports whether two optional times are both unset or the
+// same instant
+func equalTimes(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
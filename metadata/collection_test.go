@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnionBBox(t *testing.T) {
+	tests := []struct {
+		a, b, union [4]float64
+	}{
+		{[4]float64{0, 0, 10, 10}, [4]float64{5, -5, 20, 5}, [4]float64{0, -5, 20, 10}},
+		// the union crosses the antimeridian rather than spanning the globe
+		{[4]float64{170, -10, 175, 0}, [4]float64{-175, 0, -170, 10}, [4]float64{170, -10, -170, 10}},
+		{[4]float64{170, -10, -170, 10}, [4]float64{-160, 0, -150, 5}, [4]float64{170, -10, -150, 10}},
+		{[4]float64{-180, -90, 180, 90}, [4]float64{170, -10, -170, 10}, [4]float64{-180, -90, 180, 90}},
+	}
+	for _, test := range tests {
+		if union := UnionBBox(test.a, test.b); union != test.union {
+			t.Errorf("union of %v and %v: %v, expected %v", test.a, test.b, union, test.union)
+		}
+	}
+}
+
+func TestExtentAdd(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	var e Extent
+	var r Record
+	e.Add(r)
+	if e.BBox != nil || e.Temporal != nil {
+		t.Fatalf("a record without geometry or time gave extent %+v", e)
+	}
+
+	r.SetGeometry(NewPoint(10, 20))
+	r.Properties.Datetime = day(5)
+	e.Add(r)
+	if e.BBox == nil || *e.BBox != [4]float64{10, 20, 10, 20} {
+		t.Errorf("bbox %v", e.BBox)
+	}
+	if e.Temporal == nil || !e.Temporal.Begin.Equal(*day(5)) || !e.Temporal.End.Equal(*day(5)) {
+		t.Fatalf("temporal %+v", e.Temporal)
+	}
+
+	var s Record
+	s.BoundingBox = &[4]float64{0, 0, 1, 1}
+	s.Properties.TemporalExtent = &Temporal{Begin: day(2), End: day(8)}
+	if e.Covers(s) {
+		t.Error("extent should not cover a record outside it")
+	}
+	e.Add(s)
+	if *e.BBox != [4]float64{0, 0, 10, 20} || !e.Temporal.Begin.Equal(*day(2)) || !e.Temporal.End.Equal(*day(8)) {
+		t.Errorf("extent %v %+v", *e.BBox, e.Temporal)
+	}
+	if !e.Covers(r) || !e.Covers(s) {
+		t.Error("extent should cover the records added")
+	}
+
+	// an ongoing record leaves the end open
+	var o Record
+	o.Properties.TemporalExtent = &Temporal{Begin: day(3)}
+	e.Add(o)
+	if e.Temporal.End != nil || !e.Temporal.Begin.Equal(*day(2)) {
+		t.Errorf("temporal %+v, expected an open end", e.Temporal)
+	}
+	e.Add(r)
+	if e.Temporal.End != nil {
+		t.Error("an open end should stay open")
+	}
+}
+
+func TestExtentReaches(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	record := func(bbox [4]float64, begin *time.Time, end *time.Time) Record {
+		r := Record{BoundingBox: &bbox}
+		r.Properties.TemporalExtent = &Temporal{Begin: begin, End: end}
+		return r
+	}
+
+	var e Extent
+	edge := record([4]float64{0, 0, 10, 5}, day(1), day(3))
+	inside := record([4]float64{2, 1, 8, 4}, day(2), day(3))
+	e.Add(edge)
+	e.Add(inside)
+	e.Add(record([4]float64{1, -5, 9, 1}, day(2), day(9)))
+	if !e.Reaches(edge) {
+		t.Error("a record on the edge should reach it")
+	}
+	if e.Reaches(inside) {
+		t.Error("a record inside should not reach the edge")
+	}
+	if e.Reaches(Record{}) {
+		t.Error("a record without geometry or time should not reach the edge")
+	}
+	if !e.Reaches(record([4]float64{2, 1, 8, 4}, day(2), day(9))) {
+		t.Error("a record ending last should reach the edge")
+	}
+
+	world := Extent{BBox: &[4]float64{-180, -90, 180, 90}}
+	if !world.Reaches(inside) {
+		t.Error("a record should reach an extent around the world")
+	}
+}
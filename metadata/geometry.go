@@ -0,0 +1,348 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// GeoJSON geometries
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GeoJSON geometry types
+const (
+	GeometryPoint              = "Point"
+	GeometryMultiPoint         = "MultiPoint"
+	GeometryLineString         = "LineString"
+	GeometryMultiLineString    = "MultiLineString"
+	GeometryPolygon            = "Polygon"
+	GeometryMultiPolygon       = "MultiPolygon"
+	GeometryGeometryCollection = "GeometryCollection"
+)
+
+// Position is a longitude, latitude and optional elevation
+type Position []float64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Position) UnmarshalJSON(data []byte) error {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if len(values) < 2 {
+		return fmt.Errorf("position %s has fewer than two values", data)
+	}
+	*p = values
+	return nil
+}
+
+// Geometry describes a GeoJSON geometry.  The coordinates are held in
+// the field named after Type (Geometries for a GeometryCollection);
+// the zero value, with an empty Type, is the null geometry
+type Geometry struct {
+	Type            string
+	Point           Position
+	MultiPoint      []Position
+	LineString      []Position
+	MultiLineString [][]Position
+	Polygon         [][]Position
+	MultiPolygon    [][][]Position
+	Geometries      []Geometry
+}
+
+// NewPoint creates a Point geometry
+func NewPoint(x float64, y float64) Geometry {
+	return Geometry{Type: GeometryPoint, Point: Position{x, y}}
+}
+
+// NewPolygon creates a Polygon geometry from an exterior ring and any
+// interior rings
+func NewPolygon(rings ...[]Position) Geometry {
+	return Geometry{Type: GeometryPolygon, Polygon: rings}
+}
+
+// NewBBoxPolygon creates a rectangular Polygon from a minx, miny, maxx,
+// maxy bounding box.  A bounding box crossing the antimeridian (minx
+// greater than maxx) becomes a MultiPolygon of its eastern and western
+// parts
+func NewBBoxPolygon(bbox [4]float64) Geometry {
+	if bbox[0] > bbox[2] {
+		east := NewBBoxPolygon([4]float64{bbox[0], bbox[1], 180, bbox[3]})
+		west := NewBBoxPolygon([4]float64{-180, bbox[1], bbox[2], bbox[3]})
+		return Geometry{Type: GeometryMultiPolygon, MultiPolygon: [][][]Position{east.Polygon, west.Polygon}}
+	}
+	return NewPolygon([]Position{
+		{bbox[0], bbox[1]},
+		{bbox[0], bbox[3]},
+		{bbox[2], bbox[3]},
+		{bbox[2], bbox[1]},
+		{bbox[0], bbox[1]},
+	})
+}
+
+// IsEmpty reports whether a geometry has no positions
+func (g Geometry) IsEmpty() bool {
+	empty := true
+	g.positions(func(Position) bool {
+		empty = false
+		return false
+	})
+	return empty
+}
+
+// Bounds returns the minx, miny, maxx, maxy bounding box of a geometry,
+// reporting false for an empty geometry.  Following GeoJSON and STAC, a
+// geometry split at the antimeridian has a bounding box with minx
+// greater than maxx, spanning the antimeridian rather than the globe
+func (g Geometry) Bounds() ([4]float64, bool) {
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	found := false
+	g.positions(func(p Position) bool {
+		bbox[0] = math.Min(bbox[0], p[0])
+		bbox[1] = math.Min(bbox[1], p[1])
+		bbox[2] = math.Max(bbox[2], p[0])
+		bbox[3] = math.Max(bbox[3], p[1])
+		found = true
+		return true
+	})
+	if !found {
+		return [4]float64{}, false
+	}
+	if bbox[0] <= -180 && bbox[2] >= 180 {
+		if west, east, ok := g.antimeridianGap(); ok {
+			bbox[0], bbox[2] = east, west
+		}
+	}
+	return bbox, true
+}
+
+// antimeridianGap finds the widest range of longitudes not covered by
+// any part of a geometry touching both sides of the antimeridian.  It
+// returns the longitudes bordering the gap, reporting false when the
+// parts leave no gap
+func (g Geometry) antimeridianGap() (float64, float64, bool) {
+	var spans [][2]float64
+	g.parts(func(part Geometry) {
+		span := [2]float64{math.Inf(1), math.Inf(-1)}
+		part.positions(func(p Position) bool {
+			span[0] = math.Min(span[0], p[0])
+			span[1] = math.Max(span[1], p[0])
+			return true
+		})
+		if span[0] <= span[1] {
+			spans = append(spans, span)
+		}
+	})
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	west, east, gap := 0.0, 0.0, 0.0
+	covered := spans[0][1]
+	for _, span := range spans[1:] {
+		if span[0]-covered > gap {
+			west, east, gap = covered, span[0], span[0]-covered
+		}
+		covered = math.Max(covered, span[1])
+	}
+	return west, east, gap > 0
+}
+
+// parts calls fn with each point, line and polygon of a geometry
+func (g Geometry) parts(fn func(Geometry)) {
+	switch g.Type {
+	case GeometryMultiPoint:
+		for _, p := range g.MultiPoint {
+			fn(Geometry{Type: GeometryPoint, Point: p})
+		}
+	case GeometryMultiLineString:
+		for _, line := range g.MultiLineString {
+			fn(Geometry{Type: GeometryLineString, LineString: line})
+		}
+	case GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			fn(Geometry{Type: GeometryPolygon, Polygon: polygon})
+		}
+	case GeometryGeometryCollection:
+		for _, member := range g.Geometries {
+			member.parts(fn)
+		}
+	default:
+		fn(g)
+	}
+}
+
+// positions calls fn with every position of a geometry until fn
+// returns false, reporting whether all positions were visited
+func (g Geometry) positions(fn func(Position) bool) bool {
+	each := func(positions []Position) bool {
+		for _, p := range positions {
+			if len(p) >= 2 && !fn(p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch g.Type {
+	case GeometryPoint:
+		if g.Point != nil {
+			return each([]Position{g.Point})
+		}
+	case GeometryMultiPoint:
+		return each(g.MultiPoint)
+	case GeometryLineString:
+		return each(g.LineString)
+	case GeometryMultiLineString:
+		for _, line := range g.MultiLineString {
+			if !each(line) {
+				return false
+			}
+		}
+	case GeometryPolygon:
+		for _, ring := range g.Polygon {
+			if !each(ring) {
+				return false
+			}
+		}
+	case GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			for _, ring := range polygon {
+				if !each(ring) {
+					return false
+				}
+			}
+		}
+	case GeometryGeometryCollection:
+		for _, member := range g.Geometries {
+			if !member.positions(fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// coordinates returns the coordinates of a geometry, as an empty array
+// rather than null when unset
+func (g Geometry) coordinates() (interface{}, error) {
+	switch g.Type {
+	case GeometryPoint:
+		if g.Point == nil {
+			return []float64{}, nil
+		}
+		return g.Point, nil
+	case GeometryMultiPoint:
+		if g.MultiPoint == nil {
+			return []Position{}, nil
+		}
+		return g.MultiPoint, nil
+	case GeometryLineString:
+		if g.LineString == nil {
+			return []Position{}, nil
+		}
+		return g.LineString, nil
+	case GeometryMultiLineString:
+		if g.MultiLineString == nil {
+			return [][]Position{}, nil
+		}
+		return g.MultiLineString, nil
+	case GeometryPolygon:
+		if g.Polygon == nil {
+			return [][]Position{}, nil
+		}
+		return g.Polygon, nil
+	case GeometryMultiPolygon:
+		if g.MultiPolygon == nil {
+			return [][][]Position{}, nil
+		}
+		return g.MultiPolygon, nil
+	}
+	return nil, fmt.Errorf("unknown geometry type %q", g.Type)
+}
+
+// MarshalJSON implements json.Marshaler, encoding the null geometry
+// as null
+func (g Geometry) MarshalJSON() ([]byte, error) {
+	if g.Type == "" {
+		return []byte("null"), nil
+	}
+	if g.Type == GeometryGeometryCollection {
+		geometries := g.Geometries
+		if geometries == nil {
+			geometries = []Geometry{}
+		}
+		return json.Marshal(struct {
+			Type       string     `json:"type"`
+			Geometries []Geometry `json:"geometries"`
+		}{g.Type, geometries})
+	}
+
+	coordinates, err := g.coordinates()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}{g.Type, coordinates})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.  Both null and an object
+// without a type decode to the null geometry
+func (g *Geometry) UnmarshalJSON(data []byte) error {
+	*g = Geometry{}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometries  []Geometry      `json:"geometries"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var target interface{}
+	switch raw.Type {
+	case "":
+		return nil
+	case GeometryPoint:
+		target = &g.Point
+	case GeometryMultiPoint:
+		target = &g.MultiPoint
+	case GeometryLineString:
+		target = &g.LineString
+	case GeometryMultiLineString:
+		target = &g.MultiLineString
+	case GeometryPolygon:
+		target = &g.Polygon
+	case GeometryMultiPolygon:
+		target = &g.MultiPolygon
+	case GeometryGeometryCollection:
+		g.Type = raw.Type
+		g.Geometries = raw.Geometries
+		return nil
+	default:
+		return fmt.Errorf("unknown geometry type %q", raw.Type)
+	}
+
+	g.Type = raw.Type
+	coordinates := bytes.TrimSpace(raw.Coordinates)
+	if len(coordinates) == 0 || bytes.Equal(coordinates, []byte("null")) {
+		return nil
+	}
+	// an empty Point is encoded as an empty array
+	if g.Type == GeometryPoint && bytes.Equal(coordinates, []byte("[]")) {
+		return nil
+	}
+	if err := json.Unmarshal(coordinates, target); err != nil {
+		return fmt.Errorf("invalid %s coordinates: %v", g.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGeometryRoundTrip(t *testing.T) {
+	tests := []struct {
+		json string
+		bbox [4]float64
+	}{
+		{`{"type":"Point","coordinates":[30,10]}`, [4]float64{30, 10, 30, 10}},
+		{`{"type":"Point","coordinates":[30,10,5]}`, [4]float64{30, 10, 30, 10}},
+		{`{"type":"MultiPoint","coordinates":[[10,40],[40,30],[20,20]]}`, [4]float64{10, 20, 40, 40}},
+		{`{"type":"LineString","coordinates":[[30,10],[10,30],[40,40]]}`, [4]float64{10, 10, 40, 40}},
+		{`{"type":"MultiLineString","coordinates":[[[10,10],[20,20]],[[40,40],[30,30],[40,20]]]}`, [4]float64{10, 10, 40, 40}},
+		{`{"type":"Polygon","coordinates":[[[35,10],[45,45],[15,40],[10,20],[35,10]],[[20,30],[35,35],[30,20],[20,30]]]}`, [4]float64{10, 10, 45, 45}},
+		{`{"type":"MultiPolygon","coordinates":[[[[30,20],[45,40],[10,40],[30,20]]],[[[15,5],[40,10],[10,20],[5,10],[15,5]]]]}`, [4]float64{5, 5, 45, 40}},
+		{`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[-40,10]},{"type":"LineString","coordinates":[[10,10],[20,-20]]}]}`, [4]float64{-40, -20, 20, 10}},
+	}
+
+	for _, test := range tests {
+		var g Geometry
+		if err := json.Unmarshal([]byte(test.json), &g); err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		out, err := json.Marshal(g)
+		if err != nil || string(out) != test.json {
+			t.Errorf("round trip of %s gave %s (%v)", test.json, out, err)
+		}
+		if bbox, ok := g.Bounds(); !ok || bbox != test.bbox {
+			t.Errorf("bounds of %s: %v, expected %v", test.json, bbox, test.bbox)
+		}
+	}
+}
+
+func TestEmptyGeometry(t *testing.T) {
+	for _, input := range []string{`null`, `{"type":"","coordinates":null}`, `{"type":"Polygon","coordinates":[]}`, `{"type":"Point","coordinates":[]}`} {
+		var g Geometry
+		if err := json.Unmarshal([]byte(input), &g); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if !g.IsEmpty() {
+			t.Errorf("%s: expected an empty geometry", input)
+		}
+		if _, ok := g.Bounds(); ok {
+			t.Errorf("%s: expected no bounds", input)
+		}
+	}
+
+	var record Record
+	out, _ := json.Marshal(record)
+	var decoded map[string]interface{}
+	json.Unmarshal(out, &decoded)
+	if decoded["geometry"] != nil || decoded["bbox"] != nil {
+		t.Errorf("expected a null geometry and no bbox, got %s", out)
+	}
+
+	var g Geometry
+	if err := json.Unmarshal([]byte(`{"type":"Circle","coordinates":[0,0]}`), &g); err == nil {
+		t.Error("expected an unknown geometry type to fail")
+	}
+	if err := json.Unmarshal([]byte(`{"type":"Point","coordinates":[1]}`), &g); err == nil {
+		t.Error("expected a one-dimensional position to fail")
+	}
+}
@@ -75,11 +75,6 @@ type Link struct {
 	Rel         string `json:"rel,omitempty"`
 }
 
-type Geometry struct {
-	Type        string         `json:"type"`
-	Coordinates [][][2]float64 `json:"coordinates"`
-}
-
 type geocatalogo struct {
 	Inserted time.Time `json:"inserted"`
 	Source   string    `json:"source"`
@@ -135,20 +130,52 @@ type Properties struct {
 	JobMetadata            map[string]interface{} `json:"job_metadata,omitempty"`
 	Owner                  string               `json:"owner,omitempty"`
 	Relationships          map[string]interface{} `json:"relationships,omitempty"`
+	// CRS is the native coordinate reference system of the source
+	// metadata; geometries are always reprojected to CRS84
+	CRS string `json:"crs,omitempty"`
+}
+
+// AddKeywords adds a set of keywords of a type (such as theme or
+// place), which may be empty
+func (p *Properties) AddKeywords(keywordsType string, words ...string) {
+	if len(words) > 0 {
+		p.KeywordsSets = append(p.KeywordsSets, keywords{Keyword: words, Type: keywordsType})
+	}
 }
 
 // Record describes a generic metadata record
 type Record struct {
-	Identifier  string     `json:"id"`
-	Type        string     `json:"type"`
-	BoundingBox [4]float64 `json:"bbox"`
-	Geometry    Geometry   `json:"geometry"`
-	Properties  Properties `json:"properties"`
-	Links       []Link     `json:"links,omitempty"`
-	Assets      []Link     `json:"assets,omitempty"`
+	Identifier  string      `json:"id"`
+	Type        string      `json:"type"`
+	BoundingBox *[4]float64 `json:"bbox,omitempty"`
+	Geometry    Geometry    `json:"geometry"`
+	Properties  Properties  `json:"properties"`
+	Links       []Link      `json:"links,omitempty"`
+	Assets      []Link      `json:"assets,omitempty"`
+}
+
+// SetGeometry sets the geometry of a record and its bounding box
+func (r *Record) SetGeometry(g Geometry) {
+	r.Geometry = g
+	r.UpdateBounds()
+}
+
+// UpdateBounds sets the bounding box of a record from its geometry.
+// A record without a geometry keeps any bounding box it was given
+func (r *Record) UpdateBounds() {
+	if bbox, ok := r.Geometry.Bounds(); ok {
+		r.BoundingBox = &bbox
+	}
 }
 
-func (g *Geometry) Bounds() [4]float64 {
-	var a = [4]float64{g.Coordinates[0][0][0], g.Coordinates[0][0][1], g.Coordinates[0][2][0], g.Coordinates[0][2][1]}
-	return a
+// Bounds returns the bounding box of a record, computed from its
+// geometry when it has one, reporting false when it has neither
+func (r *Record) Bounds() ([4]float64, bool) {
+	if bbox, ok := r.Geometry.Bounds(); ok {
+		return bbox, true
+	}
+	if r.BoundingBox != nil {
+		return *r.BoundingBox, true
+	}
+	return [4]float64{}, false
 }
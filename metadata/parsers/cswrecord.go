@@ -32,13 +32,16 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"golang.org/x/net/html/charset"
 
 	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
 )
 
 // CSWRecord provides a CSW 2.0.2 Record model
 type CSWRecord struct {
+	XMLName          xml.Name
 	Identifier       string      `xml:"http://purl.org/dc/elements/1.1/ identifier"`
 	Type             string      `xml:"http://purl.org/dc/elements/1.1/ type"`
 	Title            string      `xml:"http://purl.org/dc/elements/1.1/ title"`
@@ -60,62 +63,40 @@ type boundingBox struct {
 	UpperCorner string `xml:"http://www.opengis.net/ows UpperCorner"`
 }
 
-func (e *boundingBox) Minx() (float64, error) {
-	s := strings.Split(e.LowerCorner, " ")
-	minx, err := strconv.ParseFloat(s[0], 64)
+// corner parses the x and y of an ows:LowerCorner or ows:UpperCorner,
+// separated by any whitespace
+func corner(name string, value string) (float64, float64, error) {
+	s := strings.Fields(value)
+	if len(s) != 2 {
+		return 0, 0, fmt.Errorf("%s %q does not have 2 values", name, value)
+	}
+	x, err := strconv.ParseFloat(s[0], 64)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return minx, nil
-}
-
-func (e *boundingBox) Miny() (float64, error) {
-	s := strings.Split(e.LowerCorner, " ")
-	miny, err := strconv.ParseFloat(s[1], 64)
+	y, err := strconv.ParseFloat(s[1], 64)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	return miny, nil
+	return x, y, nil
 }
 
-func (e *boundingBox) Maxx() (float64, error) {
-	s := strings.Split(e.UpperCorner, " ")
-	maxx, err := strconv.ParseFloat(s[0], 64)
+// BBox generates a list of minx,miny,maxx,maxy
+func (e *boundingBox) BBox() ([4]float64, error) {
+	minx, miny, err := corner("LowerCorner", e.LowerCorner)
 	if err != nil {
-		return 0, err
+		return [4]float64{}, err
 	}
-	return maxx, nil
-}
-
-func (e *boundingBox) Maxy() (float64, error) {
-	s := strings.Split(e.UpperCorner, " ")
-	maxy, err := strconv.ParseFloat(s[1], 64)
+	maxx, maxy, err := corner("UpperCorner", e.UpperCorner)
 	if err != nil {
-		return 0, err
+		return [4]float64{}, err
 	}
-	return maxy, nil
-}
-
-// BBox generates a list of minx,miny,maxx,maxy
-func (e *boundingBox) BBox() [][][2]float64 {
-	minx, _ := e.Minx()
-	miny, _ := e.Miny()
-	maxx, _ := e.Maxx()
-	maxy, _ := e.Maxy()
-	var a = [][][2]float64{{
-		{minx, miny},
-		{minx, maxy},
-		{maxx, maxy},
-		{maxx, miny},
-		{minx, miny},
-	}}
-	return a
+	return [4]float64{minx, miny, maxx, maxy}, nil
 }
 
 // ParseCSWRecord parses CSWRecord
 func ParseCSWRecord(xmlBuffer []byte) (metadata.Record, error) {
 	var cswRecord CSWRecord
-	var metadataRecord metadata.Record
 	reader := bytes.NewReader(xmlBuffer)
 	decoder := xml.NewDecoder(reader)
 	decoder.CharsetReader = charset.NewReaderLabel
@@ -123,31 +104,67 @@ func ParseCSWRecord(xmlBuffer []byte) (metadata.Record, error) {
 	err := decoder.Decode(&cswRecord)
 
 	if err != nil {
-		return metadataRecord, err
+		return metadata.Record{}, err
 	}
+	return cswRecord.metadataRecord()
+}
 
+// DecodeCSWRecord decodes the csw:Record starting with start, as an
+// xml.Unmarshaler of an element holding records does
+func DecodeCSWRecord(decoder *xml.Decoder, start xml.StartElement) (metadata.Record, error) {
+	var cswRecord CSWRecord
+	if err := decoder.DecodeElement(&cswRecord, &start); err != nil {
+		return metadata.Record{}, err
+	}
+	return cswRecord.metadataRecord()
+}
+
+func (cswRecord *CSWRecord) metadataRecord() (metadata.Record, error) {
+	var metadataRecord metadata.Record
 	metadataRecord = metadata.Record{}
 	metadataRecord.Type = "Feature"
 	metadataRecord.Identifier = cswRecord.Identifier
 	metadataRecord.Properties.Type = cswRecord.Type
 	metadataRecord.Properties.Title = cswRecord.Title
 	metadataRecord.Properties.Abstract = cswRecord.Abstract
-	metadataRecord.Geometry.Type = "Polygon"
+	metadataRecord.Properties.Language = cswRecord.Language
+	metadataRecord.Properties.AddKeywords("", cswRecord.Subject...)
 
-	fmt.Println(cswRecord)
 	for _, ref := range cswRecord.References {
 		metadataRecord.Links = append(metadataRecord.Links, metadata.Link{URL: ref})
 	}
 
+	// ows:BoundingBox corners are in the axis order of their CRS, and
+	// are reprojected; ows:WGS84BoundingBox is always CRS84.  A record
+	// whose bounding box cannot be reprojected is kept without geometry,
+	// one whose corners are not two numbers each is an error
 	if (cswRecord.WGS84BoundingBox != boundingBox{}) {
-		metadataRecord.Geometry.Coordinates = cswRecord.WGS84BoundingBox.BBox()
+		bbox, err := cswRecord.WGS84BoundingBox.BBox()
+		if err != nil {
+			return metadataRecord, fmt.Errorf("record %s: bounding box: %v", cswRecord.Identifier, err)
+		}
+		metadataRecord.SetGeometry(metadata.NewBBoxPolygon(bbox))
 	} else if (cswRecord.BoundingBox != boundingBox{}) {
-		metadataRecord.Geometry.Coordinates = cswRecord.BoundingBox.BBox()
+		bbox, err := cswRecord.BoundingBox.BBox()
+		if err != nil {
+			return metadataRecord, fmt.Errorf("record %s: bounding box: %v", cswRecord.Identifier, err)
+		}
+		crs, err := spatial.ParseCRS(cswRecord.BoundingBox.Crs)
+		if err != nil {
+			logrus.Warnf("Record %s: bounding box not indexed: %v", cswRecord.Identifier, err)
+			metadataRecord.Properties.CRS = strings.TrimSpace(cswRecord.BoundingBox.Crs)
+		} else {
+			metadataRecord.SetGeometry(metadata.NewBBoxPolygon(crs.BBoxToCRS84(bbox)))
+			if cswRecord.BoundingBox.Crs != "" {
+				metadataRecord.Properties.CRS = crs.Identifier
+			}
+		}
 	}
 
-	metadataRecord.BoundingBox = metadataRecord.Geometry.Bounds()
-
 	metadataRecord.Properties.Geocatalogo.Schema = "http://www.opengis.net/cat/csw/2.0.2"
+	if cswRecord.XMLName.Space != "" {
+		metadataRecord.Properties.Geocatalogo.Schema = cswRecord.XMLName.Space
+	}
 	metadataRecord.Properties.Geocatalogo.Typename = "csw:Record"
 	metadataRecord.Properties.Geocatalogo.Source = "local"
 
@@ -0,0 +1,91 @@
+package parsers
+
+import (
+	"math"
+	"testing"
+)
+
+func cswRecord(boundingBox string) []byte {
+	return []byte(`<csw:Record xmlns:csw="http://www.opengis.net/cat/csw/2.0.2"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:ows="http://www.opengis.net/ows">
+	<dc:identifier>record-1</dc:identifier>
+	<dc:title>Record</dc:title>
+	` + boundingBox + `
+</csw:Record>`)
+}
+
+func TestParseCSWRecordBoundingBoxCRS(t *testing.T) {
+	tests := []struct {
+		boundingBox string
+		expected    [4]float64
+		crs         string
+	}{
+		{`<ows:WGS84BoundingBox><ows:LowerCorner>-10 40</ows:LowerCorner><ows:UpperCorner>5 50</ows:UpperCorner></ows:WGS84BoundingBox>`,
+			[4]float64{-10, 40, 5, 50}, ""},
+		{`<ows:BoundingBox><ows:LowerCorner>-10 40</ows:LowerCorner><ows:UpperCorner>5 50</ows:UpperCorner></ows:BoundingBox>`,
+			[4]float64{-10, 40, 5, 50}, ""},
+		{`<ows:BoundingBox crs="urn:ogc:def:crs:EPSG::4326"><ows:LowerCorner>40 -10</ows:LowerCorner><ows:UpperCorner>50 5</ows:UpperCorner></ows:BoundingBox>`,
+			[4]float64{-10, 40, 5, 50}, "EPSG:4326"},
+		{`<ows:BoundingBox crs="EPSG:3857"><ows:LowerCorner>0 0</ows:LowerCorner><ows:UpperCorner>255422.57 6250868.90</ows:UpperCorner></ows:BoundingBox>`,
+			[4]float64{0, 0, 2.2945, 48.8584}, "EPSG:3857"},
+		{"<ows:WGS84BoundingBox>\n\t<ows:LowerCorner>\n\t\t-10  40\n\t</ows:LowerCorner>\n\t<ows:UpperCorner>5\n50</ows:UpperCorner>\n</ows:WGS84BoundingBox>",
+			[4]float64{-10, 40, 5, 50}, ""},
+		{`<ows:BoundingBox crs="http://www.opengis.net/def/crs/EPSG/0/32631"><ows:LowerCorner>448252 5411933</ows:LowerCorner><ows:UpperCorner>448252 5411933</ows:UpperCorner></ows:BoundingBox>`,
+			[4]float64{2.2945, 48.8584, 2.2945, 48.8584}, "EPSG:32631"},
+	}
+	for _, test := range tests {
+		record, err := ParseCSWRecord(cswRecord(test.boundingBox))
+		if err != nil {
+			t.Errorf("%s: %v", test.boundingBox, err)
+			continue
+		}
+		bbox, ok := record.Bounds()
+		if !ok {
+			t.Errorf("%s: no bounds", test.boundingBox)
+			continue
+		}
+		for i := range bbox {
+			if math.Abs(bbox[i]-test.expected[i]) > 1e-3 {
+				t.Errorf("%s: bbox %v, expected %v", test.boundingBox, bbox, test.expected)
+				break
+			}
+		}
+		if record.Properties.CRS != test.crs {
+			t.Errorf("%s: native CRS %q, expected %q", test.boundingBox, record.Properties.CRS, test.crs)
+		}
+	}
+
+	// a record in an unsupported CRS is kept, without geometry
+	record, err := ParseCSWRecord(cswRecord(`<ows:BoundingBox crs="EPSG:27700"><ows:LowerCorner>0 0</ows:LowerCorner><ows:UpperCorner>1 1</ows:UpperCorner></ows:BoundingBox>`))
+	if err != nil {
+		t.Fatalf("expected a record in an unsupported CRS to parse: %v", err)
+	}
+	if !record.Geometry.IsEmpty() || record.Properties.CRS != "EPSG:27700" {
+		t.Errorf("unexpected geometry %v and CRS %q", record.Geometry, record.Properties.CRS)
+	}
+}
+
+func TestParseCSWRecordInvalidBoundingBox(t *testing.T) {
+	for _, boundingBox := range []string{
+		`<ows:WGS84BoundingBox><ows:LowerCorner>5</ows:LowerCorner><ows:UpperCorner>5 50</ows:UpperCorner></ows:WGS84BoundingBox>`,
+		`<ows:BoundingBox crs="EPSG:4326"><ows:LowerCorner>40 -10</ows:LowerCorner><ows:UpperCorner>50 5 1</ows:UpperCorner></ows:BoundingBox>`,
+		`<ows:BoundingBox><ows:LowerCorner>-10 north</ows:LowerCorner><ows:UpperCorner>5 50</ows:UpperCorner></ows:BoundingBox>`,
+	} {
+		if _, err := ParseCSWRecord(cswRecord(boundingBox)); err == nil {
+			t.Errorf("%s: expected an error", boundingBox)
+		}
+		if _, err := ParseRecords(cswRecord(boundingBox)); err == nil {
+			t.Errorf("%s: expected ParseRecords to fail", boundingBox)
+		}
+	}
+}
+
+func TestProjectionCRS(t *testing.T) {
+	wkt := `PROJCS["WGS 84 / UTM zone 33N",GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],AUTHORITY["EPSG","4326"]],PROJECTION["Transverse_Mercator"],AUTHORITY["EPSG","32633"]]`
+	if crs := projectionCRS(wkt); crs != "EPSG:32633" {
+		t.Errorf("projection CRS %q", crs)
+	}
+	if crs := projectionCRS(""); crs != "" {
+		t.Errorf("expected no CRS, got %q", crs)
+	}
+}
@@ -0,0 +1,311 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// ISO 19115/19139 metadata (gmd:MD_Metadata)
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+// ISONamespace is the namespace of ISO 19139 metadata
+const ISONamespace = "http://www.isotc211.org/2005/gmd"
+
+// isoCode is a codelist value, such as a date or keyword type
+type isoCode struct {
+	Value string `xml:"codeListValue,attr"`
+	Text  string `xml:",chardata"`
+}
+
+func (c isoCode) String() string {
+	if c.Value != "" {
+		return c.Value
+	}
+	return strings.TrimSpace(c.Text)
+}
+
+// isoString is a free text element, as gco:CharacterString or an
+// anchor
+type isoString struct {
+	CharacterString string `xml:"CharacterString"`
+	Anchor          string `xml:"Anchor"`
+}
+
+func (s isoString) String() string {
+	if s.CharacterString != "" {
+		return strings.TrimSpace(s.CharacterString)
+	}
+	return strings.TrimSpace(s.Anchor)
+}
+
+// isoLanguage is a language as text or gmd:LanguageCode
+type isoLanguage struct {
+	isoString
+	LanguageCode isoCode `xml:"LanguageCode"`
+}
+
+func (l isoLanguage) String() string {
+	if code := l.LanguageCode.String(); code != "" {
+		return code
+	}
+	return l.isoString.String()
+}
+
+// isoDate is a gco:Date or gco:DateTime
+type isoDate struct {
+	Date     string `xml:"Date"`
+	DateTime string `xml:"DateTime"`
+}
+
+// Time parses the date, reporting false when it is missing or invalid
+func (d isoDate) Time() (time.Time, bool) {
+	if v := strings.TrimSpace(d.DateTime); v != "" {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	if t, err := time.Parse("2006-01-02", strings.TrimSpace(d.Date)); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+type isoResponsibleParty struct {
+	OrganisationName isoString `xml:"CI_ResponsibleParty>organisationName"`
+	IndividualName   isoString `xml:"CI_ResponsibleParty>individualName"`
+	Role             isoCode   `xml:"CI_ResponsibleParty>role>CI_RoleCode"`
+}
+
+type isoIdentification struct {
+	Title string `xml:"citation>CI_Citation>title>CharacterString"`
+	Dates []struct {
+		Date isoDate `xml:"date"`
+		Type isoCode `xml:"dateType>CI_DateTypeCode"`
+	} `xml:"citation>CI_Citation>date>CI_Date"`
+	Abstract string                `xml:"abstract>CharacterString"`
+	Contacts []isoResponsibleParty `xml:"pointOfContact"`
+	Keywords []struct {
+		Keyword []isoString `xml:"keyword"`
+		Type    isoCode     `xml:"type>MD_KeywordTypeCode"`
+	} `xml:"descriptiveKeywords>MD_Keywords"`
+	TopicCategories []string    `xml:"topicCategory>MD_TopicCategoryCode"`
+	UseLimitations  []string    `xml:"resourceConstraints>MD_LegalConstraints>useLimitation>CharacterString"`
+	Language        isoLanguage `xml:"language"`
+	// gmd:extent for data, srv:extent for services
+	Extents []struct {
+		BoundingBoxes []struct {
+			West  string `xml:"westBoundLongitude>Decimal"`
+			East  string `xml:"eastBoundLongitude>Decimal"`
+			South string `xml:"southBoundLatitude>Decimal"`
+			North string `xml:"northBoundLatitude>Decimal"`
+		} `xml:"geographicElement>EX_GeographicBoundingBox"`
+		TimePeriods []struct {
+			Begin string `xml:"beginPosition"`
+			End   string `xml:"endPosition"`
+		} `xml:"temporalElement>EX_TemporalExtent>extent>TimePeriod"`
+	} `xml:"extent>EX_Extent"`
+}
+
+// ISORecord provides an ISO 19139 metadata model.  Elements are
+// matched by local name, so that ISO 19139 and GML 3.2 variants are
+// read
+type ISORecord struct {
+	XMLName          xml.Name
+	FileIdentifier   string                `xml:"fileIdentifier>CharacterString"`
+	Language         isoLanguage           `xml:"language"`
+	ParentIdentifier string                `xml:"parentIdentifier>CharacterString"`
+	HierarchyLevel   isoCode               `xml:"hierarchyLevel>MD_ScopeCode"`
+	Contacts         []isoResponsibleParty `xml:"contact"`
+	DateStamp        isoDate               `xml:"dateStamp"`
+	CRS              []string              `xml:"referenceSystemInfo>MD_ReferenceSystem>referenceSystemIdentifier>RS_Identifier>code>CharacterString"`
+	Data             *isoIdentification    `xml:"identificationInfo>MD_DataIdentification"`
+	Service          *isoIdentification    `xml:"identificationInfo>SV_ServiceIdentification"`
+	Formats          []string              `xml:"distributionInfo>MD_Distribution>distributionFormat>MD_Format>name>CharacterString"`
+	OnlineResources  []struct {
+		URL         string    `xml:"linkage>URL"`
+		Protocol    isoString `xml:"protocol"`
+		Name        isoString `xml:"name"`
+		Description isoString `xml:"description"`
+		Function    isoCode   `xml:"function>CI_OnLineFunctionCode"`
+	} `xml:"distributionInfo>MD_Distribution>transferOptions>MD_DigitalTransferOptions>onLine>CI_OnlineResource"`
+}
+
+// ParseISORecord parses an ISO 19139 gmd:MD_Metadata document
+func ParseISORecord(xmlBuffer []byte) (metadata.Record, error) {
+	var isoRecord ISORecord
+	var metadataRecord metadata.Record
+	decoder := xml.NewDecoder(bytes.NewReader(xmlBuffer))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	if err := decoder.Decode(&isoRecord); err != nil {
+		return metadataRecord, err
+	}
+	return isoRecord.metadataRecord()
+}
+
+// DecodeISORecord decodes the gmd:MD_Metadata starting with start, as
+// an xml.Unmarshaler of an element holding records does
+func DecodeISORecord(decoder *xml.Decoder, start xml.StartElement) (metadata.Record, error) {
+	var isoRecord ISORecord
+	if err := decoder.DecodeElement(&isoRecord, &start); err != nil {
+		return metadata.Record{}, err
+	}
+	return isoRecord.metadataRecord()
+}
+
+func (isoRecord *ISORecord) metadataRecord() (metadata.Record, error) {
+	var metadataRecord metadata.Record
+	if isoRecord.XMLName.Local != "MD_Metadata" {
+		return metadataRecord, fmt.Errorf("%s is not ISO metadata (should be gmd:MD_Metadata)", isoRecord.XMLName.Local)
+	}
+	if isoRecord.FileIdentifier == "" {
+		return metadataRecord, fmt.Errorf("metadata has no fileIdentifier")
+	}
+
+	metadataRecord.Type = "Feature"
+	metadataRecord.Identifier = strings.TrimSpace(isoRecord.FileIdentifier)
+	p := &metadataRecord.Properties
+	p.Type = isoRecord.HierarchyLevel.String()
+	p.Collection = strings.TrimSpace(isoRecord.ParentIdentifier)
+	p.Language = isoRecord.Language.String()
+	if t, ok := isoRecord.DateStamp.Time(); ok {
+		p.Modified = &t
+	}
+
+	identification := isoRecord.Data
+	if identification == nil {
+		identification = isoRecord.Service
+	}
+	if identification == nil {
+		identification = &isoIdentification{}
+	}
+	p.Title = strings.TrimSpace(identification.Title)
+	p.Abstract = strings.TrimSpace(identification.Abstract)
+	p.License = strings.TrimSpace(strings.Join(identification.UseLimitations, " "))
+	if p.Language == "" {
+		p.Language = identification.Language.String()
+	}
+
+	for _, d := range identification.Dates {
+		t, ok := d.Date.Time()
+		if !ok {
+			continue
+		}
+		switch d.Type.String() {
+		case "creation":
+			p.Created = &t
+		case "revision":
+			p.Modified = &t
+		case "publication":
+			if p.Created == nil {
+				p.Created = &t
+			}
+		}
+	}
+
+	for _, set := range identification.Keywords {
+		var words []string
+		for _, k := range set.Keyword {
+			if k := k.String(); k != "" {
+				words = append(words, k)
+			}
+		}
+		p.AddKeywords(set.Type.String(), words...)
+	}
+	p.AddKeywords("topicCategory", identification.TopicCategories...)
+
+	// the owner is the organisation of the resource, or else of the
+	// metadata
+	for _, party := range append(identification.Contacts, isoRecord.Contacts...) {
+		name := party.OrganisationName.String()
+		if name == "" {
+			name = party.IndividualName.String()
+		}
+		if name == "" {
+			continue
+		}
+		p.Contacts = append(p.Contacts, metadata.Contact{Type: party.Role.String(), Value: name})
+		if p.Owner == "" {
+			p.Owner = name
+		}
+	}
+
+	for _, extent := range identification.Extents {
+		for _, b := range extent.BoundingBoxes {
+			var bbox [4]float64
+			var err error
+			for i, v := range []string{b.West, b.South, b.East, b.North} {
+				if bbox[i], err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+					return metadataRecord, fmt.Errorf("bounding box: %v", err)
+				}
+			}
+			if metadataRecord.Geometry.IsEmpty() {
+				metadataRecord.SetGeometry(metadata.NewBBoxPolygon(bbox))
+			}
+		}
+		for _, period := range extent.TimePeriods {
+			if p.TemporalExtent != nil {
+				break
+			}
+			temporal := metadata.Temporal{}
+			if t, ok := (isoDate{DateTime: period.Begin, Date: period.Begin}).Time(); ok {
+				temporal.Begin = &t
+			}
+			if t, ok := (isoDate{DateTime: period.End, Date: period.End}).Time(); ok {
+				temporal.End = &t
+			}
+			if temporal.Begin != nil || temporal.End != nil {
+				p.TemporalExtent = &temporal
+			}
+		}
+	}
+
+	// the reference system is kept when it is one geocatalogo knows
+	for _, code := range isoRecord.CRS {
+		if crs, err := spatial.ParseCRS(strings.TrimSpace(code)); err == nil {
+			p.CRS = crs.Identifier
+			break
+		}
+	}
+
+	for _, format := range isoRecord.Formats {
+		if format = strings.TrimSpace(format); format != "" {
+			p.GROMetadata = &metadata.GROMetadata{DataFormat: format}
+			break
+		}
+	}
+
+	for _, resource := range isoRecord.OnlineResources {
+		url := strings.TrimSpace(resource.URL)
+		if url == "" {
+			continue
+		}
+		metadataRecord.Links = append(metadataRecord.Links, metadata.Link{
+			Name:        resource.Name.String(),
+			Description: resource.Description.String(),
+			Protocol:    resource.Protocol.String(),
+			URL:         url,
+			Rel:         resource.Function.String(),
+		})
+	}
+
+	metadataRecord.Properties.Geocatalogo.Schema = ISONamespace
+	metadataRecord.Properties.Geocatalogo.Typename = "gmd:MD_Metadata"
+	metadataRecord.Properties.Geocatalogo.Source = "local"
+
+	return metadataRecord, nil
+}
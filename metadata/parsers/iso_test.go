@@ -0,0 +1,126 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+)
+
+const isoRecord = `<?xml version="1.0" encoding="UTF-8"?>
+<gmd:MD_Metadata xmlns:gmd="http://www.isotc211.org/2005/gmd" xmlns:gco="http://www.isotc211.org/2005/gco" xmlns:gml="http://www.opengis.net/gml">
+  <gmd:fileIdentifier><gco:CharacterString>iso-1</gco:CharacterString></gmd:fileIdentifier>
+  <gmd:language><gmd:LanguageCode codeList="http://www.loc.gov/standards/iso639-2/" codeListValue="eng">English</gmd:LanguageCode></gmd:language>
+  <gmd:parentIdentifier><gco:CharacterString>elevation</gco:CharacterString></gmd:parentIdentifier>
+  <gmd:hierarchyLevel><gmd:MD_ScopeCode codeList="#MD_ScopeCode" codeListValue="dataset"/></gmd:hierarchyLevel>
+  <gmd:contact><gmd:CI_ResponsibleParty>
+    <gmd:organisationName><gco:CharacterString>Metadata Agency</gco:CharacterString></gmd:organisationName>
+    <gmd:role><gmd:CI_RoleCode codeList="#CI_RoleCode" codeListValue="pointOfContact"/></gmd:role>
+  </gmd:CI_ResponsibleParty></gmd:contact>
+  <gmd:dateStamp><gco:Date>2019-01-01</gco:Date></gmd:dateStamp>
+  <gmd:referenceSystemInfo><gmd:MD_ReferenceSystem><gmd:referenceSystemIdentifier><gmd:RS_Identifier>
+    <gmd:code><gco:CharacterString>EPSG:32631</gco:CharacterString></gmd:code>
+  </gmd:RS_Identifier></gmd:referenceSystemIdentifier></gmd:MD_ReferenceSystem></gmd:referenceSystemInfo>
+  <gmd:identificationInfo><gmd:MD_DataIdentification>
+    <gmd:citation><gmd:CI_Citation>
+      <gmd:title><gco:CharacterString>Digital Elevation Model</gco:CharacterString></gmd:title>
+      <gmd:date><gmd:CI_Date>
+        <gmd:date><gco:DateTime>2018-06-01T12:00:00Z</gco:DateTime></gmd:date>
+        <gmd:dateType><gmd:CI_DateTypeCode codeList="#CI_DateTypeCode" codeListValue="creation"/></gmd:dateType>
+      </gmd:CI_Date></gmd:date>
+      <gmd:date><gmd:CI_Date>
+        <gmd:date><gco:Date>2019-03-01</gco:Date></gmd:date>
+        <gmd:dateType><gmd:CI_DateTypeCode codeList="#CI_DateTypeCode" codeListValue="revision"/></gmd:dateType>
+      </gmd:CI_Date></gmd:date>
+    </gmd:CI_Citation></gmd:citation>
+    <gmd:abstract><gco:CharacterString>Terrain heights</gco:CharacterString></gmd:abstract>
+    <gmd:pointOfContact><gmd:CI_ResponsibleParty>
+      <gmd:organisationName><gco:CharacterString>Mapping Agency</gco:CharacterString></gmd:organisationName>
+      <gmd:role><gmd:CI_RoleCode codeList="#CI_RoleCode" codeListValue="owner"/></gmd:role>
+    </gmd:CI_ResponsibleParty></gmd:pointOfContact>
+    <gmd:descriptiveKeywords><gmd:MD_Keywords>
+      <gmd:keyword><gco:CharacterString>elevation</gco:CharacterString></gmd:keyword>
+      <gmd:keyword><gco:CharacterString>terrain</gco:CharacterString></gmd:keyword>
+      <gmd:type><gmd:MD_KeywordTypeCode codeList="#MD_KeywordTypeCode" codeListValue="theme"/></gmd:type>
+    </gmd:MD_Keywords></gmd:descriptiveKeywords>
+    <gmd:topicCategory><gmd:MD_TopicCategoryCode>elevation</gmd:MD_TopicCategoryCode></gmd:topicCategory>
+    <gmd:extent><gmd:EX_Extent>
+      <gmd:geographicElement><gmd:EX_GeographicBoundingBox>
+        <gmd:westBoundLongitude><gco:Decimal>-10</gco:Decimal></gmd:westBoundLongitude>
+        <gmd:eastBoundLongitude><gco:Decimal>5</gco:Decimal></gmd:eastBoundLongitude>
+        <gmd:southBoundLatitude><gco:Decimal>40</gco:Decimal></gmd:southBoundLatitude>
+        <gmd:northBoundLatitude><gco:Decimal>50</gco:Decimal></gmd:northBoundLatitude>
+      </gmd:EX_GeographicBoundingBox></gmd:geographicElement>
+      <gmd:temporalElement><gmd:EX_TemporalExtent><gmd:extent>
+        <gml:TimePeriod gml:id="t1"><gml:beginPosition>2018-01-01</gml:beginPosition><gml:endPosition>2018-12-31T00:00:00Z</gml:endPosition></gml:TimePeriod>
+      </gmd:extent></gmd:EX_TemporalExtent></gmd:temporalElement>
+    </gmd:EX_Extent></gmd:extent>
+  </gmd:MD_DataIdentification></gmd:identificationInfo>
+  <gmd:distributionInfo><gmd:MD_Distribution><gmd:transferOptions><gmd:MD_DigitalTransferOptions>
+    <gmd:onLine><gmd:CI_OnlineResource>
+      <gmd:linkage><gmd:URL>https://example.org/dem.tif</gmd:URL></gmd:linkage>
+      <gmd:protocol><gco:CharacterString>WWW:DOWNLOAD-1.0-http--download</gco:CharacterString></gmd:protocol>
+      <gmd:name><gco:CharacterString>GeoTIFF</gco:CharacterString></gmd:name>
+    </gmd:CI_OnlineResource></gmd:onLine>
+  </gmd:MD_DigitalTransferOptions></gmd:transferOptions></gmd:MD_Distribution></gmd:distributionInfo>
+</gmd:MD_Metadata>`
+
+func TestParseISORecord(t *testing.T) {
+	record, err := ParseISORecord([]byte(isoRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := record.Properties
+	if record.Identifier != "iso-1" || p.Title != "Digital Elevation Model" || p.Abstract != "Terrain heights" {
+		t.Errorf("identification %q %q %q", record.Identifier, p.Title, p.Abstract)
+	}
+	if p.Type != "dataset" || p.Language != "eng" || p.Collection != "elevation" || p.CRS != "EPSG:32631" {
+		t.Errorf("type %q, language %q, collection %q, CRS %q", p.Type, p.Language, p.Collection, p.CRS)
+	}
+	if p.Owner != "Mapping Agency" || len(p.Contacts) != 2 {
+		t.Errorf("owner %q, contacts %v", p.Owner, p.Contacts)
+	}
+	if p.Created == nil || p.Created.Format("2006-01-02") != "2018-06-01" || p.Modified == nil || p.Modified.Format("2006-01-02") != "2019-03-01" {
+		t.Errorf("created %v, modified %v", p.Created, p.Modified)
+	}
+	if len(p.KeywordsSets) != 2 || !reflect.DeepEqual(p.KeywordsSets[0].Keyword, []string{"elevation", "terrain"}) || p.KeywordsSets[0].Type != "theme" {
+		t.Errorf("keywords %v", p.KeywordsSets)
+	}
+	if bbox, ok := record.Bounds(); !ok || bbox != [4]float64{-10, 40, 5, 50} {
+		t.Errorf("bbox %v", bbox)
+	}
+	if p.TemporalExtent == nil || p.TemporalExtent.Begin == nil || p.TemporalExtent.End == nil || p.TemporalExtent.End.Year() != 2018 {
+		t.Errorf("temporal extent %v", p.TemporalExtent)
+	}
+	if len(record.Links) != 1 || record.Links[0].URL != "https://example.org/dem.tif" || record.Links[0].Name != "GeoTIFF" {
+		t.Errorf("links %v", record.Links)
+	}
+
+	for _, doc := range []string{
+		`not xml`,
+		string(cswRecord("")),
+		`<gmd:MD_Metadata xmlns:gmd="http://www.isotc211.org/2005/gmd"/>`,
+	} {
+		if _, err := ParseISORecord([]byte(doc)); err == nil {
+			t.Errorf("expected %s to fail", doc)
+		}
+	}
+}
+
+func TestParseRecords(t *testing.T) {
+	doc := `<csw:GetRecordByIdResponse xmlns:csw="http://www.opengis.net/cat/csw/3.0">` +
+		string(cswRecord("")) + isoRecord[len(`<?xml version="1.0" encoding="UTF-8"?>`):] +
+		`</csw:GetRecordByIdResponse>`
+	records, err := ParseRecords([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Identifier != "record-1" || records[1].Identifier != "iso-1" {
+		t.Fatalf("records %v", records)
+	}
+	if records[1].Properties.Geocatalogo.Schema != ISONamespace {
+		t.Errorf("schema %q", records[1].Properties.Geocatalogo.Schema)
+	}
+
+	if _, err := ParseRecords([]byte(`<html><body/></html>`)); err == nil {
+		t.Error("expected a document without records to fail")
+	}
+}
@@ -26,6 +26,7 @@
 package parsers
 
 import (
+	"regexp"
 	"time"
 
 	"github.com/go-spatial/geocatalogo/metadata"
@@ -63,6 +64,19 @@ type OAMCatalogResults struct {
 	Result []OAMCatalogResult `json:"results"`
 }
 
+// epsgAuthority matches the EPSG authority of a WKT CRS definition
+var epsgAuthority = regexp.MustCompile(`AUTHORITY\["EPSG",\s*"(\d+)"\]`)
+
+// projectionCRS returns the EPSG code of the CRS in a WKT projection,
+// which is given by the last (outermost) authority
+func projectionCRS(wkt string) string {
+	matches := epsgAuthority.FindAllStringSubmatch(wkt, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return "EPSG:" + matches[len(matches)-1][1]
+}
+
 // ParseOAMCatalogResult parses CSWRecord
 func ParseOAMCatalogResult(result OAMCatalogResult) (metadata.Record, error) {
 	metadataRecord := metadata.Record{}
@@ -71,7 +85,6 @@ func ParseOAMCatalogResult(result OAMCatalogResult) (metadata.Record, error) {
 	metadataRecord.Identifier = result.Identifier
 	metadataRecord.Properties.Type = "dataset"
 	metadataRecord.Properties.Title = result.Title
-	metadataRecord.Geometry.Type = "Polygon"
 
 	metadataRecord.Properties.Contacts = append(metadataRecord.Properties.Contacts, metadata.Contact{Value: result.Provider})
 	metadataRecord.Properties.Contacts = append(metadataRecord.Properties.Contacts, metadata.Contact{Value: result.Contact})
@@ -89,16 +102,9 @@ func ParseOAMCatalogResult(result OAMCatalogResult) (metadata.Record, error) {
 	metadataRecord.Links = append(metadataRecord.Links, metadata.Link{URL: result.Properties.WTMS, Protocol: "OGC:WMTS"})
 	metadataRecord.Links = append(metadataRecord.Links, metadata.Link{URL: result.MetaUri, Protocol: "WWW:LINK"})
 
-	var bbox = [][][2]float64{{
-		{result.Bbox[0], result.Bbox[1]},
-		{result.Bbox[0], result.Bbox[3]},
-		{result.Bbox[2], result.Bbox[3]},
-		{result.Bbox[2], result.Bbox[1]},
-		{result.Bbox[0], result.Bbox[1]},
-	}}
-
-	metadataRecord.Geometry.Coordinates = bbox
-	metadataRecord.BoundingBox = metadataRecord.Geometry.Bounds()
+	// the bbox is CRS84 whatever the projection of the imagery
+	metadataRecord.SetGeometry(metadata.NewBBoxPolygon(result.Bbox))
+	metadataRecord.Properties.CRS = projectionCRS(result.Projection)
 
 	metadataRecord.Properties.Geocatalogo.Typename = "oam:meta"
 	metadataRecord.Properties.Geocatalogo.Schema = "https://api.openaerialmap.org/meta"
@@ -0,0 +1,77 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Metadata records of any supported schema
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// IsRecord reports whether an element is a metadata record parsers
+// read: a csw:Record (or its brief and summary forms) of CSW 2.0.2 or
+// 3.0, or an ISO gmd:MD_Metadata
+func IsRecord(name xml.Name) bool {
+	switch {
+	case name.Space == ISONamespace:
+		return name.Local == "MD_Metadata"
+	case strings.HasPrefix(name.Space, "http://www.opengis.net/cat/csw/"):
+		return name.Local == "Record" || name.Local == "SummaryRecord" || name.Local == "BriefRecord"
+	}
+	return false
+}
+
+// DecodeRecord decodes the metadata record starting with start, as an
+// xml.Unmarshaler of an element holding records does
+func DecodeRecord(decoder *xml.Decoder, start xml.StartElement) (metadata.Record, error) {
+	if !IsRecord(start.Name) {
+		decoder.Skip()
+		return metadata.Record{}, fmt.Errorf("%s is not a supported record (should be csw:Record or gmd:MD_Metadata)", start.Name.Local)
+	}
+	if start.Name.Space == ISONamespace {
+		return DecodeISORecord(decoder, start)
+	}
+	return DecodeCSWRecord(decoder, start)
+}
+
+// ParseRecords parses the metadata records of a document, which is
+// either a record or holds records, such as a CSW GetRecords or
+// GetRecordById response
+func ParseRecords(xmlBuffer []byte) ([]metadata.Record, error) {
+	var records []metadata.Record
+	decoder := xml.NewDecoder(bytes.NewReader(xmlBuffer))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || !IsRecord(start.Name) {
+			continue
+		}
+		record, err := DecodeRecord(decoder, start)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no csw:Record or gmd:MD_Metadata records found")
+	}
+	return records, nil
+}
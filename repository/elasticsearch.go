@@ -26,14 +26,13 @@
 package repository
 
 import (
-	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"reflect"
 	"strings"
-	"text/template"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -43,19 +42,116 @@ import (
 	"github.com/go-spatial/geocatalogo/config"
 	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/search"
+	"github.com/go-spatial/geocatalogo/spatial"
 )
 
+// propertyField describes the Elasticsearch field a property filter
+// applies to, and whether values must match exactly or as a substring
+type propertyField struct {
+	Field string
+	Exact bool
+}
+
+// propertyFields maps property filter keys to Elasticsearch fields,
+// following the exact-versus-substring semantics of the memory backend.
+// Values are matched against the lowercase-normalized keyword subfield
+// of each field, so filtering is case-insensitive
+var propertyFields = map[string]propertyField{
+	"collection":            {"properties.collection", true},
+	"type":                  {"properties.type", true},
+	"title":                 {"properties.title", false},
+	"owner":                 {"properties.owner", false},
+	"continent":             {"properties.gro_metadata.continent", true},
+	"country":               {"properties.gro_metadata.country", true},
+	"state":                 {"properties.gro_metadata.state_province", true},
+	"state_province":        {"properties.gro_metadata.state_province", true},
+	"city":                  {"properties.gro_metadata.city", true},
+	"admin2":                {"properties.gro_metadata.admin2", true},
+	"county":                {"properties.gro_metadata.admin2", true},
+	"data_format":           {"properties.gro_metadata.data_format", true},
+	"implementation_status": {"properties.gro_metadata.implementation_status", true},
+	"status":                {"properties.gro_metadata.implementation_status", true},
+	"geographic_scope":      {"properties.gro_metadata.geographic_scope", true},
+	"database_table":        {"properties.gro_metadata.database_table", false},
+	"v6_job_file":           {"properties.gro_metadata.v6_job_file", false},
+	"v6_job_type":           {"properties.gro_metadata.v6_job_type", false},
+	"s3_path":               {"properties.gro_metadata.s3_path", false},
+}
+
+// wildcardEscaper escapes wildcard query metacharacters
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
+
+// propertyFilterQuery translates a property filter into an Elasticsearch
+// query.  Unsupported keys match no records, as in the memory backend
+func propertyFilterQuery(key string, value string) elastic.Query {
+	pf, ok := propertyFields[key]
+	if !ok {
+		return elastic.NewBoolQuery().MustNot(elastic.NewMatchAllQuery())
+	}
+	field := pf.Field + ".lowercase"
+	value = strings.ToLower(value)
+	if pf.Exact {
+		return elastic.NewTermQuery(field, value)
+	}
+	return elastic.NewWildcardQuery(field, "*"+wildcardEscaper.Replace(value)+"*")
+}
+
+// sortFields maps the keys of search.SortFields to sortable index fields
+// and the type to assume when an index has no mapping for the field yet
+var sortFields = map[string][2]string{
+	"title":       {"properties.title.keyword", "keyword"},
+	"collection":  {"properties.collection", "keyword"},
+	"datetime":    {"properties.datetime", "date"},
+	"modified":    {"properties.modified", "date"},
+	"inserted":    {"properties._geocatalogo.inserted", "date"},
+	"cloud_cover": {"properties.product_info.cloud_cover", "float"},
+}
+
+// identifierSortField breaks ties between equally sorted records
+const identifierSortField = "id"
+
+// sorters translates a search sort into Elasticsearch sorts.  Records
+// without a value sort last, and ties are broken by relevance and then
+// identifier so that repeated searches page consistently
+func sorters(sort []search.SortField) []elastic.Sorter {
+	var sorters []elastic.Sorter
+	for _, sf := range sort {
+		field, ok := sortFields[sf.Field]
+		if !ok {
+			continue
+		}
+		sorters = append(sorters, elastic.NewFieldSort(field[0]).
+			Order(!sf.Descending).
+			Missing("_last").
+			UnmappedType(field[1]))
+	}
+	return append(sorters,
+		elastic.NewScoreSort(),
+		elastic.NewFieldSort(identifierSortField).Asc().UnmappedType("keyword"))
+}
+
+// defaultBulkSize is the number of records sent per bulk request
+// when not set in configuration
+const defaultBulkSize = 500
+
+// defaultFlushInterval is the maximum time a partial bulk request is
+// held before being sent when not set in configuration
+const defaultFlushInterval = 5 * time.Second
+
 // Elasticsearch provides an object model for repository.
 // Implements the Repository interface.
 type Elasticsearch struct {
-	Type      string
-	URL       string
-	Username  string
-	Password  string
-	Mappings  map[string]string
-	Index     elastic.Client
-	IndexName string
-	TypeName  string
+	Type          string
+	URL           string
+	Username      string
+	Password      string
+	Mappings      map[string]string
+	Index         *elastic.Client
+	IndexName     string
+	TypeName      string
+	BulkSize      int
+	FlushInterval time.Duration
+	log           *logrus.Logger
 }
 
 func createClient(repo *config.Repository) (*elastic.Client, error) {
@@ -78,57 +174,6 @@ func createClient(repo *config.Repository) (*elastic.Client, error) {
 
 }
 
-// New creates a repository
-func New(cfg config.Config, log *logrus.Logger) error {
-	var tpl bytes.Buffer
-	vars := map[string]interface{}{
-		"typename": getTypeName(cfg.Repository.URL),
-	}
-
-	indexMappingTemplate, _ := template.New("geo_mapping").Parse(`{
-		"mappings": {
-			"{{ .typename }}": {
-				"properties": {
-					"geometry": {
-						"type": "geo_shape"
-					},
-                    "collection": {
-                        "type": "text",
-                        "fielddata": true
-                    }
-				}
-			}
-		}
-	}`)
-
-	indexMappingTemplate.Execute(&tpl, vars)
-
-	ctx := context.Background()
-
-	client, err := createClient(&cfg.Repository)
-	if err != nil {
-		panic(err)
-	}
-
-	indexName := getIndexName(cfg.Repository.URL)
-
-	createIndex, err := client.CreateIndex(indexName).Body(tpl.String()).Do(ctx)
-	if err != nil {
-		errorText := fmt.Sprintf("Cannot create repository: %v\n", err)
-		log.Errorf(errorText)
-		return errors.New(errorText)
-	}
-	if !createIndex.Acknowledged {
-		return errors.New("CreateIndex was not acknowledged. Check that timeout value is correct.")
-	}
-
-	log.Debug("Creating Repository" + cfg.Repository.URL)
-	log.Debug("Type: " + cfg.Repository.Type)
-	log.Debug("URL: " + cfg.Repository.URL)
-
-	return nil
-}
-
 // Open loads a repository
 func Open(cfg config.Config, log *logrus.Logger) (Elasticsearch, error) {
 	log.Debug("Loading Repository " + cfg.Repository.URL)
@@ -138,12 +183,21 @@ func Open(cfg config.Config, log *logrus.Logger) (Elasticsearch, error) {
 	log.Debug("Password: " + cfg.Repository.Password)
 
 	s := Elasticsearch{
-		Type:      cfg.Repository.Type,
-		URL:       cfg.Repository.URL,
-		Username:  cfg.Repository.Username,
-		Mappings:  cfg.Repository.Mappings,
-		IndexName: getIndexName(cfg.Repository.URL),
-		TypeName:  getTypeName(cfg.Repository.URL),
+		Type:          cfg.Repository.Type,
+		URL:           cfg.Repository.URL,
+		Username:      cfg.Repository.Username,
+		Mappings:      cfg.Repository.Mappings,
+		IndexName:     getIndexName(cfg.Repository.URL),
+		TypeName:      getTypeName(cfg.Repository.URL),
+		BulkSize:      cfg.Repository.BulkSize,
+		FlushInterval: cfg.Repository.FlushInterval,
+		log:           log,
+	}
+	if s.BulkSize <= 0 {
+		s.BulkSize = defaultBulkSize
+	}
+	if s.FlushInterval <= 0 {
+		s.FlushInterval = defaultFlushInterval
 	}
 	log.Debug("IndexName: " + s.IndexName)
 	log.Debug("TypeName: " + s.TypeName)
@@ -153,14 +207,13 @@ func Open(cfg config.Config, log *logrus.Logger) (Elasticsearch, error) {
 		return s, err
 	}
 
-	s.Index = *client
+	s.Index = client
 
 	return s, nil
 }
 
 // Insert inserts a record into the repository
-func (r *Elasticsearch) Insert(record metadata.Record) error {
-	ctx := context.Background()
+func (r *Elasticsearch) Insert(ctx context.Context, record metadata.Record) error {
 	record.Properties.Geocatalogo.Inserted = time.Now()
 	_, err := r.Index.Index().
 		Index(r.IndexName).
@@ -175,124 +228,388 @@ func (r *Elasticsearch) Insert(record metadata.Record) error {
 	return nil
 }
 
-// Update updates a record in the repository
-func (r *Elasticsearch) Update() bool {
-	return true
+// BulkInsert inserts a batch of records into the repository using
+// the Elasticsearch bulk API.  Requests are sent every BulkSize
+// records or FlushInterval, whichever comes first
+func (r *Elasticsearch) BulkInsert(ctx context.Context, records []metadata.Record) error {
+	var mu sync.Mutex
+	var failures []BulkFailure
+
+	identifiers := make(map[elastic.BulkableRequest]string, len(records))
+
+	after := func(executionID int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			for _, request := range requests {
+				failures = append(failures, BulkFailure{Identifier: identifiers[request], Reason: err.Error()})
+			}
+			return
+		}
+		for _, item := range response.Failed() {
+			reason := "unknown error"
+			if item.Error != nil {
+				reason = item.Error.Reason
+			}
+			failures = append(failures, BulkFailure{Identifier: item.Id, Reason: reason})
+		}
+	}
+
+	processor, err := r.Index.BulkProcessor().
+		Name("geocatalogo-bulk").
+		Workers(1).
+		BulkActions(r.BulkSize).
+		FlushInterval(r.FlushInterval).
+		After(after).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	inserted := time.Now()
+	for _, record := range records {
+		record.Properties.Geocatalogo.Inserted = insertionTime(record, inserted)
+		request := elastic.NewBulkIndexRequest().
+			Index(r.IndexName).
+			Type(r.TypeName).
+			Id(record.Identifier).
+			Doc(record)
+		mu.Lock()
+		identifiers[request] = record.Identifier
+		mu.Unlock()
+		processor.Add(request)
+	}
+
+	// Close flushes any pending requests before stopping the workers
+	if err := processor.Close(); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		return &BulkError{Failures: failures}
+	}
+	return nil
 }
 
-// Delete deletes a record from the repository
-func (r *Elasticsearch) Delete() bool {
-	return true
+// Update replaces an existing record in the repository
+func (r *Elasticsearch) Update(ctx context.Context, record metadata.Record) error {
+	var existing metadata.Record
+
+	res, err := r.Index.Get().
+		Index(r.IndexName).
+		Type(r.TypeName).
+		Id(record.Identifier).
+		Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return fmt.Errorf("%s: %w", record.Identifier, ErrRecordNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if res.Source != nil {
+		if err := json.Unmarshal(*res.Source, &existing); err == nil {
+			record.Properties.Geocatalogo.Inserted = existing.Properties.Geocatalogo.Inserted
+		}
+	}
+
+	_, err = r.Index.Index().
+		Index(r.IndexName).
+		Type(r.TypeName).
+		Id(record.Identifier).
+		BodyJson(record).
+		Do(ctx)
+
+	return err
 }
 
-// Query performs a search against the repository
-func (r *Elasticsearch) Query(collections []string, term string, bbox []float64, timeVal []time.Time, from int, size int, propertyFilters map[string]string, sr *search.Results) error {
-	var mr metadata.Record
-	//	var query elastic.Query
-	ctx := context.Background()
+// Delete removes a record from the repository
+func (r *Elasticsearch) Delete(ctx context.Context, identifier string) error {
+	_, err := r.Index.Delete().
+		Index(r.IndexName).
+		Type(r.TypeName).
+		Id(identifier).
+		Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return fmt.Errorf("%s: %w", identifier, ErrRecordNotFound)
+	}
+	return err
+}
+
+// geoShapeQuery matches records whose geometry has the relation of a
+// spatial filter to its geometry, or to its bbox sent as an envelope.
+// A bbox crossing the antimeridian is sent as an envelope for each side
+// of it.  It returns nil for a filter without either
+func geoShapeQuery(sf *search.SpatialFilter) elastic.Query {
+	relation := sf.Relation
+	if relation == "" {
+		relation = search.RelationIntersects
+	}
+
+	switch {
+	case !sf.Geometry.IsEmpty():
+		return geoShape(spatial.Normalize(sf.Geometry), relation)
+	case len(sf.BBox) == 4:
+		bbox := spatial.NormalizeBBox([4]float64{sf.BBox[0], sf.BBox[1], sf.BBox[2], sf.BBox[3]})
+		if bbox[0] <= bbox[2] {
+			return geoShape(envelope(bbox), relation)
+		}
+		east := envelope([4]float64{bbox[0], bbox[1], 180, bbox[3]})
+		west := envelope([4]float64{-180, bbox[1], bbox[2], bbox[3]})
+		switch relation {
+		case search.RelationIntersects:
+			return elastic.NewBoolQuery().Should(geoShape(east, relation), geoShape(west, relation)).MinimumNumberShouldMatch(1)
+		case search.RelationWithin:
+			// a record may lie across both envelopes
+			return geoShape(metadata.NewBBoxPolygon(bbox), relation)
+		default:
+			return elastic.NewBoolQuery().Must(geoShape(east, relation), geoShape(west, relation))
+		}
+	}
+	return nil
+}
+
+// envelope returns a bbox as an Elasticsearch envelope, given by its
+// upper left and lower right corners
+func envelope(bbox [4]float64) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "envelope",
+		"coordinates": [][]float64{{bbox[0], bbox[3]}, {bbox[2], bbox[1]}},
+	}
+}
+
+// geoShape returns a geo_shape query on record geometries
+func geoShape(shape interface{}, relation string) elastic.Query {
+	// workaround for issuing a RawStringQuery until
+	// GeoShape queries are supported (https://github.com/olivere/elastic/pull/276)
+	data, _ := json.Marshal(map[string]interface{}{
+		"geo_shape": map[string]interface{}{
+			"geometry": map[string]interface{}{
+				"shape":    shape,
+				"relation": relation,
+			},
+		},
+	})
+	return elastic.NewRawStringQuery(string(data))
+}
+
+// temporalQuery matches records whose datetime falls in the interval
+// of a temporal filter or whose temporal extent intersects it, as
+// search.TemporalFilter.Matches does
+func temporalQuery(tf *search.TemporalFilter) elastic.Query {
+	const (
+		datetime = "properties.datetime"
+		begin    = "properties.temporal_extent.begin"
+		end      = "properties.temporal_extent.end"
+	)
+
+	instant := elastic.NewRangeQuery(datetime)
+	extent := elastic.NewBoolQuery().Should(
+		elastic.NewExistsQuery(begin),
+		elastic.NewExistsQuery(end)).MinimumNumberShouldMatch(1)
+	if !tf.Start.IsZero() {
+		instant = instant.Gte(tf.Start)
+		// an extent without an end is ongoing
+		extent = extent.Must(elastic.NewBoolQuery().Should(
+			elastic.NewRangeQuery(end).Gte(tf.Start),
+			elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(end))).MinimumNumberShouldMatch(1))
+	}
+	if !tf.End.IsZero() {
+		instant = instant.Lte(tf.End)
+		extent = extent.Must(elastic.NewBoolQuery().Should(
+			elastic.NewRangeQuery(begin).Lte(tf.End),
+			elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(begin))).MinimumNumberShouldMatch(1))
+	}
+	return elastic.NewBoolQuery().Should(instant, extent).MinimumNumberShouldMatch(1)
+}
 
+// searchQuery translates the criteria of a search request into an
+// Elasticsearch query
+func searchQuery(req search.Request) elastic.Query {
 	query := elastic.NewBoolQuery()
 
-	if term == "" {
+	if req.Term == "" {
 		query = query.Must(elastic.NewMatchAllQuery())
 	} else {
-		query = query.Must(elastic.NewQueryStringQuery(term))
-	}
-	if len(timeVal) > 0 {
-		if len(timeVal) == 1 { // exact match
-			query = query.Must(elastic.NewTermQuery("properties.product_info.acquisition_date", timeVal[0]))
-		} else if len(timeVal) == 2 { // range
-			rangeQuery := elastic.NewRangeQuery("properties.product_info.acquisition_date").
-				From(timeVal[0]).
-				To(timeVal[1])
-			query = query.Must(rangeQuery)
-		}
+		query = query.Must(elastic.NewQueryStringQuery(req.Term))
+	}
+	if req.Temporal != nil {
+		query = query.Must(temporalQuery(req.Temporal))
+	}
+	if req.Filter != nil {
+		query = query.Must(cql2Query(req.Filter))
 	}
-	if len(bbox) == 4 {
-		// workaround for issuing a RawStringQuery until
-		// GeoShape queries are supported (https://github.com/olivere/elastic/pull/276)
-		var tpl bytes.Buffer
-		vars := map[string]interface{}{
-			"bbox":  bbox,
-			"field": "geometry",
+	if req.Spatial != nil {
+		if shape := geoShapeQuery(req.Spatial); shape != nil {
+			query = query.Must(shape)
 		}
-		rawStringQueryTemplate, _ := template.New("geo_shape_query").Parse(`{   
-          "geo_shape": {
-            "{{ .field }}": {
-              "shape": {
-                "type": "envelope",
-                "coordinates": [
-                  [   
-                    {{ index .bbox 0 }}, 
-                    {{ index .bbox 1 }}
-                  ],  
-                  [   
-                    {{ index .bbox 2 }}, 
-                    {{ index .bbox 3 }}
-                  ]   
-                ]
-              },
-              "relation": "within"
-            }   
-          }   
-        }`)
-		rawStringQueryTemplate.Execute(&tpl, vars)
-
-		query = query.Must(elastic.NewRawStringQuery(tpl.String()))
-	}
-	if len(collections) > 0 {
-		c := make([]interface{}, len(collections))
-		for i, s := range collections {
+	}
+	if len(req.Collections) > 0 {
+		c := make([]interface{}, len(req.Collections))
+		for i, s := range req.Collections {
 			c[i] = s
 		}
-		query = query.Must(elastic.NewTermsQuery("properties.product_info.collection", c...))
+		query = query.Must(elastic.NewTermsQuery(facetFields["collection"], c...))
+	}
+	for key, value := range req.Filters {
+		query = query.Filter(propertyFilterQuery(key, value))
 	}
+	return query
+}
 
-	//src, err := query.Source()
-	//data, err := json.Marshal(src)
-	//fmt.Println(string(data))
+// Query performs a search against the repository
+func (r *Elasticsearch) Query(ctx context.Context, req search.Request, sr *search.Results) error {
 
-	searchResult, err := r.Index.Search().
+	query := searchQuery(req)
+
+	service := r.Index.Search().
 		Index(r.IndexName).
 		Type(r.TypeName).
-		From(from).
-		Size(size).
-		Query(query).Do(ctx)
+		Size(req.Size).
+		SortBy(sorters(req.Sort)...).
+		Query(query)
+
+	// resume after a cursor with search_after, which unlike from/size
+	// is not limited to the first 10000 results
+	if len(req.After) > 0 {
+		service = service.SearchAfter(req.After...)
+	} else {
+		service = service.From(req.From)
+	}
 
+	searchResult, err := service.Do(ctx)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Println("JJJJ")
 		return err
 	}
 
 	sr.ElapsedTime = int(searchResult.TookInMillis)
 	sr.Matches = int(searchResult.TotalHits())
-	sr.Returned = size
-	sr.NextRecord = size + 1
+	sr.Returned = len(searchResult.Hits.Hits)
+	sr.NextRecord = 0
 
-	if sr.Matches < size {
-		sr.Returned = sr.Matches
-		sr.NextRecord = 0
+	if next := req.From + sr.Returned; sr.Returned > 0 && next < sr.Matches {
+		sr.NextRecord = next
+		sr.After = searchResult.Hits.Hits[sr.Returned-1].Sort
 	}
 
-	for _, item := range searchResult.Each(reflect.TypeOf(mr)) {
-		if t, ok := item.(metadata.Record); ok {
-			sr.Records = append(sr.Records, t)
+	for _, hit := range searchResult.Hits.Hits {
+		var t metadata.Record
+		if err := json.Unmarshal(*hit.Source, &t); err != nil {
+			continue
+		}
+		sr.Records = append(sr.Records, t)
+		if req.Term != "" && hit.Score != nil {
+			sr.Scores = append(sr.Scores, *hit.Score)
 		}
 	}
 
 	return nil
 }
 
+// facetFields maps the keys of search.FacetFields to aggregatable index fields
+var facetFields = map[string]string{
+	"collection":       "properties.collection",
+	"type":             "properties.type",
+	"owner":            "properties.gro_metadata.owner",
+	"continent":        "properties.gro_metadata.continent",
+	"country":          "properties.gro_metadata.country",
+	"state":            "properties.gro_metadata.state_province",
+	"city":             "properties.gro_metadata.city",
+	"admin2":           "properties.gro_metadata.admin2",
+	"data_format":      "properties.gro_metadata.data_format",
+	"status":           "properties.gro_metadata.implementation_status",
+	"geographic_scope": "properties.gro_metadata.geographic_scope",
+}
+
+// maxFacetValues is the number of distinct values returned per facet.
+// Shards return the same number of terms, so counts are exact for every
+// value returned
+const maxFacetValues = 10000
+
+// Facets counts the values of the given fields across all records
+// matching a search request, using terms aggregations
+func (r *Elasticsearch) Facets(ctx context.Context, req search.Request, fields []string, sr *search.Results) error {
+
+	service := r.Index.Search().
+		Index(r.IndexName).
+		Type(r.TypeName).
+		Size(0).
+		Query(searchQuery(req))
+	for _, field := range fields {
+		if agg := facetAggregation(field); agg != nil {
+			service = service.Aggregation(field, agg)
+		}
+	}
+
+	searchResult, err := service.Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	sr.ElapsedTime = int(searchResult.TookInMillis)
+	sr.Matches = int(searchResult.TotalHits())
+	sr.Facets = make(map[string]map[string]int, len(fields))
+	for _, field := range fields {
+		sr.Facets[field] = make(map[string]int)
+		terms, ok := searchResult.Aggregations.Terms(field)
+		if !ok {
+			continue
+		}
+		if terms.SumOfOtherDocCount > 0 {
+			r.log.Warnf("Facet %s has more than %d values; counts are incomplete", field, maxFacetValues)
+		}
+		for _, bucket := range terms.Buckets {
+			key, ok := bucket.Key.(string)
+			if !ok {
+				continue
+			}
+			inner, pivot := bucket.Aggregations.Terms("inner")
+			if !pivot {
+				sr.Facets[field][key] = int(bucket.DocCount)
+				continue
+			}
+			for _, b := range inner.Buckets {
+				if value, ok := b.Key.(string); ok {
+					sr.Facets[field][search.PivotFacet(key, value)] = int(b.DocCount)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// facetAggregation returns the terms aggregation counting the values of
+// a facet, nested for a pivot facet.  It returns nil for an unknown field
+func facetAggregation(field string) *elastic.TermsAggregation {
+	outer, inner, pivot := search.SplitPivot(field)
+	path, ok := facetFields[outer]
+	if !ok {
+		return nil
+	}
+	agg := elastic.NewTermsAggregation().Field(path).Size(maxFacetValues).ShardSize(maxFacetValues)
+	if pivot {
+		innerPath, ok := facetFields[inner]
+		if !ok {
+			return nil
+		}
+		agg = agg.SubAggregation("inner", elastic.NewTermsAggregation().
+			Field(innerPath).
+			Size(maxFacetValues).
+			ShardSize(maxFacetValues))
+	}
+	return agg
+}
+
 // Get gets specified metadata records from the repository
-func (r *Elasticsearch) Get(identifiers []string, sr *search.Results) error {
+func (r *Elasticsearch) Get(ctx context.Context, identifiers []string, sr *search.Results) error {
 	var mr metadata.Record
 
 	idsQuery := elastic.NewIdsQuery(r.TypeName).Ids(identifiers...)
-	ctx := context.Background()
 	searchResult, err := r.Index.Search().
 		Index(r.IndexName).
 		Type(r.TypeName).
+		Size(len(identifiers)).
 		Query(idsQuery).Do(ctx)
 
 	if err != nil {
@@ -312,6 +629,12 @@ func (r *Elasticsearch) Get(identifiers []string, sr *search.Results) error {
 	return nil
 }
 
+// Flush refreshes the index so that all changes are visible to searches
+func (r *Elasticsearch) Flush() error {
+	_, err := r.Index.Refresh(r.IndexName).Do(context.Background())
+	return err
+}
+
 // getTypeName returns the name of the ES Index
 func getIndexName(url string) string {
 	tokens := strings.Split(url, "/")
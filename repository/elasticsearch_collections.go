@@ -0,0 +1,160 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Collections stored in Elasticsearch
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/olivere/elastic.v6"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// maxCollections is the largest number of collections listed
+const maxCollections = 10000
+
+// collectionIndexName returns the name of the index holding the
+// collections of a repository.  It is kept apart from the versioned
+// record indices, so reindexing records leaves collections in place
+func collectionIndexName(alias string) string {
+	return alias + "_collections"
+}
+
+// collectionMapping returns the settings and mappings of the collection
+// index.  Summaries are free form and stored without being indexed
+func collectionMapping(typeName string) map[string]interface{} {
+	mapping := indexMapping(typeName, IndexOptions{})
+	mapping["mappings"] = map[string]interface{}{
+		typeName: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"id":          keywordField(),
+				"title":       textField(),
+				"description": textField(),
+				"icon":        keywordField(),
+				"license":     keywordField(),
+				"keywords":    keywordField(),
+				"providers": objectField(map[string]interface{}{
+					"name":        textField(),
+					"description": textField(),
+					"roles":       keywordField(),
+					"url":         keywordField(),
+				}),
+				"summaries": map[string]interface{}{"type": "object", "enabled": false},
+				"links":     map[string]interface{}{"type": "object", "enabled": false},
+				"extent": objectField(map[string]interface{}{
+					"bbox": typedField("double"),
+					"temporal": objectField(map[string]interface{}{
+						"begin": typedField("date"),
+						"end":   typedField("date"),
+					}),
+				}),
+			},
+		},
+	}
+	return mapping
+}
+
+// createCollectionIndex creates the collection index of a repository
+func createCollectionIndex(ctx context.Context, client *elastic.Client, alias string, typeName string) error {
+	createIndex, err := client.CreateIndex(collectionIndexName(alias)).BodyJson(collectionMapping(typeName)).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !createIndex.Acknowledged {
+		return errors.New("CreateIndex was not acknowledged. Check that timeout value is correct.")
+	}
+	return nil
+}
+
+// InsertCollection adds a collection to the repository, replacing any
+// with the same identifier
+func (r *Elasticsearch) InsertCollection(ctx context.Context, collection metadata.Collection) error {
+	_, err := r.Index.Index().
+		Index(collectionIndexName(r.IndexName)).
+		Type(r.TypeName).
+		Id(collection.Identifier).
+		BodyJson(collection).
+		Refresh("wait_for").
+		Do(ctx)
+	return err
+}
+
+// UpdateCollection replaces an existing collection in the repository
+func (r *Elasticsearch) UpdateCollection(ctx context.Context, collection metadata.Collection) error {
+	if _, err := r.GetCollection(ctx, collection.Identifier); err != nil {
+		return err
+	}
+	return r.InsertCollection(ctx, collection)
+}
+
+// DeleteCollection removes a collection from the repository.  Its
+// records are kept
+func (r *Elasticsearch) DeleteCollection(ctx context.Context, identifier string) error {
+	_, err := r.Index.Delete().
+		Index(collectionIndexName(r.IndexName)).
+		Type(r.TypeName).
+		Id(identifier).
+		Refresh("wait_for").
+		Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return fmt.Errorf("%s: %w", identifier, ErrCollectionNotFound)
+	}
+	return err
+}
+
+// GetCollection retrieves a collection by identifier
+func (r *Elasticsearch) GetCollection(ctx context.Context, identifier string) (metadata.Collection, error) {
+	var collection metadata.Collection
+
+	res, err := r.Index.Get().
+		Index(collectionIndexName(r.IndexName)).
+		Type(r.TypeName).
+		Id(identifier).
+		Do(ctx)
+
+	if elastic.IsNotFound(err) || (err == nil && (!res.Found || res.Source == nil)) {
+		return collection, fmt.Errorf("%s: %w", identifier, ErrCollectionNotFound)
+	}
+	if err != nil {
+		return collection, err
+	}
+	err = json.Unmarshal(*res.Source, &collection)
+	return collection, err
+}
+
+// ListCollections retrieves all collections, ordered by identifier.  A
+// repository created before collections were stored has none
+func (r *Elasticsearch) ListCollections(ctx context.Context) ([]metadata.Collection, error) {
+	var collection metadata.Collection
+
+	searchResult, err := r.Index.Search().
+		Index(collectionIndexName(r.IndexName)).
+		Type(r.TypeName).
+		Size(maxCollections).
+		Query(elastic.NewMatchAllQuery()).
+		Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return []metadata.Collection{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make(map[string]metadata.Collection)
+	for _, item := range searchResult.Each(reflect.TypeOf(collection)) {
+		if c, ok := item.(metadata.Collection); ok {
+			collections[c.Identifier] = c
+		}
+	}
+	return sortedCollections(collections), nil
+}
@@ -0,0 +1,164 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Translation of CQL2 filters into Elasticsearch queries
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"strings"
+
+	"gopkg.in/olivere/elastic.v6"
+
+	"github.com/go-spatial/geocatalogo/cql2"
+)
+
+// cql2Query translates a CQL2 expression into an Elasticsearch query
+// with the semantics of cql2.Match
+func cql2Query(e cql2.Expr) elastic.Query {
+	switch e := e.(type) {
+	case cql2.Logical:
+		queries := make([]elastic.Query, len(e.Args))
+		for i, arg := range e.Args {
+			queries[i] = cql2Query(arg)
+		}
+		if e.Op == "and" {
+			return elastic.NewBoolQuery().Must(queries...)
+		}
+		return elastic.NewBoolQuery().Should(queries...).MinimumNumberShouldMatch(1)
+	case cql2.Not:
+		return elastic.NewBoolQuery().MustNot(cql2Query(e.Arg))
+	case cql2.Comparison:
+		fields := cql2Fields(e.Property)
+		if e.Op == "<>" {
+			query := elastic.NewBoolQuery().Must(anyField(fields, func(field string) elastic.Query {
+				return elastic.NewExistsQuery(field)
+			}))
+			for _, field := range fields {
+				query = query.MustNot(elastic.NewTermQuery(field, e.Value))
+			}
+			return query
+		}
+		return anyField(fields, func(field string) elastic.Query {
+			switch e.Op {
+			case "=":
+				return elastic.NewTermQuery(field, e.Value)
+			case "<":
+				return elastic.NewRangeQuery(field).Lt(e.Value)
+			case "<=":
+				return elastic.NewRangeQuery(field).Lte(e.Value)
+			case ">":
+				return elastic.NewRangeQuery(field).Gt(e.Value)
+			}
+			return elastic.NewRangeQuery(field).Gte(e.Value)
+		})
+	case cql2.Like:
+		pattern := wildcardPattern(e.Pattern)
+		if e.CaseInsensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		return anyField(cql2Fields(e.Property), func(field string) elastic.Query {
+			if e.CaseInsensitive {
+				field = strings.TrimSuffix(field, ".keyword") + ".lowercase"
+			}
+			return elastic.NewWildcardQuery(field, pattern)
+		})
+	case cql2.In:
+		return anyField(cql2Fields(e.Property), func(field string) elastic.Query {
+			return elastic.NewTermsQuery(field, e.Values...)
+		})
+	case cql2.Between:
+		return anyField(cql2Fields(e.Property), func(field string) elastic.Query {
+			return elastic.NewRangeQuery(field).Gte(e.Low).Lte(e.High)
+		})
+	case cql2.IsNull:
+		query := elastic.NewBoolQuery()
+		for _, field := range cql2Fields(e.Property) {
+			query = query.MustNot(elastic.NewExistsQuery(field))
+		}
+		return query
+	case cql2.SpatialPredicate:
+		return geoShape(e.Geometry, strings.TrimPrefix(e.Op, "s_"))
+	case cql2.TemporalPredicate:
+		return cql2TemporalQuery(e)
+	}
+	return elastic.NewBoolQuery().MustNot(elastic.NewMatchAllQuery())
+}
+
+// cql2Fields returns the fields holding a queryable
+func cql2Fields(name string) []string {
+	q, _ := cql2.Lookup(name)
+	return q.Fields()
+}
+
+func cql2Field(name string) string {
+	return cql2Fields(name)[0]
+}
+
+// anyField matches records in which any of the fields satisfies the
+// query made by fn
+func anyField(fields []string, fn func(field string) elastic.Query) elastic.Query {
+	if len(fields) == 1 {
+		return fn(fields[0])
+	}
+	queries := make([]elastic.Query, len(fields))
+	for i, field := range fields {
+		queries[i] = fn(field)
+	}
+	return elastic.NewBoolQuery().Should(queries...).MinimumNumberShouldMatch(1)
+}
+
+// wildcardPattern translates a LIKE pattern into a wildcard query
+func wildcardPattern(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(wildcardEscaper.Replace(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '%':
+			b.WriteByte('*')
+		case c == '_':
+			b.WriteByte('?')
+		default:
+			b.WriteString(wildcardEscaper.Replace(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// cql2TemporalQuery matches records whose timestamp, or interval
+// between two timestamps, intersects the interval of a predicate
+func cql2TemporalQuery(e cql2.TemporalPredicate) elastic.Query {
+	begin := cql2Field(e.Property)
+	if e.EndProperty == "" {
+		query := elastic.NewRangeQuery(begin)
+		if !e.Start.IsZero() {
+			query = query.Gte(e.Start)
+		}
+		if !e.End.IsZero() {
+			query = query.Lte(e.End)
+		}
+		return elastic.NewBoolQuery().Must(elastic.NewExistsQuery(begin), query)
+	}
+
+	end := cql2Field(e.EndProperty)
+	query := elastic.NewBoolQuery().Should(
+		elastic.NewExistsQuery(begin),
+		elastic.NewExistsQuery(end)).MinimumNumberShouldMatch(1)
+	if !e.Start.IsZero() {
+		query = query.Must(elastic.NewBoolQuery().Should(
+			elastic.NewRangeQuery(end).Gte(e.Start),
+			elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(end))).MinimumNumberShouldMatch(1))
+	}
+	if !e.End.IsZero() {
+		query = query.Must(elastic.NewBoolQuery().Should(
+			elastic.NewRangeQuery(begin).Lte(e.End),
+			elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery(begin))).MinimumNumberShouldMatch(1))
+	}
+	return query
+}
@@ -0,0 +1,457 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Elasticsearch index lifecycle: the managed mapping, versioned physical
+// indices behind an alias, and zero-downtime reindexing between versions
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+
+	"github.com/go-spatial/geocatalogo/config"
+)
+
+// IndexOptions holds the settings of a physical Elasticsearch index.
+// Zero values leave the Elasticsearch default (or, when reindexing,
+// the setting of the current index) in place
+type IndexOptions struct {
+	Shards   int
+	Replicas int
+}
+
+// indexVersion matches the version suffix of a physical index name
+var indexVersion = regexp.MustCompile(`_v(\d+)$`)
+
+// physicalIndexName returns the name of a version of the index behind
+// an alias
+func physicalIndexName(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// lowercaseField is the case-insensitive subfield used by property filters
+var lowercaseField = map[string]interface{}{
+	"type":         "keyword",
+	"normalizer":   "lowercase_normalizer",
+	"ignore_above": 256,
+}
+
+// textField maps free text with exact and case-insensitive subfields
+func textField() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "text",
+		"fields": map[string]interface{}{
+			"keyword": map[string]interface{}{
+				"type":         "keyword",
+				"ignore_above": 256,
+			},
+			"lowercase": lowercaseField,
+		},
+	}
+}
+
+// keywordField maps an identifier or code list value
+func keywordField() map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "keyword",
+		"ignore_above": 256,
+		"fields": map[string]interface{}{
+			"lowercase": lowercaseField,
+		},
+	}
+}
+
+// typedField maps a value of a single type
+func typedField(fieldType string) map[string]interface{} {
+	return map[string]interface{}{"type": fieldType}
+}
+
+// objectField maps an object with the given properties
+func objectField(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"properties": properties}
+}
+
+// managedProperties returns the explicit field mappings of a record.
+// Fields not listed here are mapped by the dynamic templates
+func managedProperties() map[string]interface{} {
+	gro := map[string]interface{}{}
+	for _, field := range []string{
+		"implementation_status", "data_format", "geographic_scope", "owner",
+		"update_frequency", "continent", "country", "state_province", "admin2",
+		"city", "file_path", "s3_path", "database_table", "v6_job_file",
+		"v6_job_type", "file_size_mb",
+	} {
+		gro[field] = keywordField()
+	}
+
+	return map[string]interface{}{
+		"id":       keywordField(),
+		"type":     keywordField(),
+		"bbox":     typedField("double"),
+		"geometry": typedField("geo_shape"),
+		"properties": objectField(map[string]interface{}{
+			"title":       textField(),
+			"abstract":    textField(),
+			"description": textField(),
+			"type":        keywordField(),
+			"collection":  keywordField(),
+			"owner":       textField(),
+			"license":     keywordField(),
+			"language":    keywordField(),
+			"crs":         keywordField(),
+			"datetime":    typedField("date"),
+			"created":     typedField("date"),
+			"modified":    typedField("date"),
+			"keywords": objectField(map[string]interface{}{
+				"Keyword": keywordField(),
+				"Type":    keywordField(),
+			}),
+			"temporal_extent": objectField(map[string]interface{}{
+				"begin": typedField("date"),
+				"end":   typedField("date"),
+			}),
+			"product_info": objectField(map[string]interface{}{
+				"collection":       keywordField(),
+				"platform":         keywordField(),
+				"product_id":       keywordField(),
+				"scene_id":         keywordField(),
+				"path":             typedField("long"),
+				"row":              typedField("long"),
+				"cloud_cover":      typedField("float"),
+				"acquisition_date": typedField("date"),
+				"processing_level": keywordField(),
+				"sensor_id":        keywordField(),
+			}),
+			"gro_metadata": objectField(gro),
+			"_geocatalogo": objectField(map[string]interface{}{
+				"inserted": typedField("date"),
+				"source":   keywordField(),
+				"schema":   keywordField(),
+				"type":     keywordField(),
+			}),
+		}),
+	}
+}
+
+// IndexMapping returns the settings and mappings used to create the
+// index of a repository
+func IndexMapping(cfg config.Config, opts IndexOptions) map[string]interface{} {
+	return indexMapping(getTypeName(cfg.Repository.URL), opts)
+}
+
+func indexMapping(typeName string, opts IndexOptions) map[string]interface{} {
+	settings := map[string]interface{}{
+		"analysis": map[string]interface{}{
+			"normalizer": map[string]interface{}{
+				"lowercase_normalizer": map[string]interface{}{
+					"type":   "custom",
+					"filter": []string{"lowercase"},
+				},
+			},
+		},
+	}
+	if opts.Shards > 0 {
+		settings["number_of_shards"] = opts.Shards
+	}
+	if opts.Replicas > 0 {
+		settings["number_of_replicas"] = opts.Replicas
+	}
+
+	return map[string]interface{}{
+		"settings": settings,
+		"mappings": map[string]interface{}{
+			typeName: map[string]interface{}{
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"strings": map[string]interface{}{
+							"match_mapping_type": "string",
+							"mapping":            textField(),
+						},
+					},
+				},
+				"properties": managedProperties(),
+			},
+		},
+	}
+}
+
+// New creates a repository with default index settings
+func New(cfg config.Config, log *logrus.Logger) error {
+	return NewWithOptions(cfg, log, IndexOptions{})
+}
+
+// NewWithOptions creates a repository: the first version of the physical
+// index, with the managed mapping, and the alias the catalogue uses
+func NewWithOptions(cfg config.Config, log *logrus.Logger, opts IndexOptions) error {
+	ctx := context.Background()
+
+	client, err := createClient(&cfg.Repository)
+	if err != nil {
+		return err
+	}
+
+	alias := getIndexName(cfg.Repository.URL)
+	indexName := physicalIndexName(alias, 1)
+
+	log.Debug("Creating Repository " + cfg.Repository.URL)
+	log.Debug("Type: " + cfg.Repository.Type)
+	log.Debug("URL: " + cfg.Repository.URL)
+
+	if err := createPhysicalIndex(ctx, client, indexName, getTypeName(cfg.Repository.URL), opts); err != nil {
+		errorText := fmt.Sprintf("Cannot create repository: %v\n", err)
+		log.Error(errorText)
+		return errors.New(errorText)
+	}
+	if _, err := client.Alias().Add(indexName, alias).Do(ctx); err != nil {
+		client.DeleteIndex(indexName).Do(ctx)
+		errorText := fmt.Sprintf("Cannot create alias %s: %v\n", alias, err)
+		log.Error(errorText)
+		return errors.New(errorText)
+	}
+	log.Infof("Created index %s with alias %s", indexName, alias)
+
+	if err := createCollectionIndex(ctx, client, alias, getTypeName(cfg.Repository.URL)); err != nil {
+		errorText := fmt.Sprintf("Cannot create collection index: %v\n", err)
+		log.Error(errorText)
+		return errors.New(errorText)
+	}
+	log.Infof("Created index %s", collectionIndexName(alias))
+	return nil
+}
+
+// createPhysicalIndex creates an index with the managed mapping
+func createPhysicalIndex(ctx context.Context, client *elastic.Client, indexName string, typeName string, opts IndexOptions) error {
+	createIndex, err := client.CreateIndex(indexName).BodyJson(indexMapping(typeName, opts)).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !createIndex.Acknowledged {
+		return errors.New("CreateIndex was not acknowledged. Check that timeout value is correct.")
+	}
+	return nil
+}
+
+// currentIndex returns the physical index behind an alias.  legacy is
+// set when the name is a concrete index created before versioned indices
+func currentIndex(ctx context.Context, client *elastic.Client, alias string) (name string, legacy bool, err error) {
+	aliases, err := client.Aliases().Index(alias).Do(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	indices := aliases.IndicesByAlias(alias)
+	switch {
+	case len(indices) == 1:
+		return indices[0], false, nil
+	case len(indices) > 1:
+		return "", false, fmt.Errorf("alias %s points to %d indices", alias, len(indices))
+	}
+	if _, ok := aliases.Indices[alias]; ok {
+		return alias, true, nil
+	}
+	return "", false, fmt.Errorf("index %s not found", alias)
+}
+
+// currentOptions fills unset options from the settings of an index
+func currentOptions(ctx context.Context, client *elastic.Client, indexName string, opts IndexOptions) IndexOptions {
+	settings, err := client.IndexGetSettings(indexName).FlatSettings(true).Do(ctx)
+	if err != nil || settings[indexName] == nil {
+		return opts
+	}
+	value := func(key string) int {
+		s, _ := settings[indexName].Settings[key].(string)
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = value("index.number_of_shards")
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = value("index.number_of_replicas")
+	}
+	return opts
+}
+
+// setWriteBlock blocks or allows writes to an index
+func setWriteBlock(ctx context.Context, client *elastic.Client, indexName string, block bool) error {
+	_, err := client.IndexPutSettings(indexName).
+		BodyJson(map[string]interface{}{"index.blocks.write": block}).
+		Do(ctx)
+	return err
+}
+
+// Reindex copies the records of a repository into a new version of the
+// physical index, created with the managed mapping, and atomically moves
+// the alias to it.  Searches are served from the old index throughout;
+// writes are rejected while documents are copied so none are lost.  The
+// old index is kept, with writes blocked, for rollback unless deleteOld
+// is set.  An index created before versioned indices is replaced by the
+// first version.  Returns the name of the new index
+func Reindex(cfg config.Config, log *logrus.Logger, opts IndexOptions, deleteOld bool) (string, error) {
+	ctx := context.Background()
+
+	client, err := createClient(&cfg.Repository)
+	if err != nil {
+		return "", err
+	}
+
+	alias := getIndexName(cfg.Repository.URL)
+	typeName := getTypeName(cfg.Repository.URL)
+
+	oldIndex, legacy, err := currentIndex(ctx, client, alias)
+	if err != nil {
+		return "", err
+	}
+	version := 1
+	if m := indexVersion.FindStringSubmatch(oldIndex); m != nil && !legacy {
+		n, _ := strconv.Atoi(m[1])
+		version = n + 1
+	}
+	newIndex := physicalIndexName(alias, version)
+
+	if err := createPhysicalIndex(ctx, client, newIndex, typeName, currentOptions(ctx, client, oldIndex, opts)); err != nil {
+		return "", fmt.Errorf("cannot create index %s: %v", newIndex, err)
+	}
+	log.Infof("Created index %s", newIndex)
+
+	fail := func(err error) (string, error) {
+		setWriteBlock(ctx, client, oldIndex, false)
+		client.DeleteIndex(newIndex).Do(ctx)
+		return "", err
+	}
+
+	if err := setWriteBlock(ctx, client, oldIndex, true); err != nil {
+		return fail(fmt.Errorf("cannot block writes to %s: %v", oldIndex, err))
+	}
+
+	log.Infof("Copying documents from %s to %s", oldIndex, newIndex)
+	res, err := client.Reindex().
+		SourceIndex(oldIndex).
+		DestinationIndex(newIndex).
+		WaitForCompletion(true).
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("cannot copy documents: %v", err))
+	}
+	if len(res.Failures) > 0 {
+		return fail(fmt.Errorf("%d documents failed to copy", len(res.Failures)))
+	}
+
+	oldCount, err := client.Count(oldIndex).Do(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	newCount, err := client.Count(newIndex).Do(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	if oldCount != newCount {
+		return fail(fmt.Errorf("copied %d of %d documents", newCount, oldCount))
+	}
+	log.Infof("Copied %d documents", newCount)
+
+	// a legacy index has the name the alias takes, so it is removed in
+	// the same atomic request that creates the alias
+	swap := client.Alias().Action(elastic.NewAliasAddAction(alias).Index(newIndex))
+	if legacy {
+		swap = swap.Action(elastic.NewAliasRemoveIndexAction(oldIndex))
+	} else {
+		swap = swap.Action(elastic.NewAliasRemoveAction(alias).Index(oldIndex))
+	}
+	if _, err := swap.Do(ctx); err != nil {
+		return fail(fmt.Errorf("cannot move alias %s: %v", alias, err))
+	}
+	log.Infof("Alias %s now points to %s", alias, newIndex)
+
+	if deleteOld && !legacy {
+		if _, err := client.DeleteIndex(oldIndex).Do(ctx); err != nil {
+			log.Warnf("Cannot delete index %s: %v", oldIndex, err)
+		} else {
+			log.Infof("Deleted index %s", oldIndex)
+		}
+	}
+	return newIndex, nil
+}
+
+// MappingChanges lists the differences between the mapping of the current
+// index of a repository and the managed mapping, as field: wanted (found)
+func MappingChanges(cfg config.Config, log *logrus.Logger) ([]string, error) {
+	ctx := context.Background()
+
+	client, err := createClient(&cfg.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	alias := getIndexName(cfg.Repository.URL)
+	typeName := getTypeName(cfg.Repository.URL)
+
+	indexName, _, err := currentIndex(ctx, client, alias)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.GetMapping().Index(indexName).Type(typeName).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// round-trip the managed mapping so both sides have the same types
+	var managed map[string]interface{}
+	b, _ := json.Marshal(managedProperties())
+	json.Unmarshal(b, &managed)
+
+	live := map[string]string{}
+	if index, ok := res[indexName].(map[string]interface{}); ok {
+		mappings, _ := index["mappings"].(map[string]interface{})
+		typeMapping, _ := mappings[typeName].(map[string]interface{})
+		properties, _ := typeMapping["properties"].(map[string]interface{})
+		flattenMapping("", properties, live)
+	}
+	wanted := map[string]string{}
+	flattenMapping("", managed, wanted)
+
+	var changes []string
+	for field, fieldType := range wanted {
+		switch found, ok := live[field]; {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s: %s (missing)", field, fieldType))
+		case found != fieldType:
+			changes = append(changes, fmt.Sprintf("%s: %s (%s)", field, fieldType, found))
+		}
+	}
+	sort.Strings(changes)
+	log.Debugf("Index %s differs from the managed mapping in %d fields", indexName, len(changes))
+	return changes, nil
+}
+
+// flattenMapping collects the type of every field and subfield of a
+// mapping by dotted path
+func flattenMapping(prefix string, properties map[string]interface{}, fields map[string]string) {
+	for name, value := range properties {
+		field, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+		if nested, ok := field["properties"].(map[string]interface{}); ok {
+			fields[path] = "object"
+			flattenMapping(path+".", nested, fields)
+			continue
+		}
+		fieldType, _ := field["type"].(string)
+		fields[path] = fieldType
+		if subfields, ok := field["fields"].(map[string]interface{}); ok {
+			flattenMapping(path+".", subfields, fields)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geocatalogo/cql2"
+)
+
+func TestManagedMappingCoversQueryFields(t *testing.T) {
+	var properties map[string]interface{}
+	b, _ := json.Marshal(managedProperties())
+	json.Unmarshal(b, &properties)
+
+	fields := map[string]string{}
+	flattenMapping("", properties, fields)
+
+	for key, pf := range propertyFields {
+		if fields[pf.Field+".lowercase"] != "keyword" {
+			t.Errorf("property filter %s: %s.lowercase is not a mapped keyword", key, pf.Field)
+		}
+	}
+	for key, sf := range sortFields {
+		if fields[sf[0]] != sf[1] {
+			t.Errorf("sort field %s: %s is mapped as %q, not %s", key, sf[0], fields[sf[0]], sf[1])
+		}
+	}
+	for key, path := range facetFields {
+		if fields[path] != "keyword" {
+			t.Errorf("facet %s: %s is not a mapped keyword", key, path)
+		}
+	}
+	if fields[identifierSortField] != "keyword" {
+		t.Errorf("%s is not a mapped keyword", identifierSortField)
+	}
+}
+
+func TestManagedMappingCoversQueryables(t *testing.T) {
+	var properties map[string]interface{}
+	b, _ := json.Marshal(managedProperties())
+	json.Unmarshal(b, &properties)
+
+	fields := map[string]string{}
+	flattenMapping("", properties, fields)
+
+	types := map[string][]string{
+		cql2.TypeString:    {"keyword"},
+		cql2.TypeNumber:    {"float", "double", "long", "integer", "scaled_float"},
+		cql2.TypeTimestamp: {"date"},
+		cql2.TypeGeometry:  {"geo_shape"},
+	}
+	for _, q := range cql2.Queryables() {
+		for _, field := range q.Fields() {
+			mapped := false
+			for _, fieldType := range types[q.Type] {
+				mapped = mapped || fields[field] == fieldType
+			}
+			if !mapped {
+				t.Errorf("queryable %s: %s is mapped as %q", q.Name, field, fields[field])
+			}
+			// case-insensitive LIKE uses the lowercase subfield
+			lowercase := strings.TrimSuffix(field, ".keyword") + ".lowercase"
+			if q.Type == cql2.TypeString && fields[lowercase] != "keyword" {
+				t.Errorf("queryable %s: %s is not a mapped keyword", q.Name, lowercase)
+			}
+		}
+	}
+}
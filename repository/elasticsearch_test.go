@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+func TestSearchQueryCollections(t *testing.T) {
+	source, err := searchQuery(search.Request{Collections: []string{"landsat8", "sentinel2"}}).Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(source)
+	// the field records belong to a collection by, as mapped and faceted
+	if !strings.Contains(string(b), `{"terms":{"properties.collection":["landsat8","sentinel2"]}}`) {
+		t.Errorf("collections not queried on properties.collection: %s", b)
+	}
+}
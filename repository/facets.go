@@ -0,0 +1,136 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Facet counting for the in-memory repository backend
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// facetValue returns the value of a record for one of search.FacetFields,
+// or for a search.PivotFacet of two of them when the record has both
+func facetValue(record metadata.Record, field string) string {
+	if outer, inner, ok := search.SplitPivot(field); ok {
+		o, i := facetValue(record, outer), facetValue(record, inner)
+		if o == "" || i == "" {
+			return ""
+		}
+		return search.PivotFacet(o, i)
+	}
+
+	switch field {
+	case "collection":
+		return record.Properties.Collection
+	case "type":
+		return record.Properties.Type
+	}
+
+	gro := record.Properties.GROMetadata
+	if gro == nil {
+		return ""
+	}
+	switch field {
+	case "owner":
+		return gro.Owner
+	case "continent":
+		return gro.Continent
+	case "country":
+		return gro.Country
+	case "state":
+		return gro.StateProvince
+	case "city":
+		return gro.City
+	case "admin2":
+		return gro.Admin2
+	case "data_format":
+		return gro.DataFormat
+	case "status":
+		return gro.ImplementationStatus
+	case "geographic_scope":
+		return gro.GeographicScope
+	}
+	return ""
+}
+
+// facetCounter keeps running value counts of every facet field across
+// all records, so unfiltered facet requests need not scan the records
+type facetCounter map[string]map[string]int
+
+func newFacetCounter() facetCounter {
+	fc := make(facetCounter)
+	for _, field := range search.FacetFields {
+		fc[field] = make(map[string]int)
+	}
+	return fc
+}
+
+// add counts the facet values of a record
+func (fc facetCounter) add(record metadata.Record) {
+	for field, counts := range fc {
+		if value := facetValue(record, field); value != "" {
+			counts[value]++
+		}
+	}
+}
+
+// remove discounts the facet values of a record
+func (fc facetCounter) remove(record metadata.Record) {
+	for field, counts := range fc {
+		value := facetValue(record, field)
+		if value == "" {
+			continue
+		}
+		if counts[value]--; counts[value] <= 0 {
+			delete(counts, value)
+		}
+	}
+}
+
+// counts returns a copy of the counts for the given fields
+func (fc facetCounter) counts(fields []string) map[string]map[string]int {
+	facets := make(map[string]map[string]int, len(fields))
+	for _, field := range fields {
+		facets[field] = make(map[string]int, len(fc[field]))
+		for value, count := range fc[field] {
+			facets[field][value] = count
+		}
+	}
+	return facets
+}
+
+// countFacets counts the facet values of the given records
+func countFacets(records []metadata.Record, fields []string) map[string]map[string]int {
+	facets := make(map[string]map[string]int, len(fields))
+	for _, field := range fields {
+		facets[field] = make(map[string]int)
+	}
+	for _, record := range records {
+		for _, field := range fields {
+			if value := facetValue(record, field); value != "" {
+				facets[field][value]++
+			}
+		}
+	}
+	return facets
+}
+
+// pivoted reports whether any of the facets requested is a pivot facet,
+// which the running counts do not keep
+func pivoted(fields []string) bool {
+	for _, field := range fields {
+		if _, _, ok := search.SplitPivot(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unfiltered reports whether a search request matches every record
+func unfiltered(req search.Request) bool {
+	return len(req.Collections) == 0 && req.Term == "" && len(req.Filters) == 0 &&
+		req.Spatial == nil && req.Temporal == nil && req.Filter == nil
+}
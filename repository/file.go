@@ -0,0 +1,524 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// File repository backend for geocatalogo
+// Persists records on local disk as an append-only log plus snapshots
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+const (
+	snapshotFilename    = "snapshot.json"
+	collectionsFilename = "collections.json"
+	logFilename         = "records.log"
+
+	// defaultCompactionThreshold is the number of log entries written
+	// before the log is compacted into a new snapshot
+	defaultCompactionThreshold = 1000
+)
+
+// logEntry describes a single change written to the append-only log
+type logEntry struct {
+	Op         string               `json:"op"`
+	Record     *metadata.Record     `json:"record,omitempty"`
+	Collection *metadata.Collection `json:"collection,omitempty"`
+	Identifier string               `json:"id,omitempty"`
+}
+
+// File provides a durable repository stored in a local directory.
+// Records are served from memory; every change is appended to a log
+// and the log is periodically compacted into a snapshot.
+// Implements the Repository interface.
+type File struct {
+	Type                string
+	Dir                 string
+	CompactionThreshold int
+
+	// mu serializes writes; reads are served by mem under its own lock
+	mu         sync.Mutex
+	mem        *Memory
+	logFile    *os.File
+	logEntries int
+	log        *logrus.Logger
+}
+
+// OpenFile loads a file repository, replaying any changes logged since
+// the last snapshot.  The repository URL is the directory holding the
+// snapshot and log (file:///path/to/dir)
+func OpenFile(cfg config.Config, log *logrus.Logger) (*File, error) {
+	dir := strings.TrimPrefix(cfg.Repository.URL, "file://")
+	if dir == "" {
+		return nil, fmt.Errorf("file repository requires a directory URL")
+	}
+	log.Debug("Loading file repository from " + dir)
+
+	f := &File{
+		Type:                cfg.Repository.Type,
+		Dir:                 dir,
+		CompactionThreshold: cfg.Repository.CompactionThreshold,
+		mem:                 newMemory(cfg.Repository.Type, log),
+		log:                 log,
+	}
+	if f.CompactionThreshold <= 0 {
+		f.CompactionThreshold = defaultCompactionThreshold
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := f.replayLog(); err != nil {
+		return nil, err
+	}
+
+	logFile, err := os.OpenFile(f.path(logFilename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.logFile = logFile
+
+	if f.logEntries > 0 {
+		if err := f.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("Loaded %d records from %s", len(f.mem.Records), dir)
+	return f, nil
+}
+
+// path returns the location of a file in the repository directory
+func (f *File) path(name string) string {
+	return filepath.Join(f.Dir, name)
+}
+
+// loadSnapshot reads the last compacted snapshot, if any
+func (f *File) loadSnapshot() error {
+	var records []metadata.Record
+
+	data, err := os.ReadFile(f.path(snapshotFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("failed to parse snapshot: %v", err)
+	}
+	f.mem.load(records)
+
+	collections, err := readCollections(f.path(collectionsFilename))
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		f.mem.Collections[collection.Identifier] = collection
+	}
+	return nil
+}
+
+// replayLog applies the changes logged since the last snapshot.  A torn
+// or corrupt entry left by a crash ends the replay, and the log is
+// truncated back to the last complete entry
+func (f *File) replayLog() error {
+	logFile, err := os.OpenFile(f.path(logFilename), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	var offset int64
+	reader := bufio.NewReader(logFile)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var entry logEntry
+		if err == io.EOF || json.Unmarshal(line, &entry) != nil || !f.apply(entry) {
+			f.log.Warnf("Discarding incomplete log entry at offset %d in %s", offset, logFile.Name())
+			if err := logFile.Truncate(offset); err != nil {
+				return err
+			}
+			return logFile.Sync()
+		}
+		offset += int64(len(line))
+		f.logEntries++
+	}
+}
+
+// apply replays a log entry against the in-memory records
+func (f *File) apply(entry logEntry) bool {
+	switch entry.Op {
+	case "insert", "update":
+		if entry.Record == nil {
+			return false
+		}
+		f.mem.put(*entry.Record)
+	case "delete":
+		f.mem.remove(entry.Identifier)
+	case "insert-collection", "update-collection":
+		if entry.Collection == nil {
+			return false
+		}
+		f.mem.Collections[entry.Collection.Identifier] = *entry.Collection
+	case "delete-collection":
+		delete(f.mem.Collections, entry.Identifier)
+	default:
+		return false
+	}
+	return true
+}
+
+// appendLog durably writes entries to the log
+func (f *File) appendLog(entries ...logEntry) error {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if _, err := f.logFile.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.logFile.Sync(); err != nil {
+		return err
+	}
+	f.logEntries += len(entries)
+	return nil
+}
+
+// maybeCompact compacts the log once it reaches the compaction threshold
+func (f *File) maybeCompact() {
+	if f.logEntries < f.CompactionThreshold {
+		return
+	}
+	if err := f.compact(); err != nil {
+		f.log.Errorf("Compaction failed: %v", err)
+	}
+}
+
+// compact writes all records to a new snapshot and truncates the log.
+// The snapshot is written to a temporary file and renamed into place,
+// so a crash at any point leaves either the old or the new snapshot
+// alongside a log that can safely be replayed over it
+func (f *File) compact() error {
+	start := time.Now()
+
+	if err := writeRecords(f.path(snapshotFilename), f.mem.Records); err != nil {
+		return err
+	}
+	if err := writeCollections(f.path(collectionsFilename), f.mem.Collections); err != nil {
+		return err
+	}
+
+	if err := f.logFile.Truncate(0); err != nil {
+		return err
+	}
+	if err := f.logFile.Sync(); err != nil {
+		return err
+	}
+	f.logEntries = 0
+
+	f.log.Debugf("Compacted %d records into snapshot in %s", len(f.mem.Records), time.Since(start))
+	return nil
+}
+
+// writeRecords writes records, ordered by identifier, as a JSON array
+func writeRecords(path string, records map[string]metadata.Record) error {
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return writeJSONArray(path, len(ids), func(i int) interface{} { return records[ids[i]] })
+}
+
+// writeCollections writes collections, ordered by identifier, as a
+// JSON array
+func writeCollections(path string, collections map[string]metadata.Collection) error {
+	list := sortedCollections(collections)
+	return writeJSONArray(path, len(list), func(i int) interface{} { return list[i] })
+}
+
+// writeJSONArray writes n elements as a JSON array, one per line.  The
+// file is written to a temporary file and renamed into place, so a
+// crash at any point leaves either the old or the new file
+func writeJSONArray(path string, n int, element func(i int) interface{}) error {
+	tmpPath := path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	writer.WriteString("[\n")
+	for i := 0; i < n; i++ {
+		data, err := json.Marshal(element(i))
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if i > 0 {
+			writer.WriteString(",\n")
+		}
+		writer.Write(data)
+	}
+	writer.WriteString("\n]\n")
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Insert adds a record to the repository
+func (f *File) Insert(ctx context.Context, record metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record.Properties.Geocatalogo.Inserted = time.Now()
+	if err := f.appendLog(logEntry{Op: "insert", Record: &record}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	f.mem.put(record)
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// BulkInsert adds a batch of records to the repository with a single
+// write to the log
+func (f *File) BulkInsert(ctx context.Context, records []metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inserted := time.Now()
+	entries := make([]logEntry, len(records))
+	for i := range records {
+		record := records[i]
+		record.Properties.Geocatalogo.Inserted = insertionTime(record, inserted)
+		entries[i] = logEntry{Op: "insert", Record: &record}
+	}
+	if err := f.appendLog(entries...); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	for _, entry := range entries {
+		f.mem.put(*entry.Record)
+	}
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// Update replaces an existing record in the repository
+func (f *File) Update(ctx context.Context, record metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	existing, ok := f.mem.Records[record.Identifier]
+	if !ok {
+		return fmt.Errorf("%s: %w", record.Identifier, ErrRecordNotFound)
+	}
+	record.Properties.Geocatalogo.Inserted = existing.Properties.Geocatalogo.Inserted
+	if err := f.appendLog(logEntry{Op: "update", Record: &record}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	f.mem.put(record)
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// Delete removes a record from the repository
+func (f *File) Delete(ctx context.Context, identifier string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.mem.Records[identifier]; !ok {
+		return fmt.Errorf("%s: %w", identifier, ErrRecordNotFound)
+	}
+	if err := f.appendLog(logEntry{Op: "delete", Identifier: identifier}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	f.mem.remove(identifier)
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// InsertCollection adds a collection to the repository, replacing any
+// with the same identifier
+func (f *File) InsertCollection(ctx context.Context, collection metadata.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.appendLog(logEntry{Op: "insert-collection", Collection: &collection}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	f.mem.Collections[collection.Identifier] = collection
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// UpdateCollection replaces an existing collection in the repository
+func (f *File) UpdateCollection(ctx context.Context, collection metadata.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.mem.Collections[collection.Identifier]; !ok {
+		return fmt.Errorf("%s: %w", collection.Identifier, ErrCollectionNotFound)
+	}
+	if err := f.appendLog(logEntry{Op: "update-collection", Collection: &collection}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	f.mem.Collections[collection.Identifier] = collection
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// DeleteCollection removes a collection from the repository.  Its
+// records are kept
+func (f *File) DeleteCollection(ctx context.Context, identifier string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.mem.Collections[identifier]; !ok {
+		return fmt.Errorf("%s: %w", identifier, ErrCollectionNotFound)
+	}
+	if err := f.appendLog(logEntry{Op: "delete-collection", Identifier: identifier}); err != nil {
+		return err
+	}
+	f.mem.mu.Lock()
+	delete(f.mem.Collections, identifier)
+	f.mem.mu.Unlock()
+	f.maybeCompact()
+	return nil
+}
+
+// GetCollection retrieves a collection by identifier
+func (f *File) GetCollection(ctx context.Context, identifier string) (metadata.Collection, error) {
+	return f.mem.GetCollection(ctx, identifier)
+}
+
+// ListCollections retrieves all collections, ordered by identifier
+func (f *File) ListCollections(ctx context.Context) ([]metadata.Collection, error) {
+	return f.mem.ListCollections(ctx)
+}
+
+// Query performs a search against the repository
+func (f *File) Query(ctx context.Context, req search.Request, sr *search.Results) error {
+	return f.mem.Query(ctx, req, sr)
+}
+
+// Facets counts the values of the given fields across all records
+// matching a search request
+func (f *File) Facets(ctx context.Context, req search.Request, fields []string, sr *search.Results) error {
+	return f.mem.Facets(ctx, req, fields, sr)
+}
+
+// Get retrieves records by identifier(s)
+func (f *File) Get(ctx context.Context, identifiers []string, sr *search.Results) error {
+	return f.mem.Get(ctx, identifiers, sr)
+}
+
+// Close compacts any outstanding log entries and releases the log file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.logEntries > 0 {
+		if err := f.compact(); err != nil {
+			return err
+		}
+	}
+	return f.logFile.Close()
+}
+
+// Flush compacts any outstanding log entries into the snapshot
+func (f *File) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.logEntries > 0 {
+		return f.compact()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+func openTestFile(t *testing.T, dir string) *File {
+	var cfg config.Config
+	cfg.Repository.Type = "file"
+	cfg.Repository.URL = "file://" + dir
+
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+
+	f, err := OpenFile(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	return f
+}
+
+func testRecord(id string, title string) metadata.Record {
+	record := metadata.Record{Identifier: id, Type: "Feature"}
+	record.Properties.Title = title
+	return record
+}
+
+func TestFilePersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	f := openTestFile(t, dir)
+	f.Insert(ctx, testRecord("a", "first"))
+	f.BulkInsert(ctx, []metadata.Record{testRecord("b", "second"), testRecord("c", "third")})
+	if err := f.Update(ctx, testRecord("a", "first (revised)")); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := f.Delete(ctx, "b"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	// simulate a crash: release the log without compacting
+	f.logFile.Close()
+
+	f = openTestFile(t, dir)
+	defer f.Close()
+
+	sr := search.Results{}
+	f.Get(ctx, []string{"a", "b", "c"}, &sr)
+	if sr.Matches != 2 {
+		t.Fatalf("expected 2 records after reopen, got %d", sr.Matches)
+	}
+	if sr.Records[0].Properties.Title != "first (revised)" {
+		t.Errorf("update was not recovered: %q", sr.Records[0].Properties.Title)
+	}
+	if err := f.Update(ctx, testRecord("b", "gone")); err == nil {
+		t.Error("expected updating a deleted record to fail")
+	}
+}
+
+func TestFilePersistsCollections(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	f := openTestFile(t, dir)
+	f.InsertCollection(ctx, metadata.Collection{Identifier: "landsat", Title: "Landsat"})
+	f.InsertCollection(ctx, metadata.Collection{Identifier: "sentinel"})
+	if err := f.UpdateCollection(ctx, metadata.Collection{Identifier: "landsat", Title: "Landsat 8"}); err != nil {
+		t.Fatalf("UpdateCollection failed: %v", err)
+	}
+	if err := f.DeleteCollection(ctx, "sentinel"); err != nil {
+		t.Fatalf("DeleteCollection failed: %v", err)
+	}
+	if err := f.UpdateCollection(ctx, metadata.Collection{Identifier: "modis"}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("expected updating a missing collection to fail, got %v", err)
+	}
+	// recovered from the log, then from the snapshot once compacted
+	f.logFile.Close()
+	for i := 0; i < 2; i++ {
+		f = openTestFile(t, dir)
+		collections, err := f.ListCollections(ctx)
+		if err != nil || len(collections) != 1 || collections[0].Title != "Landsat 8" {
+			t.Fatalf("unexpected collections after reopen: %+v (%v)", collections, err)
+		}
+		if _, err := f.GetCollection(ctx, "sentinel"); !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("deleted collection was recovered: %v", err)
+		}
+		f.Close()
+	}
+}
+
+func TestFileRecoversFromTornLogEntry(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	f := openTestFile(t, dir)
+	f.Insert(ctx, testRecord("a", "first"))
+	f.logFile.Close()
+
+	// append a partially written entry, as left by a crash mid-write
+	logFile, _ := os.OpenFile(filepath.Join(dir, logFilename), os.O_WRONLY|os.O_APPEND, 0644)
+	logFile.WriteString(`{"op":"insert","record":{"id":"b"`)
+	logFile.Close()
+
+	f = openTestFile(t, dir)
+	defer f.Close()
+
+	if len(f.mem.Records) != 1 {
+		t.Fatalf("expected 1 record after recovery, got %d", len(f.mem.Records))
+	}
+	if err := f.Insert(ctx, testRecord("c", "third")); err != nil {
+		t.Fatalf("Insert after recovery failed: %v", err)
+	}
+}
@@ -8,24 +8,44 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/cql2"
 	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/search"
+	"github.com/go-spatial/geocatalogo/spatial"
 )
 
-// Memory provides an in-memory object model for repository
+// Memory provides an in-memory object model for repository.
+// It is safe for concurrent use; Records, Collections and the indexes
+// are guarded by mu and replaced as a whole when a watched source file
+// is reloaded
 type Memory struct {
-	Type    string
-	Records map[string]metadata.Record
-	log     *logrus.Logger
+	Type        string
+	Records     map[string]metadata.Record
+	Collections map[string]metadata.Collection
+	path        string
+	log         *logrus.Logger
+
+	mu      sync.RWMutex
+	spatial *rtree
+	text    *textIndex
+	facets  facetCounter
+
+	stopWatch chan struct{}
+	watchDone chan struct{}
 }
 
 // NewMemory creates an in-memory repository
@@ -42,58 +62,605 @@ func NewMemory(cfg config.Config, log *logrus.Logger) error {
 func OpenMemory(cfg config.Config, log *logrus.Logger) (*Memory, error) {
 	log.Debug("Loading in-memory repository from " + cfg.Repository.URL)
 
-	m := &Memory{
-		Type:    cfg.Repository.Type,
-		Records: make(map[string]metadata.Record),
-		log:     log,
-	}
+	m := newMemory(cfg.Repository.Type, log)
 
 	// Load records from JSON file if URL is provided
 	if cfg.Repository.URL != "" && cfg.Repository.URL != "memory://" {
 		// URL format: file:///path/to/records.json
 		filePath := strings.TrimPrefix(cfg.Repository.URL, "file://")
+		m.path = filePath
 
+		// stat before reading so a change made meanwhile is reloaded
+		info, _ := os.Stat(filePath)
+		collectionsInfo, _ := os.Stat(collectionsPath(filePath))
 		data, err := ioutil.ReadFile(filePath)
 		if err != nil {
 			log.Warnf("Could not load records from %s: %v", filePath, err)
-			return m, nil // Return empty repository, not an error
+		} else {
+			records, err := parseRecords(data, log)
+			if err != nil {
+				return nil, err
+			}
+			m.load(records)
+			log.Infof("Loaded %d records from %s", len(m.Records), filePath)
 		}
 
-		var records []metadata.Record
-		if err := json.Unmarshal(data, &records); err != nil {
-			return nil, fmt.Errorf("failed to parse records JSON: %v", err)
+		collections, err := readCollections(collectionsPath(filePath))
+		if err != nil {
+			return nil, err
 		}
-
-		for _, record := range records {
-			m.Records[record.Identifier] = record
+		for _, collection := range collections {
+			m.Collections[collection.Identifier] = collection
 		}
 
-		log.Infof("Loaded %d records from %s", len(m.Records), filePath)
+		if cfg.Repository.WatchInterval > 0 {
+			m.watch(info, collectionsInfo, cfg.Repository.WatchInterval)
+		}
 	}
 
 	return m, nil
 }
 
+// parseRecords parses a JSON array of records.  Records which cannot be
+// parsed, such as those with an unknown geometry type, are logged and
+// skipped rather than failing the whole load
+func parseRecords(data []byte, log *logrus.Logger) ([]metadata.Record, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("failed to parse records JSON: %v", err)
+	}
+	records := make([]metadata.Record, 0, len(elements))
+	for i, element := range elements {
+		var record metadata.Record
+		if err := json.Unmarshal(element, &record); err != nil {
+			var id struct {
+				Identifier string `json:"id"`
+			}
+			json.Unmarshal(element, &id)
+			log.Warnf("Skipping record %d (%s): %v", i, id.Identifier, err)
+			continue
+		}
+		spatial.NormalizeRecord(&record)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// collectionsPath returns the location of the collections stored
+// alongside records loaded from a file: records.collections.json for
+// records.json
+func collectionsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".collections.json"
+}
+
+// readCollections reads a JSON array of collections, returning none
+// when the file does not exist
+func readCollections(path string) ([]metadata.Collection, error) {
+	var collections []metadata.Collection
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return nil, fmt.Errorf("failed to parse collections JSON: %v", err)
+	}
+	return collections, nil
+}
+
+// watch polls the source file and the collections stored alongside it
+// every interval, and reloads both when the modification time or size
+// of either changes.  A file which cannot be read or parsed, such as
+// one still being written, is retried on the next poll while the
+// current records continue to be served
+func (m *Memory) watch(loaded os.FileInfo, loadedCollections os.FileInfo, interval time.Duration) {
+	m.stopWatch = make(chan struct{})
+	m.watchDone = make(chan struct{})
+	m.log.Infof("Watching %s for changes every %s", m.path, interval)
+
+	go func() {
+		defer close(m.watchDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, lastCollections := loaded, loadedCollections
+		for {
+			select {
+			case <-m.stopWatch:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(m.path)
+			if err != nil {
+				continue
+			}
+			// the collections file is optional, so its absence is a state
+			collectionsInfo, _ := os.Stat(collectionsPath(m.path))
+			if !fileChanged(last, info) && !fileChanged(lastCollections, collectionsInfo) {
+				continue
+			}
+			if err := m.reload(); err != nil {
+				m.log.Warnf("Could not reload records from %s: %v", m.path, err)
+				continue
+			}
+			last, lastCollections = info, collectionsInfo
+		}
+	}()
+}
+
+// fileChanged reports whether a file differs from when it was last seen,
+// either being nil when the file did not exist
+func fileChanged(last os.FileInfo, info os.FileInfo) bool {
+	if last == nil || info == nil {
+		return last != info
+	}
+	return !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size()
+}
+
+// reload reads the source file into a new record set and indexes, then
+// swaps them in so that readers see either the old or the new set
+func (m *Memory) reload() error {
+	data, err := ioutil.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	records, err := parseRecords(data, m.log)
+	if err != nil {
+		return err
+	}
+	collections, err := readCollections(collectionsPath(m.path))
+	if err != nil {
+		return err
+	}
+	fresh := newMemory(m.Type, m.log)
+	fresh.load(records)
+	for _, collection := range collections {
+		fresh.Collections[collection.Identifier] = collection
+	}
+
+	m.mu.Lock()
+	m.Records, m.spatial, m.text, m.facets = fresh.Records, fresh.spatial, fresh.text, fresh.facets
+	m.Collections = fresh.Collections
+	m.mu.Unlock()
+
+	m.log.Infof("Reloaded %d records from %s", len(records), m.path)
+	return nil
+}
+
+// Close stops watching the source file, if watched
+func (m *Memory) Close() error {
+	if m.stopWatch != nil {
+		close(m.stopWatch)
+		<-m.watchDone
+		m.stopWatch = nil
+	}
+	return nil
+}
+
+// newMemory creates an empty in-memory repository
+func newMemory(repoType string, log *logrus.Logger) *Memory {
+	return &Memory{
+		Type:        repoType,
+		Records:     make(map[string]metadata.Record),
+		Collections: make(map[string]metadata.Collection),
+		log:         log,
+		spatial:     newRTree(),
+		text:        newTextIndex(),
+		facets:      newFacetCounter(),
+	}
+}
+
+// load replaces all records, bulk loading the spatial index.  Records
+// without a geometry or bounding box are left out of the spatial index
+func (m *Memory) load(records []metadata.Record) {
+	m.Records = make(map[string]metadata.Record, len(records))
+	m.text = newTextIndex()
+	m.facets = newFacetCounter()
+	for _, record := range records {
+		m.Records[record.Identifier] = record
+	}
+
+	entries := make([]rtreeEntry, 0, len(m.Records))
+	for id, record := range m.Records {
+		for _, rect := range recordRects(record) {
+			entries = append(entries, rtreeEntry{rect: rect, id: id})
+		}
+		m.text.add(record)
+		m.facets.add(record)
+	}
+	m.spatial.Load(entries)
+}
+
+// put stores a record as is, replacing any record with the same
+// identifier.  The caller must hold the write lock
+func (m *Memory) put(record metadata.Record) {
+	if existing, ok := m.Records[record.Identifier]; ok {
+		for _, rect := range recordRects(existing) {
+			m.spatial.Delete(existing.Identifier, rect)
+		}
+		m.facets.remove(existing)
+	}
+	m.Records[record.Identifier] = record
+	for _, rect := range recordRects(record) {
+		m.spatial.Insert(record.Identifier, rect)
+	}
+	m.text.add(record)
+	m.facets.add(record)
+}
+
+// remove drops a record, reporting whether it existed.  The caller
+// must hold the write lock
+func (m *Memory) remove(identifier string) bool {
+	existing, ok := m.Records[identifier]
+	if !ok {
+		return false
+	}
+	for _, rect := range recordRects(existing) {
+		m.spatial.Delete(identifier, rect)
+	}
+	m.text.remove(identifier)
+	m.facets.remove(existing)
+	delete(m.Records, identifier)
+	return true
+}
+
+// candidates returns the records which may satisfy a query, using the
+// spatial index to narrow spatial searches other than disjoint ones
+func (m *Memory) candidates(sf *search.SpatialFilter) []metadata.Record {
+	records := []metadata.Record{}
+	var bbox [4]float64
+	var bounded bool
+	if sf != nil && sf.Relation != search.RelationDisjoint {
+		bbox, bounded = sf.Shape().Bounds()
+	}
+	if !bounded {
+		for _, record := range m.Records {
+			records = append(records, record)
+		}
+		return records
+	}
+
+	seen := make(map[string]bool)
+	for _, rect := range bboxRects(bbox) {
+		m.spatial.Search(rect, func(id string) bool {
+			if !seen[id] {
+				seen[id] = true
+				records = append(records, m.Records[id])
+			}
+			return true
+		})
+	}
+	return records
+}
+
+// bboxRects returns the rectangles covering a bounding box, splitting
+// one crossing the antimeridian into its eastern and western parts
+func bboxRects(bbox [4]float64) []rtreeRect {
+	if bbox[0] > bbox[2] {
+		return []rtreeRect{
+			{bbox[0], bbox[1], 180, bbox[3]},
+			{-180, bbox[1], bbox[2], bbox[3]},
+		}
+	}
+	return []rtreeRect{rtreeRect(bbox)}
+}
+
+// recordRects returns the rectangles a record is indexed under
+func recordRects(record metadata.Record) []rtreeRect {
+	if bbox, ok := record.Bounds(); ok {
+		return bboxRects(bbox)
+	}
+	return nil
+}
+
+// recordShape returns the geometry of a record, or a polygon of its
+// bounding box when it has no geometry
+func recordShape(record metadata.Record) (metadata.Geometry, bool) {
+	if !record.Geometry.IsEmpty() {
+		return record.Geometry, true
+	}
+	if record.BoundingBox != nil {
+		return metadata.NewBBoxPolygon(*record.BoundingBox), true
+	}
+	return metadata.Geometry{}, false
+}
+
+// spatialMatch reports whether a record has the given relation to the
+// shape of a spatial filter.  Records without a geometry never match
+func spatialMatch(record metadata.Record, shape metadata.Geometry, relation string) bool {
+	g, ok := recordShape(record)
+	if !ok {
+		return false
+	}
+	switch relation {
+	case search.RelationWithin:
+		return spatial.Within(g, shape)
+	case search.RelationContains:
+		return spatial.Contains(g, shape)
+	case search.RelationDisjoint:
+		return spatial.Disjoint(g, shape)
+	default:
+		return spatial.Intersects(g, shape)
+	}
+}
+
+// sortKey holds the value of a sortable record property
+type sortKey struct {
+	set bool
+	str string
+	t   time.Time
+	num float64
+}
+
+// recordSortKey returns the sort key of a record for one of search.SortFields
+func recordSortKey(record metadata.Record, field string) sortKey {
+	p := record.Properties
+	switch field {
+	case "title":
+		return sortKey{set: p.Title != "", str: p.Title}
+	case "collection":
+		return sortKey{set: p.Collection != "", str: p.Collection}
+	case "datetime":
+		if p.Datetime != nil {
+			return sortKey{set: true, t: *p.Datetime}
+		}
+	case "modified":
+		if p.Modified != nil {
+			return sortKey{set: true, t: *p.Modified}
+		}
+	case "inserted":
+		return sortKey{set: !p.Geocatalogo.Inserted.IsZero(), t: p.Geocatalogo.Inserted}
+	case "cloud_cover":
+		if p.ProductInfo != nil {
+			return sortKey{set: true, num: p.ProductInfo.CloudCover}
+		}
+	}
+	return sortKey{}
+}
+
+// compareSortKeys orders two keys of the same property, placing
+// records without a value last in either direction
+func compareSortKeys(a sortKey, b sortKey, descending bool) int {
+	if !a.set || !b.set {
+		switch {
+		case a.set:
+			return -1
+		case b.set:
+			return 1
+		}
+		return 0
+	}
+
+	c := strings.Compare(a.str, b.str)
+	if c == 0 {
+		switch {
+		case a.t.Before(b.t):
+			c = -1
+		case a.t.After(b.t):
+			c = 1
+		case a.num < b.num:
+			c = -1
+		case a.num > b.num:
+			c = 1
+		}
+	}
+	if descending {
+		return -c
+	}
+	return c
+}
+
+// orderKey positions a record in the order of a search
+type orderKey struct {
+	keys  []sortKey
+	score float64
+	id    string
+}
+
+func newOrderKey(record metadata.Record, sortBy []search.SortField, score float64) orderKey {
+	k := orderKey{keys: make([]sortKey, len(sortBy)), score: score, id: record.Identifier}
+	for i, sf := range sortBy {
+		k.keys[i] = recordSortKey(record, sf.Field)
+	}
+	return k
+}
+
+// compareOrder orders two records by sort keys, then relevance, then identifier
+func compareOrder(a orderKey, b orderKey, sortBy []search.SortField) int {
+	for i, sf := range sortBy {
+		if c := compareSortKeys(a.keys[i], b.keys[i], sf.Descending); c != 0 {
+			return c
+		}
+	}
+	if a.score != b.score {
+		if a.score > b.score {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.id, b.id)
+}
+
+// values encodes an order key as cursor values
+func (k orderKey) values(sortBy []search.SortField) []interface{} {
+	values := make([]interface{}, 0, len(k.keys)+2)
+	for i, sf := range sortBy {
+		key := k.keys[i]
+		switch {
+		case !key.set:
+			values = append(values, nil)
+		case sortFieldKind(sf.Field) == "time":
+			values = append(values, key.t.Format(time.RFC3339Nano))
+		case sortFieldKind(sf.Field) == "number":
+			values = append(values, key.num)
+		default:
+			values = append(values, key.str)
+		}
+	}
+	return append(values, k.score, k.id)
+}
+
+// parseOrderKey decodes cursor values created by orderKey.values
+func parseOrderKey(values []interface{}, sortBy []search.SortField) (orderKey, error) {
+	k := orderKey{keys: make([]sortKey, len(sortBy))}
+	invalid := fmt.Errorf("cursor does not match the search sort")
+
+	if len(values) != len(sortBy)+2 {
+		return k, invalid
+	}
+	for i, sf := range sortBy {
+		if values[i] == nil {
+			continue
+		}
+		key := sortKey{set: true}
+		switch sortFieldKind(sf.Field) {
+		case "time":
+			value, _ := values[i].(string)
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return k, invalid
+			}
+			key.t = t
+		case "number":
+			num, ok := cursorNumber(values[i])
+			if !ok {
+				return k, invalid
+			}
+			key.num = num
+		default:
+			str, ok := values[i].(string)
+			if !ok {
+				return k, invalid
+			}
+			key.str = str
+		}
+		k.keys[i] = key
+	}
+
+	score, ok := cursorNumber(values[len(sortBy)])
+	id, idOK := values[len(sortBy)+1].(string)
+	if !ok || !idOK {
+		return k, invalid
+	}
+	k.score, k.id = score, id
+	return k, nil
+}
+
+// cursorNumber reads a number from decoded cursor values
+func cursorNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// sortFieldKind returns the type of value a sort field holds
+func sortFieldKind(field string) string {
+	switch field {
+	case "datetime", "modified", "inserted":
+		return "time"
+	case "cloud_cover":
+		return "number"
+	}
+	return "string"
+}
+
+// byOrder sorts records along with their order keys
+type byOrder struct {
+	records []metadata.Record
+	keys    []orderKey
+	sortBy  []search.SortField
+}
+
+func (b byOrder) Len() int { return len(b.records) }
+
+func (b byOrder) Less(i, j int) bool {
+	return compareOrder(b.keys[i], b.keys[j], b.sortBy) < 0
+}
+
+func (b byOrder) Swap(i, j int) {
+	b.records[i], b.records[j] = b.records[j], b.records[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
 // Insert adds a record to the in-memory repository
-func (m *Memory) Insert(record metadata.Record) error {
+func (m *Memory) Insert(ctx context.Context, record metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	record.Properties.Geocatalogo.Inserted = time.Now()
-	m.Records[record.Identifier] = record
+	m.put(record)
 	m.log.Debugf("Inserted record %s", record.Identifier)
 	return nil
 }
 
-// Update updates a record in the repository
-func (m *Memory) Update() bool {
-	return true
+// BulkInsert adds a batch of records to the in-memory repository
+func (m *Memory) BulkInsert(ctx context.Context, records []metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := time.Now()
+	for _, record := range records {
+		record.Properties.Geocatalogo.Inserted = insertionTime(record, inserted)
+		m.put(record)
+	}
+	m.log.Debugf("Inserted %d records", len(records))
+	return nil
 }
 
-// Delete deletes a record from the repository
-func (m *Memory) Delete() bool {
-	return true
+// Update replaces an existing record in the in-memory repository
+func (m *Memory) Update(ctx context.Context, record metadata.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.Records[record.Identifier]
+	if !ok {
+		return fmt.Errorf("%s: %w", record.Identifier, ErrRecordNotFound)
+	}
+	record.Properties.Geocatalogo.Inserted = existing.Properties.Geocatalogo.Inserted
+	m.put(record)
+	m.log.Debugf("Updated record %s", record.Identifier)
+	return nil
+}
+
+// Delete removes a record from the in-memory repository
+func (m *Memory) Delete(ctx context.Context, identifier string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.remove(identifier) {
+		return fmt.Errorf("%s: %w", identifier, ErrRecordNotFound)
+	}
+	m.log.Debugf("Deleted record %s", identifier)
+	return nil
 }
 
 // Get retrieves records by identifier(s)
-func (m *Memory) Get(identifiers []string, sr *search.Results) error {
+func (m *Memory) Get(ctx context.Context, identifiers []string, sr *search.Results) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	sr.Records = []metadata.Record{}
 
 	for _, id := range identifiers {
@@ -109,19 +676,130 @@ func (m *Memory) Get(identifiers []string, sr *search.Results) error {
 	return nil
 }
 
-// Query performs a search against the in-memory repository
-func (m *Memory) Query(collections []string, term string, bbox []float64, timeVal []time.Time, from int, size int, propertyFilters map[string]string, sr *search.Results) error {
-	sr.Records = []metadata.Record{}
+// InsertCollection adds a collection to the in-memory repository,
+// replacing any with the same identifier
+func (m *Memory) InsertCollection(ctx context.Context, collection metadata.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Collections[collection.Identifier] = collection
+	m.log.Debugf("Inserted collection %s", collection.Identifier)
+	return nil
+}
+
+// UpdateCollection replaces an existing collection in the in-memory
+// repository
+func (m *Memory) UpdateCollection(ctx context.Context, collection metadata.Collection) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.Collections[collection.Identifier]; !ok {
+		return fmt.Errorf("%s: %w", collection.Identifier, ErrCollectionNotFound)
+	}
+	m.Collections[collection.Identifier] = collection
+	m.log.Debugf("Updated collection %s", collection.Identifier)
+	return nil
+}
+
+// DeleteCollection removes a collection from the in-memory repository.
+// Its records are kept
+func (m *Memory) DeleteCollection(ctx context.Context, identifier string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.Collections[identifier]; !ok {
+		return fmt.Errorf("%s: %w", identifier, ErrCollectionNotFound)
+	}
+	delete(m.Collections, identifier)
+	m.log.Debugf("Deleted collection %s", identifier)
+	return nil
+}
+
+// GetCollection retrieves a collection by identifier
+func (m *Memory) GetCollection(ctx context.Context, identifier string) (metadata.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return metadata.Collection{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, ok := m.Collections[identifier]
+	if !ok {
+		return collection, fmt.Errorf("%s: %w", identifier, ErrCollectionNotFound)
+	}
+	return collection, nil
+}
+
+// ListCollections retrieves all collections, ordered by identifier
+func (m *Memory) ListCollections(ctx context.Context) ([]metadata.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedCollections(m.Collections), nil
+}
+
+// sortedCollections returns collections ordered by identifier
+func sortedCollections(collections map[string]metadata.Collection) []metadata.Collection {
+	list := make([]metadata.Collection, 0, len(collections))
+	for _, collection := range collections {
+		list = append(list, collection)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Identifier < list[j].Identifier })
+	return list
+}
+
+// cancelCheckInterval is the number of records scanned between checks
+// for the cancellation of a search
+const cancelCheckInterval = 1024
+
+// filter returns the records matching a search request, along with
+// their relevance scores for term searches.  It stops with the error of
+// the context when the context is cancelled or times out mid-scan
+func (m *Memory) filter(ctx context.Context, req search.Request) ([]metadata.Record, map[string]float64, error) {
 	matches := []metadata.Record{}
 
-	// Search through all records
-	for _, record := range m.Records {
+	// Score term matches against the full-text index
+	var scores map[string]float64
+	if req.Term != "" {
+		scores = m.text.search(req.Term)
+	}
+
+	var shape metadata.Geometry
+	if req.Spatial != nil {
+		shape = req.Spatial.Shape()
+	}
+	spatialFilter := !shape.IsEmpty()
+
+	var filterMatch func(record metadata.Record) bool
+	if req.Filter != nil {
+		filterMatch = cql2.Matcher(req.Filter)
+	}
+
+	// Search through candidate records
+	for i, record := range m.candidates(req.Spatial) {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+		}
 		match := true
 
 		// Collection filter
-		if len(collections) > 0 {
+		if len(req.Collections) > 0 {
 			collectionMatch := false
-			for _, coll := range collections {
+			for _, coll := range req.Collections {
 				if record.Properties.Collection == coll {
 					collectionMatch = true
 					break
@@ -133,8 +811,8 @@ func (m *Memory) Query(collections []string, term string, bbox []float64, timeVa
 		}
 
 		// Property-level filters (NEW!)
-		if len(propertyFilters) > 0 && match {
-			for key, value := range propertyFilters {
+		if len(req.Filters) > 0 && match {
+			for key, value := range req.Filters {
 				valueLower := strings.ToLower(value)
 				propertyMatch := false
 
@@ -207,52 +885,33 @@ func (m *Memory) Query(collections []string, term string, bbox []float64, timeVa
 			}
 		}
 
-		// Text search (searches in title and abstract)
-		if term != "" && match {
-			termLower := strings.ToLower(term)
-			titleMatch := strings.Contains(strings.ToLower(record.Properties.Title), termLower)
-			abstractMatch := strings.Contains(strings.ToLower(record.Properties.Abstract), termLower)
-			idMatch := strings.Contains(strings.ToLower(record.Identifier), termLower)
+		// Text search (title, abstract, description and keywords, or identifier)
+		if req.Term != "" && match {
+			_, textMatch := scores[record.Identifier]
+			idMatch := strings.Contains(strings.ToLower(record.Identifier), strings.ToLower(req.Term))
 
-			if !titleMatch && !abstractMatch && !idMatch {
+			if !textMatch && !idMatch {
 				match = false
 			}
 		}
 
-		// Bounding box filter (simple overlap check)
-		if len(bbox) == 4 && match {
-			// bbox format: [minx, miny, maxx, maxy]
-			recordBBox := record.BoundingBox
-
-			// Check if bounding boxes overlap
-			overlap := !(bbox[2] < recordBBox[0] || // query max_x < record min_x
-				bbox[0] > recordBBox[2] || // query min_x > record max_x
-				bbox[3] < recordBBox[1] || // query max_y < record min_y
-				bbox[1] > recordBBox[3])   // query min_y > record max_y
-
-			if !overlap {
+		// Spatial filter
+		if spatialFilter && match {
+			if !spatialMatch(record, shape, req.Spatial.Relation) {
 				match = false
 			}
 		}
 
 		// Time filter
-		if len(timeVal) > 0 && match {
-			if record.Properties.Datetime != nil {
-				// Check if record datetime falls within query time range
-				if len(timeVal) == 1 {
-					// Exact time match (or close enough - within a day)
-					diff := record.Properties.Datetime.Sub(timeVal[0]).Hours()
-					if diff < -24 || diff > 24 {
-						match = false
-					}
-				} else if len(timeVal) == 2 {
-					// Time range
-					if record.Properties.Datetime.Before(timeVal[0]) || record.Properties.Datetime.After(timeVal[1]) {
-						match = false
-					}
-				}
-			} else {
-				// No datetime in record, doesn't match time query
+		if req.Temporal != nil && match {
+			if !req.Temporal.Matches(record) {
+				match = false
+			}
+		}
+
+		// CQL2 filter
+		if req.Filter != nil && match {
+			if !filterMatch(record) {
 				match = false
 			}
 		}
@@ -261,44 +920,145 @@ func (m *Memory) Query(collections []string, term string, bbox []float64, timeVa
 			matches = append(matches, record)
 		}
 	}
+	return matches, scores, nil
+}
+
+// Query performs a search against the in-memory repository
+func (m *Memory) Query(ctx context.Context, req search.Request, sr *search.Results) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sr.Records = []metadata.Record{}
+	matches, scores, err := m.filter(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// Order by the requested sort keys, then relevance, then identifier
+	keys := make([]orderKey, len(matches))
+	for i, record := range matches {
+		keys[i] = newOrderKey(record, req.Sort, scores[record.Identifier])
+	}
+	sort.Sort(byOrder{matches, keys, req.Sort})
 
 	// Pagination
 	sr.Matches = len(matches)
 
-	if from >= len(matches) {
+	from, size := req.From, req.Size
+	start := from
+	if len(req.After) > 0 {
+		cursor, err := parseOrderKey(req.After, req.Sort)
+		if err != nil {
+			return err
+		}
+		start = sort.Search(len(keys), func(i int) bool {
+			return compareOrder(keys[i], cursor, req.Sort) > 0
+		})
+	}
+	if start >= len(matches) {
 		sr.Returned = 0
 		sr.NextRecord = 0
 		return nil
 	}
 
-	end := from + size
+	end := start + size
 	if end > len(matches) {
 		end = len(matches)
 	}
 
-	sr.Records = matches[from:end]
+	sr.Records = matches[start:end]
 	sr.Returned = len(sr.Records)
 
+	if req.Term != "" {
+		sr.Scores = make([]float64, len(sr.Records))
+		for i, record := range sr.Records {
+			sr.Scores[i] = scores[record.Identifier]
+		}
+	}
+
 	if end < len(matches) {
-		sr.NextRecord = end
+		sr.NextRecord = from + sr.Returned
+		sr.After = keys[end-1].values(req.Sort)
 	} else {
 		sr.NextRecord = 0
 	}
 
-	m.log.Debugf("Query found %d matches, returning %d from offset %d", sr.Matches, sr.Returned, from)
+	m.log.Debugf("Query found %d matches, returning %d from offset %d", sr.Matches, sr.Returned, start)
 
 	return nil
 }
 
+// Facets counts the values of the given fields across all records
+// matching a search request
+func (m *Memory) Facets(ctx context.Context, req search.Request, fields []string, sr *search.Results) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if unfiltered(req) && !pivoted(fields) {
+		sr.Matches = len(m.Records)
+		sr.Facets = m.facets.counts(fields)
+		return nil
+	}
+
+	matches, _, err := m.filter(ctx, req)
+	if err != nil {
+		return err
+	}
+	sr.Matches = len(matches)
+	sr.Facets = countFacets(matches, fields)
+	return nil
+}
+
+// Flush writes all records back to the JSON file the repository was
+// loaded from, and any collections alongside it.  Changes are otherwise
+// not persisted
+func (m *Memory) Flush() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.path == "" {
+		return nil
+	}
+	if err := writeRecords(m.path, m.Records); err != nil {
+		return err
+	}
+	m.log.Debugf("Wrote %d records to %s", len(m.Records), m.path)
+
+	path := collectionsPath(m.path)
+	if _, err := os.Stat(path); len(m.Collections) == 0 && os.IsNotExist(err) {
+		return nil
+	}
+	if err := writeCollections(path, m.Collections); err != nil {
+		return err
+	}
+	m.log.Debugf("Wrote %d collections to %s", len(m.Collections), path)
+	return nil
+}
+
 // DeleteAll removes all records (for testing)
 func (m *Memory) DeleteAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	count := len(m.Records)
 	m.Records = make(map[string]metadata.Record)
+	m.spatial = newRTree()
+	m.text = newTextIndex()
+	m.facets = newFacetCounter()
 	m.log.Infof("Deleted all %d records", count)
 	return nil
 }
 
 // Count returns the number of records
 func (m *Memory) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return len(m.Records)
 }
@@ -0,0 +1,488 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/cql2"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+func TestMemorySortIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	older := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(1, 0, 0)
+	for _, id := range []string{"d", "b", "c", "a"} {
+		record := testRecord(id, "same title")
+		switch id {
+		case "a", "c":
+			record.Properties.Datetime = &older
+		case "b":
+			record.Properties.Datetime = &newer
+		}
+		m.Insert(ctx, record)
+	}
+
+	sortBy, err := search.ParseSort("-datetime,title")
+	if err != nil {
+		t.Fatalf("ParseSort failed: %v", err)
+	}
+
+	var ids []string
+	for from := 0; from < 4; from += 2 {
+		sr := search.Results{}
+		m.Query(ctx, search.Request{Sort: sortBy, From: from, Size: 2}, &sr)
+		for _, record := range sr.Records {
+			ids = append(ids, record.Identifier)
+		}
+	}
+	// newest first, ties by identifier, records without a datetime last
+	if len(ids) != 4 || ids[0] != "b" || ids[1] != "a" || ids[2] != "c" || ids[3] != "d" {
+		t.Errorf("unexpected order: %v", ids)
+	}
+
+	if _, err := search.ParseSort("abstract"); err == nil {
+		t.Error("expected sorting on an unsupported property to fail")
+	}
+}
+
+func TestMemoryFacets(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	for i, country := range []string{"Canada", "Canada", "Kenya", "Peru"} {
+		record := testRecord(strconv.Itoa(i), "record")
+		record.Properties.GROMetadata = &metadata.GROMetadata{Country: country, DataFormat: "csv", Continent: "Americas"}
+		if country == "Kenya" {
+			record.Properties.GROMetadata.DataFormat = "geojson"
+			record.Properties.GROMetadata.Continent = "Africa"
+		}
+		m.Insert(ctx, record)
+	}
+	m.Delete(ctx, "3")
+
+	sr := search.Results{}
+	m.Facets(ctx, search.Request{}, []string{"country"}, &sr)
+	if sr.Facets["country"]["Canada"] != 2 || sr.Facets["country"]["Kenya"] != 1 || len(sr.Facets["country"]) != 2 {
+		t.Errorf("unexpected unfiltered counts: %v", sr.Facets)
+	}
+
+	sr = search.Results{}
+	m.Facets(ctx, search.Request{Filters: map[string]string{"data_format": "csv"}}, []string{"country"}, &sr)
+	if sr.Matches != 2 || sr.Facets["country"]["Canada"] != 2 || len(sr.Facets["country"]) != 1 {
+		t.Errorf("unexpected filtered counts: %v", sr.Facets)
+	}
+
+	sr = search.Results{}
+	pivot := search.PivotFacet("continent", "country")
+	m.Facets(ctx, search.Request{}, []string{pivot}, &sr)
+	if sr.Facets[pivot]["Americas/Canada"] != 2 || sr.Facets[pivot]["Africa/Kenya"] != 1 || len(sr.Facets[pivot]) != 2 {
+		t.Errorf("unexpected pivot counts: %v", sr.Facets)
+	}
+}
+
+func TestMemoryCursorWalksAllRecords(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	for i := 0; i < 25; i++ {
+		m.Insert(ctx, testRecord(strconv.Itoa(i), "title "+strconv.Itoa(i%4)))
+	}
+	sortBy, _ := search.ParseSort("-title")
+
+	seen := map[string]bool{}
+	req := search.Request{Sort: sortBy, Size: 10}
+	for page := 0; ; page++ {
+		sr := search.Results{}
+		if err := m.Query(ctx, req, &sr); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for _, record := range sr.Records {
+			if seen[record.Identifier] {
+				t.Fatalf("record %s returned twice", record.Identifier)
+			}
+			seen[record.Identifier] = true
+		}
+		if len(sr.After) == 0 {
+			break
+		}
+		// a record deleted between pages must not shift the cursor
+		if page == 0 {
+			m.Delete(ctx, sr.Records[len(sr.Records)-1].Identifier)
+		}
+		req.After, req.From = sr.After, sr.NextRecord
+	}
+	if len(seen) != 25 {
+		t.Errorf("expected to walk 25 records, got %d", len(seen))
+	}
+}
+
+// cancelledAfter is a context which is cancelled once Err has been
+// called a number of times
+type cancelledAfter struct {
+	context.Context
+	calls int
+}
+
+func (c *cancelledAfter) Err() error {
+	if c.calls--; c.calls < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestMemoryQueryStopsWhenCancelled(t *testing.T) {
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+	for i := 0; i < 3*cancelCheckInterval; i++ {
+		m.Insert(context.Background(), testRecord(strconv.Itoa(i), "record"))
+	}
+
+	// cancelled after the check on entry and the first in the scan
+	ctx := &cancelledAfter{Context: context.Background(), calls: 2}
+	sr := search.Results{}
+	if err := m.Query(ctx, search.Request{Term: "record"}, &sr); err != context.Canceled {
+		t.Errorf("expected the scan to be cancelled, got %v", err)
+	}
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				m.Insert(ctx, testRecord(strconv.Itoa(w*100+i), "title "+strconv.Itoa(i)))
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				sr := search.Results{}
+				m.Query(ctx, search.Request{Term: "title", Size: 10}, &sr)
+				m.Facets(ctx, search.Request{}, []string{"country"}, &sr)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(m.Records) != 200 {
+		t.Errorf("expected 200 records, got %d", len(m.Records))
+	}
+}
+
+func TestMemoryReloadsWatchedFile(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+
+	path := filepath.Join(t.TempDir(), "records.json")
+	records := map[string]metadata.Record{"a": testRecord("a", "first")}
+	if err := writeRecords(path, records); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config.Config
+	cfg.Repository.Type = "memory"
+	cfg.Repository.URL = "file://" + path
+	cfg.Repository.WatchInterval = 10 * time.Millisecond
+	m, err := OpenMemory(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenMemory failed: %v", err)
+	}
+	defer m.Close()
+
+	records["b"] = testRecord("b", "second")
+	records["c"] = testRecord("c", "third")
+	if err := writeRecords(path, records); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sr := search.Results{}
+		if err := m.Query(ctx, search.Request{Term: "third", Size: 10}, &sr); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if sr.Matches == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("records were not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemoryReloadsWatchedCollections(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+
+	path := filepath.Join(t.TempDir(), "records.json")
+	if err := writeRecords(path, map[string]metadata.Record{"a": testRecord("a", "first")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config.Config
+	cfg.Repository.Type = "memory"
+	cfg.Repository.URL = "file://" + path
+	cfg.Repository.WatchInterval = 10 * time.Millisecond
+	m, err := OpenMemory(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenMemory failed: %v", err)
+	}
+	defer m.Close()
+
+	// only the collections file changes
+	collections := map[string]metadata.Collection{"a": {Identifier: "a", Title: "first"}}
+	if err := writeCollections(collectionsPath(path), collections); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if collection, err := m.GetCollection(ctx, "a"); err == nil {
+			if collection.Title != "first" {
+				t.Errorf("unexpected collection %+v", collection)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("collections were not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemoryCollectionsPersistAlongsideRecords(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+
+	var cfg config.Config
+	cfg.Repository.Type = "memory"
+	cfg.Repository.URL = "file://" + filepath.Join(t.TempDir(), "records.json")
+	m, err := OpenMemory(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenMemory failed: %v", err)
+	}
+	m.Insert(ctx, testRecord("a", "first"))
+	m.InsertCollection(ctx, metadata.Collection{Identifier: "b", Title: "second"})
+	m.InsertCollection(ctx, metadata.Collection{Identifier: "a", Title: "first"})
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	loaded, err := OpenMemory(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenMemory failed: %v", err)
+	}
+	collections, _ := loaded.ListCollections(ctx)
+	if len(collections) != 2 || collections[0].Identifier != "a" || collections[1].Title != "second" {
+		t.Errorf("unexpected collections %+v", collections)
+	}
+	if len(loaded.Records) != 1 {
+		t.Errorf("expected 1 record, got %d", len(loaded.Records))
+	}
+}
+
+func TestMemorySpatialUsesGeometryBounds(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	point := testRecord("point", "point")
+	point.SetGeometry(metadata.NewPoint(10, 20))
+	line := testRecord("line", "line")
+	line.Geometry = metadata.Geometry{Type: metadata.GeometryLineString, LineString: []metadata.Position{{-5, -5}, {5, 5}}}
+	m.Insert(ctx, point)
+	m.Insert(ctx, line)
+	m.Insert(ctx, testRecord("none", "no geometry"))
+
+	sr := search.Results{}
+	m.Query(ctx, search.Request{Size: 10, Spatial: &search.SpatialFilter{BBox: []float64{-1, -1, 1, 1}}}, &sr)
+	if sr.Matches != 1 || sr.Records[0].Identifier != "line" {
+		t.Errorf("expected only the line to intersect the origin, got %d matches", sr.Matches)
+	}
+
+	sr = search.Results{}
+	m.Query(ctx, search.Request{Size: 10, Spatial: &search.SpatialFilter{BBox: []float64{0, 0, 15, 25}}}, &sr)
+	if sr.Matches != 2 {
+		t.Errorf("expected the point and line to match, got %d", sr.Matches)
+	}
+}
+
+func TestMemorySpatialAcrossAntimeridian(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	// loaded records are normalized, so Fiji given past 180 is split
+	records, err := parseRecords([]byte(`[
+		{"id": "fiji", "type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[177, -20], [177, -15], [182, -15], [182, -20], [177, -20]]]}, "properties": {"title": "Fiji"}},
+		{"id": "aleutians", "type": "Feature", "geometry": {"type": "LineString", "coordinates": [[172, 52], [-170, 53]]}, "properties": {"title": "Aleutian Islands"}},
+		{"id": "greenwich", "type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 51.5]}, "properties": {"title": "Greenwich"}},
+		{"id": "unknown", "type": "Feature", "geometry": {"type": "Circle", "coordinates": [0, 0]}, "properties": {"title": "Unknown"}}
+	]`), testLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a record with an unknown geometry type is skipped
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if bbox := records[0].BoundingBox; bbox == nil || *bbox != [4]float64{177, -20, -178, -15} {
+		t.Errorf("unexpected Fiji bbox %v", bbox)
+	}
+	m.load(records)
+
+	tests := []struct {
+		bbox     []float64
+		relation string
+		expected []string
+	}{
+		{[]float64{170, -30, -170, 60}, search.RelationIntersects, []string{"aleutians", "fiji"}},
+		{[]float64{170, -30, 190, 60}, search.RelationIntersects, []string{"aleutians", "fiji"}},
+		{[]float64{-179, -18, -178.5, -17}, search.RelationIntersects, []string{"fiji"}},
+		{[]float64{175, -25, -175, -10}, search.RelationWithin, []string{"fiji"}},
+		{[]float64{170, -30, -170, 60}, search.RelationDisjoint, []string{"greenwich"}},
+	}
+	for _, test := range tests {
+		sr := search.Results{}
+		m.Query(ctx, search.Request{Size: 10, Spatial: &search.SpatialFilter{BBox: test.bbox, Relation: test.relation}}, &sr)
+		var ids []string
+		for _, record := range sr.Records {
+			ids = append(ids, record.Identifier)
+		}
+		sort.Strings(ids)
+		if strings.Join(ids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%s %v matched %v, expected %v", test.relation, test.bbox, ids, test.expected)
+		}
+	}
+}
+
+func TestMemoryTemporalIntervals(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	date := func(year int) *time.Time {
+		t := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	instant := testRecord("instant", "instant")
+	instant.Properties.Datetime = date(2010)
+	closed := testRecord("closed", "closed extent")
+	closed.Properties.TemporalExtent = &metadata.Temporal{Begin: date(2000), End: date(2005)}
+	ongoing := testRecord("ongoing", "ongoing extent")
+	ongoing.Properties.TemporalExtent = &metadata.Temporal{Begin: date(2015)}
+	m.Insert(ctx, instant)
+	m.Insert(ctx, closed)
+	m.Insert(ctx, ongoing)
+	m.Insert(ctx, testRecord("timeless", "no time"))
+
+	tests := map[string][]string{
+		"2010-01-01T00:00:00Z":                      {"instant"},
+		"2010-01-02T00:00:00Z":                      nil,
+		"2003-06-01":                                {"closed"},
+		"2030-01-01T00:00:00Z":                      {"ongoing"},
+		"2004-01-01T00:00:00Z/2012-01-01T00:00:00Z": {"closed", "instant"},
+		"../2001-01-01T00:00:00Z":                   {"closed"},
+		"2006-01-01T00:00:00Z/..":                   {"instant", "ongoing"},
+		"2005-01-01T00:00:00Z/..":                   {"closed", "instant", "ongoing"},
+	}
+	for value, expected := range tests {
+		tf, err := search.ParseTemporalFilter(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sr := search.Results{}
+		m.Query(ctx, search.Request{Size: 10, Temporal: tf}, &sr)
+		var ids []string
+		for _, record := range sr.Records {
+			ids = append(ids, record.Identifier)
+		}
+		sort.Strings(ids)
+		if strings.Join(ids, ",") != strings.Join(expected, ",") {
+			t.Errorf("%s matched %v, expected %v", value, ids, expected)
+		}
+	}
+}
+
+func TestMemoryCQL2Filter(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	for _, id := range []string{"a", "b", "c"} {
+		record := testRecord(id, "record "+id)
+		record.Properties.Collection = "even"
+		if id == "b" {
+			record.Properties.Collection = "odd"
+		}
+		record.Geometry = metadata.NewPoint(10, 0)
+		m.Insert(ctx, record)
+	}
+
+	tests := map[string][]string{
+		"collection = 'even'":                                          {"a", "c"},
+		"collection <> 'even' OR title LIKE '%c'":                      {"b", "c"},
+		"NOT (title IN ('record a', 'record b'))":                      {"c"},
+		"S_INTERSECTS(geometry, BBOX(5, -5, 15, 5)) AND owner IS NULL": {"a", "b", "c"},
+		"S_DISJOINT(geometry, BBOX(5, -5, 15, 5))":                     nil,
+	}
+	for filter, expected := range tests {
+		e, err := cql2.ParseText(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sr := search.Results{}
+		m.Query(ctx, search.Request{Size: 10, Filter: e}, &sr)
+		var ids []string
+		for _, record := range sr.Records {
+			ids = append(ids, record.Identifier)
+		}
+		sort.Strings(ids)
+		if strings.Join(ids, ",") != strings.Join(expected, ",") {
+			t.Errorf("%s matched %v, expected %v", filter, ids, expected)
+		}
+		facets := search.Results{}
+		if err := m.Facets(ctx, search.Request{Filter: e}, []string{"collection"}, &facets); err != nil {
+			t.Fatal(err)
+		}
+		if facets.Matches != len(expected) {
+			t.Errorf("%s: facets matched %d, expected %d", filter, facets.Matches, len(expected))
+		}
+	}
+}
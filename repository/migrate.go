@@ -0,0 +1,156 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Migration of records between repository backends
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// defaultMigrateBatchSize is the number of records read and written at
+// a time when not set in MigrateOptions
+const defaultMigrateBatchSize = 500
+
+// ErrMigrationMismatch is returned when the records read back from the
+// destination of a migration differ from those read from the source
+var ErrMigrationMismatch = errors.New("migrated records do not match the source")
+
+// MigrateOptions controls a migration between repositories
+type MigrateOptions struct {
+	BatchSize int
+	// DryRun reads and checksums the source without writing anything
+	DryRun bool
+	// Progress, if set, is called after every batch
+	Progress func(migrated int, total int)
+}
+
+// MigrateResult summarizes a migration
+type MigrateResult struct {
+	Records     int
+	Collections int
+	Checksum    string
+}
+
+// recordChecksum is an order-independent checksum of a set of records.
+// The insertion time is left out as backends set it when writing
+// records without one
+type recordChecksum [sha256.Size]byte
+
+func (c *recordChecksum) add(record metadata.Record) error {
+	record.Properties.Geocatalogo.Inserted = time.Time{}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	for i := range c {
+		c[i] ^= sum[i]
+	}
+	return nil
+}
+
+func (c recordChecksum) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+// Migrate copies every record and collection of one repository into
+// another, records in batches, using only the Repository interface so
+// that any pair of backends can be migrated in either direction.  Once written, records
+// are read back from the destination by identifier and their count and
+// checksum verified against the source.  Insertion times are kept
+func Migrate(ctx context.Context, from Repository, to Repository, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	var sourceSum, destSum recordChecksum
+	var identifiers []string
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultMigrateBatchSize
+	}
+
+	req := search.Request{Size: opts.BatchSize}
+	for {
+		sr := search.Results{}
+		if err := from.Query(ctx, req, &sr); err != nil {
+			return result, fmt.Errorf("reading source: %w", err)
+		}
+		for _, record := range sr.Records {
+			if err := sourceSum.add(record); err != nil {
+				return result, err
+			}
+			identifiers = append(identifiers, record.Identifier)
+		}
+		if !opts.DryRun && len(sr.Records) > 0 {
+			if err := to.BulkInsert(ctx, sr.Records); err != nil {
+				return result, fmt.Errorf("writing destination: %w", err)
+			}
+		}
+		result.Records += len(sr.Records)
+		if opts.Progress != nil {
+			opts.Progress(result.Records, sr.Matches)
+		}
+		if len(sr.After) == 0 {
+			break
+		}
+		req.After, req.From = sr.After, sr.NextRecord
+	}
+	result.Checksum = sourceSum.String()
+
+	collections, err := from.ListCollections(ctx)
+	if err != nil {
+		return result, fmt.Errorf("reading source collections: %w", err)
+	}
+	result.Collections = len(collections)
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	for _, collection := range collections {
+		if err := to.InsertCollection(ctx, collection); err != nil {
+			return result, fmt.Errorf("writing destination collection: %w", err)
+		}
+	}
+
+	if flusher, ok := to.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return result, fmt.Errorf("flushing destination: %w", err)
+		}
+	}
+
+	verified := 0
+	for start := 0; start < len(identifiers); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(identifiers) {
+			end = len(identifiers)
+		}
+		sr := search.Results{}
+		if err := to.Get(ctx, identifiers[start:end], &sr); err != nil {
+			return result, fmt.Errorf("verifying destination: %w", err)
+		}
+		for _, record := range sr.Records {
+			if err := destSum.add(record); err != nil {
+				return result, err
+			}
+		}
+		verified += len(sr.Records)
+	}
+	if verified != result.Records {
+		return result, fmt.Errorf("%w: %d of %d records found in destination", ErrMigrationMismatch, verified, result.Records)
+	}
+	if destSum != sourceSum {
+		return result, fmt.Errorf("%w: checksum %s, expected %s", ErrMigrationMismatch, destSum, sourceSum)
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+func TestMigrateMemoryToFileAndBack(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+
+	source := newMemory("memory", testLog)
+	for i := 0; i < 23; i++ {
+		source.Insert(ctx, testRecord(strconv.Itoa(i), "record "+strconv.Itoa(i)))
+	}
+	source.InsertCollection(ctx, metadata.Collection{Identifier: "records", Title: "Records"})
+
+	dir := t.TempDir()
+	dest := openTestFile(t, dir)
+
+	dry, err := Migrate(ctx, source, dest, MigrateOptions{BatchSize: 5, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if dry.Records != 23 || len(dest.mem.Records) != 0 {
+		t.Fatalf("dry run read %d records and wrote %d", dry.Records, len(dest.mem.Records))
+	}
+
+	batches := 0
+	result, err := Migrate(ctx, source, dest, MigrateOptions{
+		BatchSize: 5,
+		Progress:  func(migrated int, total int) { batches++ },
+	})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.Records != 23 || batches != 5 || result.Checksum != dry.Checksum {
+		t.Errorf("unexpected result %+v after %d batches", result, batches)
+	}
+	if collection, err := dest.GetCollection(ctx, "records"); err != nil || result.Collections != 1 || collection.Title != "Records" {
+		t.Errorf("collection was not migrated: %+v (%v)", collection, err)
+	}
+	dest.Close()
+
+	// back into a memory repository persisted to a JSON file
+	var cfg config.Config
+	cfg.Repository.Type = "memory"
+	cfg.Repository.URL = "file://" + filepath.Join(t.TempDir(), "records.json")
+	back, err := OpenMemory(cfg, testLog)
+	if err != nil {
+		t.Fatalf("OpenMemory failed: %v", err)
+	}
+	reopened := openTestFile(t, dir)
+	defer reopened.Close()
+	if result, err = Migrate(ctx, reopened, back, MigrateOptions{}); err != nil || result.Checksum != dry.Checksum {
+		t.Fatalf("migrating back failed: %v (%+v)", err, result)
+	}
+
+	loaded, _ := OpenMemory(cfg, testLog)
+	if len(loaded.Records) != 23 {
+		t.Errorf("expected 23 records written to %s, got %d", cfg.Repository.URL, len(loaded.Records))
+	}
+
+	// insertion times survive both migrations
+	for id, record := range back.Records {
+		if inserted := source.Records[id].Properties.Geocatalogo.Inserted; !record.Properties.Geocatalogo.Inserted.Equal(inserted) {
+			t.Errorf("record %s inserted at %v, expected %v", id, record.Properties.Geocatalogo.Inserted, inserted)
+		}
+	}
+}
@@ -7,17 +7,102 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/config"
 	"github.com/go-spatial/geocatalogo/metadata"
 	"github.com/go-spatial/geocatalogo/search"
 )
 
+// ErrRecordNotFound is returned when an operation targets a record
+// that does not exist in the repository
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrCollectionNotFound is returned when an operation targets a
+// collection that does not exist in the repository
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// BulkFailure describes a record which could not be written
+// during a bulk operation
+type BulkFailure struct {
+	Identifier string
+	Reason     string
+}
+
+// BulkError reports the per record failures of a bulk operation
+type BulkError struct {
+	Failures []BulkFailure
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d record(s) failed to index", len(e.Failures))
+}
+
+// insertionTime returns the time a bulk inserted record is recorded as
+// inserted at: its own insertion time, as when records are copied from
+// another repository, or else now
+func insertionTime(record metadata.Record, now time.Time) time.Time {
+	if inserted := record.Properties.Geocatalogo.Inserted; !inserted.IsZero() {
+		return inserted
+	}
+	return now
+}
+
 // Repository defines the interface that all backend implementations must satisfy
 type Repository interface {
-	Insert(record metadata.Record) error
-	Update() bool
-	Delete() bool
-	Query(collections []string, term string, bbox []float64, timeVal []time.Time, from int, size int, propertyFilters map[string]string, sr *search.Results) error
-	Get(identifiers []string, sr *search.Results) error
+	Insert(ctx context.Context, record metadata.Record) error
+	BulkInsert(ctx context.Context, records []metadata.Record) error
+	Update(ctx context.Context, record metadata.Record) error
+	Delete(ctx context.Context, identifier string) error
+	Query(ctx context.Context, req search.Request, sr *search.Results) error
+	Facets(ctx context.Context, req search.Request, fields []string, sr *search.Results) error
+	Get(ctx context.Context, identifiers []string, sr *search.Results) error
+	CollectionRepository
+}
+
+// CollectionRepository stores the collections records belong to.
+// InsertCollection replaces any collection with the same identifier;
+// ListCollections returns all collections ordered by identifier
+type CollectionRepository interface {
+	InsertCollection(ctx context.Context, collection metadata.Collection) error
+	UpdateCollection(ctx context.Context, collection metadata.Collection) error
+	DeleteCollection(ctx context.Context, identifier string) error
+	GetCollection(ctx context.Context, identifier string) (metadata.Collection, error)
+	ListCollections(ctx context.Context) ([]metadata.Collection, error)
+}
+
+// Flusher is implemented by repositories which can be asked to make all
+// changes durable and visible to searches before returning
+type Flusher interface {
+	Flush() error
+}
+
+// OpenBackend opens the repository backend selected by configuration
+func OpenBackend(cfg config.Config, log *logrus.Logger) (Repository, error) {
+	switch cfg.Repository.Type {
+	case "memory":
+		memRepo, err := OpenMemory(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return memRepo, nil
+	case "file":
+		fileRepo, err := OpenFile(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return fileRepo, nil
+	default:
+		// Default to Elasticsearch
+		esRepo, err := Open(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		return &esRepo, nil
+	}
 }
@@ -0,0 +1,372 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// R-tree spatial index for the in-memory repository backend
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	rtreeMaxEntries = 16
+	rtreeMinEntries = 6
+)
+
+// rtreeRect is a bounding rectangle (minx, miny, maxx, maxy)
+type rtreeRect [4]float64
+
+func (r rtreeRect) intersects(o rtreeRect) bool {
+	return !(o[2] < r[0] || o[0] > r[2] || o[3] < r[1] || o[1] > r[3])
+}
+
+func (r rtreeRect) area() float64 {
+	return (r[2] - r[0]) * (r[3] - r[1])
+}
+
+func (r rtreeRect) union(o rtreeRect) rtreeRect {
+	return rtreeRect{
+		math.Min(r[0], o[0]), math.Min(r[1], o[1]),
+		math.Max(r[2], o[2]), math.Max(r[3], o[3]),
+	}
+}
+
+func (r rtreeRect) enlargement(o rtreeRect) float64 {
+	return r.union(o).area() - r.area()
+}
+
+// rtreeEntry is either a record reference (in leaves) or a child node
+type rtreeEntry struct {
+	rect  rtreeRect
+	child *rtreeNode
+	id    string
+}
+
+// rtreeNode holds the entries of one tree level; leaves are level 0
+type rtreeNode struct {
+	level   int
+	entries []rtreeEntry
+}
+
+func (n *rtreeNode) bounds() rtreeRect {
+	b := n.entries[0].rect
+	for _, e := range n.entries[1:] {
+		b = b.union(e.rect)
+	}
+	return b
+}
+
+// rtree provides a Guttman R-tree with quadratic splits, keyed on
+// record identifiers.  It can also be bulk loaded with
+// Sort-Tile-Recursive packing
+type rtree struct {
+	root *rtreeNode
+	size int
+}
+
+func newRTree() *rtree {
+	return &rtree{root: &rtreeNode{}}
+}
+
+// Len returns the number of entries in the tree
+func (t *rtree) Len() int {
+	return t.size
+}
+
+// Search calls fn with the identifier of every entry intersecting r,
+// stopping early if fn returns false
+func (t *rtree) Search(r rtreeRect, fn func(id string) bool) {
+	if t.size > 0 {
+		searchNode(t.root, r, fn)
+	}
+}
+
+func searchNode(n *rtreeNode, r rtreeRect, fn func(id string) bool) bool {
+	for _, e := range n.entries {
+		if !r.intersects(e.rect) {
+			continue
+		}
+		if n.level == 0 {
+			if !fn(e.id) {
+				return false
+			}
+		} else if !searchNode(e.child, r, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Insert adds an entry for id with bounds r
+func (t *rtree) Insert(id string, r rtreeRect) {
+	t.insert(rtreeEntry{rect: r, id: id}, 0)
+	t.size++
+}
+
+// insert places an entry at the given level, splitting nodes
+// on the way back up as needed
+func (t *rtree) insert(e rtreeEntry, level int) {
+	type step struct {
+		node  *rtreeNode
+		index int
+	}
+	var path []step
+
+	n := t.root
+	for n.level > level {
+		i := chooseSubtree(n, e.rect)
+		path = append(path, step{n, i})
+		n = n.entries[i].child
+	}
+	n.entries = append(n.entries, e)
+
+	var split *rtreeNode
+	if len(n.entries) > rtreeMaxEntries {
+		split = splitNode(n)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		parent := path[i].node
+		parent.entries[path[i].index].rect = n.bounds()
+		if split != nil {
+			parent.entries = append(parent.entries, rtreeEntry{rect: split.bounds(), child: split})
+			split = nil
+			if len(parent.entries) > rtreeMaxEntries {
+				split = splitNode(parent)
+			}
+		}
+		n = parent
+	}
+	if split != nil {
+		t.root = &rtreeNode{
+			level: n.level + 1,
+			entries: []rtreeEntry{
+				{rect: n.bounds(), child: n},
+				{rect: split.bounds(), child: split},
+			},
+		}
+	}
+}
+
+// chooseSubtree picks the entry needing the least enlargement to
+// include r, resolving ties by smallest area
+func chooseSubtree(n *rtreeNode, r rtreeRect) int {
+	best := 0
+	bestEnlargement := math.Inf(1)
+	bestArea := math.Inf(1)
+	for i, e := range n.entries {
+		enlargement := e.rect.enlargement(r)
+		area := e.rect.area()
+		if enlargement < bestEnlargement || (enlargement == bestEnlargement && area < bestArea) {
+			best, bestEnlargement, bestArea = i, enlargement, area
+		}
+	}
+	return best
+}
+
+// splitNode divides an overflowing node using Guttman's quadratic
+// split.  n keeps one group and the other is returned as a new node
+func splitNode(n *rtreeNode) *rtreeNode {
+	entries := n.entries
+
+	// pick the pair of seeds wasting the most area if grouped together
+	seed1, seed2 := 0, 1
+	worst := math.Inf(-1)
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			waste := entries[i].rect.union(entries[j].rect).area() - entries[i].rect.area() - entries[j].rect.area()
+			if waste > worst {
+				seed1, seed2, worst = i, j, waste
+			}
+		}
+	}
+
+	group1 := []rtreeEntry{entries[seed1]}
+	group2 := []rtreeEntry{entries[seed2]}
+	bounds1, bounds2 := entries[seed1].rect, entries[seed2].rect
+
+	remaining := make([]rtreeEntry, 0, len(entries)-2)
+	for i, e := range entries {
+		if i != seed1 && i != seed2 {
+			remaining = append(remaining, e)
+		}
+	}
+
+	for len(remaining) > 0 {
+		// assign everything left if a group needs it to reach the minimum
+		if len(group1)+len(remaining) == rtreeMinEntries {
+			group1 = append(group1, remaining...)
+			break
+		}
+		if len(group2)+len(remaining) == rtreeMinEntries {
+			group2 = append(group2, remaining...)
+			break
+		}
+
+		// pick the entry with the strongest preference for one group
+		next := 0
+		maxDiff := math.Inf(-1)
+		for i, e := range remaining {
+			diff := math.Abs(bounds1.enlargement(e.rect) - bounds2.enlargement(e.rect))
+			if diff > maxDiff {
+				next, maxDiff = i, diff
+			}
+		}
+		e := remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+
+		d1, d2 := bounds1.enlargement(e.rect), bounds2.enlargement(e.rect)
+		toFirst := d1 < d2 ||
+			(d1 == d2 && bounds1.area() < bounds2.area()) ||
+			(d1 == d2 && bounds1.area() == bounds2.area() && len(group1) <= len(group2))
+		if toFirst {
+			group1 = append(group1, e)
+			bounds1 = bounds1.union(e.rect)
+		} else {
+			group2 = append(group2, e)
+			bounds2 = bounds2.union(e.rect)
+		}
+	}
+
+	n.entries = group1
+	return &rtreeNode{level: n.level, entries: group2}
+}
+
+// Delete removes the entry for id with bounds r, reporting whether
+// it was found
+func (t *rtree) Delete(id string, r rtreeRect) bool {
+	type step struct {
+		node  *rtreeNode
+		index int
+	}
+	var path []step
+	var leaf *rtreeNode
+	var leafIndex int
+
+	var find func(n *rtreeNode) bool
+	find = func(n *rtreeNode) bool {
+		for i, e := range n.entries {
+			if n.level == 0 {
+				if e.id == id && e.rect == r {
+					leaf, leafIndex = n, i
+					return true
+				}
+				continue
+			}
+			if !e.rect.intersects(r) {
+				continue
+			}
+			path = append(path, step{n, i})
+			if find(e.child) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	if !find(t.root) {
+		return false
+	}
+
+	leaf.entries = append(leaf.entries[:leafIndex], leaf.entries[leafIndex+1:]...)
+	t.size--
+
+	// condense the tree, collecting the entries of underflowing nodes
+	var orphans []*rtreeNode
+	n := leaf
+	for i := len(path) - 1; i >= 0; i-- {
+		parent, index := path[i].node, path[i].index
+		if len(n.entries) < rtreeMinEntries {
+			parent.entries = append(parent.entries[:index], parent.entries[index+1:]...)
+			orphans = append(orphans, n)
+		} else {
+			parent.entries[index].rect = n.bounds()
+		}
+		n = parent
+	}
+	for t.root.level > 0 && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+	}
+	if len(t.root.entries) == 0 {
+		t.root = &rtreeNode{}
+	}
+
+	// reinsert the records held under removed nodes
+	for _, orphan := range orphans {
+		for _, e := range leafEntries(orphan, nil) {
+			t.insert(e, 0)
+		}
+	}
+	return true
+}
+
+// leafEntries appends the record entries found under n to entries
+func leafEntries(n *rtreeNode, entries []rtreeEntry) []rtreeEntry {
+	if n.level == 0 {
+		return append(entries, n.entries...)
+	}
+	for _, e := range n.entries {
+		entries = leafEntries(e.child, entries)
+	}
+	return entries
+}
+
+// Load replaces the contents of the tree with the given leaf entries,
+// packed bottom-up with the Sort-Tile-Recursive algorithm
+func (t *rtree) Load(entries []rtreeEntry) {
+	t.size = len(entries)
+	if len(entries) == 0 {
+		t.root = &rtreeNode{}
+		return
+	}
+
+	nodes := strPack(entries, 0)
+	for level := 1; len(nodes) > 1; level++ {
+		parents := make([]rtreeEntry, len(nodes))
+		for i, n := range nodes {
+			parents[i] = rtreeEntry{rect: n.bounds(), child: n}
+		}
+		nodes = strPack(parents, level)
+	}
+	t.root = nodes[0]
+}
+
+// strPack groups entries into nodes of the given level by tiling
+// them into vertical slices sorted by x, then runs sorted by y
+func strPack(entries []rtreeEntry, level int) []*rtreeNode {
+	centre := func(e rtreeEntry, axis int) float64 {
+		return (e.rect[axis] + e.rect[axis+2]) / 2
+	}
+
+	nodeCount := int(math.Ceil(float64(len(entries)) / rtreeMaxEntries))
+	sliceCount := int(math.Ceil(math.Sqrt(float64(nodeCount))))
+	sliceSize := sliceCount * rtreeMaxEntries
+
+	sort.Slice(entries, func(i, j int) bool {
+		return centre(entries[i], 0) < centre(entries[j], 0)
+	})
+
+	nodes := make([]*rtreeNode, 0, nodeCount)
+	for start := 0; start < len(entries); start += sliceSize {
+		end := start + sliceSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		slice := entries[start:end]
+		sort.Slice(slice, func(i, j int) bool {
+			return centre(slice[i], 1) < centre(slice[j], 1)
+		})
+		for i := 0; i < len(slice); i += rtreeMaxEntries {
+			j := i + rtreeMaxEntries
+			if j > len(slice) {
+				j = len(slice)
+			}
+			group := make([]rtreeEntry, j-i)
+			copy(group, slice[i:j])
+			nodes = append(nodes, &rtreeNode{level: level, entries: group})
+		}
+	}
+	return nodes
+}
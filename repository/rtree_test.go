@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// randomFootprint returns a footprint of up to 2 degrees square
+func randomFootprint(rnd *rand.Rand) rtreeRect {
+	x := rnd.Float64()*358 - 179
+	y := rnd.Float64()*178 - 89
+	return rtreeRect{x, y, x + rnd.Float64()*2, y + rnd.Float64()*2}
+}
+
+func linearSearch(entries []rtreeEntry, r rtreeRect) []string {
+	ids := []string{}
+	for _, e := range entries {
+		if r.intersects(e.rect) {
+			ids = append(ids, e.id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func treeSearch(t *rtree, r rtreeRect) []string {
+	ids := []string{}
+	t.Search(r, func(id string) bool {
+		ids = append(ids, id)
+		return true
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+func equalIDs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRTreeMatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tree := newRTree()
+	live := map[string]rtreeRect{}
+
+	for i := 0; i < 5000; i++ {
+		id := strconv.Itoa(i)
+		r := randomFootprint(rnd)
+		tree.Insert(id, r)
+		live[id] = r
+	}
+	for i := 0; i < 5000; i += 3 {
+		id := strconv.Itoa(i)
+		if !tree.Delete(id, live[id]) {
+			t.Fatalf("Delete(%s) did not find entry", id)
+		}
+		delete(live, id)
+	}
+	if tree.Len() != len(live) {
+		t.Fatalf("expected %d entries, got %d", len(live), tree.Len())
+	}
+
+	entries := make([]rtreeEntry, 0, len(live))
+	for id, r := range live {
+		entries = append(entries, rtreeEntry{rect: r, id: id})
+	}
+	packed := newRTree()
+	packed.Load(append([]rtreeEntry(nil), entries...))
+
+	for i := 0; i < 200; i++ {
+		q := randomFootprint(rnd)
+		q[2] += 10
+		q[3] += 10
+		want := linearSearch(entries, q)
+		if got := treeSearch(tree, q); !equalIDs(got, want) {
+			t.Fatalf("query %v: tree returned %d ids, want %d", q, len(got), len(want))
+		}
+		if got := treeSearch(packed, q); !equalIDs(got, want) {
+			t.Fatalf("query %v: packed tree returned %d ids, want %d", q, len(got), len(want))
+		}
+	}
+}
+
+var (
+	benchOnce    sync.Once
+	benchEntries []rtreeEntry
+	benchTree    *rtree
+)
+
+// benchmarkFootprints builds 1M synthetic footprints once per run
+func benchmarkFootprints() ([]rtreeEntry, *rtree) {
+	benchOnce.Do(func() {
+		rnd := rand.New(rand.NewSource(42))
+		benchEntries = make([]rtreeEntry, 1000000)
+		for i := range benchEntries {
+			benchEntries[i] = rtreeEntry{rect: randomFootprint(rnd), id: strconv.Itoa(i)}
+		}
+		benchTree = newRTree()
+		benchTree.Load(append([]rtreeEntry(nil), benchEntries...))
+	})
+	return benchEntries, benchTree
+}
+
+var benchQuery = rtreeRect{-75, 40, -70, 45}
+
+func BenchmarkBBoxLinearScan1M(b *testing.B) {
+	entries, _ := benchmarkFootprints()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		for _, e := range entries {
+			if benchQuery.intersects(e.rect) {
+				count++
+			}
+		}
+	}
+}
+
+func BenchmarkBBoxRTree1M(b *testing.B) {
+	_, tree := benchmarkFootprints()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		tree.Search(benchQuery, func(id string) bool {
+			count++
+			return true
+		})
+	}
+}
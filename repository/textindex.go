@@ -0,0 +1,394 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Inverted full-text index for the in-memory repository backend
+// Tokenizes, stems and scores record text with Okapi BM25
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package repository
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopWords are common English words left out of the index
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "no": true, "not": true,
+	"of": true, "on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "were": true, "will": true, "with": true,
+}
+
+// textIndex maps stemmed terms to the records containing them
+type textIndex struct {
+	postings map[string]map[string]int
+	docTerms map[string][]string
+	docLen   map[string]int
+	totalLen int
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: make(map[string]map[string]int),
+		docTerms: make(map[string][]string),
+		docLen:   make(map[string]int),
+	}
+}
+
+// analyze splits text into lowercased, stemmed terms, dropping stop words
+func analyze(text string) []string {
+	terms := []string{}
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if stopWords[token] {
+			continue
+		}
+		terms = append(terms, stem(token))
+	}
+	return terms
+}
+
+// recordText returns the searchable text of a record
+func recordText(record metadata.Record) string {
+	parts := []string{
+		record.Properties.Title,
+		record.Properties.Abstract,
+		record.Properties.Description,
+	}
+	for _, set := range record.Properties.KeywordsSets {
+		parts = append(parts, set.Keyword...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// add indexes a record, replacing any previous entry for it
+func (ix *textIndex) add(record metadata.Record) {
+	id := record.Identifier
+	ix.remove(id)
+
+	terms := analyze(recordText(record))
+	frequencies := make(map[string]int)
+	for _, term := range terms {
+		frequencies[term]++
+	}
+	unique := make([]string, 0, len(frequencies))
+	for term, count := range frequencies {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[string]int)
+		}
+		ix.postings[term][id] = count
+		unique = append(unique, term)
+	}
+	ix.docTerms[id] = unique
+	ix.docLen[id] = len(terms)
+	ix.totalLen += len(terms)
+}
+
+// remove drops a record from the index
+func (ix *textIndex) remove(id string) {
+	terms, ok := ix.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.totalLen -= ix.docLen[id]
+	delete(ix.docTerms, id)
+	delete(ix.docLen, id)
+}
+
+// search returns the BM25 score of every record containing all terms
+// of the query.  A query made up only of stop words matches nothing
+func (ix *textIndex) search(query string) map[string]float64 {
+	scores := make(map[string]float64)
+
+	terms := analyze(query)
+	if len(terms) == 0 || len(ix.docLen) == 0 {
+		return scores
+	}
+
+	docCount := float64(len(ix.docLen))
+	avgLen := float64(ix.totalLen) / docCount
+
+	for i, term := range terms {
+		postings := ix.postings[term]
+		if len(postings) == 0 {
+			return map[string]float64{}
+		}
+		idf := math.Log(1 + (docCount-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+		next := make(map[string]float64)
+		for id, tf := range postings {
+			prev, ok := scores[id]
+			if i > 0 && !ok {
+				continue
+			}
+			norm := float64(tf) + bm25K1*(1-bm25B+bm25B*float64(ix.docLen[id])/avgLen)
+			next[id] = prev + idf*float64(tf)*(bm25K1+1)/norm
+		}
+		scores = next
+	}
+	return scores
+}
+
+// stem reduces an English word to its stem with the Porter algorithm
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+	s := &stemmer{b: []byte(word)}
+	s.step1ab()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+// stemmer holds the word being stemmed; j marks the end of the stem
+// being considered when testing a suffix
+type stemmer struct {
+	b []byte
+	j int
+}
+
+// cons reports whether b[i] is a consonant
+func (s *stemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in b[0:j]
+func (s *stemmer) measure() int {
+	n, i := 0, 0
+	for {
+		if i >= s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i >= s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i >= s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// vowelInStem reports whether b[0:j] contains a vowel
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i < s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleCons reports whether b[i-1:i+1] is a double consonant
+func (s *stemmer) doubleCons(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc reports whether b[i-2:i+1] is consonant-vowel-consonant with the
+// last consonant not w, x or y
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether the word ends with suffix, setting j to the
+// length of the remaining stem
+func (s *stemmer) ends(suffix string) bool {
+	if len(suffix) > len(s.b) || string(s.b[len(s.b)-len(suffix):]) != suffix {
+		return false
+	}
+	s.j = len(s.b) - len(suffix)
+	return true
+}
+
+// setTo replaces the suffix after j
+func (s *stemmer) setTo(suffix string) {
+	s.b = append(s.b[:s.j], suffix...)
+}
+
+// replace replaces the suffix after j when the stem measure is positive
+func (s *stemmer) replace(suffix string) {
+	if s.measure() > 0 {
+		s.setTo(suffix)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing
+func (s *stemmer) step1ab() {
+	if s.b[len(s.b)-1] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.b = s.b[:len(s.b)-2]
+		case s.ends("ies"):
+			s.setTo("i")
+		case len(s.b) > 1 && s.b[len(s.b)-2] != 's':
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+	if s.ends("eed") {
+		if s.measure() > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+		return
+	}
+	if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.b = s.b[:s.j]
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doubleCons(len(s.b) - 1):
+			switch s.b[len(s.b)-1] {
+			case 'l', 's', 'z':
+			default:
+				s.b = s.b[:len(s.b)-1]
+			}
+		default:
+			s.j = len(s.b)
+			if s.measure() == 1 && s.cvc(len(s.b)-1) {
+				s.b = append(s.b, 'e')
+			}
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+// step2 maps double suffixes to single ones
+func (s *stemmer) step2() {
+	for _, suffix := range step2Suffixes {
+		if s.ends(suffix[0]) {
+			s.replace(suffix[1])
+			return
+		}
+	}
+}
+
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// step3 deals with -ic-, -full, -ness and similar
+func (s *stemmer) step3() {
+	for _, suffix := range step3Suffixes {
+		if s.ends(suffix[0]) {
+			s.replace(suffix[1])
+			return
+		}
+	}
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// step4 removes -ant, -ence and similar when the stem measure exceeds one
+func (s *stemmer) step4() {
+	for _, suffix := range step4Suffixes {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j == 0 || (s.b[s.j-1] != 's' && s.b[s.j-1] != 't')) {
+			return
+		}
+		if s.measure() > 1 {
+			s.b = s.b[:s.j]
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and reduces -ll when the stem measure exceeds one
+func (s *stemmer) step5() {
+	s.j = len(s.b)
+	if s.b[len(s.b)-1] == 'e' {
+		s.j = len(s.b) - 1
+		m := s.measure()
+		if m > 1 || (m == 1 && !s.cvc(len(s.b)-2)) {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+	s.j = len(s.b)
+	if s.b[len(s.b)-1] == 'l' && s.doubleCons(len(s.b)-1) && s.measure() > 1 {
+		s.b = s.b[:len(s.b)-1]
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+func TestMemoryRanksTermMatches(t *testing.T) {
+	ctx := context.Background()
+	testLog := logrus.New()
+	testLog.Out = ioutil.Discard
+	m := newMemory("memory", testLog)
+
+	weak := testRecord("weak", "Coastal imagery")
+	weak.Properties.Abstract = "Imagery collected after the floods of 2019 along the coast"
+	m.Insert(ctx, weak)
+	m.Insert(ctx, testRecord("strong", "Flooding extent: flood depth"))
+	m.Insert(ctx, testRecord("other", "Forest cover"))
+
+	sr := search.Results{}
+	m.Query(ctx, search.Request{Term: "flooded", Size: 10}, &sr)
+	if sr.Matches != 2 {
+		t.Fatalf("expected 2 matches, got %d", sr.Matches)
+	}
+	if sr.Records[0].Identifier != "strong" || sr.Records[1].Identifier != "weak" {
+		t.Errorf("unexpected ranking: %s, %s", sr.Records[0].Identifier, sr.Records[1].Identifier)
+	}
+	if len(sr.Scores) != 2 || sr.Scores[0] <= sr.Scores[1] {
+		t.Errorf("expected descending scores, got %v", sr.Scores)
+	}
+
+	sr = search.Results{}
+	m.Query(ctx, search.Request{Term: "of the", Size: 10}, &sr)
+	if sr.Matches != 0 {
+		t.Errorf("expected stop words to match nothing, got %d", sr.Matches)
+	}
+}
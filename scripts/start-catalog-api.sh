@@ -9,6 +9,8 @@ cd /Users/jjohnson/projects/geocatalogo
 # Set environment variables for GRO catalog
 export GEOCATALOGO_REPOSITORY_TYPE=memory
 export GEOCATALOGO_REPOSITORY_URL="file://$HOME/projects/geosure/catalog/data/geocatalogo_records.json"
+# reload the records when the catalog data is regenerated
+export GEOCATALOGO_REPOSITORY_WATCH_INTERVAL=5s
 export GEOCATALOGO_SERVER_URL=http://localhost:9000
 export GEOCATALOGO_LOGGING_LEVEL=INFO
 export GEOCATALOGO_METADATA_IDENTIFICATION_TITLE="GRO Geospatial Data Catalog (Port 9000)"
@@ -6,6 +6,21 @@ set -e
 
 cd /Users/jjohnson/projects/geocatalogo
 
+# Collection descriptions are read from the records of the catalog API
+# (the GEOCATALOGO_REPOSITORY_URL of start-catalog-api.sh)
+: "${GEOCATALOGO_REPOSITORY_URL:?set GEOCATALOGO_REPOSITORY_URL to the records file of the catalog API}"
+export GEOCATALOGO_REPOSITORY_TYPE=memory
+export GEOCATALOGO_REPOSITORY_URL
+# pick up collections imported while running
+export GEOCATALOGO_REPOSITORY_WATCH_INTERVAL=5s
+
+# Import the GRO collection descriptions when none are stored yet
+records="${GEOCATALOGO_REPOSITORY_URL#file://}"
+if [ ! -f "${records%.*}.collections.json" ]; then
+    echo "Importing collections from scripts/gro-collections.json..."
+    ./cmd/geocatalogo/geocatalogo collection -file scripts/gro-collections.json
+fi
+
 echo "Starting Catalog UI..."
 echo "Working directory: $(pwd)"
 echo "Port: 3000"
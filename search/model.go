@@ -26,6 +26,9 @@
 package search
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/go-spatial/geocatalogo/metadata"
 )
 
@@ -36,6 +39,86 @@ type Results struct {
 	Returned    int
 	NextRecord  int
 	Records     []metadata.Record
+	// Scores holds the relevance score of each record for term searches
+	Scores []float64 `json:",omitempty"`
+	// After holds the sort values of the last record returned when
+	// more records remain, for resuming the search with Request.After
+	After []interface{} `json:"-"`
+	// NextToken is an opaque token for fetching the next page
+	NextToken string `json:",omitempty"`
+	// Facets holds the number of matching records per value of each
+	// requested facet field
+	Facets map[string]map[string]int `json:",omitempty"`
+}
+
+// recordKeys are the top level keys of a JSON encoded record
+var recordKeys = map[string]bool{
+	"id": true, "type": true, "bbox": true, "geometry": true,
+	"properties": true, "links": true, "assets": true,
+}
+
+// Project reduces each record to the given fields.  Fields are JSON
+// paths separated by dots; names which are not top level record keys
+// are taken as properties (title is properties.title).  The record id
+// and type are always kept
+func (r *Results) Project(fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	for i, record := range r.Records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		var source map[string]interface{}
+		if err := json.Unmarshal(data, &source); err != nil {
+			continue
+		}
+
+		projected := map[string]interface{}{
+			"id":   source["id"],
+			"type": source["type"],
+		}
+		for _, field := range fields {
+			path := strings.Split(strings.TrimSpace(field), ".")
+			if !recordKeys[path[0]] {
+				path = append([]string{"properties"}, path...)
+			}
+			copyPath(source, projected, path)
+		}
+
+		data, err = json.Marshal(projected)
+		if err != nil {
+			continue
+		}
+		var projectedRecord metadata.Record
+		if err := json.Unmarshal(data, &projectedRecord); err == nil {
+			r.Records[i] = projectedRecord
+		}
+	}
+}
+
+// copyPath copies the value at path from src into dst, creating
+// intermediate objects as needed
+func copyPath(src map[string]interface{}, dst map[string]interface{}, path []string) {
+	value, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = value
+		return
+	}
+	child, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	next, ok := dst[path[0]].(map[string]interface{})
+	if !ok {
+		next = make(map[string]interface{})
+		dst[path[0]] = next
+	}
+	copyPath(child, next, path[1:])
 }
 
 // Exception provides the error messaging structure
@@ -0,0 +1,349 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Structured search requests
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/cql2"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/spatial"
+)
+
+// Spatial relations supported by SpatialFilter
+const (
+	RelationIntersects = "intersects"
+	RelationWithin     = "within"
+	RelationContains   = "contains"
+	RelationDisjoint   = "disjoint"
+)
+
+// Relations lists the spatial relations supported by SpatialFilter
+var Relations = []string{
+	RelationIntersects, RelationWithin, RelationContains, RelationDisjoint,
+}
+
+// PropertyFilterKeys lists the record properties which can be used as
+// exact or partial match filters (see PROPERTY_FILTERS.md)
+var PropertyFilterKeys = []string{
+	"collection", "type", "title", "owner",
+	"continent", "country", "state", "state_province", "city", "admin2", "county",
+	"data_format", "implementation_status", "status", "geographic_scope",
+	"database_table", "v6_job_file", "v6_job_type", "s3_path",
+}
+
+// SortFields lists the record properties results can be sorted on
+var SortFields = []string{
+	"title", "datetime", "modified", "inserted", "collection", "cloud_cover",
+}
+
+// FacetFields lists the record properties whose values can be counted
+var FacetFields = []string{
+	"collection", "type", "owner", "continent", "country", "state", "city",
+	"admin2", "data_format", "status", "geographic_scope",
+}
+
+// PivotFacet names a facet counting the combinations of values of two
+// FacetFields, such as the countries of each continent.  Its values are
+// those of both fields joined by a slash
+func PivotFacet(outer string, inner string) string {
+	return outer + "/" + inner
+}
+
+// SplitPivot returns the fields of a facet named by PivotFacet.  It
+// reports false for a facet on a single field
+func SplitPivot(facet string) (string, string, bool) {
+	fields := strings.SplitN(facet, "/", 2)
+	if len(fields) != 2 {
+		return facet, "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// facetAliases maps alternative facet names to FacetFields
+var facetAliases = map[string]string{
+	"state_province":        "state",
+	"county":                "admin2",
+	"implementation_status": "status",
+	"format":                "data_format",
+}
+
+// SpatialFilter restricts results to records whose geometry has the
+// given relation (intersects by default) to Geometry, or to BBox
+// (minx, miny, maxx, maxy) when no geometry is given.  Relations are
+// those of the record to the filter: within matches records inside the
+// filter geometry, contains records enclosing it
+type SpatialFilter struct {
+	BBox     []float64
+	Geometry metadata.Geometry
+	Relation string
+}
+
+// Shape returns the geometry a spatial filter tests records against,
+// which is empty when neither a geometry nor a bbox is set.  The shape
+// is normalized, so a bbox with minx greater than maxx crosses the
+// antimeridian
+func (sf *SpatialFilter) Shape() metadata.Geometry {
+	if !sf.Geometry.IsEmpty() {
+		return spatial.Normalize(sf.Geometry)
+	}
+	if len(sf.BBox) == 4 {
+		return metadata.NewBBoxPolygon(spatial.NormalizeBBox([4]float64{sf.BBox[0], sf.BBox[1], sf.BBox[2], sf.BBox[3]}))
+	}
+	return metadata.Geometry{}
+}
+
+// TemporalFilter restricts results to records whose datetime falls in
+// the interval Start to End, or whose temporal extent intersects it.
+// A zero Start or End leaves that end of the interval open, and an
+// instant has equal Start and End
+type TemporalFilter struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Matches reports whether the datetime or the temporal extent of a
+// record intersects the filter.  An extent without a begin (or end)
+// is open at that end; records with neither never match
+func (tf *TemporalFilter) Matches(record metadata.Record) bool {
+	p := record.Properties
+	if p.Datetime != nil && tf.intersects(p.Datetime, p.Datetime) {
+		return true
+	}
+	if e := p.TemporalExtent; e != nil && (e.Begin != nil || e.End != nil) {
+		return tf.intersects(e.Begin, e.End)
+	}
+	return false
+}
+
+// intersects reports whether the interval begin to end, open where
+// nil, intersects the filter
+func (tf *TemporalFilter) intersects(begin *time.Time, end *time.Time) bool {
+	if begin != nil && !tf.End.IsZero() && begin.After(tf.End) {
+		return false
+	}
+	if end != nil && !tf.Start.IsZero() && end.Before(tf.Start) {
+		return false
+	}
+	return true
+}
+
+// SortField orders results by a record property (one of SortFields).
+// Ties are always broken by record identifier, so a given sort returns
+// records in the same order every time
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Request describes a search against the repository
+type Request struct {
+	// Collections restricts results to any of the given collections
+	Collections []string
+	// Term is a full-text query
+	Term string
+	// Filters are property filters, keyed by PropertyFilterKeys
+	Filters  map[string]string
+	Spatial  *SpatialFilter
+	Temporal *TemporalFilter
+	// Filter is a CQL2 filter over record properties
+	Filter cql2.Expr
+	Sort   []SortField
+	// From is the position of the first record to return.  When After
+	// is set records are located by the cursor instead, and From only
+	// numbers them
+	From int
+	Size int
+	// After resumes a search following the record with these sort
+	// values, as returned in Results.After
+	After []interface{}
+	// Fields limits the record properties returned (e.g. title,
+	// properties.gro_metadata.country); all are returned when empty
+	Fields []string
+	// Facets lists the properties (FacetFields) to count values of
+	// across all matches
+	Facets []string
+}
+
+// ParseFilters extracts property filters from request parameters,
+// keyed by lowercase parameter name
+func ParseFilters(kvp map[string][]string) map[string]string {
+	filters := make(map[string]string)
+	for _, key := range PropertyFilterKeys {
+		if value, ok := kvp[key]; ok && len(value) > 0 && value[0] != "" {
+			filters[key] = value[0]
+		}
+	}
+	return filters
+}
+
+// ParseTemporalFilter parses a STAC/OGC datetime: an RFC 3339 instant
+// or an interval start/end, either end of which may be open (.. or
+// empty).  A date without a time stands for the whole of that day (UTC).
+// It returns nil when no datetime is given
+func ParseTemporalFilter(value string) (*TemporalFilter, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	tokens := strings.Split(value, "/")
+	if len(tokens) > 2 {
+		return nil, fmt.Errorf("time format error (should be an instant or start/end)")
+	}
+	if len(tokens) == 1 {
+		start, end, err := parseTime(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		return &TemporalFilter{Start: start, End: end}, nil
+	}
+
+	tf := &TemporalFilter{}
+	if token := strings.TrimSpace(tokens[0]); token != "" && token != ".." {
+		start, _, err := parseTime(token)
+		if err != nil {
+			return nil, err
+		}
+		tf.Start = start
+	}
+	if token := strings.TrimSpace(tokens[1]); token != "" && token != ".." {
+		_, end, err := parseTime(token)
+		if err != nil {
+			return nil, err
+		}
+		tf.End = end
+	}
+	if tf.Start.IsZero() && tf.End.IsZero() {
+		return nil, fmt.Errorf("time format error (at most one end of an interval may be open)")
+	}
+	if !tf.Start.IsZero() && !tf.End.IsZero() && tf.Start.After(tf.End) {
+		return nil, fmt.Errorf("time format error (start is after end)")
+	}
+	return tf, nil
+}
+
+// parseTime parses an RFC 3339 date-time, returning it as both the
+// start and end of the instant, or a date, returning the start and end
+// of that day
+func parseTime(value string) (time.Time, time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(value)); err == nil {
+		return t, t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("time format error: %s (should be ISO 8601/RFC3339)", value)
+}
+
+// ParseBBox parses a comma separated minx,miny,maxx,maxy bounding box
+func ParseBBox(value string) ([]float64, error) {
+	tokens := strings.Split(value, ",")
+	if len(tokens) != 4 {
+		return nil, fmt.Errorf("bbox format error (should be minx,miny,maxx,maxy)")
+	}
+	bbox := make([]float64, 4)
+	for i, token := range tokens {
+		f, err := strconv.ParseFloat(strings.TrimSpace(token), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bbox format error: %s is not a number", token)
+		}
+		bbox[i] = f
+	}
+	return bbox, nil
+}
+
+// ParseRelation validates a spatial relation, returning intersects
+// when none is given
+func ParseRelation(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return RelationIntersects, nil
+	}
+	for _, relation := range Relations {
+		if value == relation {
+			return relation, nil
+		}
+	}
+	return "", fmt.Errorf("unknown spatial relation %s (should be one of %s)", value, strings.Join(Relations, ", "))
+}
+
+// ParseSort parses a comma separated list of sort keys.  Each key is a
+// property name, optionally prefixed with + (ascending) or - (descending)
+// or suffixed with :A or :D.  Property names may be given with their
+// properties. or product_info. prefix
+func ParseSort(value string) ([]SortField, error) {
+	var fields []SortField
+
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		sf := SortField{}
+		switch {
+		case strings.HasPrefix(key, "-"):
+			sf.Descending = true
+			key = key[1:]
+		case strings.HasPrefix(key, "+"):
+			key = key[1:]
+		}
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			switch strings.ToUpper(key[i+1:]) {
+			case "A", "ASC":
+			case "D", "DESC":
+				sf.Descending = true
+			default:
+				return nil, fmt.Errorf("invalid sort order in %s (should be A or D)", key)
+			}
+			key = key[:i]
+		}
+		key = strings.TrimPrefix(key, "properties.")
+		key = strings.TrimPrefix(key, "product_info.")
+
+		for _, name := range SortFields {
+			if key == name {
+				sf.Field = name
+			}
+		}
+		if sf.Field == "" {
+			return nil, fmt.Errorf("cannot sort on %s (should be one of %s)", key, strings.Join(SortFields, ", "))
+		}
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
+
+// ParseFacets parses a comma separated list of facet fields
+func ParseFacets(value string) ([]string, error) {
+	var facets []string
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if alias, ok := facetAliases[name]; ok {
+			name = alias
+		}
+		found := false
+		for _, field := range FacetFields {
+			if name == field {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cannot facet on %s (should be one of %s)", name, strings.Join(FacetFields, ", "))
+		}
+		facets = append(facets, name)
+	}
+	return facets, nil
+}
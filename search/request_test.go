@@ -0,0 +1,45 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTemporalFilter(t *testing.T) {
+	t1 := time.Date(2018, 1, 19, 18, 28, 2, 0, time.UTC)
+	t2 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2018, 1, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value      string
+		start, end time.Time
+	}{
+		{"2018-01-19T18:28:02Z", t1, t1},
+		{"2018-01-19t18:28:02z", t1, t1},
+		{"2018-01-19", day, day.AddDate(0, 0, 1).Add(-time.Nanosecond)},
+		{"2018-01-19T18:28:02Z/2019-01-01T00:00:00Z", t1, t2},
+		{"../2019-01-01T00:00:00Z", time.Time{}, t2},
+		{"/2019-01-01T00:00:00Z", time.Time{}, t2},
+		{"2018-01-19T18:28:02Z/..", t1, time.Time{}},
+		{"2018-01-19/2018-01-19", day, day.AddDate(0, 0, 1).Add(-time.Nanosecond)},
+	}
+	for _, test := range tests {
+		tf, err := ParseTemporalFilter(test.value)
+		if err != nil {
+			t.Errorf("%s: %v", test.value, err)
+			continue
+		}
+		if !tf.Start.Equal(test.start) || !tf.End.Equal(test.end) {
+			t.Errorf("%s parsed as %v/%v", test.value, tf.Start, tf.End)
+		}
+	}
+
+	for _, value := range []string{"../..", "/", "yesterday", "2019-01-01T00:00:00Z/2018-01-01T00:00:00Z", "a/b/c"} {
+		if _, err := ParseTemporalFilter(value); err == nil {
+			t.Errorf("expected %q to fail", value)
+		}
+	}
+	if tf, err := ParseTemporalFilter(""); tf != nil || err != nil {
+		t.Errorf("expected no filter for an empty datetime")
+	}
+}
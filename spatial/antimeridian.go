@@ -0,0 +1,361 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Antimeridian and polar normalization of geometries
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package spatial
+
+import (
+	"math"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// NormalizeBBox wraps the longitudes of a bounding box into -180 to 180
+// and clamps its latitudes to -90 to 90.  A bounding box extending past
+// the antimeridian, e.g. 170 to 190, crosses it (170 to -170) and one
+// spanning 360 degrees or more covers all longitudes
+func NormalizeBBox(bbox [4]float64) [4]float64 {
+	minx, maxx := bbox[0], bbox[2]
+	if maxx-minx >= 360 {
+		minx, maxx = -180, 180
+	} else if minx < -180 || minx > 180 || maxx < -180 || maxx > 180 {
+		minx, maxx = wrapLongitude(minx), wrapLongitude(maxx)
+	}
+	return [4]float64{minx, clampLatitude(bbox[1]), maxx, clampLatitude(bbox[3])}
+}
+
+// Normalize brings a geometry into -180 to 180 longitude and -90 to 90
+// latitude.  Positions are taken literally, as GeoJSON has them, so a
+// line from -100 to 100 runs across the prime meridian, not the
+// antimeridian.  Lines and polygons given with longitudes beyond 180
+// (e.g. 178 to 182) are split at the antimeridian into multi-part
+// geometries, as GeoJSON recommends; others are left whole.  Polygons
+// whose exterior ring circles a pole, running once round the globe and
+// back along the antimeridian, are closed over it
+func Normalize(g metadata.Geometry) metadata.Geometry {
+	switch g.Type {
+	case metadata.GeometryPoint:
+		if len(g.Point) >= 2 {
+			g.Point = normalizePosition(g.Point)
+		}
+	case metadata.GeometryMultiPoint:
+		points := make([]metadata.Position, len(g.MultiPoint))
+		for i, p := range g.MultiPoint {
+			points[i] = normalizePosition(p)
+		}
+		g.MultiPoint = points
+	case metadata.GeometryLineString:
+		return lineGeometry(splitLine(g.LineString))
+	case metadata.GeometryMultiLineString:
+		var lines [][]metadata.Position
+		for _, line := range g.MultiLineString {
+			lines = append(lines, splitLine(line)...)
+		}
+		return metadata.Geometry{Type: metadata.GeometryMultiLineString, MultiLineString: lines}
+	case metadata.GeometryPolygon:
+		return polygonGeometry(splitPolygon(g.Polygon))
+	case metadata.GeometryMultiPolygon:
+		var polygons [][][]metadata.Position
+		for _, polygon := range g.MultiPolygon {
+			polygons = append(polygons, splitPolygon(polygon)...)
+		}
+		return metadata.Geometry{Type: metadata.GeometryMultiPolygon, MultiPolygon: polygons}
+	case metadata.GeometryGeometryCollection:
+		members := make([]metadata.Geometry, len(g.Geometries))
+		for i, member := range g.Geometries {
+			members[i] = Normalize(member)
+		}
+		g.Geometries = members
+	}
+	return g
+}
+
+// NormalizeRecord normalizes the geometry of a record, or its bounding
+// box when it has no geometry, and updates its bounding box
+func NormalizeRecord(record *metadata.Record) {
+	if !record.Geometry.IsEmpty() {
+		record.SetGeometry(Normalize(record.Geometry))
+		return
+	}
+	if record.BoundingBox != nil {
+		bbox := NormalizeBBox(*record.BoundingBox)
+		record.BoundingBox = &bbox
+	}
+}
+
+// lineGeometry returns a LineString, or a MultiLineString when a line
+// was split
+func lineGeometry(lines [][]metadata.Position) metadata.Geometry {
+	if len(lines) == 1 {
+		return metadata.Geometry{Type: metadata.GeometryLineString, LineString: lines[0]}
+	}
+	return metadata.Geometry{Type: metadata.GeometryMultiLineString, MultiLineString: lines}
+}
+
+// polygonGeometry returns a Polygon, or a MultiPolygon when a polygon
+// was split
+func polygonGeometry(polygons [][][]metadata.Position) metadata.Geometry {
+	if len(polygons) == 1 {
+		return metadata.Geometry{Type: metadata.GeometryPolygon, Polygon: polygons[0]}
+	}
+	return metadata.Geometry{Type: metadata.GeometryMultiPolygon, MultiPolygon: polygons}
+}
+
+// wrapLongitude brings a longitude into -180 to 180
+func wrapLongitude(x float64) float64 {
+	if x >= -180 && x <= 180 {
+		return x
+	}
+	x = math.Mod(x+180, 360)
+	if x < 0 {
+		x += 360
+	}
+	return x - 180
+}
+
+func clampLatitude(y float64) float64 {
+	return math.Max(-90, math.Min(90, y))
+}
+
+// normalizePosition wraps and clamps a position, keeping any elevation
+func normalizePosition(p metadata.Position) metadata.Position {
+	q := append(metadata.Position{}, p...)
+	q[0], q[1] = wrapLongitude(p[0]), clampLatitude(p[1])
+	return q
+}
+
+// inRange reports whether all longitudes of some positions lie within
+// -180 to 180
+func inRange(positions []metadata.Position) bool {
+	for _, p := range positions {
+		if p[0] < -180 || p[0] > 180 {
+			return false
+		}
+	}
+	return true
+}
+
+// isJump reports whether the edge from a to b is a jump from one side of
+// the antimeridian to the other, joining two positions on the same
+// meridian
+func isJump(a metadata.Position, b metadata.Position) bool {
+	return math.Abs(a[0]) == 180 && b[0] == -a[0]
+}
+
+// winding returns the longitude a ring runs round the globe: 360 (or
+// -360) for a ring circling a pole, which closes by jumping back across
+// the antimeridian, and 0 for others, such as a world-wide bbox whose
+// jumps cancel out
+func winding(ring []metadata.Position) float64 {
+	w := 0.0
+	for i := 1; i < len(ring); i++ {
+		if isJump(ring[i-1], ring[i]) {
+			w -= ring[i][0] - ring[i-1][0]
+		}
+	}
+	return w
+}
+
+// closeJumps copies positions, shifting longitudes by 360 after each
+// jump across the antimeridian so that the jumps have no length
+func closeJumps(positions []metadata.Position) []metadata.Position {
+	out := make([]metadata.Position, len(positions))
+	offset := 0.0
+	for i, p := range positions {
+		if i > 0 && isJump(positions[i-1], p) {
+			offset -= p[0] - positions[i-1][0]
+		}
+		q := append(metadata.Position{}, p...)
+		q[0] += offset
+		out[i] = q
+	}
+	return out
+}
+
+// shift copies positions, moving them east by dx degrees
+func shift(positions []metadata.Position, dx float64) []metadata.Position {
+	out := make([]metadata.Position, len(positions))
+	for i, p := range positions {
+		q := append(metadata.Position{}, p...)
+		q[0] += dx
+		q[1] = clampLatitude(q[1])
+		out[i] = q
+	}
+	return out
+}
+
+// windows returns the offsets of the 360 degree windows, centred on
+// multiples of 360, which the longitudes minx to maxx fall in
+func windows(minx float64, maxx float64) []float64 {
+	var offsets []float64
+	for k := math.Floor((minx + 180) / 360); k*360-180 < maxx; k++ {
+		offsets = append(offsets, k*360)
+	}
+	if len(offsets) == 0 {
+		offsets = append(offsets, math.Floor((minx+180)/360)*360)
+	}
+	return offsets
+}
+
+func longitudeRange(positions []metadata.Position) (float64, float64) {
+	minx, maxx := math.Inf(1), math.Inf(-1)
+	for _, p := range positions {
+		minx = math.Min(minx, p[0])
+		maxx = math.Max(maxx, p[0])
+	}
+	return minx, maxx
+}
+
+// splitLine cuts a line extending beyond -180 to 180 where it crosses
+// the antimeridian
+func splitLine(line []metadata.Position) [][]metadata.Position {
+	if len(line) == 0 {
+		return nil
+	}
+	if inRange(line) {
+		return [][]metadata.Position{shift(line, 0)}
+	}
+
+	var parts [][]metadata.Position
+	current := []metadata.Position{line[0]}
+	for i := 1; i < len(line); i++ {
+		a, b := line[i-1], line[i]
+		lo, hi := math.Min(a[0], b[0]), math.Max(a[0], b[0])
+		for _, offset := range windows(lo, hi) {
+			edge := offset + 180
+			if a[0] > b[0] {
+				edge = offset - 180
+			}
+			if edge <= lo || edge >= hi {
+				continue
+			}
+			t := (edge - a[0]) / (b[0] - a[0])
+			cut := metadata.Position{edge, a[1] + t*(b[1]-a[1])}
+			parts = append(parts, append(current, cut))
+			current = []metadata.Position{cut}
+		}
+		current = append(current, b)
+	}
+	parts = append(parts, current)
+
+	// move each part into -180 to 180
+	for i, part := range parts {
+		minx, maxx := longitudeRange(part)
+		offset := math.Floor(((minx+maxx)/2+180)/360) * 360
+		parts[i] = shift(part, -offset)
+	}
+	return parts
+}
+
+// splitPolygon clips a polygon extending beyond -180 to 180 into a part
+// for each 360 degree window it extends into, after closing a ring
+// circling a pole over it
+func splitPolygon(polygon [][]metadata.Position) [][][]metadata.Position {
+	if len(polygon) == 0 || len(polygon[0]) < 3 {
+		return [][][]metadata.Position{polygon}
+	}
+
+	exterior := polygon[0]
+	if winding(exterior) == 0 {
+		whole := true
+		for _, ring := range polygon {
+			whole = whole && inRange(ring)
+		}
+		if whole {
+			rings := make([][]metadata.Position, len(polygon))
+			for i, ring := range polygon {
+				rings[i] = shift(ring, 0)
+			}
+			return [][][]metadata.Position{rings}
+		}
+	} else {
+		// the ring circles a pole: close it along the pole's latitude
+		exterior = closeJumps(exterior)
+		first, last := exterior[0], exterior[len(exterior)-1]
+		pole := 90.0
+		if meanLatitude(exterior) < 0 {
+			pole = -90
+		}
+		exterior = append(exterior,
+			metadata.Position{last[0], pole},
+			metadata.Position{first[0], pole},
+			metadata.Position{first[0], first[1]})
+	}
+	minx, maxx := longitudeRange(exterior)
+
+	rings := [][]metadata.Position{exterior}
+	centre := (minx + maxx) / 2
+	for _, hole := range polygon[1:] {
+		if len(hole) > 0 {
+			hx, _ := longitudeRange(hole)
+			hole = shift(hole, 360*math.Round((centre-hx)/360))
+		}
+		rings = append(rings, hole)
+	}
+
+	var parts [][][]metadata.Position
+	for _, offset := range windows(minx, maxx) {
+		var part [][]metadata.Position
+		for i, ring := range rings {
+			clipped := clipRing(ring, offset-180, offset+180)
+			if len(clipped) < 4 {
+				if i == 0 {
+					break
+				}
+				continue
+			}
+			part = append(part, shift(clipped, -offset))
+		}
+		if len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func meanLatitude(positions []metadata.Position) float64 {
+	sum := 0.0
+	for _, p := range positions {
+		sum += p[1]
+	}
+	return sum / float64(len(positions))
+}
+
+// clipRing clips a closed ring to the longitudes minx to maxx
+// (Sutherland-Hodgman), returning a closed ring
+func clipRing(ring []metadata.Position, minx float64, maxx float64) []metadata.Position {
+	clipped := clipHalf(ring, minx, true)
+	clipped = clipHalf(clipped, maxx, false)
+	if len(clipped) == 0 {
+		return nil
+	}
+	first, last := clipped[0], clipped[len(clipped)-1]
+	if first[0] != last[0] || first[1] != last[1] {
+		clipped = append(clipped, first)
+	}
+	return clipped
+}
+
+// clipHalf keeps the part of a ring east (or west) of a longitude
+func clipHalf(ring []metadata.Position, x float64, east bool) []metadata.Position {
+	inside := func(p metadata.Position) bool {
+		if east {
+			return p[0] >= x
+		}
+		return p[0] <= x
+	}
+	var out []metadata.Position
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		if inside(a) {
+			out = append(out, a)
+		}
+		if inside(a) != inside(b) {
+			t := (x - a[0]) / (b[0] - a[0])
+			out = append(out, metadata.Position{x, a[1] + t*(b[1]-a[1])})
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package spatial
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+func TestNormalizeBBox(t *testing.T) {
+	tests := []struct {
+		bbox, expected [4]float64
+	}{
+		{[4]float64{-10, -10, 10, 10}, [4]float64{-10, -10, 10, 10}},
+		{[4]float64{170, -20, 190, -10}, [4]float64{170, -20, -170, -10}},
+		{[4]float64{-190, 50, -170, 60}, [4]float64{170, 50, -170, 60}},
+		{[4]float64{175, -20, -175, -10}, [4]float64{175, -20, -175, -10}},
+		{[4]float64{-200, -95, 200, 95}, [4]float64{-180, -90, 180, 90}},
+	}
+	for _, test := range tests {
+		if got := NormalizeBBox(test.bbox); got != test.expected {
+			t.Errorf("NormalizeBBox(%v) = %v, expected %v", test.bbox, got, test.expected)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"POINT (190 95)": `{"type":"Point","coordinates":[-170,90]}`,
+		"POLYGON ((0 0, 0 10, 10 10, 10 0, 0 0))":                 `{"type":"Polygon","coordinates":[[[0,0],[0,10],[10,10],[10,0],[0,0]]]}`,
+		"LINESTRING (170 0, -170 10)":                             `{"type":"LineString","coordinates":[[170,0],[-170,10]]}`,
+		"LINESTRING (-100 40, 100 40)":                            `{"type":"LineString","coordinates":[[-100,40],[100,40]]}`,
+		"LINESTRING (170 0, 190 10)":                              `{"type":"MultiLineString","coordinates":[[[170,0],[180,5]],[[-180,5],[-170,10]]]}`,
+		"POLYGON ((177 -20, 177 -15, 182 -15, 182 -20, 177 -20))": `{"type":"MultiPolygon","coordinates":[[[[177,-20],[177,-15],[180,-15],[180,-20],[177,-20]]],[[[-180,-15],[-178,-15],[-178,-20],[-180,-20],[-180,-15]]]]}`,
+	}
+	for wkt, expected := range tests {
+		g := Normalize(mustWKT(t, wkt))
+		if out, _ := json.Marshal(g); string(out) != expected {
+			t.Errorf("%s normalized to %s", wkt, out)
+		}
+	}
+
+	// a bbox crossing the antimeridian (Fiji) covers both sides of it
+	fiji := Normalize(metadata.NewBBoxPolygon([4]float64{177, -20, -178, -15}))
+	bbox, _ := fiji.Bounds()
+	if fiji.Type != metadata.GeometryMultiPolygon || bbox != [4]float64{177, -20, -178, -15} {
+		t.Errorf("unexpected Fiji geometry %s with bbox %v", fiji.Type, bbox)
+	}
+	if !Intersects(fiji, metadata.NewPoint(179, -17)) || !Intersects(fiji, metadata.NewPoint(-179, -17)) ||
+		Intersects(fiji, metadata.NewPoint(0, -17)) {
+		t.Error("expected the Fiji geometry to cover only both sides of the antimeridian")
+	}
+
+	// extents wider than 180 degrees, up to the whole world, are kept
+	// whole rather than taken to cross the antimeridian
+	for _, extent := range [][4]float64{{-180, -90, 180, 0}, {-10, 35, 180, 80}, {-120, -60, 100, 60}} {
+		wide := Normalize(metadata.NewBBoxPolygon(extent))
+		if bbox, _ := wide.Bounds(); wide.Type != metadata.GeometryPolygon || bbox != extent {
+			t.Errorf("bbox %v normalized to %s %v", extent, wide.Type, bbox)
+		}
+	}
+	if !Intersects(Normalize(metadata.NewBBoxPolygon([4]float64{-120, -60, 100, 60})), metadata.NewPoint(0, 5)) {
+		t.Error("expected a wide bbox to cover the prime meridian")
+	}
+
+	// a ring circling the south pole is closed over it
+	antarctica := Normalize(mustWKT(t, "POLYGON ((-180 -70, -90 -65, 0 -70, 90 -65, 180 -70, -180 -70))"))
+	bbox, _ = antarctica.Bounds()
+	if bbox != [4]float64{-180, -90, 180, -65} {
+		t.Errorf("unexpected polar bbox %v", bbox)
+	}
+	if !Intersects(antarctica, metadata.NewPoint(45, -85)) {
+		t.Error("expected the polar polygon to cover the pole")
+	}
+}
@@ -0,0 +1,270 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Coordinate reference systems and reprojection to CRS84
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package spatial
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// CRS84 identifies WGS84 longitude/latitude, the CRS of all record
+// geometries
+const CRS84 = "OGC:CRS84"
+
+// WGS84 ellipsoid, used for all supported datums: NAD83 and ETRS89 lie
+// within a metre or so of WGS84, well below the precision of catalogue
+// extents
+const (
+	semiMajorAxis = 6378137.0
+	flattening    = 1 / 298.257223563
+)
+
+// CRS describes a coordinate reference system whose coordinates can be
+// reprojected to CRS84
+type CRS struct {
+	// Identifier is the EPSG code (e.g. EPSG:32633), or CRS84
+	Identifier string
+	// LatLon reports whether coordinates are given latitude first
+	LatLon bool
+	// unproject converts easting and northing (or longitude and
+	// latitude) to longitude and latitude; nil for geographic CRSs
+	unproject func(x float64, y float64) (float64, float64)
+}
+
+// geographicCodes lists the supported geographic EPSG codes
+var geographicCodes = map[int]bool{
+	4326: true, // WGS84
+	4258: true, // ETRS89
+	4269: true, // NAD83
+	4283: true, // GDA94
+	4617: true, // NAD83(CSRS)
+	4674: true, // SIRGAS 2000
+}
+
+// webMercatorCodes lists the EPSG (and ESRI) codes of Web Mercator
+var webMercatorCodes = map[int]bool{
+	3857:   true,
+	3785:   true,
+	900913: true,
+	102100: true,
+	102113: true,
+}
+
+// ParseCRS parses a CRS identifier in any of the forms found in
+// metadata: EPSG:4326, urn:ogc:def:crs:EPSG::4326,
+// http://www.opengis.net/def/crs/EPSG/0/4326,
+// http://www.opengis.net/gml/srs/epsg.xml#4326 or CRS84.  The URN and
+// http://www.opengis.net/def forms follow the axis order of the EPSG
+// registry (latitude first for geographic CRSs), while the older
+// EPSG:4326 and epsg.xml forms are longitude first, as they have
+// traditionally been used.  An empty identifier is CRS84.  Supported
+// CRSs are the common geographic ones, Web Mercator and the UTM zones
+// of WGS84, NAD83 and ETRS89
+func ParseCRS(value string) (CRS, error) {
+	id := strings.TrimSpace(value)
+	lower := strings.ToLower(id)
+
+	var code string
+	authorityAxes := true
+	switch {
+	case lower == "", lower == "crs84", lower == "ogc:crs84",
+		strings.HasPrefix(lower, "urn:ogc:def:crs:ogc:") && strings.HasSuffix(lower, ":crs84"),
+		strings.HasPrefix(lower, "http://www.opengis.net/def/crs/ogc/") && strings.HasSuffix(lower, "/crs84"),
+		strings.HasPrefix(lower, "https://www.opengis.net/def/crs/ogc/") && strings.HasSuffix(lower, "/crs84"):
+		return CRS{Identifier: CRS84}, nil
+	case strings.HasPrefix(lower, "epsg:"):
+		code, authorityAxes = id[len("epsg:"):], false
+	case strings.HasPrefix(lower, "urn:ogc:def:crs:epsg:"), strings.HasPrefix(lower, "urn:x-ogc:def:crs:epsg:"):
+		code = id[strings.LastIndex(id, ":")+1:]
+	case strings.HasPrefix(lower, "http://www.opengis.net/def/crs/epsg/"), strings.HasPrefix(lower, "https://www.opengis.net/def/crs/epsg/"):
+		code = id[strings.LastIndex(id, "/")+1:]
+	case strings.HasPrefix(lower, "http://www.opengis.net/gml/srs/epsg.xml#"):
+		code, authorityAxes = id[strings.LastIndex(id, "#")+1:], false
+	default:
+		return CRS{}, fmt.Errorf("unsupported CRS %s", value)
+	}
+
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return CRS{}, fmt.Errorf("unsupported CRS %s", value)
+	}
+	crs := CRS{Identifier: "EPSG:" + strconv.Itoa(n)}
+	switch {
+	case geographicCodes[n]:
+		crs.LatLon = authorityAxes
+	case webMercatorCodes[n]:
+		crs.unproject = unprojectWebMercator
+	case n >= 32601 && n <= 32660:
+		crs.unproject = unprojectUTM(n-32600, false)
+	case n >= 32701 && n <= 32760:
+		crs.unproject = unprojectUTM(n-32700, true)
+	case n >= 26901 && n <= 26923:
+		crs.unproject = unprojectUTM(n-26900, false)
+	case n >= 25828 && n <= 25838:
+		crs.unproject = unprojectUTM(n-25800, false)
+	default:
+		return CRS{}, fmt.Errorf("unsupported CRS %s", value)
+	}
+	return crs, nil
+}
+
+// IsCRS84 reports whether coordinates are already longitude/latitude
+func (c CRS) IsCRS84() bool {
+	return !c.LatLon && c.unproject == nil
+}
+
+// ToCRS84 converts a position given in the axis order of the CRS to
+// longitude and latitude
+func (c CRS) ToCRS84(x float64, y float64) (float64, float64) {
+	if c.LatLon {
+		x, y = y, x
+	}
+	if c.unproject != nil {
+		return c.unproject(x, y)
+	}
+	return x, y
+}
+
+// BBoxToCRS84 converts a bounding box given as its lower and upper
+// corners, in the axis order of the CRS, to a CRS84 minx, miny, maxx,
+// maxy bounding box.  Projected bounding boxes are sampled along their
+// edges, which are curved in longitude and latitude
+func (c CRS) BBoxToCRS84(bbox [4]float64) [4]float64 {
+	if c.LatLon {
+		bbox = [4]float64{bbox[1], bbox[0], bbox[3], bbox[2]}
+	}
+	if c.unproject == nil {
+		return bbox
+	}
+
+	const samples = 8
+	out := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	add := func(x float64, y float64) {
+		lon, lat := c.unproject(x, y)
+		out[0] = math.Min(out[0], lon)
+		out[1] = math.Min(out[1], lat)
+		out[2] = math.Max(out[2], lon)
+		out[3] = math.Max(out[3], lat)
+	}
+	for i := 0; i <= samples; i++ {
+		t := float64(i) / samples
+		x := bbox[0] + t*(bbox[2]-bbox[0])
+		y := bbox[1] + t*(bbox[3]-bbox[1])
+		add(x, bbox[1])
+		add(x, bbox[3])
+		add(bbox[0], y)
+		add(bbox[2], y)
+	}
+	return out
+}
+
+// Transform reprojects a geometry to CRS84
+func (c CRS) Transform(g metadata.Geometry) metadata.Geometry {
+	if c.IsCRS84() {
+		return g
+	}
+	each := func(positions []metadata.Position) []metadata.Position {
+		if positions == nil {
+			return nil
+		}
+		out := make([]metadata.Position, len(positions))
+		for i, p := range positions {
+			q := append(metadata.Position{}, p...)
+			q[0], q[1] = c.ToCRS84(p[0], p[1])
+			out[i] = q
+		}
+		return out
+	}
+	rings := func(lines [][]metadata.Position) [][]metadata.Position {
+		if lines == nil {
+			return nil
+		}
+		out := make([][]metadata.Position, len(lines))
+		for i, line := range lines {
+			out[i] = each(line)
+		}
+		return out
+	}
+
+	out := metadata.Geometry{Type: g.Type}
+	switch g.Type {
+	case metadata.GeometryPoint:
+		if len(g.Point) >= 2 {
+			out.Point = each([]metadata.Position{g.Point})[0]
+		}
+	case metadata.GeometryMultiPoint:
+		out.MultiPoint = each(g.MultiPoint)
+	case metadata.GeometryLineString:
+		out.LineString = each(g.LineString)
+	case metadata.GeometryMultiLineString:
+		out.MultiLineString = rings(g.MultiLineString)
+	case metadata.GeometryPolygon:
+		out.Polygon = rings(g.Polygon)
+	case metadata.GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			out.MultiPolygon = append(out.MultiPolygon, rings(polygon))
+		}
+	case metadata.GeometryGeometryCollection:
+		for _, member := range g.Geometries {
+			out.Geometries = append(out.Geometries, c.Transform(member))
+		}
+	}
+	return out
+}
+
+// unprojectWebMercator converts Web Mercator (spherical Mercator on
+// the WGS84 semi-major axis) to longitude and latitude
+func unprojectWebMercator(x float64, y float64) (float64, float64) {
+	lon := x / semiMajorAxis * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/semiMajorAxis)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// unprojectUTM returns the inverse of a Universal Transverse Mercator
+// zone (Snyder, Map Projections: A Working Manual, pp. 63-64)
+func unprojectUTM(zone int, south bool) func(float64, float64) (float64, float64) {
+	const k0 = 0.9996
+	e2 := flattening * (2 - flattening)
+	ep2 := e2 / (1 - e2)
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	lon0 := float64(zone-1)*6 - 180 + 3
+
+	return func(easting float64, northing float64) (float64, float64) {
+		x := easting - 500000
+		y := northing
+		if south {
+			y -= 10000000
+		}
+
+		m := y / k0
+		mu := m / (semiMajorAxis * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+		phi1 := mu +
+			(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+			(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+			(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+			(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+		sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+		n1 := semiMajorAxis / math.Sqrt(1-e2*sin*sin)
+		t1 := tan * tan
+		c1 := ep2 * cos * cos
+		r1 := semiMajorAxis * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+		d := x / (n1 * k0)
+
+		lat := phi1 - (n1*tan/r1)*(d*d/2-
+			(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+			(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+		lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+			(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos
+
+		return lon0 + lon*180/math.Pi, lat * 180 / math.Pi
+	}
+}
@@ -0,0 +1,91 @@
+package spatial
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCRS(t *testing.T) {
+	tests := []struct {
+		value, identifier string
+		latLon            bool
+	}{
+		{"", CRS84, false},
+		{"urn:ogc:def:crs:OGC:1.3:CRS84", CRS84, false},
+		{"http://www.opengis.net/def/crs/OGC/1.3/CRS84", CRS84, false},
+		{"EPSG:4326", "EPSG:4326", false},
+		{"urn:ogc:def:crs:EPSG::4326", "EPSG:4326", true},
+		{"urn:ogc:def:crs:EPSG:6.6:4326", "EPSG:4326", true},
+		{"http://www.opengis.net/def/crs/EPSG/0/4258", "EPSG:4258", true},
+		{"http://www.opengis.net/gml/srs/epsg.xml#4326", "EPSG:4326", false},
+		{"urn:ogc:def:crs:EPSG::3857", "EPSG:3857", false},
+		{"EPSG:32633", "EPSG:32633", false},
+	}
+	for _, test := range tests {
+		crs, err := ParseCRS(test.value)
+		if err != nil {
+			t.Errorf("%s: %v", test.value, err)
+			continue
+		}
+		if crs.Identifier != test.identifier || crs.LatLon != test.latLon {
+			t.Errorf("%s parsed as %s (latitude first: %v)", test.value, crs.Identifier, crs.LatLon)
+		}
+	}
+
+	for _, value := range []string{"EPSG:27700", "EPSG:abc", "urn:ogc:def:crs:EPSG::", "WGS84"} {
+		if _, err := ParseCRS(value); err == nil {
+			t.Errorf("expected %q to be unsupported", value)
+		}
+	}
+}
+
+func TestToCRS84(t *testing.T) {
+	tests := []struct {
+		crs       string
+		x, y      float64
+		lon, lat  float64
+		tolerance float64
+	}{
+		{"urn:ogc:def:crs:EPSG::4326", 48.8584, 2.2945, 2.2945, 48.8584, 1e-9},
+		{"EPSG:3857", 0, 0, 0, 0, 1e-9},
+		{"EPSG:3857", 20037508.34, 20037508.34, 180, 85.0511, 1e-4},
+		{"EPSG:3857", 255422.57, 6250868.90, 2.2945, 48.8584, 1e-6},
+		// central meridian of zone 33 at the equator
+		{"EPSG:32633", 500000, 0, 15, 0, 1e-9},
+		// the Eiffel Tower
+		{"EPSG:32631", 448252, 5411933, 2.2945, 48.8584, 1e-3},
+		// Sydney Opera House
+		{"EPSG:32756", 334873, 6252266, 151.2153, -33.8568, 1e-3},
+	}
+	for _, test := range tests {
+		crs, err := ParseCRS(test.crs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lon, lat := crs.ToCRS84(test.x, test.y)
+		if math.Abs(lon-test.lon) > test.tolerance || math.Abs(lat-test.lat) > test.tolerance {
+			t.Errorf("%s (%v %v) converted to (%v %v), expected (%v %v)", test.crs, test.x, test.y, lon, lat, test.lon, test.lat)
+		}
+	}
+}
+
+func TestBBoxToCRS84(t *testing.T) {
+	crs, _ := ParseCRS("urn:ogc:def:crs:EPSG::4326")
+	if bbox := crs.BBoxToCRS84([4]float64{40, -10, 50, 5}); bbox != [4]float64{-10, 40, 5, 50} {
+		t.Errorf("latitude first bbox converted to %v", bbox)
+	}
+
+	crs, _ = ParseCRS("EPSG:3857")
+	bbox := crs.BBoxToCRS84([4]float64{-20037508.34, -20037508.34, 20037508.34, 20037508.34})
+	if math.Abs(bbox[0]+180) > 1e-4 || math.Abs(bbox[3]-85.0511) > 1e-4 {
+		t.Errorf("Web Mercator world converted to %v", bbox)
+	}
+
+	// the lines of a UTM zone are curved in longitude and latitude, so
+	// the bbox extends beyond its corners
+	crs, _ = ParseCRS("EPSG:32633")
+	bbox = crs.BBoxToCRS84([4]float64{166000, 0, 834000, 9300000})
+	if bbox[0] > 9 || bbox[2] < 21 || bbox[1] != 0 || bbox[3] < 83 {
+		t.Errorf("UTM zone converted to %v", bbox)
+	}
+}
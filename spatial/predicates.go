@@ -0,0 +1,450 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Spatial predicates between GeoJSON geometries
+// Coordinates are treated as planar longitude/latitude
+//
+///////////////////////////////////////////////////////////////////////////////
+
+// Package spatial provides geometry operations: reprojection to CRS84
+// and normalization of geometries on ingest, and the evaluation of
+// spatial filters outside of the search engine
+package spatial
+
+import (
+	"math"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// location of a point relative to a polygon
+type location int
+
+const (
+	outside location = iota
+	boundary
+	inside
+)
+
+// parts holds the points, lines and polygons making up a geometry
+type parts struct {
+	points   []metadata.Position
+	lines    [][]metadata.Position
+	polygons [][][]metadata.Position
+}
+
+// decompose splits a geometry, including the members of a collection,
+// into its points, lines and polygons
+func decompose(g metadata.Geometry, p *parts) {
+	switch g.Type {
+	case metadata.GeometryPoint:
+		if len(g.Point) >= 2 {
+			p.points = append(p.points, g.Point)
+		}
+	case metadata.GeometryMultiPoint:
+		for _, point := range g.MultiPoint {
+			p.points = append(p.points, point)
+		}
+	case metadata.GeometryLineString:
+		p.addLine(g.LineString)
+	case metadata.GeometryMultiLineString:
+		for _, line := range g.MultiLineString {
+			p.addLine(line)
+		}
+	case metadata.GeometryPolygon:
+		p.addPolygon(g.Polygon)
+	case metadata.GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			p.addPolygon(polygon)
+		}
+	case metadata.GeometryGeometryCollection:
+		for _, member := range g.Geometries {
+			decompose(member, p)
+		}
+	}
+}
+
+// addLine adds a line, treating a single position as a point
+func (p *parts) addLine(line []metadata.Position) {
+	switch len(line) {
+	case 0:
+	case 1:
+		p.points = append(p.points, line[0])
+	default:
+		p.lines = append(p.lines, line)
+	}
+}
+
+// addPolygon adds a polygon, ignoring one without an exterior ring
+func (p *parts) addPolygon(polygon [][]metadata.Position) {
+	if len(polygon) > 0 && len(polygon[0]) >= 3 {
+		p.polygons = append(p.polygons, polygon)
+	}
+}
+
+func (p *parts) empty() bool {
+	return len(p.points) == 0 && len(p.lines) == 0 && len(p.polygons) == 0
+}
+
+// Intersects reports whether two geometries share at least one point
+func Intersects(a metadata.Geometry, b metadata.Geometry) bool {
+	if !boundsOverlap(a, b) {
+		return false
+	}
+	var pa, pb parts
+	decompose(a, &pa)
+	decompose(b, &pb)
+
+	for _, p := range pa.points {
+		if pointIntersects(p, &pb) {
+			return true
+		}
+	}
+	for _, p := range pb.points {
+		if pointIntersects(p, &pa) {
+			return true
+		}
+	}
+	for _, line := range pa.lines {
+		if lineIntersects(line, &pb) {
+			return true
+		}
+	}
+	for _, line := range pb.lines {
+		for _, polygon := range pa.polygons {
+			if linePolygonIntersect(line, polygon) {
+				return true
+			}
+		}
+	}
+	for _, polygon := range pa.polygons {
+		for _, other := range pb.polygons {
+			if polygonsIntersect(polygon, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Disjoint reports whether two geometries share no point
+func Disjoint(a metadata.Geometry, b metadata.Geometry) bool {
+	return !Intersects(a, b)
+}
+
+// Contains reports whether every point of b lies in a.  Boundaries are
+// included, so a polygon contains a point on its edge.  Each part of a
+// multi-part b must lie within a single part of a
+func Contains(a metadata.Geometry, b metadata.Geometry) bool {
+	ab, ok := planarBounds(a)
+	if !ok {
+		return false
+	}
+	bb, ok := planarBounds(b)
+	if !ok || bb[0] < ab[0] || bb[1] < ab[1] || bb[2] > ab[2] || bb[3] > ab[3] {
+		return false
+	}
+
+	var pa, pb parts
+	decompose(a, &pa)
+	decompose(b, &pb)
+	if pb.empty() {
+		return false
+	}
+
+	for _, p := range pb.points {
+		if !pointIntersects(p, &pa) {
+			return false
+		}
+	}
+	for _, line := range pb.lines {
+		if !lineCovered(line, &pa) {
+			return false
+		}
+	}
+	for _, polygon := range pb.polygons {
+		covered := false
+		for _, container := range pa.polygons {
+			if polygonCovered(polygon, container) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// Within reports whether every point of a lies in b
+func Within(a metadata.Geometry, b metadata.Geometry) bool {
+	return Contains(b, a)
+}
+
+// boundsOverlap reports whether the bounding boxes of two non-empty
+// geometries overlap
+func boundsOverlap(a metadata.Geometry, b metadata.Geometry) bool {
+	ab, ok := planarBounds(a)
+	if !ok {
+		return false
+	}
+	bb, ok := planarBounds(b)
+	if !ok {
+		return false
+	}
+	return ab[0] <= bb[2] && bb[0] <= ab[2] && ab[1] <= bb[3] && bb[1] <= ab[3]
+}
+
+// planarBounds returns the minimum and maximum coordinates of a
+// geometry, which unlike its Bounds never cross the antimeridian
+func planarBounds(g metadata.Geometry) ([4]float64, bool) {
+	var p parts
+	decompose(g, &p)
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	extend := func(positions []metadata.Position) {
+		for _, q := range positions {
+			bbox[0] = math.Min(bbox[0], q[0])
+			bbox[1] = math.Min(bbox[1], q[1])
+			bbox[2] = math.Max(bbox[2], q[0])
+			bbox[3] = math.Max(bbox[3], q[1])
+		}
+	}
+	extend(p.points)
+	for _, line := range p.lines {
+		extend(line)
+	}
+	for _, polygon := range p.polygons {
+		extend(polygon[0])
+	}
+	return bbox, bbox[0] <= bbox[2]
+}
+
+// pointIntersects reports whether a point lies on any part of a geometry
+func pointIntersects(p metadata.Position, g *parts) bool {
+	for _, q := range g.points {
+		if samePosition(p, q) {
+			return true
+		}
+	}
+	for _, line := range g.lines {
+		if onLine(p, line) {
+			return true
+		}
+	}
+	for _, polygon := range g.polygons {
+		if locate(p, polygon) != outside {
+			return true
+		}
+	}
+	return false
+}
+
+// lineIntersects reports whether a line crosses or touches the lines
+// or polygons of a geometry
+func lineIntersects(line []metadata.Position, g *parts) bool {
+	for _, other := range g.lines {
+		if linesIntersect(line, other) {
+			return true
+		}
+	}
+	for _, polygon := range g.polygons {
+		if linePolygonIntersect(line, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+func linesIntersect(a []metadata.Position, b []metadata.Position) bool {
+	for i := 0; i+1 < len(a); i++ {
+		for j := 0; j+1 < len(b); j++ {
+			if segmentsIntersect(a[i], a[i+1], b[j], b[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func linePolygonIntersect(line []metadata.Position, polygon [][]metadata.Position) bool {
+	for _, p := range line {
+		if locate(p, polygon) != outside {
+			return true
+		}
+	}
+	for _, ring := range polygon {
+		if linesIntersect(line, ring) {
+			return true
+		}
+	}
+	return false
+}
+
+func polygonsIntersect(a [][]metadata.Position, b [][]metadata.Position) bool {
+	for _, ring := range a {
+		for _, other := range b {
+			if linesIntersect(ring, other) {
+				return true
+			}
+		}
+	}
+	return locate(a[0][0], b) != outside || locate(b[0][0], a) != outside
+}
+
+// lineCovered reports whether a line lies within a single line or
+// polygon of a geometry
+func lineCovered(line []metadata.Position, g *parts) bool {
+	for _, other := range g.lines {
+		if lineOnLine(line, other) {
+			return true
+		}
+	}
+	for _, polygon := range g.polygons {
+		if lineInPolygon(line, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOnLine reports whether every vertex and segment midpoint of a
+// line lies on another
+func lineOnLine(line []metadata.Position, other []metadata.Position) bool {
+	for i, p := range line {
+		if !onLine(p, other) {
+			return false
+		}
+		if i > 0 && !onLine(midpoint(line[i-1], p), other) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineInPolygon reports whether a line lies within a polygon: every
+// vertex and segment midpoint is inside or on its boundary and no
+// segment crosses a ring
+func lineInPolygon(line []metadata.Position, polygon [][]metadata.Position) bool {
+	for i, p := range line {
+		if locate(p, polygon) == outside {
+			return false
+		}
+		if i == 0 {
+			continue
+		}
+		if locate(midpoint(line[i-1], p), polygon) == outside {
+			return false
+		}
+		for _, ring := range polygon {
+			for j := 0; j+1 < len(ring); j++ {
+				if segmentsCross(line[i-1], p, ring[j], ring[j+1]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// polygonCovered reports whether a polygon lies within a container:
+// its exterior ring is inside the container and no hole of the
+// container lies in its interior
+func polygonCovered(polygon [][]metadata.Position, container [][]metadata.Position) bool {
+	if !lineInPolygon(polygon[0], container) {
+		return false
+	}
+	for _, hole := range container[1:] {
+		for i, p := range hole {
+			if locate(p, polygon) == inside {
+				return false
+			}
+			if i > 0 && locate(midpoint(hole[i-1], p), polygon) == inside {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// locate finds a point relative to a polygon with optional holes
+func locate(p metadata.Position, polygon [][]metadata.Position) location {
+	loc := locateInRing(p, polygon[0])
+	if loc != inside {
+		return loc
+	}
+	for _, hole := range polygon[1:] {
+		switch locateInRing(p, hole) {
+		case inside:
+			return outside
+		case boundary:
+			return boundary
+		}
+	}
+	return inside
+}
+
+// locateInRing finds a point relative to a closed ring by ray casting
+func locateInRing(p metadata.Position, ring []metadata.Position) location {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[j], ring[i]
+		if onSegment(p, a, b) {
+			return boundary
+		}
+		if (a[1] > p[1]) != (b[1] > p[1]) &&
+			p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	if in {
+		return inside
+	}
+	return outside
+}
+
+func onLine(p metadata.Position, line []metadata.Position) bool {
+	for i := 0; i+1 < len(line); i++ {
+		if onSegment(p, line[i], line[i+1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// orient is positive when c lies to the left of the line a to b,
+// negative to the right and zero when the three are collinear
+func orient(a metadata.Position, b metadata.Position, c metadata.Position) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func onSegment(p metadata.Position, a metadata.Position, b metadata.Position) bool {
+	return orient(a, b, p) == 0 &&
+		p[0] >= min(a[0], b[0]) && p[0] <= max(a[0], b[0]) &&
+		p[1] >= min(a[1], b[1]) && p[1] <= max(a[1], b[1])
+}
+
+// segmentsIntersect reports whether segments ab and cd cross or touch
+func segmentsIntersect(a, b, c, d metadata.Position) bool {
+	o1, o2 := orient(a, b, c), orient(a, b, d)
+	o3, o4 := orient(c, d, a), orient(c, d, b)
+	if ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0)) {
+		return true
+	}
+	return onSegment(c, a, b) || onSegment(d, a, b) || onSegment(a, c, d) || onSegment(b, c, d)
+}
+
+// segmentsCross reports whether segments ab and cd cross at a single
+// point interior to both
+func segmentsCross(a, b, c, d metadata.Position) bool {
+	o1, o2 := orient(a, b, c), orient(a, b, d)
+	o3, o4 := orient(c, d, a), orient(c, d, b)
+	return ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0))
+}
+
+func samePosition(a metadata.Position, b metadata.Position) bool {
+	return a[0] == b[0] && a[1] == b[1]
+}
+
+func midpoint(a metadata.Position, b metadata.Position) metadata.Position {
+	return metadata.Position{(a[0] + b[0]) / 2, (a[1] + b[1]) / 2}
+}
@@ -0,0 +1,70 @@
+package spatial
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+func mustWKT(t *testing.T, value string) metadata.Geometry {
+	g, err := ParseWKT(value)
+	if err != nil {
+		t.Fatalf("%s: %v", value, err)
+	}
+	return g
+}
+
+func TestPredicates(t *testing.T) {
+	square := "POLYGON((0 0, 0 10, 10 10, 10 0, 0 0))"
+	donut := "POLYGON((0 0, 0 10, 10 10, 10 0, 0 0), (3 3, 3 7, 7 7, 7 3, 3 3))"
+	// an L shape whose bbox covers (8 8) without the polygon doing so
+	ell := "POLYGON((0 0, 0 10, 2 10, 2 2, 10 2, 10 0, 0 0))"
+
+	tests := []struct {
+		a, b                         string
+		intersects, contains, within bool
+	}{
+		{square, "POINT(5 5)", true, true, false},
+		{square, "POINT(10 5)", true, true, false},
+		{square, "POINT(11 5)", false, false, false},
+		{donut, "POINT(5 5)", false, false, false},
+		{donut, "POINT(1 1)", true, true, false},
+		{ell, "POINT(8 8)", false, false, false},
+		{ell, "LINESTRING(8 8, 9 9)", false, false, false},
+		{ell, "LINESTRING(1 5, 1 9)", true, true, false},
+		{ell, "LINESTRING(1 5, 5 5)", true, false, false},
+		{"LINESTRING(0 0, 10 10)", "LINESTRING(0 10, 10 0)", true, false, false},
+		{"LINESTRING(0 0, 10 10)", "LINESTRING(2 2, 4 4)", true, true, false},
+		{square, "POLYGON((2 2, 2 4, 4 4, 4 2, 2 2))", true, true, false},
+		{donut, "POLYGON((2 2, 2 8, 8 8, 8 2, 2 2))", true, false, false},
+		{donut, "POLYGON((4 4, 4 6, 6 6, 6 4, 4 4))", false, false, false},
+		{"POLYGON((2 2, 2 4, 4 4, 4 2, 2 2))", square, true, false, true},
+		{square, "POLYGON((10 0, 10 10, 20 10, 20 0, 10 0))", true, false, false},
+		{square, "MULTIPOINT((1 1), (20 20))", true, false, false},
+		{"MULTIPOLYGON(((0 0, 0 1, 1 1, 1 0, 0 0)), ((5 5, 5 6, 6 6, 6 5, 5 5)))", "POINT(5.5 5.5)", true, true, false},
+		{"GEOMETRYCOLLECTION(POINT(20 20), LINESTRING(-5 5, 5 5))", square, true, false, false},
+	}
+
+	for _, test := range tests {
+		a, b := mustWKT(t, test.a), mustWKT(t, test.b)
+		if got := Intersects(a, b); got != test.intersects {
+			t.Errorf("Intersects(%s, %s) = %v", test.a, test.b, got)
+		}
+		if got := Intersects(b, a); got != test.intersects {
+			t.Errorf("Intersects(%s, %s) = %v", test.b, test.a, got)
+		}
+		if got := Disjoint(a, b); got == test.intersects {
+			t.Errorf("Disjoint(%s, %s) = %v", test.a, test.b, got)
+		}
+		if got := Contains(a, b); got != test.contains {
+			t.Errorf("Contains(%s, %s) = %v", test.a, test.b, got)
+		}
+		if got := Within(a, b); got != test.within {
+			t.Errorf("Within(%s, %s) = %v", test.a, test.b, got)
+		}
+	}
+
+	if Intersects(metadata.Geometry{}, mustWKT(t, square)) || Contains(mustWKT(t, square), metadata.Geometry{}) {
+		t.Error("expected the empty geometry to have no relation")
+	}
+}
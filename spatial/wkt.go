@@ -0,0 +1,265 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Well-known text (WKT) geometries
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package spatial
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// wktTypes maps WKT geometry tags to GeoJSON geometry types
+var wktTypes = map[string]string{
+	"POINT":              metadata.GeometryPoint,
+	"MULTIPOINT":         metadata.GeometryMultiPoint,
+	"LINESTRING":         metadata.GeometryLineString,
+	"MULTILINESTRING":    metadata.GeometryMultiLineString,
+	"POLYGON":            metadata.GeometryPolygon,
+	"MULTIPOLYGON":       metadata.GeometryMultiPolygon,
+	"GEOMETRYCOLLECTION": metadata.GeometryGeometryCollection,
+}
+
+// The fewest positions of a line and of a closed ring
+const (
+	minLinePositions = 2
+	minRingPositions = 4
+)
+
+// ParseWKT parses a WKT geometry, e.g. POLYGON((0 0, 0 1, 1 1, 1 0, 0 0)).
+// Z coordinates are kept and M coordinates dropped
+func ParseWKT(value string) (metadata.Geometry, error) {
+	p := wktParser{tokens: tokenizeWKT(value)}
+	g, err := p.geometry()
+	if err != nil {
+		return metadata.Geometry{}, fmt.Errorf("invalid WKT: %v", err)
+	}
+	if p.pos < len(p.tokens) {
+		return metadata.Geometry{}, fmt.Errorf("invalid WKT: unexpected %q after geometry", p.tokens[p.pos])
+	}
+	return g, nil
+}
+
+// tokenizeWKT splits WKT into words, numbers and punctuation
+func tokenizeWKT(value string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range value {
+		switch r {
+		case '(', ')', ',', ' ', '\t', '\n', '\r':
+			if start >= 0 {
+				tokens = append(tokens, value[start:i])
+				start = -1
+			}
+			if r == '(' || r == ')' || r == ',' {
+				tokens = append(tokens, string(r))
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, value[start:])
+	}
+	return tokens
+}
+
+type wktParser struct {
+	tokens []string
+	pos    int
+	// dropM is set while parsing a geometry with M coordinates
+	dropM bool
+}
+
+func (p *wktParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *wktParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *wktParser) expect(token string) error {
+	if next := p.next(); next != token {
+		if next == "" {
+			return fmt.Errorf("expected %q at end of input", token)
+		}
+		return fmt.Errorf("expected %q, found %q", token, next)
+	}
+	return nil
+}
+
+func (p *wktParser) geometry() (metadata.Geometry, error) {
+	tag := strings.ToUpper(p.next())
+	g := metadata.Geometry{Type: wktTypes[tag]}
+	if g.Type == "" {
+		return g, fmt.Errorf("unknown geometry type %q", tag)
+	}
+
+	outer := p.dropM
+	defer func() { p.dropM = outer }()
+	switch strings.ToUpper(p.peek()) {
+	case "Z":
+		p.next()
+	case "M":
+		p.next()
+		p.dropM = true
+	case "ZM":
+		p.next()
+		p.dropM = true
+	}
+	if strings.ToUpper(p.peek()) == "EMPTY" {
+		p.next()
+		return g, nil
+	}
+
+	var err error
+	switch g.Type {
+	case metadata.GeometryPoint:
+		var points []metadata.Position
+		if points, err = p.positions(); err == nil && len(points) != 1 {
+			err = fmt.Errorf("POINT must have a single position")
+		} else if err == nil {
+			g.Point = points[0]
+		}
+	case metadata.GeometryMultiPoint:
+		g.MultiPoint, err = p.multiPoint()
+	case metadata.GeometryLineString:
+		g.LineString, err = p.line(minLinePositions)
+	case metadata.GeometryMultiLineString:
+		g.MultiLineString, err = p.rings(minLinePositions)
+	case metadata.GeometryPolygon:
+		g.Polygon, err = p.rings(minRingPositions)
+	case metadata.GeometryMultiPolygon:
+		g.MultiPolygon, err = p.polygons()
+	case metadata.GeometryGeometryCollection:
+		g.Geometries, err = p.collection()
+	}
+	return g, err
+}
+
+// position parses space separated coordinates
+func (p *wktParser) position() (metadata.Position, error) {
+	var position metadata.Position
+	for {
+		token := p.peek()
+		if token == "" || token == "," || token == ")" || token == "(" {
+			break
+		}
+		f, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", token)
+		}
+		position = append(position, f)
+		p.next()
+	}
+	if len(position) < 2 || len(position) > 4 {
+		return nil, fmt.Errorf("position must have 2 to 4 coordinates, found %d", len(position))
+	}
+	if p.dropM {
+		position = position[:len(position)-1]
+	}
+	if len(position) > 3 {
+		position = position[:3]
+	}
+	return position, nil
+}
+
+// list parses a parenthesized, comma separated list of items
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	return p.expect(")")
+}
+
+func (p *wktParser) positions() ([]metadata.Position, error) {
+	var positions []metadata.Position
+	err := p.list(func() error {
+		position, err := p.position()
+		positions = append(positions, position)
+		return err
+	})
+	return positions, err
+}
+
+// multiPoint parses points with or without their own parentheses,
+// e.g. MULTIPOINT ((1 2), (3 4)) or MULTIPOINT (1 2, 3 4)
+func (p *wktParser) multiPoint() ([]metadata.Position, error) {
+	var positions []metadata.Position
+	err := p.list(func() error {
+		if p.peek() != "(" {
+			position, err := p.position()
+			positions = append(positions, position)
+			return err
+		}
+		point, err := p.positions()
+		if err == nil && len(point) != 1 {
+			err = fmt.Errorf("MULTIPOINT member must have a single position")
+		}
+		positions = append(positions, point...)
+		return err
+	})
+	return positions, err
+}
+
+// line parses the positions of a line or ring, which must have at
+// least minimum positions
+func (p *wktParser) line(minimum int) ([]metadata.Position, error) {
+	positions, err := p.positions()
+	if err == nil && len(positions) < minimum {
+		err = fmt.Errorf("%d positions found where at least %d are needed", len(positions), minimum)
+	}
+	return positions, err
+}
+
+func (p *wktParser) rings(minimum int) ([][]metadata.Position, error) {
+	var rings [][]metadata.Position
+	err := p.list(func() error {
+		ring, err := p.line(minimum)
+		rings = append(rings, ring)
+		return err
+	})
+	return rings, err
+}
+
+func (p *wktParser) polygons() ([][][]metadata.Position, error) {
+	var polygons [][][]metadata.Position
+	err := p.list(func() error {
+		polygon, err := p.rings(minRingPositions)
+		polygons = append(polygons, polygon)
+		return err
+	})
+	return polygons, err
+}
+
+func (p *wktParser) collection() ([]metadata.Geometry, error) {
+	var geometries []metadata.Geometry
+	err := p.list(func() error {
+		g, err := p.geometry()
+		geometries = append(geometries, g)
+		return err
+	})
+	return geometries, err
+}
@@ -0,0 +1,40 @@
+package spatial
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseWKT(t *testing.T) {
+	tests := map[string]string{
+		"POINT (30 10)":                                    `{"type":"Point","coordinates":[30,10]}`,
+		"point z (30 10 5)":                                `{"type":"Point","coordinates":[30,10,5]}`,
+		"POINT M (30 10 99)":                               `{"type":"Point","coordinates":[30,10]}`,
+		"POINT EMPTY":                                      `{"type":"Point","coordinates":[]}`,
+		"LINESTRING (30 10, 10 30, 40 40)":                 `{"type":"LineString","coordinates":[[30,10],[10,30],[40,40]]}`,
+		"MULTIPOINT ((10 40), (40 30))":                    `{"type":"MultiPoint","coordinates":[[10,40],[40,30]]}`,
+		"MULTIPOINT (10 40, 40 30)":                        `{"type":"MultiPoint","coordinates":[[10,40],[40,30]]}`,
+		"MULTILINESTRING ((10 10, 20 20), (40 40, 30 30))": `{"type":"MultiLineString","coordinates":[[[10,10],[20,20]],[[40,40],[30,30]]]}`,
+		"POLYGON ((35 10, 45 45, 15 40, 35 10), (20 30, 35 35, 30 20, 20 30))":        `{"type":"Polygon","coordinates":[[[35,10],[45,45],[15,40],[35,10]],[[20,30],[35,35],[30,20],[20,30]]]}`,
+		"MULTIPOLYGON (((30 20, 45 40, 10 40, 30 20)), ((15 5, 40 10, 10 20, 15 5)))": `{"type":"MultiPolygon","coordinates":[[[[30,20],[45,40],[10,40],[30,20]]],[[[15,5],[40,10],[10,20],[15,5]]]]}`,
+		"GEOMETRYCOLLECTION (POINT (40 10), LINESTRING (10 10, 20 20))":               `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[40,10]},{"type":"LineString","coordinates":[[10,10],[20,20]]}]}`,
+	}
+	for wkt, expected := range tests {
+		g, err := ParseWKT(wkt)
+		if err != nil {
+			t.Errorf("%s: %v", wkt, err)
+			continue
+		}
+		if out, _ := json.Marshal(g); string(out) != expected {
+			t.Errorf("%s parsed as %s", wkt, out)
+		}
+	}
+
+	for _, wkt := range []string{"", "CIRCLE (0 0)", "POINT (1)", "POINT (1 2", "POINT (1 2) x", "POLYGON ((0 0, a 1))", "POINT (1 2, 3 4)",
+		"LINESTRING (1 2)", "MULTILINESTRING ((1 2, 3 4), (5 6))", "POLYGON ((1 2))", "POLYGON ((0 0, 1 1, 0 0))",
+		"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((1 2)))"} {
+		if _, err := ParseWKT(wkt); err == nil {
+			t.Errorf("expected %q to fail", wkt)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// Signed cursor tokens for deep pagination
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package geocatalogo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// ErrInvalidToken is returned for page tokens which were not issued by
+// this catalogue, have been altered, or belong to a different search
+var ErrInvalidToken = errors.New("invalid page token")
+
+// pageToken is the signed content of a page token
+type pageToken struct {
+	// Search identifies the criteria of the search the token belongs to
+	Search string `json:"s"`
+	// After holds the sort values of the last record of the page
+	After []interface{} `json:"a"`
+	// Position is the number of records preceding the next page
+	Position int `json:"p"`
+}
+
+// newTokenKey returns the key used to sign page tokens.  Without a
+// configured secret a random key is used, so tokens are only valid
+// until the catalogue restarts
+func newTokenKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// searchFingerprint summarizes the criteria and order of a search,
+// ignoring paging and presentation
+func searchFingerprint(req search.Request) string {
+	criteria := search.Request{
+		Collections: req.Collections,
+		Term:        req.Term,
+		Filters:     req.Filters,
+		Spatial:     req.Spatial,
+		Temporal:    req.Temporal,
+		Filter:      req.Filter,
+		Sort:        req.Sort,
+	}
+	data, _ := json.Marshal(criteria)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// sign returns the signature of a token payload
+func (c *GeoCatalogue) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.tokenKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeToken creates a page token resuming a search after a page
+func (c *GeoCatalogue) encodeToken(req search.Request, sr *search.Results) string {
+	payload, err := json.Marshal(pageToken{
+		Search:   searchFingerprint(req),
+		After:    sr.After,
+		Position: sr.NextRecord,
+	})
+	if err != nil {
+		log.Warnf("Cannot create page token: %v", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// ApplyToken positions a search at the page identified by a token
+// returned in search.Results.NextToken.  The search must have the same
+// criteria and sort as the one the token was issued for
+func (c *GeoCatalogue) ApplyToken(token string, req *search.Request) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return ErrInvalidToken
+	}
+
+	var pt pageToken
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&pt); err != nil {
+		return ErrInvalidToken
+	}
+	if pt.Search != searchFingerprint(*req) || len(pt.After) == 0 {
+		return ErrInvalidToken
+	}
+
+	req.After = pt.After
+	req.From = pt.Position
+	return nil
+}
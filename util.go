@@ -48,7 +48,12 @@ func Struct2JSON(iface interface{}, prettyPrint bool) []byte {
 
 // EmitResponse provides HTTP response for successful requests
 func EmitResponse(c *GeoCatalogue, w http.ResponseWriter, code int, response []byte) {
-	w.Header().Set("Content-Type", c.Config.Server.MimeType)
+	EmitResponseType(c, w, code, c.Config.Server.MimeType, response)
+}
+
+// EmitResponseType provides HTTP response of a given content type
+func EmitResponseType(c *GeoCatalogue, w http.ResponseWriter, code int, contentType string, response []byte) {
+	w.Header().Set("Content-Type", contentType)
 	if c.Config.Server.CORS == true {
 		w.Header().Set("Access-Control-Allow-Headers", "*")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
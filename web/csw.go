@@ -0,0 +1,916 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// OGC Catalogue Service for the Web (CSW) 2.0.2 and 3.0
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/cql2"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// OWS exception codes
+const (
+	OWSMissingParameterValue    = "MissingParameterValue"
+	OWSInvalidParameterValue    = "InvalidParameterValue"
+	OWSOperationNotSupported    = "OperationNotSupported"
+	OWSVersionNegotiationFailed = "VersionNegotiationFailed"
+	OWSNoApplicableCode         = "NoApplicableCode"
+	OWSNotFound                 = "NotFound"
+)
+
+// cswException is a failed CSW request, reported as an OWS exception
+type cswException struct {
+	Code    string
+	Locator string
+	Text    string
+	// Status is the HTTP status code of the report
+	Status int
+}
+
+func (e *cswException) Error() string {
+	return e.Text
+}
+
+func cswError(code string, locator string, format string, args ...interface{}) *cswException {
+	status := http.StatusBadRequest
+	switch code {
+	case OWSOperationNotSupported:
+		status = http.StatusNotImplemented
+	case OWSNotFound:
+		status = http.StatusNotFound
+	case OWSNoApplicableCode:
+		status = http.StatusInternalServerError
+	}
+	return &cswException{Code: code, Locator: locator, Text: fmt.Sprintf(format, args...), Status: status}
+}
+
+// withStatus overrides the HTTP status code of an exception
+func (e *cswException) withStatus(status int) *cswException {
+	e.Status = status
+	return e
+}
+
+// cswSortProperty is a property to sort records on
+type cswSortProperty struct {
+	Name       string
+	Descending bool
+}
+
+// cswRequest is a CSW request, read from KVP or an XML document
+type cswRequest struct {
+	Service        string
+	Request        string
+	Version        string
+	AcceptVersions []string
+	TypeNames      []string
+	ElementSetName string
+	ElementNames   []string
+	ResultType     string
+	OutputSchema   string
+	OutputFormat   string
+	StartPosition  string
+	MaxRecords     string
+	Constraint     cql2.Expr
+	SortBy         []cswSortProperty
+	IDs            []string
+	ParameterName  string
+	PropertyName   string
+	// Source, ResourceType, ResourceFormat, HarvestInterval and
+	// ResponseHandler are the parameters of Harvest
+	Source          string
+	ResourceType    string
+	ResourceFormat  string
+	HarvestInterval string
+	ResponseHandler []string
+	// Body is the XML document of a POST request
+	Body []byte
+}
+
+// splitList splits a comma separated parameter value
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseCSWKVP reads a request from its query parameters, whose names
+// are case-insensitive
+func parseCSWKVP(query url.Values) (cswRequest, error) {
+	kvp := make(map[string]string)
+	for k, v := range query {
+		if len(v) > 0 {
+			kvp[strings.ToLower(k)] = v[0]
+		}
+	}
+
+	typeNames := kvp["typenames"]
+	if typeNames == "" {
+		typeNames = kvp["typename"]
+	}
+	req := cswRequest{
+		Service:         kvp["service"],
+		Request:         kvp["request"],
+		Version:         kvp["version"],
+		AcceptVersions:  splitList(kvp["acceptversions"]),
+		TypeNames:       splitList(typeNames),
+		ElementSetName:  kvp["elementsetname"],
+		ElementNames:    splitList(kvp["elementname"]),
+		ResultType:      kvp["resulttype"],
+		OutputSchema:    kvp["outputschema"],
+		OutputFormat:    kvp["outputformat"],
+		StartPosition:   kvp["startposition"],
+		MaxRecords:      kvp["maxrecords"],
+		IDs:             splitList(kvp["id"]),
+		ParameterName:   kvp["parametername"],
+		PropertyName:    kvp["propertyname"],
+		Source:          kvp["source"],
+		ResourceType:    kvp["resourcetype"],
+		ResourceFormat:  kvp["resourceformat"],
+		HarvestInterval: kvp["harvestinterval"],
+		ResponseHandler: splitList(kvp["responsehandler"]),
+	}
+
+	if constraint := kvp["constraint"]; constraint != "" {
+		var err error
+		switch strings.ToUpper(kvp["constraintlanguage"]) {
+		case "":
+			return req, cswError(OWSMissingParameterValue, "constraintlanguage", "constraintlanguage is required with constraint")
+		case "FILTER":
+			req.Constraint, err = cql2.ParseFES([]byte(constraint))
+		case "CQL_TEXT":
+			req.Constraint, err = cql2.ParseText(constraint)
+		default:
+			return req, cswError(OWSInvalidParameterValue, "constraintlanguage", "constraintlanguage should be FILTER or CQL_TEXT")
+		}
+		if err != nil {
+			return req, cswError(OWSInvalidParameterValue, "constraint", "invalid constraint: %v", err)
+		}
+	}
+
+	for _, key := range splitList(kvp["sortby"]) {
+		sp := cswSortProperty{Name: key}
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			switch strings.ToUpper(key[i+1:]) {
+			case "D":
+				sp.Descending = true
+				fallthrough
+			case "A":
+				sp.Name = key[:i]
+			}
+		}
+		req.SortBy = append(req.SortBy, sp)
+	}
+	return req, nil
+}
+
+// cswFilter is a Filter Encoding constraint, kept with the error
+// parsing it so that it is reported as an invalid constraint rather
+// than an invalid request
+type cswFilter struct {
+	Expr cql2.Expr
+	Err  error
+}
+
+// UnmarshalXML parses the filter
+func (f *cswFilter) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	f.Expr, f.Err = cql2.DecodeFES(decoder, start)
+	return nil
+}
+
+// cswConstraint is the constraint of a query, an Update or a Delete,
+// as a filter or CQL text
+type cswConstraint struct {
+	Filter  *cswFilter `xml:"Filter"`
+	CqlText string     `xml:"CqlText"`
+}
+
+// expr parses the constraint, which is nil when it is empty
+func (c *cswConstraint) expr() (cql2.Expr, error) {
+	switch {
+	case c.Filter != nil:
+		return c.Filter.Expr, c.Filter.Err
+	case strings.TrimSpace(c.CqlText) != "":
+		return cql2.ParseText(c.CqlText)
+	}
+	return nil, nil
+}
+
+// cswXMLRequest is a request POSTed as XML.  Elements are matched by
+// local name so that both CSW versions are read
+type cswXMLRequest struct {
+	XMLName        xml.Name
+	Service        string   `xml:"service,attr"`
+	Version        string   `xml:"version,attr"`
+	ResultType     string   `xml:"resultType,attr"`
+	OutputSchema   string   `xml:"outputSchema,attr"`
+	OutputFormat   string   `xml:"outputFormat,attr"`
+	StartPosition  string   `xml:"startPosition,attr"`
+	MaxRecords     string   `xml:"maxRecords,attr"`
+	AcceptVersions []string `xml:"AcceptVersions>Version"`
+	Query          *struct {
+		TypeNames      string         `xml:"typeNames,attr"`
+		ElementSetName string         `xml:"ElementSetName"`
+		ElementNames   []string       `xml:"ElementName"`
+		Constraint     *cswConstraint `xml:"Constraint"`
+		SortBy         []struct {
+			PropertyName   string `xml:"PropertyName"`
+			ValueReference string `xml:"ValueReference"`
+			SortOrder      string `xml:"SortOrder"`
+		} `xml:"SortBy>SortProperty"`
+	} `xml:"Query"`
+	IDs             []string `xml:"Id"`
+	ElementSetName  string   `xml:"ElementSetName"`
+	TypeNames       []string `xml:"TypeName"`
+	ParameterName   string   `xml:"ParameterName"`
+	PropertyName    string   `xml:"PropertyName"`
+	Source          string   `xml:"Source"`
+	ResourceType    string   `xml:"ResourceType"`
+	ResourceFormat  string   `xml:"ResourceFormat"`
+	HarvestInterval string   `xml:"HarvestInterval"`
+	ResponseHandler []string `xml:"ResponseHandler"`
+}
+
+// parseCSWXML reads a request from an XML document
+func parseCSWXML(body []byte) (cswRequest, error) {
+	var x cswXMLRequest
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&x); err != nil {
+		return cswRequest{}, cswError(OWSNoApplicableCode, "", "invalid request: %v", err).withStatus(http.StatusBadRequest)
+	}
+
+	req := cswRequest{
+		Service:         x.Service,
+		Request:         x.XMLName.Local,
+		Version:         x.Version,
+		AcceptVersions:  x.AcceptVersions,
+		TypeNames:       x.TypeNames,
+		ElementSetName:  strings.TrimSpace(x.ElementSetName),
+		ResultType:      x.ResultType,
+		OutputSchema:    x.OutputSchema,
+		OutputFormat:    x.OutputFormat,
+		StartPosition:   x.StartPosition,
+		MaxRecords:      x.MaxRecords,
+		ParameterName:   strings.TrimSpace(x.ParameterName),
+		PropertyName:    strings.TrimSpace(x.PropertyName),
+		Source:          strings.TrimSpace(x.Source),
+		ResourceType:    strings.TrimSpace(x.ResourceType),
+		ResourceFormat:  strings.TrimSpace(x.ResourceFormat),
+		HarvestInterval: strings.TrimSpace(x.HarvestInterval),
+		Body:            body,
+	}
+	for _, handler := range x.ResponseHandler {
+		req.ResponseHandler = append(req.ResponseHandler, strings.TrimSpace(handler))
+	}
+	for _, id := range x.IDs {
+		req.IDs = append(req.IDs, strings.TrimSpace(id))
+	}
+
+	if q := x.Query; q != nil {
+		req.TypeNames = strings.Fields(q.TypeNames)
+		req.ElementSetName = strings.TrimSpace(q.ElementSetName)
+		for _, name := range q.ElementNames {
+			req.ElementNames = append(req.ElementNames, strings.TrimSpace(name))
+		}
+		if c := q.Constraint; c != nil {
+			var err error
+			if req.Constraint, err = c.expr(); err != nil {
+				return req, cswError(OWSInvalidParameterValue, "Constraint", "invalid constraint: %v", err)
+			}
+		}
+		for _, s := range q.SortBy {
+			sp := cswSortProperty{Name: strings.TrimSpace(s.PropertyName)}
+			if sp.Name == "" {
+				sp.Name = strings.TrimSpace(s.ValueReference)
+			}
+			sp.Descending = strings.HasPrefix(strings.ToUpper(strings.TrimSpace(s.SortOrder)), "D")
+			req.SortBy = append(req.SortBy, sp)
+		}
+	}
+	return req, nil
+}
+
+// cswVersion negotiates the version of a response: GetCapabilities
+// answers the first of AcceptVersions supported, other operations the
+// version requested.  Exceptions are reported in CSW 3.0 until the
+// version is known
+func cswVersion(req cswRequest) (string, error) {
+	if req.Request == "GetCapabilities" && len(req.AcceptVersions) > 0 {
+		for _, v := range req.AcceptVersions {
+			if v = strings.TrimSpace(v); v == CSWVersion2 || v == CSWVersion3 {
+				return v, nil
+			}
+		}
+		return CSWVersion3, cswError(OWSVersionNegotiationFailed, "acceptversions", "none of %s is supported (should be %s or %s)",
+			strings.Join(req.AcceptVersions, ", "), CSWVersion3, CSWVersion2)
+	}
+	switch req.Version {
+	case "", "3.0":
+		return CSWVersion3, nil
+	case CSWVersion2, CSWVersion3:
+		return req.Version, nil
+	}
+	return CSWVersion3, cswError(OWSInvalidParameterValue, "version", "version %s is not supported (should be %s or %s)",
+		req.Version, CSWVersion3, CSWVersion2)
+}
+
+// cswParameter is a parameter of an operation and its allowed values
+type cswParameter struct {
+	Name   string
+	Values []string
+}
+
+// cswOperation describes an operation in the capabilities document
+type cswOperation struct {
+	Name        string
+	Parameters  []cswParameter
+	Constraints []cswParameter
+}
+
+// cswQueryables lists the Dublin Core and ISO queryables of csw:Record
+// filters, which cql2 resolves
+var cswQueryables = []cswParameter{
+	{"SupportedDublinCoreQueryables", []string{
+		"csw:AnyText", "dc:identifier", "dc:title", "dc:type", "dc:subject",
+		"dct:abstract", "dc:description", "dc:creator", "dc:publisher",
+		"dc:rights", "dc:language", "dc:date", "dct:modified", "dc:format",
+		"dc:relation",
+		"ows:BoundingBox",
+	}},
+	{"SupportedISOQueryables", []string{
+		"apiso:AnyText", "apiso:Identifier", "apiso:Title", "apiso:Type",
+		"apiso:Subject", "apiso:Abstract", "apiso:OrganisationName",
+		"apiso:Language", "apiso:Modified", "apiso:CreationDate",
+		"apiso:TempExtent_begin", "apiso:TempExtent_end", "apiso:Format",
+		"apiso:BoundingBox", "apiso:CRS", "apiso:ParentIdentifier",
+		"apiso:Platform", "apiso:CloudCoverPercentage",
+	}},
+}
+
+// cswOperations describes the operations of a CSW version, with
+// Transaction and Harvest when transactions are enabled
+func cswOperations(version string, transactions bool) []cswOperation {
+	ns := cswVersions[version]
+	formats := []string{"application/xml", "text/xml"}
+	elementSets := []string{ElementSetBrief, ElementSetSummary, ElementSetFull}
+
+	operations := []cswOperation{{
+		Name:       "GetCapabilities",
+		Parameters: []cswParameter{{"AcceptVersions", []string{CSWVersion3, CSWVersion2}}, {"AcceptFormats", formats}},
+	}}
+	if version == CSWVersion2 {
+		operations = append(operations, cswOperation{
+			Name: "DescribeRecord",
+			Parameters: []cswParameter{
+				{"typeName", []string{"csw:Record"}},
+				{"outputFormat", formats},
+				{"schemaLanguage", []string{"http://www.w3.org/XML/Schema"}},
+			},
+		})
+	}
+	operations = append(operations,
+		cswOperation{
+			Name: "GetRecords",
+			Parameters: []cswParameter{
+				{"typeNames", []string{"csw:Record"}},
+				{"outputSchema", []string{ns.CSW}},
+				{"outputFormat", formats},
+				{"resultType", []string{"hits", "results", "validate"}},
+				{"ElementSetName", elementSets},
+				{"CONSTRAINTLANGUAGE", []string{"FILTER", "CQL_TEXT"}},
+			},
+			Constraints: cswQueryables,
+		},
+		cswOperation{
+			Name: "GetRecordById",
+			Parameters: []cswParameter{
+				{"outputSchema", []string{ns.CSW}},
+				{"outputFormat", formats},
+				{"ElementSetName", elementSets},
+			},
+		},
+	)
+	if transactions {
+		operations = append(operations,
+			cswOperation{
+				Name: "Transaction",
+				Parameters: []cswParameter{
+					{"TypeNames", []string{"csw:Record", "gmd:MD_Metadata"}},
+					{"ConstraintLanguage", []string{"FILTER", "CQL_TEXT"}},
+				},
+			},
+			cswOperation{
+				Name: "Harvest",
+				Parameters: []cswParameter{
+					{"ResourceType", cswResourceTypes},
+					{"ResourceFormat", formats},
+				},
+			},
+		)
+	}
+
+	// GetDomain describes the parameters of the other operations, as
+	// Operation.parameter
+	var parameterNames []string
+	for _, op := range operations {
+		for _, p := range op.Parameters {
+			parameterNames = append(parameterNames, op.Name+"."+p.Name)
+		}
+	}
+	return append(operations, cswOperation{
+		Name:       "GetDomain",
+		Parameters: []cswParameter{{"ParameterName", parameterNames}, {"PropertyName", cswDomainProperties()}},
+	})
+}
+
+// cswDomainProperties lists the Dublin Core queryables GetDomain counts
+// the values of, which are those faceted on
+func cswDomainProperties() []string {
+	var names []string
+	for _, name := range cswQueryables[0].Values {
+		if q, ok := cql2.Lookup(name); ok {
+			if _, err := search.ParseFacets(q.Name); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Comparison and spatial operators of the filter capabilities
+var (
+	fes1ComparisonOperators = []string{"EqualTo", "NotEqualTo", "LessThan", "GreaterThan", "LessThanEqualTo", "GreaterThanEqualTo", "Like", "Between", "NullCheck"}
+	fes2ComparisonOperators = []string{"PropertyIsEqualTo", "PropertyIsNotEqualTo", "PropertyIsLessThan", "PropertyIsGreaterThan", "PropertyIsLessThanOrEqualTo", "PropertyIsGreaterThanOrEqualTo", "PropertyIsLike", "PropertyIsBetween", "PropertyIsNull", "PropertyIsNil"}
+	fesSpatialOperators     = []string{"BBOX", "Intersects", "Disjoint", "Within", "Contains"}
+	gmlGeometryOperands     = []string{"Envelope", "Point", "LineString", "Polygon"}
+)
+
+var cswCapabilitiesTemplate = template.Must(template.New("capabilities").Funcs(template.FuncMap{
+	"xml": func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	},
+}).Parse(CSWCapabilitiesXML))
+
+// cswGetCapabilities describes the service
+func cswGetCapabilities(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, version string) {
+	comparisonOperators := fes2ComparisonOperators
+	if version == CSWVersion2 {
+		comparisonOperators = fes1ComparisonOperators
+	}
+	data := map[string]interface{}{
+		"Version":             version,
+		"V3":                  version == CSWVersion3,
+		"NS":                  cswVersions[version],
+		"URL":                 cat.Config.Server.URL + "/csw",
+		"Identification":      cat.Config.Metadata.Identification,
+		"Provider":            cat.Config.Metadata.Provider,
+		"Contact":             cat.Config.Metadata.Contact,
+		"Operations":          cswOperations(version, cat.Config.Server.Transactions),
+		"ComparisonOperators": comparisonOperators,
+		"SpatialOperators":    fesSpatialOperators,
+		"GeometryOperands":    gmlGeometryOperands,
+	}
+	var b bytes.Buffer
+	if err := cswCapabilitiesTemplate.Execute(&b, data); err != nil {
+		cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "%v", err))
+		return
+	}
+	cswEmit(w, cat, http.StatusOK, b.Bytes())
+}
+
+// cswDescribeRecord points to the schema of csw:Record; CSW 3.0 has
+// no DescribeRecord
+func cswDescribeRecord(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	if version != CSWVersion2 {
+		cswEmitException(w, cat, version, cswError(OWSOperationNotSupported, "request", "DescribeRecord is not supported by CSW %s", version))
+		return
+	}
+	if err := cswCheckTypeNames(req.TypeNames, "typename"); err != nil {
+		cswEmitException(w, cat, version, err)
+		return
+	}
+
+	ns := cswVersions[version]
+	response := cswDescribeRecordResponse{Namespaces: ns.declarations()}
+	response.SchemaComponent.TargetNamespace = ns.CSW
+	response.SchemaComponent.SchemaLanguage = "http://www.w3.org/XML/Schema"
+	response.SchemaComponent.Schema.XS = "http://www.w3.org/2001/XMLSchema"
+	response.SchemaComponent.Schema.TargetNamespace = ns.CSW
+	response.SchemaComponent.Schema.Include.SchemaLocation = "http://schemas.opengis.net/csw/2.0.2/record.xsd"
+	cswEmitXML(w, cat, version, response)
+}
+
+// cswCheckTypeNames accepts csw:Record, the only type records are
+// served as
+func cswCheckTypeNames(typeNames []string, locator string) error {
+	for _, name := range typeNames {
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name != "Record" {
+			return cswError(OWSInvalidParameterValue, locator, "type %s is not supported (should be csw:Record)", name)
+		}
+	}
+	return nil
+}
+
+// cswCheckOutput accepts the csw:Record output schema of either
+// version, in XML
+func cswCheckOutput(req cswRequest) error {
+	if s := req.OutputSchema; s != "" && s != "csw:Record" && s != cswVersions[CSWVersion2].CSW && s != cswVersions[CSWVersion3].CSW {
+		return cswError(OWSInvalidParameterValue, "outputschema", "output schema %s is not supported", s)
+	}
+	switch req.OutputFormat {
+	case "", "application/xml", "text/xml":
+		return nil
+	}
+	return cswError(OWSInvalidParameterValue, "outputformat", "output format %s is not supported (should be application/xml)", req.OutputFormat)
+}
+
+// cswElementSet returns the element set of records, summary unless
+// set by ElementSetName or made of ElementName
+func cswElementSet(req cswRequest) (string, error) {
+	if req.ElementSetName != "" && len(req.ElementNames) > 0 {
+		return "", cswError(OWSInvalidParameterValue, "elementsetname", "elementsetname and elementname are mutually exclusive")
+	}
+	switch strings.ToLower(req.ElementSetName) {
+	case "":
+		if len(req.ElementNames) > 0 {
+			return "", nil
+		}
+		return ElementSetSummary, nil
+	case ElementSetBrief, ElementSetSummary, ElementSetFull:
+		return strings.ToLower(req.ElementSetName), nil
+	}
+	return "", cswError(OWSInvalidParameterValue, "elementsetname", "element set %s is not supported (should be brief, summary or full)", req.ElementSetName)
+}
+
+// cswSort maps sort properties, which are queryables, to sort fields
+func cswSort(properties []cswSortProperty) ([]search.SortField, error) {
+	var fields []search.SortField
+	for _, p := range properties {
+		name := p.Name
+		if q, ok := cql2.Lookup(name); ok {
+			name = strings.TrimPrefix(q.Name, "eo:")
+		}
+		order := ":A"
+		if p.Descending {
+			order = ":D"
+		}
+		sf, err := search.ParseSort(name + order)
+		if err != nil {
+			return nil, cswError(OWSInvalidParameterValue, "sortby", "cannot sort on %s", p.Name)
+		}
+		fields = append(fields, sf...)
+	}
+	return fields, nil
+}
+
+// cswPosition parses a positive integer parameter
+func cswPosition(value string, locator string, fallback int, min int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, cswError(OWSInvalidParameterValue, locator, "%s should be an integer of at least %d", locator, min)
+	}
+	return n, nil
+}
+
+// cswGetRecords searches records
+func cswGetRecords(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	ns := cswVersions[version]
+
+	resultType := strings.ToLower(req.ResultType)
+	switch resultType {
+	case "":
+		// CSW 2.0.2 answers hits by default
+		resultType = "results"
+		if version == CSWVersion2 {
+			resultType = "hits"
+		}
+	case "results", "hits", "validate":
+	default:
+		cswEmitException(w, cat, version, cswError(OWSInvalidParameterValue, "resulttype", "result type %s is not supported (should be results, hits or validate)", req.ResultType))
+		return
+	}
+
+	elementSet, err := cswElementSet(req)
+	if err == nil {
+		err = cswCheckTypeNames(req.TypeNames, "typenames")
+	}
+	if err == nil {
+		err = cswCheckOutput(req)
+	}
+	startPosition, maxRecords := 1, 10
+	if err == nil {
+		startPosition, err = cswPosition(req.StartPosition, "startposition", 1, 1)
+	}
+	if err == nil {
+		maxRecords, err = cswPosition(req.MaxRecords, "maxrecords", 10, 0)
+	}
+	var sortBy []search.SortField
+	if err == nil {
+		sortBy, err = cswSort(req.SortBy)
+	}
+	if err != nil {
+		cswEmitException(w, cat, version, err)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if resultType == "validate" {
+		response := cswAcknowledgement{Namespaces: ns.declarations(), TimeStamp: now}
+		echoed := stripXMLDeclaration(req.Body)
+		if r.Method != http.MethodPost {
+			// A KVP request is echoed as its query string
+			var query bytes.Buffer
+			xml.EscapeText(&query, []byte(r.URL.RawQuery))
+			echoed = query.Bytes()
+		}
+		response.EchoedRequest.Request = string(echoed)
+		cswEmitXML(w, cat, version, response)
+		return
+	}
+
+	if limit := cat.Config.Server.Limit; limit > 0 && maxRecords > limit {
+		maxRecords = limit
+	}
+	if resultType == "hits" {
+		maxRecords = 0
+	}
+	results, err := cat.Search(r.Context(), search.Request{
+		Filter: req.Constraint,
+		Sort:   sortBy,
+		From:   startPosition - 1,
+		Size:   maxRecords,
+	})
+	if err != nil {
+		cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "%v", err).withStatus(repositoryStatus(err)))
+		return
+	}
+
+	response := cswGetRecordsResponse{Namespaces: ns.declarations(), Version: version}
+	response.SearchStatus.Timestamp = now
+	sr := cswSearchResults{
+		Matched:      results.Matches,
+		RecordSchema: ns.CSW,
+		ElementSet:   elementSet,
+	}
+	if resultType == "results" {
+		sr.Returned = len(results.Records)
+		if results.NextRecord > 0 {
+			sr.NextRecord = results.NextRecord + 1
+		}
+		for _, record := range results.Records {
+			sr.Records = append(sr.Records, cswRecord(record, elementSet, req.ElementNames))
+		}
+	}
+	if version == CSWVersion3 {
+		sr.Status = "complete"
+		if sr.NextRecord > 0 {
+			sr.Status = "subset"
+		}
+	}
+	response.SearchResults = sr
+	cswEmitXML(w, cat, version, response)
+}
+
+// cswGetRecordById retrieves records by identifier
+func cswGetRecordById(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	if len(req.IDs) == 0 {
+		cswEmitException(w, cat, version, cswError(OWSMissingParameterValue, "id", "id is required"))
+		return
+	}
+	elementSet, err := cswElementSet(req)
+	if err == nil {
+		err = cswCheckOutput(req)
+	}
+	if err != nil {
+		cswEmitException(w, cat, version, err)
+		return
+	}
+
+	results, err := cat.Get(r.Context(), req.IDs)
+	if err != nil {
+		cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "%v", err).withStatus(repositoryStatus(err)))
+		return
+	}
+
+	ns := cswVersions[version]
+	if version == CSWVersion3 {
+		// CSW 3.0 answers the record itself
+		if len(results.Records) == 0 {
+			cswEmitException(w, cat, version, cswError(OWSNotFound, "id", "no record %s", strings.Join(req.IDs, ", ")))
+			return
+		}
+		record := cswRecord(results.Records[0], elementSet, req.ElementNames)
+		record.Attrs = append(ns.declarations(), record.Attrs...)
+		cswEmitXML(w, cat, version, record)
+		return
+	}
+
+	response := cswGetRecordByIdResponse{Namespaces: ns.declarations()}
+	for _, record := range results.Records {
+		response.Records = append(response.Records, cswRecord(record, elementSet, req.ElementNames))
+	}
+	cswEmitXML(w, cat, version, response)
+}
+
+// cswGetDomain lists the values of a request parameter, or of a
+// property with the number of records holding each
+func cswGetDomain(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	ns := cswVersions[version]
+	response := cswGetDomainResponse{Namespaces: ns.declarations()}
+	response.DomainValues.Type = "csw:Record"
+
+	switch {
+	case req.ParameterName != "":
+		response.DomainValues.ParameterName = req.ParameterName
+		parts := strings.SplitN(req.ParameterName, ".", 2)
+		found := false
+		for _, op := range cswOperations(version, cat.Config.Server.Transactions) {
+			for _, p := range op.Parameters {
+				if len(parts) == 2 && strings.EqualFold(op.Name, parts[0]) && strings.EqualFold(p.Name, parts[1]) {
+					found = true
+					for _, v := range p.Values {
+						response.DomainValues.Values = append(response.DomainValues.Values, cswDomainValue{Value: v})
+					}
+				}
+			}
+		}
+		if !found {
+			cswEmitException(w, cat, version, cswError(OWSInvalidParameterValue, "parametername", "unknown parameter %s (should be Operation.parameter)", req.ParameterName))
+			return
+		}
+	case req.PropertyName != "":
+		response.DomainValues.PropertyName = req.PropertyName
+		q, ok := cql2.Lookup(req.PropertyName)
+		var facets []string
+		var err error
+		if ok {
+			facets, err = search.ParseFacets(q.Name)
+		}
+		if !ok || err != nil {
+			cswEmitException(w, cat, version, cswError(OWSInvalidParameterValue, "propertyname", "cannot list the values of %s", req.PropertyName))
+			return
+		}
+		results, err := cat.Facets(r.Context(), search.Request{}, facets)
+		if err != nil {
+			cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "%v", err).withStatus(repositoryStatus(err)))
+			return
+		}
+		counts := results.Facets[facets[0]]
+		values := make([]string, 0, len(counts))
+		for v := range counts {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		for _, v := range values {
+			response.DomainValues.Values = append(response.DomainValues.Values, cswDomainValue{Count: counts[v], Value: v})
+		}
+	default:
+		cswEmitException(w, cat, version, cswError(OWSMissingParameterValue, "parametername", "one of parametername or propertyname is required"))
+		return
+	}
+	cswEmitXML(w, cat, version, response)
+}
+
+// stripXMLDeclaration removes the XML declaration of a document to
+// embed it in another
+func stripXMLDeclaration(doc []byte) []byte {
+	doc = bytes.TrimSpace(doc)
+	if bytes.HasPrefix(doc, []byte("<?xml")) {
+		if i := bytes.Index(doc, []byte("?>")); i >= 0 {
+			doc = bytes.TrimSpace(doc[i+2:])
+		}
+	}
+	return doc
+}
+
+// cswEmit writes an XML response
+func cswEmit(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, status int, body []byte) {
+	geocatalogo.EmitResponseType(cat, w, status, "application/xml; charset=UTF-8", body)
+}
+
+// cswEmitXML writes a response document
+func cswEmitXML(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, version string, doc interface{}) {
+	if err := emitXML(w, cat, "application/xml", doc); err != nil {
+		cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "%v", err))
+	}
+}
+
+// cswEmitException writes an OWS exception report
+func cswEmitException(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, version string, err error) {
+	e, ok := err.(*cswException)
+	if !ok {
+		e = cswError(OWSNoApplicableCode, "", "%v", err)
+	}
+	ns := cswVersions[version]
+	report := owsExceptionReport{XMLNS: ns.OWS, Version: ns.OWSVersion}
+	report.Exception.Code = e.Code
+	report.Exception.Locator = e.Locator
+	report.Exception.Text = e.Text
+	body, _ := xml.MarshalIndent(report, "", "  ")
+	cswEmit(w, cat, e.Status, append([]byte(xml.Header), body...))
+}
+
+// isCSWRequest reports whether a request to the default API is a CSW
+// request rather than an OpenSearch query
+func isCSWRequest(r *http.Request) bool {
+	if r.Method == http.MethodPost {
+		return true
+	}
+	for k := range r.URL.Query() {
+		if k = strings.ToLower(k); k == "service" || k == "request" {
+			return true
+		}
+	}
+	return false
+}
+
+// CSWHandler serves OGC CSW 2.0.2 and 3.0 requests, in KVP (GET) or
+// XML (POST)
+func CSWHandler(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	var req cswRequest
+	var err error
+	if r.Method == http.MethodPost {
+		var body []byte
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err == nil {
+			req, err = parseCSWXML(body)
+		}
+	} else {
+		req, err = parseCSWKVP(r.URL.Query())
+	}
+
+	version, verr := cswVersion(req)
+	if err == nil {
+		err = verr
+	}
+	if err == nil {
+		switch {
+		case req.Request == "":
+			err = cswError(OWSMissingParameterValue, "request", "request is required")
+		case req.Service == "" && r.Method != http.MethodPost:
+			err = cswError(OWSMissingParameterValue, "service", "service is required")
+		case req.Service != "" && !strings.EqualFold(req.Service, "CSW"):
+			err = cswError(OWSInvalidParameterValue, "service", "service %s is not supported (should be CSW)", req.Service)
+		}
+	}
+	if err != nil {
+		cswEmitException(w, cat, version, err)
+		return
+	}
+
+	switch req.Request {
+	case "GetCapabilities":
+		cswGetCapabilities(w, cat, version)
+	case "DescribeRecord":
+		cswDescribeRecord(w, cat, version, req)
+	case "GetRecords":
+		cswGetRecords(w, r, cat, version, req)
+	case "GetRecordById":
+		cswGetRecordById(w, r, cat, version, req)
+	case "GetDomain":
+		cswGetDomain(w, r, cat, version, req)
+	case "Transaction", "Harvest":
+		if !cat.Config.Server.Transactions {
+			cswEmitException(w, cat, version, cswError(OWSOperationNotSupported, "request", "%s is disabled on this server", req.Request))
+			return
+		}
+		if req.Request == "Harvest" {
+			cswHarvest(w, r, cat, version, req)
+		} else {
+			cswTransaction(w, r, cat, version, req)
+		}
+	default:
+		cswEmitException(w, cat, version, cswError(OWSOperationNotSupported, "request", "operation %s is not supported", req.Request))
+	}
+}
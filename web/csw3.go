@@ -27,13 +27,14 @@
 package web
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/go-spatial/geocatalogo"
 	"github.com/go-spatial/geocatalogo/search"
+	"github.com/go-spatial/geocatalogo/spatial"
 	"github.com/gorilla/mux"
 )
 
@@ -41,18 +42,38 @@ import (
 func CSW3OpenSearchHandler(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
 	var q string
 	var recordids []string
-	var bbox []float64
-	var timeVal []time.Time
 	var startPosition int
 	var maxRecords = 10
 	var value []string
 	var collections []string
+	var sortBy []search.SortField
 	var results search.Results
 
 	kvp := make(map[string][]string)
 
+	// OpenSearch clients send the optional parameters of a URL
+	// template empty
 	for k, v := range r.URL.Query() {
-		kvp[strings.ToLower(k)] = v
+		if len(v) > 0 && v[0] != "" {
+			kvp[strings.ToLower(k)] = v
+		}
+	}
+
+	atom := false
+	value, _ = kvp["outputformat"]
+	if len(value) > 0 {
+		// an unescaped + is read as a space
+		switch strings.Replace(value[0], " ", "+", -1) {
+		case AtomMimeType:
+			atom = true
+		case "application/json":
+		default:
+			exception := search.Exception{
+				Code:        20003,
+				Description: "ERROR: outputformat should be application/json or " + AtomMimeType}
+			EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
+			return
+		}
 	}
 
 	value, _ = kvp["startposition"]
@@ -75,26 +96,45 @@ func CSW3OpenSearchHandler(w http.ResponseWriter, r *http.Request, cat *geocatal
 		recordids = strings.Split(value[0], ",")
 	}
 
-	// Extract property filters from query parameters
-	propertyFilters := make(map[string]string)
-	propertyKeys := []string{
-		"collection", "type", "title", "owner",
-		"continent", "country", "state", "state_province", "city", "admin2", "county",
-		"data_format", "implementation_status", "status", "geographic_scope",
-		"database_table", "v6_job_file", "v6_job_type", "s3_path",
+	value, _ = kvp["sortby"]
+	if len(value) > 0 {
+		var err error
+		sortBy, err = search.ParseSort(value[0])
+		if err != nil {
+			exception := search.Exception{
+				Code:        20003,
+				Description: "ERROR: " + err.Error()}
+			EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
+			return
+		}
 	}
 
-	for _, key := range propertyKeys {
-		if val, ok := kvp[key]; ok && len(val) > 0 {
-			propertyFilters[key] = val[0]
-		}
+	spatialFilter, err := csw3SpatialFilter(kvp)
+	if err != nil {
+		exception := search.Exception{
+			Code:        20003,
+			Description: "ERROR: " + err.Error()}
+		EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
+		return
+	}
+
+	temporalFilter, err := csw3TemporalFilter(kvp)
+	if err != nil {
+		exception := search.Exception{
+			Code:        20003,
+			Description: "ERROR: " + err.Error()}
+		EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
+		return
 	}
 
-	// Allow property filters, q, or recordids as valid query methods
-	if q == "" && len(recordids) < 1 && len(propertyFilters) < 1 {
+	// Extract property filters from query parameters
+	propertyFilters := search.ParseFilters(kvp)
+
+	// Allow property filters, q, bbox/geometry, start/end or recordids as valid query methods
+	if q == "" && len(recordids) < 1 && len(propertyFilters) < 1 && spatialFilter == nil && temporalFilter == nil {
 		exception := search.Exception{
 			Code:        20001,
-			Description: "ERROR: one of q, recordids, or property filters are required"}
+			Description: "ERROR: one of q, recordids, bbox, geometry, start, end or property filters are required"}
 		EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
 		return
 	}
@@ -108,22 +148,112 @@ func CSW3OpenSearchHandler(w http.ResponseWriter, r *http.Request, cat *geocatal
 	}
 
 	if len(recordids) > 0 {
-		results = cat.Get(recordids)
+		results, err = cat.Get(r.Context(), recordids)
 	} else {
 		// Use Search for both q and property filters
-		results = cat.Search(collections, q, bbox, timeVal, startPosition, maxRecords, propertyFilters)
+		req := search.Request{
+			Collections: collections,
+			Term:        q,
+			Filters:     propertyFilters,
+			Spatial:     spatialFilter,
+			Temporal:    temporalFilter,
+			Sort:        sortBy,
+			From:        startPosition,
+			Size:        maxRecords,
+		}
+		value, _ = kvp["token"]
+		if len(value) > 0 {
+			if err := cat.ApplyToken(value[0], &req); err != nil {
+				exception := search.Exception{
+					Code:        20004,
+					Description: "ERROR: " + err.Error()}
+				EmitResponseNotOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &exception)
+				return
+			}
+		}
+		results, err = cat.Search(r.Context(), req)
+	}
+	if err != nil {
+		exception := repositoryException(err)
+		EmitResponseError(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, repositoryStatus(err), &exception)
+		return
 	}
 
+	if atom {
+		EmitAtomResponse(w, r, cat, q, startPosition, maxRecords, &results)
+		return
+	}
 	EmitResponseOK(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, &results)
 
 	return
 }
 
-// CSW3OpenSearchRouter provides CSW 3 OpenSearch Routing
+// csw3TemporalFilter builds a temporal filter from the start and end
+// parameters of the OpenSearch time extension, returning nil when
+// neither is given
+func csw3TemporalFilter(kvp map[string][]string) (*search.TemporalFilter, error) {
+	start, end := "..", ".."
+	if value, _ := kvp["start"]; len(value) > 0 {
+		start = value[0]
+	}
+	if value, _ := kvp["end"]; len(value) > 0 {
+		end = value[0]
+	}
+	if start == ".." && end == ".." {
+		return nil, nil
+	}
+	return search.ParseTemporalFilter(start + "/" + end)
+}
+
+// csw3SpatialFilter builds a spatial filter from the bbox or geometry
+// (WKT) and relation parameters, returning nil when neither is given
+func csw3SpatialFilter(kvp map[string][]string) (*search.SpatialFilter, error) {
+	var sf search.SpatialFilter
+	var err error
+
+	bbox, _ := kvp["bbox"]
+	geometry, _ := kvp["geometry"]
+	switch {
+	case len(bbox) > 0 && len(geometry) > 0:
+		return nil, fmt.Errorf("bbox and geometry are mutually exclusive")
+	case len(bbox) > 0:
+		if sf.BBox, err = search.ParseBBox(bbox[0]); err != nil {
+			return nil, err
+		}
+	case len(geometry) > 0:
+		if sf.Geometry, err = spatial.ParseWKT(geometry[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	relation, _ := kvp["relation"]
+	if len(relation) > 0 {
+		if sf.Relation, err = search.ParseRelation(relation[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &sf, nil
+}
+
+// CSW3OpenSearchRouter provides CSW 3 OpenSearch Routing.  CSW requests
+// are served at /csw, and at / when they carry service or request
+// parameters or are POSTed
 func CSW3OpenSearchRouter(cat *geocatalogo.GeoCatalogue) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if isCSWRequest(r) {
+			CSWHandler(w, r, cat)
+			return
+		}
 		CSW3OpenSearchHandler(w, r, cat)
+	}).Methods("GET", "POST")
+	router.HandleFunc("/csw", func(w http.ResponseWriter, r *http.Request) {
+		CSWHandler(w, r, cat)
+	}).Methods("GET", "POST")
+	router.HandleFunc("/opensearch.xml", func(w http.ResponseWriter, r *http.Request) {
+		OpenSearchDescriptionHandler(w, r, cat)
 	}).Methods("GET")
 	return router
 }
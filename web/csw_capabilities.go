@@ -0,0 +1,184 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// CSW capabilities document
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package web
+
+// CSWCapabilitiesXML is the template of the CSW 2.0.2 and 3.0
+// capabilities documents, rendered with text/template; values are
+// escaped with the xml function
+var CSWCapabilitiesXML = `<?xml version="1.0" encoding="UTF-8"?>
+<csw:Capabilities xmlns:csw="{{.NS.CSW}}" xmlns:ows="{{.NS.OWS}}" xmlns:{{if .V3}}fes{{else}}ogc{{end}}="{{.NS.FES}}" xmlns:gml="http://www.opengis.net/gml" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" version="{{.Version}}" xsi:schemaLocation="{{.NS.CSW}} {{.NS.Schema}}">
+  <ows:ServiceIdentification>
+    <ows:Title>{{xml .Identification.Title}}</ows:Title>
+    <ows:Abstract>{{xml .Identification.Abstract}}</ows:Abstract>
+    {{- if .Identification.Keywords}}
+    <ows:Keywords>
+      {{- range .Identification.Keywords}}
+      <ows:Keyword>{{xml .}}</ows:Keyword>
+      {{- end}}
+      {{- if .Identification.KeywordsType}}
+      <ows:Type>{{xml .Identification.KeywordsType}}</ows:Type>
+      {{- end}}
+    </ows:Keywords>
+    {{- end}}
+    <ows:ServiceType codeSpace="OGC">CSW</ows:ServiceType>
+    <ows:ServiceTypeVersion>{{.Version}}</ows:ServiceTypeVersion>
+    <ows:Fees>{{xml .Identification.Fees}}</ows:Fees>
+    <ows:AccessConstraints>{{xml .Identification.AccessConstraints}}</ows:AccessConstraints>
+  </ows:ServiceIdentification>
+  <ows:ServiceProvider>
+    <ows:ProviderName>{{xml .Provider.Name}}</ows:ProviderName>
+    <ows:ProviderSite xlink:type="simple" xlink:href="{{xml .Provider.URL}}"/>
+    <ows:ServiceContact>
+      <ows:IndividualName>{{xml .Contact.Name}}</ows:IndividualName>
+      <ows:PositionName>{{xml .Contact.Position}}</ows:PositionName>
+      <ows:ContactInfo>
+        <ows:Phone>
+          <ows:Voice>{{xml .Contact.Phone}}</ows:Voice>
+          <ows:Facsimile>{{xml .Contact.Fax}}</ows:Facsimile>
+        </ows:Phone>
+        <ows:Address>
+          <ows:DeliveryPoint>{{xml .Contact.Address}}</ows:DeliveryPoint>
+          <ows:City>{{xml .Contact.City}}</ows:City>
+          <ows:AdministrativeArea>{{xml .Contact.StateOrProvince}}</ows:AdministrativeArea>
+          <ows:PostalCode>{{xml .Contact.PostalCode}}</ows:PostalCode>
+          <ows:Country>{{xml .Contact.Country}}</ows:Country>
+          <ows:ElectronicMailAddress>{{xml .Contact.Email}}</ows:ElectronicMailAddress>
+        </ows:Address>
+        <ows:OnlineResource xlink:type="simple" xlink:href="{{xml .Contact.URL}}"/>
+        <ows:HoursOfService>{{xml .Contact.Hours}}</ows:HoursOfService>
+        <ows:ContactInstructions>{{xml .Contact.Instructions}}</ows:ContactInstructions>
+      </ows:ContactInfo>
+      <ows:Role>{{xml .Contact.Role}}</ows:Role>
+    </ows:ServiceContact>
+  </ows:ServiceProvider>
+  <ows:OperationsMetadata>
+    {{- $v3 := .V3}}{{$url := .URL}}
+    {{- range .Operations}}
+    <ows:Operation name="{{.Name}}">
+      <ows:DCP>
+        <ows:HTTP>
+          <ows:Get xlink:type="simple" xlink:href="{{xml $url}}"/>
+          <ows:Post xlink:type="simple" xlink:href="{{xml $url}}"/>
+        </ows:HTTP>
+      </ows:DCP>
+      {{- range .Parameters}}
+      <ows:Parameter name="{{.Name}}">
+        {{- if $v3}}
+        <ows:AllowedValues>
+          {{- range .Values}}
+          <ows:Value>{{xml .}}</ows:Value>
+          {{- end}}
+        </ows:AllowedValues>
+        {{- else}}
+        {{- range .Values}}
+        <ows:Value>{{xml .}}</ows:Value>
+        {{- end}}
+        {{- end}}
+      </ows:Parameter>
+      {{- end}}
+      {{- range .Constraints}}
+      <ows:Constraint name="{{.Name}}">
+        {{- if $v3}}
+        <ows:AllowedValues>
+          {{- range .Values}}
+          <ows:Value>{{xml .}}</ows:Value>
+          {{- end}}
+        </ows:AllowedValues>
+        {{- else}}
+        {{- range .Values}}
+        <ows:Value>{{xml .}}</ows:Value>
+        {{- end}}
+        {{- end}}
+      </ows:Constraint>
+      {{- end}}
+    </ows:Operation>
+    {{- end}}
+    <ows:Parameter name="service">
+      {{- if .V3}}
+      <ows:AllowedValues>
+        <ows:Value>CSW</ows:Value>
+      </ows:AllowedValues>
+      {{- else}}
+      <ows:Value>CSW</ows:Value>
+      {{- end}}
+    </ows:Parameter>
+    <ows:Parameter name="version">
+      {{- if .V3}}
+      <ows:AllowedValues>
+        <ows:Value>3.0.0</ows:Value>
+        <ows:Value>2.0.2</ows:Value>
+      </ows:AllowedValues>
+      {{- else}}
+      <ows:Value>2.0.2</ows:Value>
+      <ows:Value>3.0.0</ows:Value>
+      {{- end}}
+    </ows:Parameter>
+  </ows:OperationsMetadata>
+  {{- if .V3}}
+  <fes:Filter_Capabilities>
+    <fes:Conformance>
+      <fes:Constraint name="ImplementsQuery"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsAdHocQuery"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsFunctions"><ows:NoValues/><ows:DefaultValue>FALSE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsMinStandardFilter"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsStandardFilter"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsMinSpatialFilter"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsSpatialFilter"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsMinTemporalFilter"><ows:NoValues/><ows:DefaultValue>FALSE</ows:DefaultValue></fes:Constraint>
+      <fes:Constraint name="ImplementsSorting"><ows:NoValues/><ows:DefaultValue>TRUE</ows:DefaultValue></fes:Constraint>
+    </fes:Conformance>
+    <fes:Scalar_Capabilities>
+      <fes:LogicalOperators/>
+      <fes:ComparisonOperators>
+        {{- range .ComparisonOperators}}
+        <fes:ComparisonOperator name="{{.}}"/>
+        {{- end}}
+      </fes:ComparisonOperators>
+    </fes:Scalar_Capabilities>
+    <fes:Spatial_Capabilities>
+      <fes:GeometryOperands>
+        {{- range .GeometryOperands}}
+        <fes:GeometryOperand name="gml:{{.}}"/>
+        {{- end}}
+      </fes:GeometryOperands>
+      <fes:SpatialOperators>
+        {{- range .SpatialOperators}}
+        <fes:SpatialOperator name="{{.}}"/>
+        {{- end}}
+      </fes:SpatialOperators>
+    </fes:Spatial_Capabilities>
+  </fes:Filter_Capabilities>
+  {{- else}}
+  <ogc:Filter_Capabilities>
+    <ogc:Spatial_Capabilities>
+      <ogc:GeometryOperands>
+        {{- range .GeometryOperands}}
+        <ogc:GeometryOperand>gml:{{.}}</ogc:GeometryOperand>
+        {{- end}}
+      </ogc:GeometryOperands>
+      <ogc:SpatialOperators>
+        {{- range .SpatialOperators}}
+        <ogc:SpatialOperator name="{{.}}"/>
+        {{- end}}
+      </ogc:SpatialOperators>
+    </ogc:Spatial_Capabilities>
+    <ogc:Scalar_Capabilities>
+      <ogc:LogicalOperators/>
+      <ogc:ComparisonOperators>
+        {{- range .ComparisonOperators}}
+        <ogc:ComparisonOperator>{{.}}</ogc:ComparisonOperator>
+        {{- end}}
+      </ogc:ComparisonOperators>
+    </ogc:Scalar_Capabilities>
+    <ogc:Id_Capabilities>
+      <ogc:EID/>
+      <ogc:FID/>
+    </ogc:Id_Capabilities>
+  </ogc:Filter_Capabilities>
+  {{- end}}
+</csw:Capabilities>
+`
@@ -0,0 +1,329 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// CSW record encodings and response documents
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+// CSW versions
+const (
+	CSWVersion2 = "2.0.2"
+	CSWVersion3 = "3.0.0"
+)
+
+// CSW element sets
+const (
+	ElementSetBrief   = "brief"
+	ElementSetSummary = "summary"
+	ElementSetFull    = "full"
+)
+
+// cswNamespaces holds the namespaces of a CSW version
+type cswNamespaces struct {
+	CSW string
+	OWS string
+	FES string
+	// OWSVersion is the version of OWS exception reports
+	OWSVersion string
+	// Schema is the location of the CSW schemas
+	Schema string
+}
+
+var cswVersions = map[string]cswNamespaces{
+	CSWVersion2: {
+		CSW:        "http://www.opengis.net/cat/csw/2.0.2",
+		OWS:        "http://www.opengis.net/ows",
+		FES:        "http://www.opengis.net/ogc",
+		OWSVersion: "1.2.0",
+		Schema:     "http://schemas.opengis.net/csw/2.0.2/CSW-discovery.xsd",
+	},
+	CSWVersion3: {
+		CSW:        "http://www.opengis.net/cat/csw/3.0",
+		OWS:        "http://www.opengis.net/ows/2.0",
+		FES:        "http://www.opengis.net/fes/2.0",
+		OWSVersion: "2.0.0",
+		Schema:     "http://schemas.opengis.net/cat/csw/3.0/cswAll.xsd",
+	},
+}
+
+// declarations returns the namespace declarations of response
+// documents, which use fixed prefixes
+func (ns cswNamespaces) declarations() []xml.Attr {
+	return []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:csw"}, Value: ns.CSW},
+		{Name: xml.Name{Local: "xmlns:dc"}, Value: "http://purl.org/dc/elements/1.1/"},
+		{Name: xml.Name{Local: "xmlns:dct"}, Value: "http://purl.org/dc/terms/"},
+		{Name: xml.Name{Local: "xmlns:ows"}, Value: ns.OWS},
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: ns.CSW + " " + ns.Schema},
+	}
+}
+
+// cswElement is an element of a record
+type cswElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Value    string     `xml:",chardata"`
+	Children []cswElement
+}
+
+func newCSWElement(name string, value string, attrs ...xml.Attr) cswElement {
+	return cswElement{XMLName: xml.Name{Local: name}, Value: value, Attrs: attrs}
+}
+
+// cswElementSets lists the elements of the brief and summary element
+// sets; the full element set holds all
+var cswElementSets = map[string][]string{
+	ElementSetBrief:   {"dc:identifier", "dc:title", "dc:type", "ows:BoundingBox"},
+	ElementSetSummary: {"dc:identifier", "dc:title", "dc:type", "dc:subject", "dc:format", "dc:relation", "dct:modified", "dct:abstract", "dct:spatial", "ows:BoundingBox"},
+}
+
+// cswRecordElements encodes a record as the elements of a full
+// csw:Record, in the order the summary and brief records require
+func cswRecordElements(record metadata.Record) []cswElement {
+	p := record.Properties
+	elements := []cswElement{newCSWElement("dc:identifier", record.Identifier)}
+	elements = append(elements, newCSWElement("dc:title", p.Title))
+	if p.Type != "" {
+		elements = append(elements, newCSWElement("dc:type", p.Type))
+	}
+	for _, set := range p.KeywordsSets {
+		for _, keyword := range set.Keyword {
+			elements = append(elements, newCSWElement("dc:subject", keyword))
+		}
+	}
+	if p.GROMetadata != nil && p.GROMetadata.DataFormat != "" {
+		elements = append(elements, newCSWElement("dc:format", p.GROMetadata.DataFormat))
+	}
+	if p.Collection != "" {
+		elements = append(elements, newCSWElement("dc:relation", p.Collection))
+	}
+	if p.Modified != nil {
+		elements = append(elements, newCSWElement("dct:modified", p.Modified.UTC().Format(time.RFC3339)))
+	}
+	if p.Abstract != "" {
+		elements = append(elements, newCSWElement("dct:abstract", p.Abstract))
+	}
+	if p.Description != "" {
+		elements = append(elements, newCSWElement("dc:description", p.Description))
+	}
+	if p.Owner != "" {
+		elements = append(elements, newCSWElement("dc:creator", p.Owner))
+	}
+	if p.Datetime != nil {
+		elements = append(elements, newCSWElement("dc:date", p.Datetime.UTC().Format(time.RFC3339)))
+	}
+	if p.Created != nil {
+		elements = append(elements, newCSWElement("dct:created", p.Created.UTC().Format(time.RFC3339)))
+	}
+	if p.TemporalExtent != nil && (p.TemporalExtent.Begin != nil || p.TemporalExtent.End != nil) {
+		elements = append(elements, newCSWElement("dct:temporal", temporalText(*p.TemporalExtent)))
+	}
+	if p.Language != "" {
+		elements = append(elements, newCSWElement("dc:language", p.Language))
+	}
+	if p.License != "" {
+		elements = append(elements, newCSWElement("dc:rights", p.License))
+	}
+	for _, link := range record.Links {
+		if link.URL == "" {
+			continue
+		}
+		var attrs []xml.Attr
+		if link.Protocol != "" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "scheme"}, Value: link.Protocol})
+		}
+		elements = append(elements, newCSWElement("dct:references", link.URL, attrs...))
+	}
+	if bbox, ok := record.Bounds(); ok {
+		elements = append(elements, owsBoundingBox(bbox))
+	}
+	return elements
+}
+
+// temporalText encodes a temporal extent as a DCMI period
+func temporalText(t metadata.Temporal) string {
+	var parts []string
+	if t.Begin != nil {
+		parts = append(parts, "start="+t.Begin.UTC().Format(time.RFC3339))
+	}
+	if t.End != nil {
+		parts = append(parts, "end="+t.End.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// owsBoundingBox encodes a bbox in EPSG:4326, latitude first
+func owsBoundingBox(bbox [4]float64) cswElement {
+	return cswElement{
+		XMLName: xml.Name{Local: "ows:BoundingBox"},
+		Attrs:   []xml.Attr{{Name: xml.Name{Local: "crs"}, Value: "urn:ogc:def:crs:EPSG::4326"}},
+		Children: []cswElement{
+			newCSWElement("ows:LowerCorner", fmt.Sprintf("%g %g", bbox[1], bbox[0])),
+			newCSWElement("ows:UpperCorner", fmt.Sprintf("%g %g", bbox[3], bbox[2])),
+		},
+	}
+}
+
+// cswRecord encodes a record in an element set, or with the given
+// elements only
+func cswRecord(record metadata.Record, elementSet string, elementNames []string) cswElement {
+	name := "csw:Record"
+	switch {
+	case len(elementNames) > 0:
+	case elementSet == ElementSetBrief:
+		name, elementNames = "csw:BriefRecord", cswElementSets[ElementSetBrief]
+	case elementSet == ElementSetSummary:
+		name, elementNames = "csw:SummaryRecord", cswElementSets[ElementSetSummary]
+	}
+
+	elements := cswRecordElements(record)
+	if len(elementNames) > 0 {
+		wanted := make(map[string]bool, len(elementNames))
+		for _, n := range elementNames {
+			wanted[strings.ToLower(n)] = true
+		}
+		var selected []cswElement
+		for _, e := range elements {
+			if wanted[strings.ToLower(e.XMLName.Local)] {
+				selected = append(selected, e)
+			}
+		}
+		elements = selected
+	}
+	return cswElement{XMLName: xml.Name{Local: name}, Children: elements}
+}
+
+// cswSearchResults is the csw:SearchResults of a GetRecords response
+type cswSearchResults struct {
+	Matched      int          `xml:"numberOfRecordsMatched,attr"`
+	Returned     int          `xml:"numberOfRecordsReturned,attr"`
+	NextRecord   int          `xml:"nextRecord,attr"`
+	RecordSchema string       `xml:"recordSchema,attr"`
+	ElementSet   string       `xml:"elementSet,attr,omitempty"`
+	Status       string       `xml:"status,attr,omitempty"`
+	Records      []cswElement `xml:",omitempty"`
+}
+
+// cswGetRecordsResponse is the response to GetRecords
+type cswGetRecordsResponse struct {
+	XMLName      xml.Name   `xml:"csw:GetRecordsResponse"`
+	Namespaces   []xml.Attr `xml:",any,attr"`
+	Version      string     `xml:"version,attr,omitempty"`
+	SearchStatus struct {
+		Timestamp string `xml:"timestamp,attr"`
+	} `xml:"csw:SearchStatus"`
+	SearchResults cswSearchResults `xml:"csw:SearchResults"`
+}
+
+// cswGetRecordByIdResponse is the CSW 2.0.2 response to GetRecordById
+type cswGetRecordByIdResponse struct {
+	XMLName    xml.Name     `xml:"csw:GetRecordByIdResponse"`
+	Namespaces []xml.Attr   `xml:",any,attr"`
+	Records    []cswElement `xml:",omitempty"`
+}
+
+// cswAcknowledgement is the response to GetRecords with resultType
+// validate
+type cswAcknowledgement struct {
+	XMLName       xml.Name   `xml:"csw:Acknowledgement"`
+	Namespaces    []xml.Attr `xml:",any,attr"`
+	TimeStamp     string     `xml:"timeStamp,attr"`
+	EchoedRequest struct {
+		Request string `xml:",innerxml"`
+	} `xml:"csw:EchoedRequest"`
+}
+
+// cswDomainValue is a value of a parameter or property with the
+// number of records holding it
+type cswDomainValue struct {
+	Count int    `xml:"count,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// cswDomainValues lists the values of a parameter or property
+type cswDomainValues struct {
+	Type          string           `xml:"type,attr"`
+	ParameterName string           `xml:"csw:ParameterName,omitempty"`
+	PropertyName  string           `xml:"csw:PropertyName,omitempty"`
+	Values        []cswDomainValue `xml:"csw:ListOfValues>csw:Value"`
+}
+
+// cswGetDomainResponse is the response to GetDomain
+type cswGetDomainResponse struct {
+	XMLName      xml.Name        `xml:"csw:GetDomainResponse"`
+	Namespaces   []xml.Attr      `xml:",any,attr"`
+	DomainValues cswDomainValues `xml:"csw:DomainValues"`
+}
+
+// cswDescribeRecordResponse is the CSW 2.0.2 response to DescribeRecord
+type cswDescribeRecordResponse struct {
+	XMLName         xml.Name   `xml:"csw:DescribeRecordResponse"`
+	Namespaces      []xml.Attr `xml:",any,attr"`
+	SchemaComponent struct {
+		TargetNamespace string `xml:"targetNamespace,attr"`
+		SchemaLanguage  string `xml:"schemaLanguage,attr"`
+		Schema          struct {
+			XMLName         xml.Name `xml:"xs:schema"`
+			XS              string   `xml:"xmlns:xs,attr"`
+			TargetNamespace string   `xml:"targetNamespace,attr"`
+			Include         struct {
+				SchemaLocation string `xml:"schemaLocation,attr"`
+			} `xml:"xs:include"`
+		}
+	} `xml:"csw:SchemaComponent"`
+}
+
+// cswTransactionSummary counts the records a Transaction or Harvest
+// changed
+type cswTransactionSummary struct {
+	RequestID     string `xml:"requestId,attr,omitempty"`
+	TotalInserted int    `xml:"csw:totalInserted"`
+	TotalUpdated  int    `xml:"csw:totalUpdated"`
+	TotalDeleted  int    `xml:"csw:totalDeleted"`
+}
+
+// cswInsertResult lists the brief records of an Insert
+type cswInsertResult struct {
+	HandleRef string       `xml:"handleRef,attr,omitempty"`
+	Records   []cswElement `xml:",omitempty"`
+}
+
+// cswTransactionResponse is the response to Transaction, and holds the
+// result of Harvest
+type cswTransactionResponse struct {
+	XMLName       xml.Name              `xml:"csw:TransactionResponse"`
+	Namespaces    []xml.Attr            `xml:",any,attr"`
+	Version       string                `xml:"version,attr,omitempty"`
+	Summary       cswTransactionSummary `xml:"csw:TransactionSummary"`
+	InsertResults []cswInsertResult     `xml:"csw:InsertResult,omitempty"`
+}
+
+// cswHarvestResponse is the response to Harvest
+type cswHarvestResponse struct {
+	XMLName     xml.Name   `xml:"csw:HarvestResponse"`
+	Namespaces  []xml.Attr `xml:",any,attr"`
+	Transaction cswTransactionResponse
+}
+
+// owsExceptionReport reports a failed request
+type owsExceptionReport struct {
+	XMLName   xml.Name `xml:"ows:ExceptionReport"`
+	XMLNS     string   `xml:"xmlns:ows,attr"`
+	Version   string   `xml:"version,attr"`
+	Exception struct {
+		Code    string `xml:"exceptionCode,attr"`
+		Locator string `xml:"locator,attr,omitempty"`
+		Text    string `xml:"ows:ExceptionText"`
+	} `xml:"ows:Exception"`
+}
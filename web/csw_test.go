@@ -0,0 +1,159 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/config"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/gorilla/mux"
+)
+
+// newTestCatalogue returns a catalogue on an in-memory repository
+// holding a few records
+func newTestCatalogue(t *testing.T) *geocatalogo.GeoCatalogue {
+	var cfg config.Config
+	cfg.Server.URL = "http://localhost:8000"
+	cfg.Server.MimeType = "application/json; charset=UTF-8"
+	cfg.Server.Limit = 100
+	cfg.Server.CORS = true
+	cfg.Repository.Type = "memory"
+	cfg.Repository.URL = "memory://"
+	cat, err := geocatalogo.New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []metadata.Record{
+		{Identifier: "dem", Properties: metadata.Properties{Title: "Digital elevation model", Collection: "elevation"}},
+		{Identifier: "roads", Properties: metadata.Properties{Title: "Road network", Collection: "transport"}},
+		{Identifier: "rail", Properties: metadata.Properties{Title: "Rail network", Collection: "transport"}},
+	}
+	records[0].SetGeometry(metadata.NewBBoxPolygon([4]float64{-80, 40, -70, 50}))
+	records[1].SetGeometry(metadata.NewBBoxPolygon([4]float64{0, 40, 10, 50}))
+	records[2].SetGeometry(metadata.NewBBoxPolygon([4]float64{5, 45, 15, 55}))
+	if failures := cat.BulkIndex(context.Background(), records); len(failures) > 0 {
+		t.Fatalf("indexing failed: %v", failures)
+	}
+	return cat
+}
+
+// serve sends a request to a router and returns the response
+func serve(router *mux.Router, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func expectBody(t *testing.T, name string, w *httptest.ResponseRecorder, status int, fragments ...string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("%s: status %d, expected %d: %s", name, w.Code, status, w.Body)
+	}
+	for _, fragment := range fragments {
+		if !strings.Contains(w.Body.String(), fragment) {
+			t.Errorf("%s: %q not found in %s", name, fragment, w.Body)
+		}
+	}
+}
+
+func TestCSWGetCapabilities(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+
+	w := serve(router, "GET", "/csw?service=CSW&request=GetCapabilities", "")
+	expectBody(t, "3.0", w, http.StatusOK, `xmlns:csw="http://www.opengis.net/cat/csw/3.0"`, `name="GetRecords"`)
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/xml; charset=UTF-8" {
+		t.Errorf("Content-Type is %s", contentType)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("CORS headers not set")
+	}
+
+	w = serve(router, "GET", "/?SERVICE=CSW&REQUEST=GetCapabilities&ACCEPTVERSIONS=2.0.2", "")
+	expectBody(t, "2.0.2", w, http.StatusOK, `xmlns:csw="http://www.opengis.net/cat/csw/2.0.2"`, `version="2.0.2"`)
+}
+
+func TestCSWGetRecords(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+	base := "/csw?service=CSW&version=3.0.0&request=GetRecords&typeNames=csw:Record&elementSetName=brief"
+
+	filter := `<fes:Filter xmlns:fes="http://www.opengis.net/fes/2.0"><fes:PropertyIsLike wildCard="%" singleChar="_" escapeChar="\"><fes:ValueReference>dc:title</fes:ValueReference><fes:Literal>%network</fes:Literal></fes:PropertyIsLike></fes:Filter>`
+	w := serve(router, "GET", base+"&constraintLanguage=FILTER&constraint="+url.QueryEscape(filter), "")
+	expectBody(t, "FILTER", w, http.StatusOK, `numberOfRecordsMatched="2"`, "roads", "rail")
+
+	w = serve(router, "GET", base+"&constraintLanguage=CQL_TEXT&constraint="+url.QueryEscape("collection = 'elevation'"), "")
+	expectBody(t, "CQL_TEXT", w, http.StatusOK, `numberOfRecordsMatched="1"`, "dem")
+
+	w = serve(router, "GET", base+"&maxRecords=1", "")
+	expectBody(t, "paging", w, http.StatusOK, `numberOfRecordsMatched="3"`, `numberOfRecordsReturned="1"`, `nextRecord="2"`, `status="subset"`)
+
+	w = serve(router, "POST", "/csw", `<?xml version="1.0"?>
+<csw:GetRecords xmlns:csw="http://www.opengis.net/cat/csw/2.0.2" service="CSW" version="2.0.2" resultType="results">
+  <csw:Query typeNames="csw:Record">
+    <csw:ElementSetName>brief</csw:ElementSetName>
+    <csw:Constraint version="1.1.0"><csw:CqlText>collection = 'transport'</csw:CqlText></csw:Constraint>
+  </csw:Query>
+</csw:GetRecords>`)
+	expectBody(t, "POST", w, http.StatusOK, `numberOfRecordsMatched="2"`)
+}
+
+func TestCSWGetRecordsValidate(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+
+	w := serve(router, "GET", "/csw?service=CSW&version=3.0.0&request=GetRecords&typeNames=csw:Record&resultType=validate", "")
+	expectBody(t, "KVP", w, http.StatusOK, "Acknowledgement", "<csw:EchoedRequest>service=CSW&amp;version=3.0.0&amp;request=GetRecords")
+
+	body := `<csw:GetRecords xmlns:csw="http://www.opengis.net/cat/csw/2.0.2" service="CSW" version="2.0.2" resultType="validate"><csw:Query typeNames="csw:Record"/></csw:GetRecords>`
+	w = serve(router, "POST", "/csw", body)
+	expectBody(t, "XML", w, http.StatusOK, "Acknowledgement", body)
+}
+
+func TestCSWGetRecordById(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+
+	w := serve(router, "GET", "/csw?service=CSW&version=3.0.0&request=GetRecordById&id=roads", "")
+	expectBody(t, "3.0", w, http.StatusOK, "<dc:identifier>roads</dc:identifier>", "Road network")
+
+	w = serve(router, "GET", "/csw?service=CSW&version=2.0.2&request=GetRecordById&id=dem,roads&elementSetName=brief", "")
+	expectBody(t, "2.0.2", w, http.StatusOK, "GetRecordByIdResponse", "<dc:identifier>dem</dc:identifier>", "<dc:identifier>roads</dc:identifier>")
+
+	w = serve(router, "GET", "/csw?service=CSW&version=3.0.0&request=GetRecordById&id=missing", "")
+	expectBody(t, "missing", w, http.StatusNotFound, "ows:ExceptionReport", `exceptionCode="NotFound"`)
+}
+
+func TestCSWExceptions(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		status  int
+		code    string
+		locator string
+	}{
+		{"missing request", "GET", "/csw?service=CSW", "", http.StatusBadRequest, "MissingParameterValue", "request"},
+		{"missing service", "GET", "/csw?request=GetCapabilities", "", http.StatusBadRequest, "MissingParameterValue", "service"},
+		{"wrong service", "GET", "/csw?service=WMS&request=GetCapabilities", "", http.StatusBadRequest, "InvalidParameterValue", "service"},
+		{"unknown operation", "GET", "/csw?service=CSW&request=GetMap", "", http.StatusNotImplemented, "OperationNotSupported", "request"},
+		{"transactions disabled", "GET", "/csw?service=CSW&request=Harvest&source=http://example.org", "", http.StatusNotImplemented, "OperationNotSupported", "request"},
+		{"no constraint language", "GET", "/csw?service=CSW&request=GetRecords&constraint=x", "", http.StatusBadRequest, "MissingParameterValue", "constraintlanguage"},
+		{"invalid constraint", "GET", "/csw?service=CSW&request=GetRecords&constraintLanguage=CQL_TEXT&constraint=" + url.QueryEscape("title ="), "", http.StatusBadRequest, "InvalidParameterValue", "constraint"},
+		{"invalid result type", "GET", "/csw?service=CSW&request=GetRecords&resultType=all", "", http.StatusBadRequest, "InvalidParameterValue", "resulttype"},
+		{"invalid XML", "POST", "/csw", "<csw:GetRecords", http.StatusBadRequest, "", ""},
+	}
+	for _, test := range tests {
+		w := serve(router, test.method, test.target, test.body)
+		fragments := []string{"ows:ExceptionReport"}
+		if test.code != "" {
+			fragments = append(fragments, `exceptionCode="`+test.code+`"`, `locator="`+test.locator+`"`)
+		}
+		expectBody(t, test.name, w, test.status, fragments...)
+	}
+}
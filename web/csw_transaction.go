@@ -0,0 +1,592 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// CSW Transaction and Harvest operations
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/cql2"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/metadata/parsers"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// cswResourceTypes lists the types of resources Harvest reads
+var cswResourceTypes = []string{cswVersions[CSWVersion2].CSW, cswVersions[CSWVersion3].CSW, parsers.ISONamespace}
+
+// cswHarvestTimeout bounds a harvest done after the request is
+// acknowledged
+const cswHarvestTimeout = 10 * time.Minute
+
+// cswHarvestLimit is the largest resource Harvest reads
+const cswHarvestLimit = 16 << 20
+
+// cswRecordProperty is a property an Update sets, or clears when it has
+// no value
+type cswRecordProperty struct {
+	Name  string  `xml:"Name"`
+	Value *string `xml:"Value"`
+}
+
+// cswAction is an Insert, Update or Delete of a Transaction, kept with
+// the error reading it so that it is reported as an invalid action
+// rather than an invalid request
+type cswAction struct {
+	Kind       string
+	Handle     string
+	Records    []metadata.Record
+	Properties []cswRecordProperty
+	Constraint cql2.Expr
+	Err        error
+}
+
+// UnmarshalXML reads the records, record properties and constraint of
+// an action
+func (a *cswAction) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	a.Kind = start.Name.Local
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "handle" {
+			a.Handle = attr.Value
+		}
+	}
+	fail := func(err error) {
+		if a.Err == nil {
+			a.Err = err
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			switch {
+			case parsers.IsRecord(t.Name):
+				record, err := parsers.DecodeRecord(decoder, t)
+				if err != nil {
+					fail(cswError(OWSInvalidParameterValue, a.Kind, "invalid %s: %v", t.Name.Local, err))
+					continue
+				}
+				a.Records = append(a.Records, record)
+			case t.Name.Local == "RecordProperty":
+				var p cswRecordProperty
+				if err := decoder.DecodeElement(&p, &t); err != nil {
+					return err
+				}
+				p.Name = strings.TrimSpace(p.Name)
+				a.Properties = append(a.Properties, p)
+			case t.Name.Local == "Constraint":
+				var c cswConstraint
+				if err := decoder.DecodeElement(&c, &t); err != nil {
+					return err
+				}
+				if a.Constraint, err = c.expr(); err != nil {
+					fail(cswError(OWSInvalidParameterValue, "Constraint", "invalid constraint: %v", err))
+				}
+			default:
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+				fail(cswError(OWSInvalidParameterValue, a.Kind, "%s is not supported (should be csw:Record or gmd:MD_Metadata)", t.Name.Local))
+			}
+		}
+	}
+}
+
+// validate checks that an action holds what it acts on: records to
+// insert, records or properties to update, and a constraint selecting
+// the records to update or delete, so that no action applies to the
+// whole catalogue
+func (a *cswAction) validate() error {
+	if a.Err != nil {
+		return a.Err
+	}
+	switch a.Kind {
+	case "Insert":
+		if len(a.Records) == 0 {
+			return cswError(OWSMissingParameterValue, a.Kind, "Insert needs a csw:Record or gmd:MD_Metadata")
+		}
+	case "Update":
+		switch {
+		case len(a.Records) > 0 && (len(a.Properties) > 0 || a.Constraint != nil):
+			return cswError(OWSInvalidParameterValue, a.Kind, "Update takes a record, or record properties and a constraint")
+		case len(a.Records) > 0:
+		case len(a.Properties) == 0:
+			return cswError(OWSMissingParameterValue, a.Kind, "Update needs a record, or record properties and a constraint")
+		case a.Constraint == nil:
+			return cswError(OWSMissingParameterValue, "Constraint", "Update of record properties needs a constraint")
+		}
+		for _, p := range a.Properties {
+			if _, err := cswUpdater(p.Name); err != nil {
+				return err
+			}
+		}
+	case "Delete":
+		if len(a.Records) > 0 || len(a.Properties) > 0 {
+			return cswError(OWSInvalidParameterValue, a.Kind, "Delete takes a constraint only")
+		}
+		if a.Constraint == nil {
+			return cswError(OWSMissingParameterValue, "Constraint", "Delete needs a constraint")
+		}
+	default:
+		return cswError(OWSInvalidParameterValue, a.Kind, "%s is not a transaction action (should be Insert, Update or Delete)", a.Kind)
+	}
+	return nil
+}
+
+// cswTransactionRequest is a Transaction POSTed as XML
+type cswTransactionRequest struct {
+	RequestID string      `xml:"requestId,attr"`
+	Actions   []cswAction `xml:",any"`
+}
+
+// cswTime parses the value of a timestamp property, nil when it is
+// empty
+func cswTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// cswUpdatable sets the queryables an Update can change from a value,
+// clearing them when it is empty
+var cswUpdatable = map[string]func(p *metadata.Properties, value string) error{
+	"title":       func(p *metadata.Properties, value string) error { p.Title = value; return nil },
+	"abstract":    func(p *metadata.Properties, value string) error { p.Abstract = value; return nil },
+	"description": func(p *metadata.Properties, value string) error { p.Description = value; return nil },
+	"type":        func(p *metadata.Properties, value string) error { p.Type = value; return nil },
+	"owner":       func(p *metadata.Properties, value string) error { p.Owner = value; return nil },
+	"license":     func(p *metadata.Properties, value string) error { p.License = value; return nil },
+	"language":    func(p *metadata.Properties, value string) error { p.Language = value; return nil },
+	"collection":  func(p *metadata.Properties, value string) error { p.Collection = value; return nil },
+	"keywords": func(p *metadata.Properties, value string) error {
+		p.KeywordsSets = nil
+		p.AddKeywords("", splitList(value)...)
+		return nil
+	},
+	"created": func(p *metadata.Properties, value string) (err error) {
+		p.Created, err = cswTime(value)
+		return err
+	},
+	"modified": func(p *metadata.Properties, value string) (err error) {
+		p.Modified, err = cswTime(value)
+		return err
+	},
+}
+
+// cswUpdater returns the function setting a record property, which is
+// a queryable
+func cswUpdater(name string) (func(p *metadata.Properties, value string) error, error) {
+	if q, ok := cql2.Lookup(name); ok {
+		if fn, ok := cswUpdatable[q.Name]; ok {
+			return fn, nil
+		}
+	}
+	return nil, cswError(OWSInvalidParameterValue, "RecordProperty", "%s cannot be updated", name)
+}
+
+// cswMatching returns all the records a constraint selects, page by
+// page.  Pages follow a cursor, as from/size paging stops at the
+// 10000th record in Elasticsearch
+func cswMatching(ctx context.Context, cat *geocatalogo.GeoCatalogue, constraint cql2.Expr) ([]metadata.Record, error) {
+	var records []metadata.Record
+	req := search.Request{Filter: constraint, Size: 100}
+	for {
+		results, err := cat.Search(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, results.Records...)
+		if results.NextRecord == 0 || len(results.Records) == 0 || len(results.After) == 0 {
+			return records, nil
+		}
+		req.From = results.NextRecord
+		req.After = results.After
+	}
+}
+
+// cswApply performs an action, counting the records it changes.  The
+// records of an action are changed as a batch, so that collection
+// extents are brought up to date once
+func cswApply(ctx context.Context, cat *geocatalogo.GeoCatalogue, a cswAction, response *cswTransactionResponse) error {
+	switch {
+	case a.Kind == "Insert":
+		if failures := cat.BulkIndex(ctx, a.Records); len(failures) > 0 {
+			return cswError(OWSNoApplicableCode, a.Kind, "inserting %s failed", failures[0].Identifier)
+		}
+		result := cswInsertResult{HandleRef: a.Handle}
+		for _, record := range a.Records {
+			result.Records = append(result.Records, cswRecord(record, ElementSetBrief, nil))
+		}
+		response.Summary.TotalInserted += len(a.Records)
+		response.InsertResults = append(response.InsertResults, result)
+		return nil
+	case a.Kind == "Update" && len(a.Records) > 0:
+		if failures := cat.BulkReIndex(ctx, a.Records); len(failures) > 0 {
+			return cswError(OWSNoApplicableCode, a.Kind, "updating %s failed", failures[0].Identifier)
+		}
+		response.Summary.TotalUpdated += len(a.Records)
+		return nil
+	}
+
+	records, err := cswMatching(ctx, cat, a.Constraint)
+	if err != nil {
+		return cswError(OWSNoApplicableCode, "", "%v", err).withStatus(repositoryStatus(err))
+	}
+	if a.Kind == "Delete" {
+		identifiers := make([]string, len(records))
+		for i, record := range records {
+			identifiers[i] = record.Identifier
+		}
+		if failures := cat.BulkUnIndex(ctx, identifiers); len(failures) > 0 {
+			return cswError(OWSNoApplicableCode, a.Kind, "deleting %s failed", failures[0].Identifier)
+		}
+		response.Summary.TotalDeleted += len(records)
+		return nil
+	}
+	for i := range records {
+		for _, p := range a.Properties {
+			fn, _ := cswUpdater(p.Name)
+			value := ""
+			if p.Value != nil {
+				value = strings.TrimSpace(*p.Value)
+			}
+			if err := fn(&records[i].Properties, value); err != nil {
+				return cswError(OWSInvalidParameterValue, "RecordProperty", "invalid value of %s: %v", p.Name, err)
+			}
+		}
+	}
+	if failures := cat.BulkReIndex(ctx, records); len(failures) > 0 {
+		return cswError(OWSNoApplicableCode, a.Kind, "updating %s failed", failures[0].Identifier)
+	}
+	response.Summary.TotalUpdated += len(records)
+	return nil
+}
+
+// cswTransaction inserts, updates and deletes records.  Actions are
+// validated before any is performed, then performed in order; a failed
+// action leaves those before it done
+func cswTransaction(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	if req.Body == nil {
+		cswEmitException(w, cat, version, cswError(OWSInvalidParameterValue, "request", "Transaction must be POSTed as XML"))
+		return
+	}
+
+	var t cswTransactionRequest
+	decoder := xml.NewDecoder(bytes.NewReader(req.Body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&t); err != nil {
+		cswEmitException(w, cat, version, cswError(OWSNoApplicableCode, "", "invalid request: %v", err).withStatus(http.StatusBadRequest))
+		return
+	}
+	if len(t.Actions) == 0 {
+		cswEmitException(w, cat, version, cswError(OWSMissingParameterValue, "Transaction", "Transaction needs an Insert, Update or Delete"))
+		return
+	}
+	for i := range t.Actions {
+		if err := t.Actions[i].validate(); err != nil {
+			cswEmitException(w, cat, version, err)
+			return
+		}
+	}
+
+	response := cswTransactionResponse{Namespaces: cswVersions[version].declarations(), Version: version}
+	response.Summary.RequestID = t.RequestID
+	for _, a := range t.Actions {
+		if err := cswApply(r.Context(), cat, a, &response); err != nil {
+			cswEmitException(w, cat, version, err)
+			return
+		}
+	}
+	cswEmitXML(w, cat, version, response)
+}
+
+// cswCheckURL accepts an absolute http or https URL on a host Harvest
+// may connect to
+func cswCheckURL(cat *geocatalogo.GeoCatalogue, value string, locator string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return cswError(OWSInvalidParameterValue, locator, "%s should be an http or https URL", locator)
+	}
+	if err := cswCheckHost(cat.Config.Server.HarvestHosts, u.Hostname()); err != nil {
+		return cswError(OWSInvalidParameterValue, locator, "%v", err)
+	}
+	return nil
+}
+
+// cswCheckHost accepts a host among the harvest hosts or, when there
+// are none, any host but a loopback, private or link-local address
+func cswCheckHost(hosts []string, host string) error {
+	if len(hosts) == 0 {
+		if ip := net.ParseIP(host); ip != nil && !cswPublicIP(ip) {
+			return fmt.Errorf("%s is not a public address", host)
+		}
+		return nil
+	}
+	for _, h := range hosts {
+		if strings.EqualFold(strings.TrimSpace(h), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a harvest host", host)
+}
+
+// cswPublicIP reports whether an address is neither loopback, private
+// nor link-local
+func cswPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// cswHarvestClient returns the client fetching harvested resources and
+// notifying response handlers.  Every connection, including those of
+// redirects, is checked with cswCheckHost, and without harvest hosts
+// the address a name resolves to is checked as well
+func cswHarvestClient(cat *geocatalogo.GeoCatalogue) *http.Client {
+	hosts := cat.Config.Server.HarvestHosts
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if len(hosts) == 0 {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return cswCheckHost(nil, host)
+		}
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			if err := cswCheckHost(hosts, host); err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   true,
+	}
+	return &http.Client{Timeout: time.Minute, Transport: transport}
+}
+
+// cswHarvestRecovered harvests as cswHarvestSource does, reporting a
+// panic as an error: net/http does not recover a harvest done after the
+// request is acknowledged, and one bad resource would stop the server
+func cswHarvestRecovered(ctx context.Context, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) (response cswHarvestResponse, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			cat.Logger().Errorf("Harvest of %s panicked: %v", req.Source, p)
+			err = cswError(OWSNoApplicableCode, "source", "harvesting %s failed", req.Source)
+		}
+	}()
+	return cswHarvestSource(ctx, cat, version, req)
+}
+
+// cswHarvestSource fetches, parses and indexes the records of a
+// resource, inserting new records and updating those already in the
+// catalogue
+func cswHarvestSource(ctx context.Context, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) (cswHarvestResponse, error) {
+	response := cswHarvestResponse{Namespaces: cswVersions[version].declarations()}
+	response.Transaction.Version = version
+
+	httpReq, err := http.NewRequest(http.MethodGet, req.Source, nil)
+	if err != nil {
+		return response, cswError(OWSInvalidParameterValue, "source", "%v", err)
+	}
+	resp, err := cswHarvestClient(cat).Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return response, cswError(OWSNoApplicableCode, "source", "cannot fetch %s: %v", req.Source, err).withStatus(http.StatusBadGateway)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return response, cswError(OWSNoApplicableCode, "source", "cannot fetch %s: %s", req.Source, resp.Status).withStatus(http.StatusBadGateway)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, cswHarvestLimit))
+	if err != nil {
+		return response, cswError(OWSNoApplicableCode, "source", "cannot fetch %s: %v", req.Source, err).withStatus(http.StatusBadGateway)
+	}
+
+	parsed, err := parsers.ParseRecords(body)
+	if err != nil {
+		return response, cswError(OWSInvalidParameterValue, "source", "%s: %v", req.Source, err)
+	}
+	var records []metadata.Record
+	for _, record := range parsed {
+		if record.Properties.Geocatalogo.Schema == req.ResourceType {
+			record.Properties.Geocatalogo.Source = req.Source
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return response, cswError(OWSInvalidParameterValue, "resourcetype", "%s holds no %s records", req.Source, req.ResourceType)
+	}
+
+	identifiers := make([]string, len(records))
+	for i, record := range records {
+		identifiers[i] = record.Identifier
+	}
+	existing, err := cat.Get(ctx, identifiers)
+	if err != nil {
+		return response, cswError(OWSNoApplicableCode, "", "%v", err).withStatus(repositoryStatus(err))
+	}
+	stored := make(map[string]bool, len(existing.Records))
+	for _, record := range existing.Records {
+		stored[record.Identifier] = true
+	}
+	var inserts, updates []metadata.Record
+	for _, record := range records {
+		if stored[record.Identifier] {
+			updates = append(updates, record)
+		} else {
+			inserts = append(inserts, record)
+		}
+	}
+
+	if len(updates) > 0 {
+		if failures := cat.BulkReIndex(ctx, updates); len(failures) > 0 {
+			return response, cswError(OWSNoApplicableCode, "source", "updating %s failed", failures[0].Identifier)
+		}
+		response.Transaction.Summary.TotalUpdated = len(updates)
+	}
+	if len(inserts) > 0 {
+		if failures := cat.BulkIndex(ctx, inserts); len(failures) > 0 {
+			return response, cswError(OWSNoApplicableCode, "source", "inserting %s failed", failures[0].Identifier)
+		}
+		response.Transaction.Summary.TotalInserted = len(inserts)
+		result := cswInsertResult{}
+		for _, record := range inserts {
+			result.Records = append(result.Records, cswRecord(record, ElementSetBrief, nil))
+		}
+		response.Transaction.InsertResults = []cswInsertResult{result}
+	}
+	return response, nil
+}
+
+// cswHarvest harvests a resource by URL.  With a response handler the
+// request is acknowledged at once, and the result (or exception report)
+// is POSTed to the handler when the harvest is done
+func cswHarvest(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, version string, req cswRequest) {
+	var err error
+	switch {
+	case req.Source == "":
+		err = cswError(OWSMissingParameterValue, "source", "source is required")
+	case req.ResourceType == "":
+		err = cswError(OWSMissingParameterValue, "resourcetype", "resourcetype is required")
+	case req.HarvestInterval != "":
+		err = cswError(OWSInvalidParameterValue, "harvestinterval", "periodic harvesting is not supported")
+	}
+	if err == nil {
+		err = cswCheckURL(cat, req.Source, "source")
+	}
+	if err == nil {
+		err = cswError(OWSInvalidParameterValue, "resourcetype", "resource type %s is not supported (should be one of %s)",
+			req.ResourceType, strings.Join(cswResourceTypes, ", "))
+		for _, t := range cswResourceTypes {
+			if req.ResourceType == t {
+				err = nil
+			}
+		}
+	}
+	if err == nil {
+		switch req.ResourceFormat {
+		case "", "application/xml", "text/xml":
+		default:
+			err = cswError(OWSInvalidParameterValue, "resourceformat", "resource format %s is not supported (should be application/xml)", req.ResourceFormat)
+		}
+	}
+	for _, handler := range req.ResponseHandler {
+		if err == nil {
+			err = cswCheckURL(cat, handler, "responsehandler")
+		}
+	}
+	if err != nil {
+		cswEmitException(w, cat, version, err)
+		return
+	}
+
+	if len(req.ResponseHandler) == 0 {
+		response, err := cswHarvestSource(r.Context(), cat, version, req)
+		if err != nil {
+			cswEmitException(w, cat, version, err)
+			return
+		}
+		cswEmitXML(w, cat, version, response)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cswHarvestTimeout)
+		defer cancel()
+
+		var rec bytes.Buffer
+		rw := &bufferedResponse{header: make(http.Header), body: &rec}
+		response, err := cswHarvestRecovered(ctx, cat, version, req)
+		if err != nil {
+			cat.Logger().Warnf("Harvest of %s failed: %v", req.Source, err)
+			cswEmitException(rw, cat, version, err)
+		} else {
+			cswEmitXML(rw, cat, version, response)
+		}
+		client := cswHarvestClient(cat)
+		for _, handler := range req.ResponseHandler {
+			notification, err := http.NewRequest(http.MethodPost, handler, bytes.NewReader(rec.Bytes()))
+			if err != nil {
+				cat.Logger().Warnf("Harvest of %s: cannot notify %s: %v", req.Source, handler, err)
+				continue
+			}
+			notification.Header.Set("Content-Type", "application/xml; charset=UTF-8")
+			resp, err := client.Do(notification.WithContext(ctx))
+			if err != nil {
+				cat.Logger().Warnf("Harvest of %s: cannot notify %s: %v", req.Source, handler, err)
+				continue
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	ack := cswAcknowledgement{Namespaces: cswVersions[version].declarations(), TimeStamp: time.Now().UTC().Format(time.RFC3339)}
+	ack.EchoedRequest.Request = string(stripXMLDeclaration(req.Body))
+	cswEmitXML(w, cat, version, ack)
+}
+
+// bufferedResponse is an http.ResponseWriter keeping the body of a
+// response, to send it elsewhere
+type bufferedResponse struct {
+	header http.Header
+	body   *bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      {}
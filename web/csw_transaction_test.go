@@ -0,0 +1,205 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+)
+
+const cswTransactionHeader = `<csw:Transaction xmlns:csw="http://www.opengis.net/cat/csw/2.0.2"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:ogc="http://www.opengis.net/ogc" service="CSW" version="2.0.2">`
+
+const cswHarvestedRecord = `<csw:Record xmlns:csw="http://www.opengis.net/cat/csw/2.0.2" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<dc:identifier>lakes</dc:identifier>
+	<dc:title>Lakes</dc:title>
+</csw:Record>`
+
+// cswBadBoundingBoxRecord has a lower corner of a single value
+const cswBadBoundingBoxRecord = `<csw:Record xmlns:csw="http://www.opengis.net/cat/csw/2.0.2" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:ows="http://www.opengis.net/ows">
+	<dc:identifier>bad</dc:identifier>
+	<ows:WGS84BoundingBox><ows:LowerCorner>5</ows:LowerCorner><ows:UpperCorner>10 50</ows:UpperCorner></ows:WGS84BoundingBox>
+</csw:Record>`
+
+func TestCSWTransaction(t *testing.T) {
+	cat := newTestCatalogue(t)
+	router := CSW3OpenSearchRouter(cat)
+
+	insert := cswTransactionHeader + `<csw:Insert handle="new">` + cswHarvestedRecord + `</csw:Insert></csw:Transaction>`
+	w := serve(router, "POST", "/csw", insert)
+	expectBody(t, "disabled", w, http.StatusNotImplemented, `exceptionCode="OperationNotSupported"`)
+
+	cat.Config.Server.Transactions = true
+	w = serve(router, "POST", "/csw", insert)
+	expectBody(t, "Insert", w, http.StatusOK, "<csw:totalInserted>1</csw:totalInserted>", `handleRef="new"`, "<dc:identifier>lakes</dc:identifier>")
+	w = serve(router, "GET", "/csw?service=CSW&version=2.0.2&request=GetRecordById&id=lakes", "")
+	expectBody(t, "inserted", w, http.StatusOK, "<dc:title>Lakes</dc:title>")
+
+	update := cswTransactionHeader + `<csw:Update>
+		<csw:RecordProperty><csw:Name>dc:title</csw:Name><csw:Value>Transport network</csw:Value></csw:RecordProperty>
+		<csw:Constraint version="1.1.0"><csw:CqlText>collection = 'transport'</csw:CqlText></csw:Constraint>
+	</csw:Update></csw:Transaction>`
+	w = serve(router, "POST", "/csw", update)
+	expectBody(t, "Update", w, http.StatusOK, "<csw:totalUpdated>2</csw:totalUpdated>")
+	w = serve(router, "GET", "/csw?service=CSW&version=2.0.2&request=GetRecordById&id=roads,rail", "")
+	if n := strings.Count(w.Body.String(), "<dc:title>Transport network</dc:title>"); n != 2 {
+		t.Errorf("%d records updated: %s", n, w.Body)
+	}
+
+	del := cswTransactionHeader + `<csw:Delete>
+		<csw:Constraint version="1.1.0"><csw:CqlText>collection = 'transport'</csw:CqlText></csw:Constraint>
+	</csw:Delete></csw:Transaction>`
+	w = serve(router, "POST", "/csw", del)
+	expectBody(t, "Delete", w, http.StatusOK, "<csw:totalDeleted>2</csw:totalDeleted>")
+	w = serve(router, "GET", "/csw?service=CSW&version=3.0.0&request=GetRecordById&id=roads", "")
+	expectBody(t, "deleted", w, http.StatusNotFound, `exceptionCode="NotFound"`)
+
+	unconstrained := cswTransactionHeader + `<csw:Delete/></csw:Transaction>`
+	w = serve(router, "POST", "/csw", unconstrained)
+	expectBody(t, "Delete without constraint", w, http.StatusBadRequest, `exceptionCode="MissingParameterValue"`, `locator="Constraint"`)
+}
+
+func TestCSWTransactionPaging(t *testing.T) {
+	cat := newTestCatalogue(t)
+	cat.Config.Server.Transactions = true
+	router := CSW3OpenSearchRouter(cat)
+
+	var records []metadata.Record
+	for i := 0; i < 250; i++ {
+		records = append(records, metadata.Record{
+			Identifier: fmt.Sprintf("bulk-%03d", i),
+			Properties: metadata.Properties{Title: "Bulk", Collection: "bulk"},
+		})
+	}
+	if failures := cat.BulkIndex(context.Background(), records); len(failures) > 0 {
+		t.Fatalf("indexing failed: %v", failures)
+	}
+
+	del := cswTransactionHeader + `<csw:Delete>
+		<csw:Constraint version="1.1.0"><csw:CqlText>collection = 'bulk'</csw:CqlText></csw:Constraint>
+	</csw:Delete></csw:Transaction>`
+	w := serve(router, "POST", "/csw", del)
+	expectBody(t, "Delete", w, http.StatusOK, "<csw:totalDeleted>250</csw:totalDeleted>")
+}
+
+func TestCSWHarvest(t *testing.T) {
+	notifications := make(chan string, 1)
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/record.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, cswHarvestedRecord)
+		case "/bad.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, cswBadBoundingBoxRecord)
+		case "/redirect":
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "127.0.0.1", "localhost", 1)+"/record.xml", http.StatusFound)
+		case "/handler":
+			body, _ := ioutil.ReadAll(r.Body)
+			notifications <- string(body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer source.Close()
+
+	cat := newTestCatalogue(t)
+	cat.Config.Server.Transactions = true
+	router := CSW3OpenSearchRouter(cat)
+	harvest := func(source string, handler string) string {
+		query := url.Values{
+			"service":      {"CSW"},
+			"version":      {"2.0.2"},
+			"request":      {"Harvest"},
+			"source":       {source},
+			"resourcetype": {"http://www.opengis.net/cat/csw/2.0.2"},
+		}
+		if handler != "" {
+			query.Set("responsehandler", handler)
+		}
+		return "/csw?" + query.Encode()
+	}
+
+	// without harvest hosts only public addresses are fetched
+	w := serve(router, "GET", harvest(source.URL+"/record.xml", ""), "")
+	expectBody(t, "loopback address", w, http.StatusBadRequest, `exceptionCode="InvalidParameterValue"`, `locator="source"`)
+	w = serve(router, "GET", harvest(strings.Replace(source.URL, "127.0.0.1", "localhost", 1)+"/record.xml", ""), "")
+	expectBody(t, "loopback name", w, http.StatusBadGateway, "is not a public address")
+
+	cat.Config.Server.HarvestHosts = []string{"127.0.0.1"}
+	w = serve(router, "GET", harvest(strings.Replace(source.URL, "127.0.0.1", "localhost", 1)+"/record.xml", ""), "")
+	expectBody(t, "other host", w, http.StatusBadRequest, "localhost is not a harvest host")
+	w = serve(router, "GET", harvest(source.URL+"/redirect", ""), "")
+	expectBody(t, "redirect", w, http.StatusBadGateway, "localhost is not a harvest host")
+
+	w = serve(router, "GET", harvest(source.URL+"/record.xml", ""), "")
+	expectBody(t, "insert", w, http.StatusOK, "HarvestResponse", "<csw:totalInserted>1</csw:totalInserted>", "<dc:identifier>lakes</dc:identifier>")
+	w = serve(router, "GET", harvest(source.URL+"/record.xml", ""), "")
+	expectBody(t, "update", w, http.StatusOK, "<csw:totalInserted>0</csw:totalInserted>", "<csw:totalUpdated>1</csw:totalUpdated>")
+
+	w = serve(router, "GET", harvest(source.URL+"/bad.xml", ""), "")
+	expectBody(t, "invalid bounding box", w, http.StatusBadRequest, `exceptionCode="InvalidParameterValue"`, "LowerCorner")
+
+	notified := func(name string, fragment string) {
+		t.Helper()
+		select {
+		case notification := <-notifications:
+			if !strings.Contains(notification, fragment) {
+				t.Errorf("%s: unexpected notification: %s", name, notification)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: response handler not notified", name)
+		}
+	}
+	w = serve(router, "GET", harvest(source.URL+"/record.xml", source.URL+"/handler"), "")
+	expectBody(t, "acknowledgement", w, http.StatusOK, "Acknowledgement")
+	notified("acknowledgement", "<csw:totalUpdated>1</csw:totalUpdated>")
+	w = serve(router, "GET", harvest(source.URL+"/bad.xml", source.URL+"/handler"), "")
+	expectBody(t, "invalid bounding box acknowledgement", w, http.StatusOK, "Acknowledgement")
+	notified("invalid bounding box acknowledgement", "LowerCorner")
+}
+
+func TestCSWTransactionExtents(t *testing.T) {
+	cat := newTestCatalogue(t)
+	cat.Config.Server.Transactions = true
+	router := CSW3OpenSearchRouter(cat)
+	ctx := context.Background()
+	if err := cat.InsertCollection(ctx, metadata.Collection{Identifier: "transport"}); err != nil {
+		t.Fatal(err)
+	}
+	extent := func() [4]float64 {
+		collection, err := cat.GetCollection(ctx, "transport")
+		if err != nil || collection.Extent.BBox == nil {
+			t.Fatalf("no extent: %v", err)
+		}
+		return *collection.Extent.BBox
+	}
+	if bbox := extent(); bbox != [4]float64{0, 40, 15, 55} {
+		t.Errorf("initial extent %v", bbox)
+	}
+
+	ferries := metadata.Record{Identifier: "ferries", Properties: metadata.Properties{Title: "Ferries", Collection: "transport"}}
+	ferries.SetGeometry(metadata.NewBBoxPolygon([4]float64{-5, 50, 0, 60}))
+	if failures := cat.BulkIndex(ctx, []metadata.Record{ferries}); len(failures) > 0 {
+		t.Fatalf("indexing failed: %v", failures)
+	}
+	if bbox := extent(); bbox != [4]float64{-5, 40, 15, 60} {
+		t.Errorf("extent after insert %v", bbox)
+	}
+
+	del := cswTransactionHeader + `<csw:Delete>
+		<csw:Constraint version="1.1.0"><csw:CqlText>title = 'Ferries' OR title = 'Rail network'</csw:CqlText></csw:Constraint>
+	</csw:Delete></csw:Transaction>`
+	w := serve(router, "POST", "/csw", del)
+	expectBody(t, "Delete", w, http.StatusOK, "<csw:totalDeleted>2</csw:totalDeleted>")
+	if bbox := extent(); bbox != [4]float64{0, 40, 10, 50} {
+		t.Errorf("extent after delete %v", bbox)
+	}
+}
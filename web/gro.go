@@ -28,12 +28,18 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/cql2"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/repository"
+	"github.com/go-spatial/geocatalogo/search"
 	"github.com/gorilla/mux"
 )
 
@@ -140,6 +146,11 @@ func GRORouter(cat *geocatalogo.GeoCatalogue) *mux.Router {
 		GRORecord(w, r, cat)
 	}).Methods("GET")
 
+	// Facets - value counts across filtered records
+	api.HandleFunc("/facets", func(w http.ResponseWriter, r *http.Request) {
+		GROFacets(w, r, cat)
+	}).Methods("GET")
+
 	// Resources - unified query endpoint with filters
 	api.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
 		GROResources(w, r, cat)
@@ -151,7 +162,11 @@ func GRORouter(cat *geocatalogo.GeoCatalogue) *mux.Router {
 // GRORoot provides catalog overview
 func GRORoot(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
 	// Get total count
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 1, map[string]string{})
+	results, err := cat.Search(r.Context(), search.Request{Size: 1})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	response := map[string]interface{}{
 		"api":           "gro",
@@ -169,8 +184,13 @@ func GRORoot(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalog
 		"endpoints": map[string]interface{}{
 			"search": map[string]string{
 				"path":        "/api/v1/search",
-				"description": "Search with text query and filters",
-				"example":     "/api/v1/search?q=wildfire&size=10",
+				"description": "Search with text query, filters and a CQL2 filter",
+				"example":     "/api/v1/search?q=wildfire&size=10&sort=-datetime&fields=title,gro_metadata.country",
+			},
+			"facets": map[string]string{
+				"path":        "/api/v1/facets",
+				"description": "Value counts of fields across records matching filters",
+				"example":     "/api/v1/facets?fields=country,data_format&status=active",
 			},
 			"resources": map[string]string{
 				"path":        "/api/v1/resources",
@@ -231,6 +251,7 @@ func GROSearch(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 	var q string
 	var from, size int
 	var collections []string
+	var fields []string
 
 	// Parse query parameters
 	query := r.URL.Query()
@@ -253,45 +274,75 @@ func GROSearch(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 		collections = strings.Split(collVal, ",")
 	}
 
-	// Extract property filters
-	propertyFilters := make(map[string]string)
-	propertyKeys := []string{
-		"continent", "country", "state", "city", "admin2",
-		"collection", "type", "owner", "data_format", "status",
-		"geographic_scope", "database_table", "v6_job_file",
-		"v6_job_type", "s3_path", "title", "implementation_status",
+	if fieldsVal := query.Get("fields"); fieldsVal != "" {
+		fields = strings.Split(fieldsVal, ",")
 	}
 
-	for _, key := range propertyKeys {
-		if val := query.Get(key); val != "" {
-			propertyFilters[key] = val
-		}
+	sortBy, err := search.ParseSort(query.Get("sort"))
+	if err != nil {
+		groBadRequest(w, err)
+		return
 	}
 
-	// Perform search
-	results := cat.Search(collections, q, []float64{}, []time.Time{}, from, size, propertyFilters)
+	spatialFilter, err := groSpatialFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	filter, err := groFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	// Extract property filters
+	propertyFilters := search.ParseFilters(query)
+
+	// Perform search, resuming from a page token if given
+	req := search.Request{
+		Collections: collections,
+		Term:        q,
+		Filters:     propertyFilters,
+		Spatial:     spatialFilter,
+		Filter:      filter,
+		Sort:        sortBy,
+		From:        from,
+		Size:        size,
+		Fields:      fields,
+	}
+	if token := query.Get("token"); token != "" {
+		if err := cat.ApplyToken(token, &req); err != nil {
+			groBadRequest(w, err)
+			return
+		}
+	}
+	results, err := cat.Search(r.Context(), req)
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	// Return results as JSON
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"matched":  results.Matches,
-		"returned": len(results.Records),
-		"from":     from,
-		"size":     size,
-		"records":  results.Records,
+		"matched":    results.Matches,
+		"returned":   len(results.Records),
+		"from":       req.From,
+		"size":       size,
+		"next_token": results.NextToken,
+		"records":    results.Records,
 	})
 }
 
 // GROListContinents lists all unique continents with counts
 func GROListContinents(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
-	continentCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.Continent != "" {
-			continentCounts[rec.Properties.GROMetadata.Continent]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"continent"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	continentCounts := facets.Facets["continent"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -302,8 +353,6 @@ func GROListContinents(w http.ResponseWriter, r *http.Request, cat *geocatalogo.
 
 // GROListAllCountries lists all unique countries across all continents with counts
 func GROListAllCountries(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
 	// Store countries with continent context
 	type CountryInfo struct {
 		Count     int    `json:"count"`
@@ -311,18 +360,21 @@ func GROListAllCountries(w http.ResponseWriter, r *http.Request, cat *geocatalog
 	}
 	countriesMap := make(map[string]*CountryInfo)
 
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.Country != "" {
-			country := rec.Properties.GROMetadata.Country
-			continent := rec.Properties.GROMetadata.Continent
-
-			if _, exists := countriesMap[country]; !exists {
-				countriesMap[country] = &CountryInfo{
-					Count:     0,
-					Continent: continent,
-				}
-			}
-			countriesMap[country].Count++
+	// Count countries, and the countries of each continent to attach the
+	// continent each country is catalogued under
+	pivot := search.PivotFacet("continent", "country")
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"country", pivot})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
+	for country, count := range facets.Facets["country"] {
+		countriesMap[country] = &CountryInfo{Count: count}
+	}
+	for value := range facets.Facets[pivot] {
+		continent, country, _ := search.SplitPivot(value)
+		if info, exists := countriesMap[country]; exists {
+			info.Continent = continent
 		}
 	}
 
@@ -344,7 +396,11 @@ func GROGeographyContinent(w http.ResponseWriter, r *http.Request, cat *geocatal
 	}
 
 	propertyFilters := map[string]string{"continent": continent}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -361,14 +417,12 @@ func GROListCountries(w http.ResponseWriter, r *http.Request, cat *geocatalogo.G
 	continent := vars["continent"]
 
 	propertyFilters := map[string]string{"continent": continent}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, propertyFilters)
-
-	countryCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.Country != "" {
-			countryCounts[rec.Properties.GROMetadata.Country]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{Filters: propertyFilters}, []string{"country"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	countryCounts := facets.Facets["country"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -393,7 +447,11 @@ func GROGeographyCountry(w http.ResponseWriter, r *http.Request, cat *geocatalog
 		"continent": continent,
 		"country":   country,
 	}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -415,14 +473,12 @@ func GROListStates(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoC
 		"continent": continent,
 		"country":   country,
 	}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, propertyFilters)
-
-	stateCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.StateProvince != "" {
-			stateCounts[rec.Properties.GROMetadata.StateProvince]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{Filters: propertyFilters}, []string{"state"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	stateCounts := facets.Facets["state"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -450,7 +506,11 @@ func GROGeographyState(w http.ResponseWriter, r *http.Request, cat *geocatalogo.
 		"country":   country,
 		"state":     state,
 	}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -475,14 +535,12 @@ func GROListCities(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoC
 		"country":   country,
 		"state":     state,
 	}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, propertyFilters)
-
-	cityCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.City != "" {
-			cityCounts[rec.Properties.GROMetadata.City]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{Filters: propertyFilters}, []string{"city"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	cityCounts := facets.Facets["city"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -513,7 +571,11 @@ func GROGeographyCity(w http.ResponseWriter, r *http.Request, cat *geocatalogo.G
 		"state":     state,
 		"city":      city,
 	}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -538,7 +600,11 @@ func GROFormat(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 	}
 
 	propertyFilters := map[string]string{"data_format": format}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -560,7 +626,11 @@ func GROStatus(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 	}
 
 	propertyFilters := map[string]string{"implementation_status": status}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -582,7 +652,11 @@ func GROOwner(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalo
 	}
 
 	propertyFilters := map[string]string{"owner": owner}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -604,14 +678,85 @@ func GROCollection(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoC
 	}
 
 	propertyFilters := map[string]string{"collection": collection}
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, size, propertyFilters)
+	results, err := cat.Search(r.Context(), search.Request{Filters: propertyFilters, Size: size})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"collection": collection,
 		"matched":    results.Matches,
 		"returned":   len(results.Records),
 		"records":    results.Records,
+	}
+	description, err := cat.GetCollection(r.Context(), collection)
+	if err == nil {
+		response["metadata"] = description
+	} else if !errors.Is(err, repository.ErrCollectionNotFound) {
+		groRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// groBadRequest reports an invalid request parameter
+func groBadRequest(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": err.Error(),
+	})
+}
+
+// groSpatialFilter builds a spatial filter from the bbox and
+// spatial_relation parameters, returning nil when no bbox is given
+func groSpatialFilter(query url.Values) (*search.SpatialFilter, error) {
+	value := query.Get("bbox")
+	if value == "" {
+		if query.Get("spatial_relation") != "" {
+			return nil, errors.New("spatial_relation requires a bbox")
+		}
+		return nil, nil
+	}
+	bbox, err := search.ParseBBox(value)
+	if err != nil {
+		return nil, err
+	}
+	relation, err := search.ParseRelation(query.Get("spatial_relation"))
+	if err != nil {
+		return nil, err
+	}
+	return &search.SpatialFilter{BBox: bbox, Relation: relation}, nil
+}
+
+// groFilter parses the CQL2 filter and filter-lang parameters,
+// returning nil when no filter is given
+func groFilter(query url.Values) (cql2.Expr, error) {
+	value := query.Get("filter")
+	if value == "" {
+		return nil, nil
+	}
+	lang := query.Get("filter-lang")
+	if lang == "" {
+		lang = cql2.LangText
+	}
+	filter, err := cql2.Parse(value, lang)
+	if err != nil {
+		return nil, fmt.Errorf("filter error: %v", err)
+	}
+	return filter, nil
+}
+
+// groRepositoryError reports a failed repository operation, with 504
+// Gateway Timeout when the repository did not respond in time
+func groRepositoryError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(repositoryStatus(err))
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": err.Error(),
 	})
 }
 
@@ -620,7 +765,11 @@ func GRORecord(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	results := cat.Get([]string{id})
+	results, err := cat.Get(r.Context(), []string{id})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	if len(results.Records) == 0 {
 		w.WriteHeader(http.StatusNotFound)
@@ -637,14 +786,28 @@ func GRORecord(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 
 // GROListCollections lists all unique collections with counts
 func GROListCollections(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	// Get all records
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
-	// Count collections
-	collectionCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.Collection != "" {
-			collectionCounts[rec.Properties.Collection]++
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"collection"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
+	collectionCounts := facets.Facets["collection"]
+	if collectionCounts == nil {
+		collectionCounts = make(map[string]int)
+	}
+
+	// stored collections are listed with their descriptions, even
+	// before records are added to them
+	collections, err := cat.Collections(r.Context())
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
+	descriptions := make(map[string]metadata.Collection)
+	for _, collection := range collections {
+		descriptions[collection.Identifier] = collection
+		if _, ok := collectionCounts[collection.Identifier]; !ok {
+			collectionCounts[collection.Identifier] = 0
 		}
 	}
 
@@ -652,19 +815,18 @@ func GROListCollections(w http.ResponseWriter, r *http.Request, cat *geocatalogo
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"total":       len(collectionCounts),
 		"collections": collectionCounts,
+		"metadata":    descriptions,
 	})
 }
 
 // GROListFormats lists all unique formats with counts
 func GROListFormats(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
-	formatCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.DataFormat != "" {
-			formatCounts[rec.Properties.GROMetadata.DataFormat]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"data_format"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	formatCounts := facets.Facets["data_format"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -675,14 +837,12 @@ func GROListFormats(w http.ResponseWriter, r *http.Request, cat *geocatalogo.Geo
 
 // GROListStatuses lists all unique implementation statuses with counts
 func GROListStatuses(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
-	statusCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.ImplementationStatus != "" {
-			statusCounts[rec.Properties.GROMetadata.ImplementationStatus]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"status"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	statusCounts := facets.Facets["status"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -693,14 +853,12 @@ func GROListStatuses(w http.ResponseWriter, r *http.Request, cat *geocatalogo.Ge
 
 // GROListOwners lists all unique owners with counts
 func GROListOwners(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	results := cat.Search([]string{}, "", []float64{}, []time.Time{}, 0, 10000, map[string]string{})
-
-	ownerCounts := make(map[string]int)
-	for _, rec := range results.Records {
-		if rec.Properties.GROMetadata != nil && rec.Properties.GROMetadata.Owner != "" {
-			ownerCounts[rec.Properties.GROMetadata.Owner]++
-		}
+	facets, err := cat.Facets(r.Context(), search.Request{}, []string{"owner"})
+	if err != nil {
+		groRepositoryError(w, err)
+		return
 	}
+	ownerCounts := facets.Facets["owner"]
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -709,25 +867,65 @@ func GROListOwners(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoC
 	})
 }
 
+// GROFacets counts the values of the requested fields across all records
+// matching the filters: ?fields=country,data_format&status=active
+func GROFacets(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	query := r.URL.Query()
+
+	fields, err := search.ParseFacets(query.Get("fields"))
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+	if len(fields) == 0 {
+		groBadRequest(w, errors.New("fields is required (one or more of "+strings.Join(search.FacetFields, ", ")+")"))
+		return
+	}
+
+	spatialFilter, err := groSpatialFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	filter, err := groFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	req := search.Request{
+		Term:    query.Get("q"),
+		Filters: search.ParseFilters(query),
+		Spatial: spatialFilter,
+		Filter:  filter,
+	}
+	if collVal := query.Get("collections"); collVal != "" {
+		req.Collections = strings.Split(collVal, ",")
+	}
+
+	results, err := cat.Facets(r.Context(), req, fields)
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filters": req.Filters,
+		"query":   req.Term,
+		"matched": results.Matches,
+		"facets":  results.Facets,
+	})
+}
+
 // GROResources provides unified resource querying with multiple filters
 // This endpoint allows combining filters: ?collection=ai_agent&format=csv&status=implemented
 func GROResources(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
 	query := r.URL.Query()
-	propertyFilters := make(map[string]string)
 
 	// Extract all supported property filters
-	propertyKeys := []string{
-		"continent", "country", "state", "city", "admin2",
-		"collection", "type", "owner", "data_format", "status",
-		"geographic_scope", "database_table", "v6_job_file",
-		"v6_job_type", "s3_path", "title", "implementation_status",
-	}
-
-	for _, key := range propertyKeys {
-		if val := query.Get(key); val != "" {
-			propertyFilters[key] = val
-		}
-	}
+	propertyFilters := search.ParseFilters(query)
 
 	// Parse pagination
 	var from, size int
@@ -743,17 +941,63 @@ func GROResources(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCa
 	// Optional text search
 	q := query.Get("q")
 
-	// Perform search
-	results := cat.Search([]string{}, q, []float64{}, []time.Time{}, from, size, propertyFilters)
+	// Optional field selection
+	var fields []string
+	if fieldsVal := query.Get("fields"); fieldsVal != "" {
+		fields = strings.Split(fieldsVal, ",")
+	}
+
+	// Optional sort (e.g. sort=-datetime,title)
+	sortBy, err := search.ParseSort(query.Get("sort"))
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	spatialFilter, err := groSpatialFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	filter, err := groFilter(query)
+	if err != nil {
+		groBadRequest(w, err)
+		return
+	}
+
+	// Perform search, resuming from a page token if given
+	req := search.Request{
+		Term:    q,
+		Filters: propertyFilters,
+		Spatial: spatialFilter,
+		Filter:  filter,
+		Sort:    sortBy,
+		From:    from,
+		Size:    size,
+		Fields:  fields,
+	}
+	if token := query.Get("token"); token != "" {
+		if err := cat.ApplyToken(token, &req); err != nil {
+			groBadRequest(w, err)
+			return
+		}
+	}
+	results, err := cat.Search(r.Context(), req)
+	if err != nil {
+		groRepositoryError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"filters":  propertyFilters,
-		"query":    q,
-		"matched":  results.Matches,
-		"returned": len(results.Records),
-		"from":     from,
-		"size":     size,
-		"records":  results.Records,
+		"filters":    propertyFilters,
+		"query":      q,
+		"matched":    results.Matches,
+		"returned":   len(results.Records),
+		"from":       req.From,
+		"size":       size,
+		"next_token": results.NextToken,
+		"records":    results.Records,
 	})
 }
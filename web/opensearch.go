@@ -0,0 +1,321 @@
+///////////////////////////////////////////////////////////////////////////////
+//
+// OpenSearch description document and Atom/GeoRSS responses
+//
+///////////////////////////////////////////////////////////////////////////////
+
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/search"
+)
+
+// OpenSearch media types
+const (
+	AtomMimeType                  = "application/atom+xml"
+	OpenSearchDescriptionMimeType = "application/opensearchdescription+xml"
+)
+
+// OpenSearch namespaces
+const (
+	openSearchNS     = "http://a9.com/-/spec/opensearch/1.1/"
+	openSearchGeoNS  = "http://a9.com/-/opensearch/extensions/geo/1.0/"
+	openSearchTimeNS = "http://a9.com/-/opensearch/extensions/time/1.0/"
+	atomNS           = "http://www.w3.org/2005/Atom"
+	geoRSSNS         = "http://www.georss.org/georss"
+)
+
+// openSearchURL is a URL template of the description document
+type openSearchURL struct {
+	Type        string `xml:"type,attr"`
+	Rel         string `xml:"rel,attr,omitempty"`
+	IndexOffset string `xml:"indexOffset,attr,omitempty"`
+	Template    string `xml:"template,attr"`
+}
+
+// openSearchDescription is an OpenSearch description document
+type openSearchDescription struct {
+	XMLName          xml.Name        `xml:"OpenSearchDescription"`
+	XMLNS            string          `xml:"xmlns,attr"`
+	XMLNSGeo         string          `xml:"xmlns:geo,attr"`
+	XMLNSTime        string          `xml:"xmlns:time,attr"`
+	ShortName        string          `xml:"ShortName"`
+	LongName         string          `xml:"LongName,omitempty"`
+	Description      string          `xml:"Description"`
+	Tags             string          `xml:"Tags,omitempty"`
+	Contact          string          `xml:"Contact,omitempty"`
+	URLs             []openSearchURL `xml:"Url"`
+	Developer        string          `xml:"Developer,omitempty"`
+	Attribution      string          `xml:"Attribution,omitempty"`
+	SyndicationRight string          `xml:"SyndicationRight"`
+	AdultContent     string          `xml:"AdultContent"`
+	Language         string          `xml:"Language"`
+	OutputEncoding   string          `xml:"OutputEncoding"`
+	InputEncoding    string          `xml:"InputEncoding"`
+}
+
+// openSearchTemplate is the query of the URL templates, in the
+// parameters of CSW3OpenSearchHandler
+var openSearchTemplate = "q={searchTerms?}&startposition={startIndex?}&maxrecords={count?}" +
+	"&bbox={geo:box?}&geometry={geo:geometry?}&relation={geo:relation?}&recordids={geo:uid?}" +
+	"&start={time:start?}&end={time:end?}"
+
+// OpenSearchDescriptionHandler serves the OpenSearch description
+// document of the default API
+func OpenSearchDescriptionHandler(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	identification := cat.Config.Metadata.Identification
+	shortName := identification.Title
+	for utf8.RuneCountInString(shortName) > 16 {
+		_, size := utf8.DecodeLastRuneInString(shortName)
+		shortName = shortName[:len(shortName)-size]
+	}
+	language := cat.Config.Server.Language
+	if language == "" {
+		language = "*"
+	}
+
+	base := cat.Config.Server.URL + "/?"
+	description := openSearchDescription{
+		XMLNS:       openSearchNS,
+		XMLNSGeo:    openSearchGeoNS,
+		XMLNSTime:   openSearchTimeNS,
+		ShortName:   shortName,
+		LongName:    identification.Title,
+		Description: identification.Abstract,
+		Tags:        strings.Join(identification.Keywords, " "),
+		Contact:     cat.Config.Metadata.Contact.Email,
+		URLs: []openSearchURL{
+			{Type: AtomMimeType, Rel: "results", IndexOffset: "0", Template: base + openSearchTemplate + "&outputformat=" + url.QueryEscape(AtomMimeType)},
+			{Type: "application/json", Rel: "results", IndexOffset: "0", Template: base + openSearchTemplate},
+			{Type: OpenSearchDescriptionMimeType, Rel: "self", Template: cat.Config.Server.URL + "/opensearch.xml"},
+		},
+		Developer:        cat.Config.Metadata.Provider.Name,
+		Attribution:      cat.Config.Metadata.Provider.Name,
+		SyndicationRight: "open",
+		AdultContent:     "false",
+		Language:         language,
+		OutputEncoding:   "UTF-8",
+		InputEncoding:    "UTF-8",
+	}
+	emitOpenSearchXML(w, cat, OpenSearchDescriptionMimeType, description)
+}
+
+// atomLink is a link of a feed or entry
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+}
+
+// atomCategory is a keyword of an entry
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomEntry is a record in an Atom feed, located with GeoRSS
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Identifier string         `xml:"dc:identifier"`
+	Date       string         `xml:"dc:date,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Links      []atomLink     `xml:"link"`
+	Point      string         `xml:"georss:point,omitempty"`
+	Line       string         `xml:"georss:line,omitempty"`
+	Polygon    string         `xml:"georss:polygon,omitempty"`
+	Box        string         `xml:"georss:box,omitempty"`
+}
+
+// atomQuery echoes the search in a feed
+type atomQuery struct {
+	Role        string `xml:"role,attr"`
+	SearchTerms string `xml:"searchTerms,attr,omitempty"`
+	StartIndex  int    `xml:"startIndex,attr"`
+	Count       int    `xml:"count,attr"`
+}
+
+// atomFeed is a page of search results as an Atom feed with OpenSearch
+// response elements
+type atomFeed struct {
+	XMLName     xml.Name `xml:"feed"`
+	XMLNS       string   `xml:"xmlns,attr"`
+	XMLNSOS     string   `xml:"xmlns:os,attr"`
+	XMLNSGeoRSS string   `xml:"xmlns:georss,attr"`
+	XMLNSDC     string   `xml:"xmlns:dc,attr"`
+	ID          string   `xml:"id"`
+	Title       string   `xml:"title"`
+	Updated     string   `xml:"updated"`
+	Author      struct {
+		Name string `xml:"name"`
+		URI  string `xml:"uri,omitempty"`
+	} `xml:"author"`
+	Links        []atomLink  `xml:"link"`
+	TotalResults int         `xml:"os:totalResults"`
+	StartIndex   int         `xml:"os:startIndex"`
+	ItemsPerPage int         `xml:"os:itemsPerPage"`
+	Query        atomQuery   `xml:"os:Query"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+// openSearchPageURL returns the URL of the request with another start
+// position, dropping any page token
+func openSearchPageURL(r *http.Request, cat *geocatalogo.GeoCatalogue, startPosition int) string {
+	query := r.URL.Query()
+	for k := range query {
+		if strings.EqualFold(k, "startposition") || strings.EqualFold(k, "token") {
+			query.Del(k)
+		}
+	}
+	query.Set("startposition", strconv.Itoa(startPosition))
+	return cat.Config.Server.URL + r.URL.Path + "?" + query.Encode()
+}
+
+// EmitAtomResponse writes search results as an Atom feed; the request
+// started at startPosition, asking for maxRecords records
+func EmitAtomResponse(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, q string, startPosition int, maxRecords int, results *search.Results) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	self := cat.Config.Server.URL + r.URL.RequestURI()
+
+	feed := atomFeed{
+		XMLNS:        atomNS,
+		XMLNSOS:      openSearchNS,
+		XMLNSGeoRSS:  geoRSSNS,
+		XMLNSDC:      "http://purl.org/dc/elements/1.1/",
+		ID:           self,
+		Title:        cat.Config.Metadata.Identification.Title,
+		Updated:      now,
+		TotalResults: results.Matches,
+		StartIndex:   startPosition,
+		ItemsPerPage: maxRecords,
+		Query:        atomQuery{Role: "request", SearchTerms: q, StartIndex: startPosition, Count: maxRecords},
+	}
+	feed.Author.Name = cat.Config.Metadata.Provider.Name
+	if feed.Author.Name == "" {
+		feed.Author.Name = feed.Title
+	}
+	feed.Author.URI = cat.Config.Metadata.Provider.URL
+
+	feed.Links = []atomLink{
+		{Rel: "self", Type: AtomMimeType, Href: self},
+		{Rel: "search", Type: OpenSearchDescriptionMimeType, Href: cat.Config.Server.URL + "/opensearch.xml"},
+		{Rel: "first", Type: AtomMimeType, Href: openSearchPageURL(r, cat, 0)},
+	}
+	if startPosition > 0 {
+		previous := startPosition - maxRecords
+		if previous < 0 {
+			previous = 0
+		}
+		feed.Links = append(feed.Links, atomLink{Rel: "previous", Type: AtomMimeType, Href: openSearchPageURL(r, cat, previous)})
+	}
+	if results.NextRecord > 0 {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Type: AtomMimeType, Href: openSearchPageURL(r, cat, results.NextRecord)})
+	}
+	if maxRecords > 0 && results.Matches > 0 {
+		last := (results.Matches - 1) / maxRecords * maxRecords
+		feed.Links = append(feed.Links, atomLink{Rel: "last", Type: AtomMimeType, Href: openSearchPageURL(r, cat, last)})
+	}
+
+	for _, record := range results.Records {
+		feed.Entries = append(feed.Entries, atomRecordEntry(cat, record, now))
+	}
+	emitOpenSearchXML(w, cat, AtomMimeType, feed)
+}
+
+// atomRecordEntry encodes a record as an Atom entry, updated when the
+// record was modified or else inserted
+func atomRecordEntry(cat *geocatalogo.GeoCatalogue, record metadata.Record, now string) atomEntry {
+	p := record.Properties
+	href := cat.Config.Server.URL + "/?recordids=" + url.QueryEscape(record.Identifier)
+	entry := atomEntry{
+		ID:         href,
+		Title:      p.Title,
+		Updated:    now,
+		Summary:    p.Abstract,
+		Identifier: record.Identifier,
+		Links: []atomLink{
+			{Rel: "alternate", Type: "application/json", Href: href},
+			{Rel: "alternate", Type: AtomMimeType, Href: href + "&outputformat=" + url.QueryEscape(AtomMimeType)},
+		},
+	}
+	switch {
+	case p.Modified != nil:
+		entry.Updated = p.Modified.UTC().Format(time.RFC3339)
+	case !p.Geocatalogo.Inserted.IsZero():
+		entry.Updated = p.Geocatalogo.Inserted.UTC().Format(time.RFC3339)
+	}
+	if p.Datetime != nil {
+		entry.Date = p.Datetime.UTC().Format(time.RFC3339)
+	}
+	for _, set := range p.KeywordsSets {
+		for _, keyword := range set.Keyword {
+			entry.Categories = append(entry.Categories, atomCategory{Term: keyword})
+		}
+	}
+	for _, link := range record.Links {
+		if link.URL == "" {
+			continue
+		}
+		rel := link.Rel
+		if rel == "" {
+			rel = "related"
+		}
+		entry.Links = append(entry.Links, atomLink{Rel: rel, Type: link.Type, Title: link.Name, Href: link.URL})
+	}
+	geoRSS(&entry, record)
+	return entry
+}
+
+// geoRSS locates an entry with the point, line or polygon (exterior)
+// of its record, or the box of other geometries.  GeoRSS is latitude
+// first
+func geoRSS(entry *atomEntry, record metadata.Record) {
+	g := record.Geometry
+	switch g.Type {
+	case metadata.GeometryPoint:
+		entry.Point = geoRSSPositions([]metadata.Position{g.Point})
+		return
+	case metadata.GeometryLineString:
+		entry.Line = geoRSSPositions(g.LineString)
+		return
+	case metadata.GeometryPolygon:
+		if len(g.Polygon) > 0 {
+			entry.Polygon = geoRSSPositions(g.Polygon[0])
+			return
+		}
+	}
+	if bbox, ok := record.Bounds(); ok {
+		entry.Box = geoRSSPositions([]metadata.Position{{bbox[0], bbox[1]}, {bbox[2], bbox[3]}})
+	}
+}
+
+func geoRSSPositions(positions []metadata.Position) string {
+	coordinates := make([]string, 0, 2*len(positions))
+	for _, p := range positions {
+		coordinates = append(coordinates,
+			strconv.FormatFloat(p[1], 'f', -1, 64),
+			strconv.FormatFloat(p[0], 'f', -1, 64))
+	}
+	return strings.Join(coordinates, " ")
+}
+
+// emitOpenSearchXML writes an OpenSearch document, or an exception
+// when it cannot be encoded
+func emitOpenSearchXML(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, contentType string, doc interface{}) {
+	if err := emitXML(w, cat, contentType, doc); err != nil {
+		exception := search.Exception{Code: 20006, Description: "ERROR: " + err.Error()}
+		EmitResponseError(w, cat.Config.Server.MimeType, cat.Config.Server.PrettyPrint, http.StatusInternalServerError, &exception)
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestOpenSearchDescription(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+
+	w := serve(router, "GET", "/opensearch.xml", "")
+	expectBody(t, "description", w, http.StatusOK,
+		`<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/"`,
+		`type="application/atom+xml"`,
+		`template="http://localhost:8000/?q={searchTerms?}`)
+	if contentType := w.Header().Get("Content-Type"); contentType != OpenSearchDescriptionMimeType+"; charset=UTF-8" {
+		t.Errorf("Content-Type is %s", contentType)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("CORS headers not set")
+	}
+}
+
+func TestOpenSearchAtomFeed(t *testing.T) {
+	router := CSW3OpenSearchRouter(newTestCatalogue(t))
+
+	query := url.Values{"outputformat": {AtomMimeType}, "bbox": {"-180,-90,180,90"}, "maxrecords": {"1"}, "startposition": {"1"}}
+	w := serve(router, "GET", "/?"+query.Encode(), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != AtomMimeType+"; charset=UTF-8" {
+		t.Errorf("Content-Type is %s", contentType)
+	}
+
+	var feed struct {
+		TotalResults int `xml:"http://a9.com/-/spec/opensearch/1.1/ totalResults"`
+		StartIndex   int `xml:"http://a9.com/-/spec/opensearch/1.1/ startIndex"`
+		ItemsPerPage int `xml:"http://a9.com/-/spec/opensearch/1.1/ itemsPerPage"`
+		Links        []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		Entries []struct {
+			Polygon string `xml:"http://www.georss.org/georss polygon"`
+		} `xml:"http://www.w3.org/2005/Atom entry"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.TotalResults != 3 || feed.StartIndex != 1 || feed.ItemsPerPage != 1 {
+		t.Errorf("totalResults %d, startIndex %d, itemsPerPage %d", feed.TotalResults, feed.StartIndex, feed.ItemsPerPage)
+	}
+	if len(feed.Entries) != 1 || feed.Entries[0].Polygon == "" {
+		t.Errorf("expected a single entry with a GeoRSS polygon: %s", w.Body)
+	}
+
+	page := func(startPosition string) string {
+		q := url.Values{"outputformat": {AtomMimeType}, "bbox": {"-180,-90,180,90"}, "maxrecords": {"1"}, "startposition": {startPosition}}
+		return "http://localhost:8000/?" + q.Encode()
+	}
+	links := make(map[string]string)
+	for _, link := range feed.Links {
+		links[link.Rel] = link.Href
+	}
+	expected := map[string]string{
+		"self":     "http://localhost:8000/?" + query.Encode(),
+		"search":   "http://localhost:8000/opensearch.xml",
+		"first":    page("0"),
+		"previous": page("0"),
+		"next":     page("2"),
+		"last":     page("2"),
+	}
+	for rel, href := range expected {
+		if links[rel] != href {
+			t.Errorf("%s link is %q, expected %q", rel, links[rel], href)
+		}
+	}
+
+	query.Set("startposition", "2")
+	w = serve(router, "GET", "/?"+query.Encode(), "")
+	feed.Links = nil
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range feed.Links {
+		if link.Rel == "next" {
+			t.Errorf("last page links to a next page: %s", link.Href)
+		}
+	}
+}
@@ -27,10 +27,14 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/go-spatial/geocatalogo"
 	"github.com/go-spatial/geocatalogo/search"
 )
 
@@ -51,10 +55,16 @@ func EmitResponseOK(w http.ResponseWriter, contentType string, prettyPrint bool,
 
 // EmitResponseNotOK provides HTTP response for unsuccessful requests
 func EmitResponseNotOK(w http.ResponseWriter, contentType string, prettyPrint bool, exception *search.Exception) {
+	EmitResponseError(w, contentType, prettyPrint, 400, exception)
+}
+
+// EmitResponseError provides HTTP response for requests which failed
+// with the given status code
+func EmitResponseError(w http.ResponseWriter, contentType string, prettyPrint bool, status int, exception *search.Exception) {
 	var jsonBytes []byte
 
 	w.Header().Set("Content-Type", contentType)
-	w.WriteHeader(400)
+	w.WriteHeader(status)
 
 	if prettyPrint == true {
 		jsonBytes, _ = json.MarshalIndent(exception, "", "    ")
@@ -64,3 +74,37 @@ func EmitResponseNotOK(w http.ResponseWriter, contentType string, prettyPrint bo
 	fmt.Fprintf(w, "%s", jsonBytes)
 	return
 }
+
+// emitXML writes a document as XML of a content type, returning
+// without writing anything when the document cannot be encoded
+func emitXML(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, contentType string, doc interface{}) error {
+	var body []byte
+	var err error
+	if cat.Config.Server.PrettyPrint == true {
+		body, err = xml.MarshalIndent(doc, "", "  ")
+	} else {
+		body, err = xml.Marshal(doc)
+	}
+	if err != nil {
+		return err
+	}
+	geocatalogo.EmitResponseType(cat, w, http.StatusOK, contentType+"; charset=UTF-8", append([]byte(xml.Header), body...))
+	return nil
+}
+
+// repositoryStatus returns the HTTP status code for a failed repository
+// operation: 504 when it ran out of time, 500 otherwise
+func repositoryStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// repositoryException describes a failed repository operation
+func repositoryException(err error) search.Exception {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return search.Exception{Code: 20005, Description: "ERROR: the repository did not respond in time"}
+	}
+	return search.Exception{Code: 20006, Description: "ERROR: " + err.Error()}
+}
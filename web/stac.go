@@ -28,41 +28,69 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-spatial/geocatalogo"
+	"github.com/go-spatial/geocatalogo/cql2"
 	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/repository"
 	"github.com/go-spatial/geocatalogo/search"
 	"github.com/gorilla/mux"
 )
 
 // VERSION provides the supported version of the STAC API specification.
-const VERSION string = "0.8.0"
+const VERSION string = "1.0.0"
+
+// Media types of STAC API responses
+const (
+	GeoJSONMimeType = "application/geo+json"
+	OpenAPIMimeType = "application/vnd.oai.openapi+json;version=3.0"
+)
 
 type STACSearch struct {
-	Limit       int        `json:"limit,omitempty"`
-	Datetime    string     `json:"datetime,omitempty"`
-	Collections []string   `json:"collections,omitempty"`
-	Bbox        [4]float64 `json:"bbox,omitempty"`
+	Limit       int                `json:"limit,omitempty"`
+	Page        int                `json:"page,omitempty"`
+	Datetime    string             `json:"datetime,omitempty"`
+	Collections []string           `json:"collections,omitempty"`
+	Ids         []string           `json:"ids,omitempty"`
+	Bbox        [4]float64         `json:"bbox,omitempty"`
+	Intersects  *metadata.Geometry `json:"intersects,omitempty"`
+	SortBy      []STACSortBy       `json:"sortby,omitempty"`
+	Token       string             `json:"token,omitempty"`
+	Q           string             `json:"q,omitempty"`
+	Filter      json.RawMessage    `json:"filter,omitempty"`
+	FilterLang  string             `json:"filter-lang,omitempty"`
+}
+
+// STACSortBy describes a sort key of the STAC sort extension
+type STACSortBy struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction,omitempty"`
 }
 
 type Properties struct {
-	start    *time.Time `json:"start,omitempty"`
-	end      *time.Time `json:"end,omitempty"`
-	provider string     `json:"provider,omitempty"`
-	license  string     `json:"license,omitempty"`
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	Provider string     `json:"provider,omitempty"`
+	License  string     `json:"license,omitempty"`
 }
 
 type Link struct {
-	Rel   string `json:"rel"`
-	Type  string `json:"type,omitempty"`
-	Title string `json:"title,omitempty"`
-	Href  string `json:"href"`
+	Rel    string                 `json:"rel"`
+	Type   string                 `json:"type,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Href   string                 `json:"href"`
+	Method string                 `json:"method,omitempty"`
+	Body   map[string]interface{} `json:"body,omitempty"`
+	Merge  bool                   `json:"merge,omitempty"`
 }
 
 type SearchMetadata struct {
@@ -72,20 +100,25 @@ type SearchMetadata struct {
 	Matched  int    `json:"matched,omitempty"`
 }
 
-//type assets struct {
-//	name string `json:"name,omitempty"`
-//	href string `json:"href,omitempty"`
-//}
+// STACAsset describes a file of an item
+type STACAsset struct {
+	Href  string   `json:"href"`
+	Type  string   `json:"type,omitempty"`
+	Title string   `json:"title,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
 
 type STACItem struct {
-	Type        string              `json:"type,omitempty"`
-	Id          string              `json:"id,omitempty"`
-	StacVersion string              `json:"stac_version"`
-	BBox        [4]float64          `json:"bbox,omitempty"`
-	Geometry    metadata.Geometry   `json:"geometry,omitempty"`
-	Properties  metadata.Properties `json:"properties,omitempty"`
-	Links       []Link              `json:"links,omitempty"`
-	Assets      map[string]Link     `json:"assets,omitempty"`
+	Type           string                 `json:"type"`
+	StacVersion    string                 `json:"stac_version"`
+	StacExtensions []string               `json:"stac_extensions"`
+	Id             string                 `json:"id"`
+	Collection     string                 `json:"collection,omitempty"`
+	BBox           *[4]float64            `json:"bbox,omitempty"`
+	Geometry       metadata.Geometry      `json:"geometry"`
+	Properties     map[string]interface{} `json:"properties"`
+	Links          []Link                 `json:"links"`
+	Assets         map[string]STACAsset   `json:"assets"`
 }
 
 type STACFeatureCollection struct {
@@ -98,35 +131,216 @@ type STACFeatureCollection struct {
 }
 
 type STACCatalogDefinition struct {
-	Version     string `json:"stac_version"`
-	Id          string `json:"id"`
-	Title       string `json:"title,omitempty"`
-	Description string `json:"description"`
-	Links       []Link `json:"links"`
+	Type        string   `json:"type"`
+	Version     string   `json:"stac_version"`
+	Id          string   `json:"id"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description"`
+	ConformsTo  []string `json:"conformsTo,omitempty"`
+	Links       []Link   `json:"links"`
+}
+
+// STACExtent is the spatial and temporal extent of a collection
+type STACExtent struct {
+	Spatial struct {
+		BBox [][4]float64 `json:"bbox"`
+	} `json:"spatial"`
+	Temporal struct {
+		Interval [][2]*time.Time `json:"interval"`
+	} `json:"temporal"`
+}
+
+// STACCollection describes a collection of items
+type STACCollection struct {
+	Type           string                 `json:"type"`
+	StacVersion    string                 `json:"stac_version"`
+	StacExtensions []string               `json:"stac_extensions"`
+	Id             string                 `json:"id"`
+	Title          string                 `json:"title,omitempty"`
+	Description    string                 `json:"description"`
+	Keywords       []string               `json:"keywords,omitempty"`
+	License        string                 `json:"license"`
+	Providers      []metadata.Provider    `json:"providers,omitempty"`
+	Extent         STACExtent             `json:"extent"`
+	Summaries      map[string]interface{} `json:"summaries,omitempty"`
+	Links          []Link                 `json:"links"`
+}
+
+// STACCollectionList lists the collections of the catalogue
+type STACCollectionList struct {
+	Collections []STACCollection `json:"collections"`
+	Links       []Link           `json:"links"`
+}
+
+// STACConformance lists the conformance classes of STAC API, OGC API -
+// Features, the filter extension and CQL2 implemented by the API
+var STACConformance = []string{
+	"https://api.stacspec.org/v1.0.0/core",
+	"https://api.stacspec.org/v1.0.0/collections",
+	"https://api.stacspec.org/v1.0.0/ogcapi-features",
+	"https://api.stacspec.org/v1.0.0/item-search",
+	"https://api.stacspec.org/v1.0.0/item-search#sort",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/oas30",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+	"https://api.stacspec.org/v1.0.0-rc.1/item-search#filter",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/filter",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/features-filter",
+	"http://www.opengis.net/spec/cql2/1.0/conf/cql2-text",
+	"http://www.opengis.net/spec/cql2/1.0/conf/cql2-json",
+	"http://www.opengis.net/spec/cql2/1.0/conf/basic-cql2",
+	"http://www.opengis.net/spec/cql2/1.0/conf/advanced-comparison-operators",
+	"http://www.opengis.net/spec/cql2/1.0/conf/basic-spatial-functions",
+	"http://www.opengis.net/spec/cql2/1.0/conf/spatial-functions",
+}
+
+// stacBaseURL returns the URL the API is served at, without a trailing
+// slash
+func stacBaseURL(cat *geocatalogo.GeoCatalogue) string {
+	return strings.TrimRight(cat.Config.Server.URL, "/")
+}
+
+// stacJSONType returns the media type of JSON responses
+func stacJSONType(cat *geocatalogo.GeoCatalogue) string {
+	if cat.Config.Server.MimeType != "" {
+		return cat.Config.Server.MimeType
+	}
+	return "application/json"
+}
+
+// stacEmit writes a JSON response of the given media type
+func stacEmit(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, status int, contentType string, v interface{}) {
+	geocatalogo.EmitResponseType(cat, w, status, contentType, geocatalogo.Struct2JSON(v, cat.Config.Server.PrettyPrint))
+}
+
+// stacError writes an exception
+func stacError(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, status int, code int, description string) {
+	stacEmit(w, cat, status, stacJSONType(cat), search.Exception{Code: code, Description: description})
+}
+
+// stacRepositoryError writes the exception of a failed repository
+// operation
+func stacRepositoryError(w http.ResponseWriter, cat *geocatalogo.GeoCatalogue, err error) {
+	exception := repositoryException(err)
+	stacEmit(w, cat, repositoryStatus(err), stacJSONType(cat), exception)
 }
 
-// STACAPIDescription provides the API description
+// stacCollections returns the collections of the catalogue, ordered by
+// identifier: those stored, and those records belong to without one
+// being stored
+func stacCollections(r *http.Request, cat *geocatalogo.GeoCatalogue) ([]metadata.Collection, error) {
+	stored, err := cat.Collections(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	results, err := cat.Facets(r.Context(), search.Request{}, []string{"collection"})
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make(map[string]metadata.Collection)
+	for id := range results.Facets["collection"] {
+		if id != "" {
+			collections[id] = metadata.Collection{Identifier: id}
+		}
+	}
+	for _, collection := range stored {
+		collections[collection.Identifier] = collection
+	}
+	ids := make([]string, 0, len(collections))
+	for id := range collections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	list := make([]metadata.Collection, len(ids))
+	for i, id := range ids {
+		list[i] = collections[id]
+	}
+	return list, nil
+}
+
+// stacCollection finds a collection, stored or one records belong to.
+// It reports false when there is none
+func stacCollection(r *http.Request, cat *geocatalogo.GeoCatalogue, id string) (metadata.Collection, bool, error) {
+	if id == "" {
+		return metadata.Collection{}, false, nil
+	}
+	collection, err := cat.GetCollection(r.Context(), id)
+	if err == nil {
+		return collection, true, nil
+	}
+	if !errors.Is(err, repository.ErrCollectionNotFound) {
+		return collection, false, err
+	}
+	results, err := cat.Facets(r.Context(), search.Request{Collections: []string{id}}, []string{"collection"})
+	if err != nil {
+		return collection, false, err
+	}
+	_, ok := results.Facets["collection"][id]
+	return metadata.Collection{Identifier: id}, ok, nil
+}
+
+// stacExtent computes the extent of a collection which has none, such
+// as one records belong to without it being stored
+func stacExtent(r *http.Request, cat *geocatalogo.GeoCatalogue, collection *metadata.Collection) error {
+	if collection.Extent.BBox != nil || collection.Extent.Temporal != nil {
+		return nil
+	}
+	extent, err := cat.CollectionExtent(r.Context(), collection.Identifier)
+	if err != nil {
+		return err
+	}
+	collection.Extent = extent
+	return nil
+}
+
+// STACAPIDescription provides the landing page, the root catalog of
+// the collections
 func STACAPIDescription(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	var jsonBytes []byte
 	var scd STACCatalogDefinition
 
-	scd.Id = "geocatalogo"
+	scd.Type = "Catalog"
+	scd.Id = cat.Config.Metadata.Identification.Id
+	if scd.Id == "" {
+		scd.Id = "geocatalogo"
+	}
 	scd.Version = VERSION
 	scd.Title = cat.Config.Metadata.Identification.Title
 	scd.Description = cat.Config.Metadata.Identification.Abstract
+	if scd.Description == "" {
+		scd.Description = "geocatalogo STAC API"
+	}
+	scd.ConformsTo = STACConformance
+
+	base := stacBaseURL(cat)
+	scd.Links = []Link{
+		{Rel: "self", Type: "application/json", Href: base + "/"},
+		{Rel: "root", Type: "application/json", Href: base + "/"},
+		{Rel: "conformance", Type: "application/json", Title: "conformance", Href: base + "/conformance"},
+		{Rel: "data", Type: "application/json", Title: "collections", Href: base + "/collections"},
+		{Rel: "search", Type: GeoJSONMimeType, Title: "search", Href: base + "/search", Method: "GET"},
+		{Rel: "search", Type: GeoJSONMimeType, Title: "search", Href: base + "/search", Method: "POST"},
+		{Rel: "service-desc", Type: OpenAPIMimeType, Title: "OpenAPI definition", Href: base + "/api?f=json"},
+		{Rel: "service-doc", Type: "text/html", Title: "OpenAPI documentation", Href: base + "/api"},
+		{Rel: "http://www.opengis.net/def/rel/ogc/1.0/queryables", Type: "application/schema+json", Title: "queryables", Href: base + "/queryables"},
+	}
 
-	var searchLink = Link{}
-	searchLink.Rel = "search"
-	searchLink.Type = "application/json"
-	searchLink.Title = "search"
-	searchLink.Href = fmt.Sprintf("%s/stac/search", cat.Config.Server.URL)
-
-	scd.Links = append(scd.Links, searchLink)
+	collections, err := stacCollections(r, cat)
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+	for _, collection := range collections {
+		scd.Links = append(scd.Links, Link{Rel: "child", Type: "application/json", Title: collection.DisplayTitle(), Href: base + "/collections/" + url.PathEscape(collection.Identifier)})
+	}
 
-	jsonBytes = geocatalogo.Struct2JSON(&scd, false)
+	stacEmit(w, cat, 200, stacJSONType(cat), &scd)
+}
 
-	geocatalogo.EmitResponse(cat, w, 200, jsonBytes)
-	return
+// STACConformanceDeclaration lists the conformance classes of the API
+func STACConformanceDeclaration(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	stacEmit(w, cat, 200, stacJSONType(cat), map[string][]string{"conformsTo": STACConformance})
 }
 
 // STACOpenAPI generates an OpenAPI document or Swagger representation
@@ -142,32 +356,183 @@ func STACOpenAPI(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCat
 		data := map[string]interface{}{"config": cat.Config}
 		content, _ := geocatalogo.RenderTemplate(SwaggerHTML, data)
 
-		cat.Config.Server.MimeType = "text/html"
-		geocatalogo.EmitResponse(cat, w, 200, content)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "%s", content)
 	}
 	return
 }
 
+// STACQueryables describes the properties filters can refer to
+func STACQueryables(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	schema := cql2.Schema(fmt.Sprintf("%s/queryables", stacBaseURL(cat)))
+	stacEmit(w, cat, 200, "application/schema+json", schema)
+}
+
+// STACCollectionDescription describes a collection.  What is not
+// stored with the collection defaults to the catalogue license and an
+// unbounded extent
+func STACCollectionDescription(cat *geocatalogo.GeoCatalogue, collection metadata.Collection) STACCollection {
+	id := collection.Identifier
+	base := stacBaseURL(cat)
+	href := base + "/collections/" + url.PathEscape(id)
+	c := STACCollection{
+		Type:           "Collection",
+		StacVersion:    VERSION,
+		StacExtensions: []string{},
+		Id:             id,
+		Title:          collection.DisplayTitle(),
+		Description:    collection.Description,
+		Keywords:       collection.Keywords,
+		License:        collection.License,
+		Providers:      collection.Providers,
+		Summaries:      collection.Summaries,
+		Links: []Link{
+			{Rel: "self", Type: "application/json", Href: href},
+			{Rel: "root", Type: "application/json", Href: base + "/"},
+			{Rel: "parent", Type: "application/json", Href: base + "/"},
+			{Rel: "items", Type: GeoJSONMimeType, Href: href + "/items"},
+		},
+	}
+	if c.Description == "" {
+		c.Description = fmt.Sprintf("Records of the %s collection", id)
+	}
+	if c.License == "" {
+		c.License = "various"
+		if cat.Config.Metadata.License.Name != "" {
+			c.License = cat.Config.Metadata.License.Name
+		}
+	}
+	for _, link := range collection.Links {
+		rel := link.Rel
+		if rel == "" || stacReservedRels[rel] || rel == "items" {
+			rel = "related"
+		}
+		c.Links = append(c.Links, Link{Rel: rel, Type: link.Type, Title: link.Name, Href: link.URL})
+	}
+
+	c.Extent.Spatial.BBox = [][4]float64{{-180, -90, 180, 90}}
+	if collection.Extent.BBox != nil {
+		c.Extent.Spatial.BBox[0] = *collection.Extent.BBox
+	}
+	c.Extent.Temporal.Interval = [][2]*time.Time{{nil, nil}}
+	if t := collection.Extent.Temporal; t != nil {
+		c.Extent.Temporal.Interval[0] = [2]*time.Time{t.Begin, t.End}
+	}
+	return c
+}
+
 // STACCollections provides STAC compliant collection descriptions
 func STACCollections(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	return
+	collections, err := stacCollections(r, cat)
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+
+	base := stacBaseURL(cat)
+	list := STACCollectionList{
+		Collections: []STACCollection{},
+		Links: []Link{
+			{Rel: "self", Type: "application/json", Href: base + "/collections"},
+			{Rel: "root", Type: "application/json", Href: base + "/"},
+			{Rel: "parent", Type: "application/json", Href: base + "/"},
+		},
+	}
+	for _, collection := range collections {
+		if err := stacExtent(r, cat, &collection); err != nil {
+			stacRepositoryError(w, cat, err)
+			return
+		}
+		list.Collections = append(list.Collections, STACCollectionDescription(cat, collection))
+	}
+	stacEmit(w, cat, 200, stacJSONType(cat), &list)
+}
+
+// STACCollectionDetail provides the STAC compliant description of a
+// collection
+func STACCollectionDetail(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	id := mux.Vars(r)["collectionId"]
+	collection, ok, err := stacCollection(r, cat, id)
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+	if !ok {
+		stacError(w, cat, http.StatusNotFound, 20007, fmt.Sprintf("ERROR: no collection %s", id))
+		return
+	}
+	if err := stacExtent(r, cat, &collection); err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+	c := STACCollectionDescription(cat, collection)
+	stacEmit(w, cat, 200, stacJSONType(cat), &c)
 }
 
 // STACItems provides STAC compliant Items matching filters
 func STACItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
-	var jsonBytes []byte
+	stacSearch(w, r, cat, "")
+}
+
+// STACCollectionItems provides the STAC compliant Items of a collection
+// matching filters
+func STACCollectionItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	id := mux.Vars(r)["collectionId"]
+	_, ok, err := stacCollection(r, cat, id)
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+	if !ok {
+		stacError(w, cat, http.StatusNotFound, 20007, fmt.Sprintf("ERROR: no collection %s", id))
+		return
+	}
+	stacSearch(w, r, cat, id)
+}
+
+// STACCollectionItem provides a STAC compliant Item, of the collection
+// when one is given
+func STACCollectionItem(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue) {
+	vars := mux.Vars(r)
+	collection, id := vars["collectionId"], vars["itemId"]
+	results, err := cat.Get(r.Context(), []string{id})
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
+	}
+	if len(results.Records) == 0 || (collection != "" && results.Records[0].Properties.Collection != collection) {
+		stacError(w, cat, http.StatusNotFound, 20007, fmt.Sprintf("ERROR: no item %s", id))
+		return
+	}
+	item := Record2STACItem(stacBaseURL(cat), results.Records[0])
+	stacEmit(w, cat, 200, GeoJSONMimeType, &item)
+}
+
+// stacSearch searches Items, of a collection when one is given
+func stacSearch(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatalogue, collection string) {
 	var value []string
-	var filter string
+	var term string
+	var filter cql2.Expr
 	var bbox []float64
-	var timeVal []time.Time
+	var intersects metadata.Geometry
+	var temporal *search.TemporalFilter
 	var limit = 10
 	var page int = 1
 	var from int
 	var ids []string
 	var collections []string
+	var sortBy []search.SortField
 	var results search.Results
 	var stacFeatureCollection STACFeatureCollection
 
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	kvp := make(map[string][]string)
 
 	if r.Method == "GET" {
@@ -179,70 +544,135 @@ func STACItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 
 		err := json.NewDecoder(r.Body).Decode(&stacSearch)
 		if err != nil {
-			fmt.Println(err)
-			exception := search.Exception{
-				Code:        20002,
-				Description: "JSON parsing error"}
-			jsonBytes = geocatalogo.Struct2JSON(exception, cat.Config.Server.PrettyPrint)
-			geocatalogo.EmitResponse(cat, w, 400, jsonBytes)
+			stacError(w, cat, 400, 20002, "JSON parsing error")
 			return
 		}
 		if stacSearch.Limit > 0 {
 			kvp["limit"] = []string{strconv.Itoa(stacSearch.Limit)}
 		}
+		if stacSearch.Page > 0 {
+			kvp["page"] = []string{strconv.Itoa(stacSearch.Page)}
+		}
 		if len(stacSearch.Datetime) > 0 {
 			kvp["datetime"] = []string{stacSearch.Datetime}
 		}
 		if stacSearch.Collections != nil {
-			kvp["collections"] = stacSearch.Collections
+			kvp["collections"] = []string{strings.Join(stacSearch.Collections, ",")}
+		}
+		if stacSearch.Ids != nil {
+			kvp["ids"] = []string{strings.Join(stacSearch.Ids, ",")}
 		}
 		if stacSearch.Bbox != [4]float64{0, 0, 0, 0} {
-			tmp := fmt.Sprintf("%f,%f,%f,%f", stacSearch.Bbox[0], stacSearch.Bbox[1], stacSearch.Bbox[2], stacSearch.Bbox[3])
+			tmp := fmt.Sprintf("%g,%g,%g,%g", stacSearch.Bbox[0], stacSearch.Bbox[1], stacSearch.Bbox[2], stacSearch.Bbox[3])
 			kvp["bbox"] = []string{tmp}
 		}
+		if stacSearch.Intersects != nil {
+			geometry, _ := json.Marshal(stacSearch.Intersects)
+			kvp["intersects"] = []string{string(geometry)}
+		}
+		if len(stacSearch.SortBy) > 0 {
+			var keys []string
+			for _, sb := range stacSearch.SortBy {
+				if strings.ToLower(sb.Direction) == "desc" {
+					keys = append(keys, "-"+sb.Field)
+				} else {
+					keys = append(keys, sb.Field)
+				}
+			}
+			kvp["sortby"] = []string{strings.Join(keys, ",")}
+		}
+		if stacSearch.Token != "" {
+			kvp["token"] = []string{stacSearch.Token}
+		}
+		if stacSearch.Q != "" {
+			kvp["q"] = []string{stacSearch.Q}
+		}
+		if len(stacSearch.Filter) > 0 {
+			// a JSON string holds a CQL2 text filter
+			var text string
+			if err := json.Unmarshal(stacSearch.Filter, &text); err == nil {
+				kvp["filter"] = []string{text}
+				kvp["filter-lang"] = []string{cql2.LangText}
+			} else {
+				kvp["filter"] = []string{string(stacSearch.Filter)}
+				kvp["filter-lang"] = []string{cql2.LangJSON}
+			}
+			if stacSearch.FilterLang != "" {
+				kvp["filter-lang"] = []string{stacSearch.FilterLang}
+			}
+		}
 	}
 
 	value, _ = kvp["bbox"]
 	if len(value) > 0 {
-		bboxTokens := strings.Split(value[0], ",")
-		fmt.Println(bboxTokens)
-		if len(bboxTokens) != 4 {
-			exception := search.Exception{
-				Code:        20002,
-				Description: "bbox format error (should be minx,miny,maxx,maxy)"}
-			jsonBytes = geocatalogo.Struct2JSON(exception, cat.Config.Server.PrettyPrint)
-			geocatalogo.EmitResponse(cat, w, 400, jsonBytes)
+		var err error
+		if bbox, err = search.ParseBBox(value[0]); err != nil {
+			stacError(w, cat, 400, 20002, err.Error())
 			return
 		}
-		for _, bt := range bboxTokens {
-			bt, _ := strconv.ParseFloat(bt, 64)
-			bbox = append(bbox, bt)
+	}
+	value, _ = kvp["intersects"]
+	if len(value) > 0 {
+		description := ""
+		if err := json.Unmarshal([]byte(value[0]), &intersects); err != nil || intersects.IsEmpty() {
+			description = "intersects format error (should be a GeoJSON geometry)"
+		} else if len(bbox) > 0 {
+			description = "bbox and intersects are mutually exclusive"
+		}
+		if description != "" {
+			stacError(w, cat, 400, 20002, description)
+			return
 		}
 	}
 	value, _ = kvp["datetime"]
 	if len(value) > 0 {
-		for _, t := range strings.Split(value[0], "/") {
-			timestep, err := time.Parse(time.RFC3339, t)
-			if err != nil {
-				exception := search.Exception{
-					Code:        20002,
-					Description: "time format error (should be ISO 8601/RFC3339)"}
-				jsonBytes = geocatalogo.Struct2JSON(exception, cat.Config.Server.PrettyPrint)
-				geocatalogo.EmitResponse(cat, w, 400, jsonBytes)
-				return
-			}
-			timeVal = append(timeVal, timestep)
+		var err error
+		temporal, err = search.ParseTemporalFilter(value[0])
+		if err != nil {
+			stacError(w, cat, 400, 20002, err.Error())
+			return
 		}
 	}
 
+	value, _ = kvp["q"]
+	if len(value) > 0 {
+		term = value[0]
+	}
+
 	value, _ = kvp["filter"]
+	if len(value) > 0 && value[0] != "" {
+		var err error
+		lang := cql2.LangText
+		if v, _ := kvp["filter-lang"]; len(v) > 0 && v[0] != "" {
+			lang = v[0]
+		}
+		filter, err = cql2.Parse(value[0], lang)
+		if err != nil {
+			stacError(w, cat, 400, 20002, fmt.Sprintf("filter error: %v", err))
+			return
+		}
+	}
+
+	value, _ = kvp["sortby"]
 	if len(value) > 0 {
-		filter = value[0]
+		var err error
+		sortBy, err = search.ParseSort(value[0])
+		if err != nil {
+			stacError(w, cat, 400, 20002, err.Error())
+			return
+		}
 	}
 
 	value, _ = kvp["limit"]
 	if len(value) > 0 {
-		limit, _ = strconv.Atoi(value[0])
+		var err error
+		if limit, err = strconv.Atoi(value[0]); err != nil || limit < 1 {
+			stacError(w, cat, 400, 20002, "limit should be a positive integer")
+			return
+		}
+	}
+	if max := cat.Config.Server.Limit; max > 0 && limit > max {
+		limit = max
 	}
 
 	value, _ = kvp["page"]
@@ -250,10 +680,8 @@ func STACItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 		page, _ = strconv.Atoi(value[0])
 	}
 
-	if page == 1 {
-		from = 0
-	} else {
-		from = page*limit - (limit - 1)
+	if page > 1 {
+		from = (page - 1) * limit
 	}
 
 	value, _ = kvp["ids"]
@@ -265,36 +693,79 @@ func STACItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 	if len(value) > 0 {
 		collections = strings.Split(value[0], ",")
 	}
-	fmt.Println(collections == nil)
-
-	// Extract property filters from query parameters
-	propertyFilters := make(map[string]string)
-	propertyKeys := []string{
-		"collection", "type", "title", "owner",
-		"continent", "country", "state", "state_province", "city", "admin2", "county",
-		"data_format", "implementation_status", "status", "geographic_scope",
-		"database_table", "v6_job_file", "v6_job_type", "s3_path",
+	if collection != "" {
+		collections = []string{collection}
 	}
 
-	for _, key := range propertyKeys {
-		if val, ok := kvp[key]; ok && len(val) > 0 {
-			propertyFilters[key] = val[0]
+	var err error
+	if len(ids) > 0 {
+		from = 0
+		results, err = cat.Get(r.Context(), ids)
+		if err == nil && collection != "" {
+			var records []metadata.Record
+			for _, record := range results.Records {
+				if record.Properties.Collection == collection {
+					records = append(records, record)
+				}
+			}
+			results.Records = records
+			results.Matches = len(records)
+			results.Returned = len(records)
+		}
+	} else {
+		req := search.Request{
+			Collections: collections,
+			Term:        term,
+			Filters:     search.ParseFilters(kvp),
+			Temporal:    temporal,
+			Filter:      filter,
+			Sort:        sortBy,
+			From:        from,
+			Size:        limit,
 		}
+		if !intersects.IsEmpty() {
+			req.Spatial = &search.SpatialFilter{Geometry: intersects}
+		} else if len(bbox) == 4 {
+			req.Spatial = &search.SpatialFilter{BBox: bbox}
+		}
+		value, _ = kvp["token"]
+		if len(value) > 0 {
+			if err := cat.ApplyToken(value[0], &req); err != nil {
+				stacError(w, cat, 400, 20002, err.Error())
+				return
+			}
+		}
+		from = req.From
+		results, err = cat.Search(r.Context(), req)
+	}
+	if err != nil {
+		stacRepositoryError(w, cat, err)
+		return
 	}
 
-	if len(ids) > 0 {
-		results = cat.Get(ids)
-	} else {
-		results = cat.Search(collections, filter, bbox, timeVal, from, limit, propertyFilters)
+	base := stacBaseURL(cat)
+	links := []Link{
+		STACSelfLink(r, base),
+		{Rel: "root", Type: "application/json", Href: base + "/"},
+	}
+	if collection != "" {
+		href := base + "/collections/" + url.PathEscape(collection)
+		links = append(links,
+			Link{Rel: "parent", Type: "application/json", Href: href},
+			Link{Rel: "collection", Type: "application/json", Href: href})
+	}
+	if next := STACNextLink(r, base, results.NextToken); next != nil {
+		links = append(links, *next)
+	}
+	if prev := STACPrevLink(r, base, from, limit); prev != nil {
+		links = append(links, *prev)
 	}
 
 	stacFeatureCollection = STACFeatureCollection{}
 
-	Results2STACFeatureCollection(cat.Config.Server.Limit, cat.Config.Server.URL, &results, &stacFeatureCollection)
-
-	jsonBytes = geocatalogo.Struct2JSON(stacFeatureCollection, cat.Config.Server.PrettyPrint)
+	Results2STACFeatureCollection(base, limit, links, &results, &stacFeatureCollection)
 
-	geocatalogo.EmitResponse(cat, w, 200, jsonBytes)
+	stacEmit(w, cat, 200, GeoJSONMimeType, &stacFeatureCollection)
 	return
 }
 
@@ -302,65 +773,204 @@ func STACItems(w http.ResponseWriter, r *http.Request, cat *geocatalogo.GeoCatal
 func STACRouter(cat *geocatalogo.GeoCatalogue) *mux.Router {
 	router := mux.NewRouter()
 
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		STACAPIDescription(w, r, cat)
+	}).Methods("GET")
+
+	// the landing page as served before STAC API 1.0
 	router.HandleFunc("/stac", func(w http.ResponseWriter, r *http.Request) {
 		STACAPIDescription(w, r, cat)
 	}).Methods("GET")
 
+	router.HandleFunc("/conformance", func(w http.ResponseWriter, r *http.Request) {
+		STACConformanceDeclaration(w, r, cat)
+	}).Methods("GET")
+
 	router.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
 		STACOpenAPI(w, r, cat)
 	}).Methods("GET")
 
+	router.HandleFunc("/queryables", func(w http.ResponseWriter, r *http.Request) {
+		STACQueryables(w, r, cat)
+	}).Methods("GET")
+
 	router.HandleFunc("/collections", func(w http.ResponseWriter, r *http.Request) {
 		STACCollections(w, r, cat)
 	}).Methods("GET")
 
-	router.HandleFunc("/collections{collectionId}", func(w http.ResponseWriter, r *http.Request) {
-		STACCollections(w, r, cat)
+	router.HandleFunc("/collections/{collectionId}", func(w http.ResponseWriter, r *http.Request) {
+		STACCollectionDetail(w, r, cat)
 	}).Methods("GET")
 
-	router.HandleFunc("/stac/search", func(w http.ResponseWriter, r *http.Request) {
-		STACItems(w, r, cat)
-	}).Methods("GET", "POST", "OPTIONS")
+	router.HandleFunc("/collections/{collectionId}/items", func(w http.ResponseWriter, r *http.Request) {
+		STACCollectionItems(w, r, cat)
+	}).Methods("GET")
+
+	router.HandleFunc("/collections/{collectionId}/items/{itemId}", func(w http.ResponseWriter, r *http.Request) {
+		STACCollectionItem(w, r, cat)
+	}).Methods("GET")
+
+	for _, path := range []string{"/search", "/stac/search"} {
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			STACItems(w, r, cat)
+		}).Methods("GET", "POST", "OPTIONS")
+	}
 
 	router.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
 		STACItems(w, r, cat)
 	}).Methods("GET", "POST")
 
-	router.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
-		STACItems(w, r, cat)
+	router.HandleFunc("/items/{itemId}", func(w http.ResponseWriter, r *http.Request) {
+		STACCollectionItem(w, r, cat)
 	}).Methods("GET")
 
 	return router
 }
 
-func Results2STACFeatureCollection(limit int, url string, r *search.Results, s *STACFeatureCollection) {
+// STACSelfLink returns the link to a search itself
+func STACSelfLink(r *http.Request, url string) Link {
+	self := Link{Rel: "self", Type: GeoJSONMimeType, Href: fmt.Sprintf("%s%s", url, r.URL.RequestURI())}
+	if r.Method == "POST" {
+		self.Href = fmt.Sprintf("%s%s", url, r.URL.Path)
+		self.Method = "POST"
+	}
+	return self
+}
+
+// STACNextLink returns the link to the page following a search, or nil
+// on the last page.  GET searches link to the same query with the page
+// token added; POST searches link to a body to merge the token into
+func STACNextLink(r *http.Request, url string, token string) *Link {
+	if token == "" {
+		return nil
+	}
+	next := Link{Rel: "next", Type: GeoJSONMimeType}
+	if r.Method == "POST" {
+		next.Href = fmt.Sprintf("%s%s", url, r.URL.Path)
+		next.Method = "POST"
+		next.Body = map[string]interface{}{"token": token}
+		next.Merge = true
+		return &next
+	}
+	query := r.URL.Query()
+	query.Del("page")
+	query.Set("token", token)
+	next.Href = fmt.Sprintf("%s%s?%s", url, r.URL.Path, query.Encode())
+	return &next
+}
+
+// STACPrevLink returns the link to the page preceding a search starting
+// at from, or nil on the first page.  Pages are addressed by number,
+// replacing any page token, so only searches paged by limit have one
+func STACPrevLink(r *http.Request, url string, from int, limit int) *Link {
+	if from <= 0 || limit <= 0 || from%limit != 0 {
+		return nil
+	}
+	page := from / limit
+	prev := Link{Rel: "prev", Type: GeoJSONMimeType}
+	if r.Method == "POST" {
+		prev.Href = fmt.Sprintf("%s%s", url, r.URL.Path)
+		prev.Method = "POST"
+		prev.Body = map[string]interface{}{"page": page, "token": ""}
+		prev.Merge = true
+		return &prev
+	}
+	query := r.URL.Query()
+	query.Del("token")
+	query.Set("page", strconv.Itoa(page))
+	prev.Href = fmt.Sprintf("%s%s?%s", url, r.URL.Path, query.Encode())
+	return &prev
+}
+
+// stacProperties returns the properties of an Item, with the datetime
+// STAC requires: that of the record, null between the start and end of
+// its temporal extent, or else when the record last changed
+func stacProperties(p metadata.Properties) map[string]interface{} {
+	properties := make(map[string]interface{})
+	data, _ := json.Marshal(p)
+	json.Unmarshal(data, &properties)
+
+	if t := p.TemporalExtent; t != nil {
+		if t.Begin != nil {
+			properties["start_datetime"] = t.Begin
+		}
+		if t.End != nil {
+			properties["end_datetime"] = t.End
+		}
+	}
+	switch {
+	case p.Datetime != nil:
+	case p.TemporalExtent != nil && p.TemporalExtent.Begin != nil && p.TemporalExtent.End != nil:
+		properties["datetime"] = nil
+	case p.Modified != nil:
+		properties["datetime"] = p.Modified
+	case p.Created != nil:
+		properties["datetime"] = p.Created
+	case !p.Geocatalogo.Inserted.IsZero():
+		properties["datetime"] = p.Geocatalogo.Inserted
+	default:
+		properties["datetime"] = nil
+	}
+	return properties
+}
+
+// stacReservedRels are the relations of the links STAC gives Items,
+// which links of records cannot take
+var stacReservedRels = map[string]bool{"self": true, "root": true, "parent": true, "collection": true}
+
+// Record2STACItem converts a record to a STAC Item, linked to its
+// collection when it has one
+func Record2STACItem(base string, rec metadata.Record) STACItem {
+	si := STACItem{
+		Type:           "Feature",
+		StacVersion:    VERSION,
+		StacExtensions: []string{},
+		Id:             rec.Identifier,
+		Collection:     rec.Properties.Collection,
+		Geometry:       rec.Geometry,
+		Properties:     stacProperties(rec.Properties),
+		Links:          []Link{},
+		Assets:         make(map[string]STACAsset),
+	}
+	if bbox, ok := rec.Bounds(); ok {
+		si.BBox = &bbox
+	}
+	if si.Collection != "" {
+		collection := base + "/collections/" + url.PathEscape(si.Collection)
+		si.Links = append(si.Links,
+			Link{Rel: "self", Type: GeoJSONMimeType, Href: collection + "/items/" + url.PathEscape(rec.Identifier)},
+			Link{Rel: "parent", Type: "application/json", Href: collection},
+			Link{Rel: "collection", Type: "application/json", Href: collection})
+	} else {
+		si.Links = append(si.Links, Link{Rel: "self", Type: GeoJSONMimeType, Href: base + "/items/" + url.PathEscape(rec.Identifier)})
+	}
+	si.Links = append(si.Links, Link{Rel: "root", Type: "application/json", Href: base + "/"})
+	for _, link := range rec.Links {
+		rel := link.Rel
+		if rel == "" || stacReservedRels[rel] {
+			rel = "related"
+		}
+		si.Links = append(si.Links, Link{Rel: rel, Type: link.Type, Title: link.Name, Href: link.URL})
+	}
+	for _, asset := range rec.Assets {
+		si.Assets[asset.Name] = STACAsset{Href: asset.URL, Type: asset.Type, Title: asset.Description}
+	}
+	return si
+}
+
+func Results2STACFeatureCollection(url string, limit int, links []Link, r *search.Results, s *STACFeatureCollection) {
 	s.Type = "FeatureCollection"
+	s.Features = []STACItem{}
 	for _, rec := range r.Records {
-		si := STACItem{}
-		si.Type = "Feature"
-		si.Id = rec.Identifier
-		si.StacVersion = "0.8.0"
-		si.BBox = rec.Geometry.Bounds()
-		si.Geometry = rec.Geometry
-		//si.Datetime = rec.Properties.ProductInfo.AcquisitionDate
-		//si.Collection = rec.Properties.ProductInfo.Collection
-		si.Properties = rec.Properties
-		for _, link := range rec.Links {
-			sil := Link{Rel: "self", Href: link.URL}
-			si.Links = append(si.Links, sil)
-		}
-		si.Assets = make(map[string]Link)
-		for _, asset := range rec.Assets {
-			si.Assets[asset.Name] = Link{Type: asset.Type, Href: asset.URL}
-		}
-		s.Features = append(s.Features, si)
-	}
-	nextLink := Link{Rel: "next"}
-	nextLink.Href = fmt.Sprintf("%s/stac/search?next=%d", url, r.NextRecord)
-	s.Links = append(s.Links, nextLink)
+		s.Features = append(s.Features, Record2STACItem(url, rec))
+	}
+	s.Links = links
+	if s.Links == nil {
+		s.Links = []Link{}
+	}
 	s.NumberMatched = r.Matches
 	s.NumberReturned = r.Returned
-	s.SearchMetadata.Next = strconv.Itoa(r.NextRecord)
+	s.SearchMetadata.Next = r.NextToken
 	s.SearchMetadata.Limit = limit
 	s.SearchMetadata.Matched = r.Matches
 	s.SearchMetadata.Returned = r.Returned
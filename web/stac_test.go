@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/gorilla/mux"
+)
+
+// stacGet sends a GET request to the STAC API and decodes its JSON
+// response
+func stacGet(t *testing.T, router *mux.Router, target string, v interface{}) http.Header {
+	t.Helper()
+	w := serve(router, "GET", target, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: %v", target, err)
+	}
+	return w.Header()
+}
+
+// link returns the href of the first link of a relation
+func link(links []Link, rel string) string {
+	for _, l := range links {
+		if l.Rel == rel {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func TestSTACLandingPage(t *testing.T) {
+	router := STACRouter(newTestCatalogue(t))
+
+	var landing STACCatalogDefinition
+	stacGet(t, router, "/", &landing)
+	if landing.Type != "Catalog" || len(landing.ConformsTo) == 0 {
+		t.Errorf("unexpected landing page: %+v", landing)
+	}
+	expected := map[string]string{
+		"self":        "http://localhost:8000/",
+		"conformance": "http://localhost:8000/conformance",
+		"data":        "http://localhost:8000/collections",
+		"search":      "http://localhost:8000/search",
+	}
+	for rel, href := range expected {
+		if link(landing.Links, rel) != href {
+			t.Errorf("%s link is %q, expected %q", rel, link(landing.Links, rel), href)
+		}
+	}
+	var children []string
+	for _, l := range landing.Links {
+		if l.Rel == "child" {
+			children = append(children, l.Href)
+		}
+	}
+	if strings.Join(children, " ") != "http://localhost:8000/collections/elevation http://localhost:8000/collections/transport" {
+		t.Errorf("unexpected child links %v", children)
+	}
+
+	var conformance struct {
+		ConformsTo []string `json:"conformsTo"`
+	}
+	stacGet(t, router, "/conformance", &conformance)
+	if len(conformance.ConformsTo) != len(STACConformance) {
+		t.Errorf("%d conformance classes, expected %d", len(conformance.ConformsTo), len(STACConformance))
+	}
+
+	var schema map[string]interface{}
+	header := stacGet(t, router, "/queryables", &schema)
+	if contentType := header.Get("Content-Type"); contentType != "application/schema+json" {
+		t.Errorf("queryables Content-Type is %s", contentType)
+	}
+	if header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("CORS headers not set")
+	}
+}
+
+func TestSTACCollections(t *testing.T) {
+	router := STACRouter(newTestCatalogue(t))
+
+	var list STACCollectionList
+	stacGet(t, router, "/collections", &list)
+	if len(list.Collections) != 2 {
+		t.Fatalf("%d collections, expected 2", len(list.Collections))
+	}
+	transport := list.Collections[1]
+	if transport.Id != "transport" || link(transport.Links, "items") != "http://localhost:8000/collections/transport/items" {
+		t.Errorf("unexpected collection %+v", transport)
+	}
+	if bbox := transport.Extent.Spatial.BBox; len(bbox) != 1 || bbox[0] != [4]float64{0, 40, 15, 55} {
+		t.Errorf("transport extent is %v", bbox)
+	}
+
+	var c STACCollection
+	stacGet(t, router, "/collections/elevation", &c)
+	if c.Id != "elevation" || c.Extent.Spatial.BBox[0] != [4]float64{-80, 40, -70, 50} {
+		t.Errorf("unexpected collection %+v", c)
+	}
+	w := serve(router, "GET", "/collections/missing", "")
+	expectBody(t, "missing collection", w, http.StatusNotFound, "no collection missing")
+}
+
+func TestSTACSearchPaging(t *testing.T) {
+	router := STACRouter(newTestCatalogue(t))
+
+	var page STACFeatureCollection
+	stacGet(t, router, "/search?limit=2&sortby=title", &page)
+	if page.NumberMatched != 3 || page.NumberReturned != 2 || page.SearchMetadata.Limit != 2 {
+		t.Errorf("matched %d, returned %d, limit %d", page.NumberMatched, page.NumberReturned, page.SearchMetadata.Limit)
+	}
+	if link(page.Links, "prev") != "" {
+		t.Error("first page links to a previous page")
+	}
+	next := link(page.Links, "next")
+	if !strings.HasPrefix(next, "http://localhost:8000/search?") || !strings.Contains(next, "token=") {
+		t.Fatalf("unexpected next link %q", next)
+	}
+
+	u, _ := url.Parse(next)
+	stacGet(t, router, u.RequestURI(), &page)
+	if page.NumberReturned != 1 || page.Features[0].Id != "roads" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+	if link(page.Links, "next") != "" {
+		t.Error("last page links to a next page")
+	}
+	if prev := link(page.Links, "prev"); prev != "http://localhost:8000/search?limit=2&page=1&sortby=title" {
+		t.Errorf("unexpected prev link %q", prev)
+	}
+
+	stacGet(t, router, "/collections/transport/items?limit=1", &page)
+	if page.NumberMatched != 2 || link(page.Links, "collection") != "http://localhost:8000/collections/transport" {
+		t.Errorf("unexpected collection items %+v", page)
+	}
+}
+
+func TestSTACItemLinks(t *testing.T) {
+	cat := newTestCatalogue(t)
+	router := STACRouter(cat)
+	if !cat.Index(context.Background(), metadata.Record{Identifier: "loose item", Properties: metadata.Properties{Title: "Loose"}}) {
+		t.Fatal("indexing failed")
+	}
+
+	var item STACItem
+	stacGet(t, router, "/collections/transport/items/roads", &item)
+	if self := link(item.Links, "self"); self != "http://localhost:8000/collections/transport/items/roads" {
+		t.Errorf("unexpected self link %q", self)
+	}
+	if link(item.Links, "collection") != "http://localhost:8000/collections/transport" {
+		t.Errorf("unexpected links %+v", item.Links)
+	}
+
+	item = STACItem{}
+	stacGet(t, router, "/items/loose%20item", &item)
+	if self := link(item.Links, "self"); self != "http://localhost:8000/items/loose%20item" {
+		t.Errorf("unexpected self link %q", self)
+	}
+	if link(item.Links, "collection") != "" {
+		t.Errorf("item without a collection links to one: %+v", item.Links)
+	}
+
+	w := serve(router, "GET", "/collections/elevation/items/roads", "")
+	expectBody(t, "item of another collection", w, http.StatusNotFound, "no item roads")
+}
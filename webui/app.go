@@ -1,6 +1,9 @@
 package webui
 
 import (
+	"fmt"
+
+	"github.com/go-spatial/geocatalogo"
 	"github.com/go-spatial/geocatalogo/helpers"
 	"github.com/go-spatial/geocatalogo/metadata"
 )
@@ -8,12 +11,14 @@ import (
 type App struct {
 	tc    *helpers.TemplateCache
 	meta  *metadata.MetadataStore
+	cat   *geocatalogo.GeoCatalogue // Collection descriptions; may be nil
 }
 
-func NewApp(tc *helpers.TemplateCache, meta *metadata.MetadataStore) *App {
+func NewApp(tc *helpers.TemplateCache, meta *metadata.MetadataStore, cat *geocatalogo.GeoCatalogue) *App {
 	return &App{
 		tc:   tc,
 		meta: meta,
+		cat:  cat,
 	}
 }
 
@@ -23,10 +28,25 @@ type PageData struct {
 }
 
 type Record struct {
-	ID         string     `json:"id"`
-	Type       string     `json:"type"`
-	Properties Properties `json:"properties"`
-	Links      []Link     `json:"links,omitempty"`
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	BBox       *[4]float64       `json:"bbox,omitempty"`
+	Geometry   metadata.Geometry `json:"geometry"`
+	Properties Properties        `json:"properties"`
+	Links      []Link            `json:"links,omitempty"`
+}
+
+// Extent formats the bounding box of a record as minx, miny, maxx, maxy,
+// or returns an empty string when the record has no geometry
+func (r Record) Extent() string {
+	bbox, ok := r.Geometry.Bounds()
+	if !ok {
+		if r.BBox == nil {
+			return ""
+		}
+		bbox = *r.BBox
+	}
+	return fmt.Sprintf("%.4f, %.4f, %.4f, %.4f", bbox[0], bbox[1], bbox[2], bbox[3])
 }
 
 type Link struct {
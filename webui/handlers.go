@@ -2,6 +2,7 @@ package webui
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/go-spatial/geocatalogo/helpers"
 	"github.com/go-spatial/geocatalogo/metadata"
+	"github.com/go-spatial/geocatalogo/repository"
 )
 
 func (a *App) HandleCatalog(w http.ResponseWriter, r *http.Request) {
@@ -1180,47 +1182,31 @@ func (a *App) HandleCollectionDetail(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Collection metadata
-	collectionMetadata := map[string]struct {
-		Name        string
-		Emoji       string
-		Description string
-	}{
-		"ai_agent":                {"AI Agents", "🤖", "Autonomous AI agents managing different parts of the GRO ecosystem"},
-		"existing_db":             {"Database Tables", "🗄️", "PostgreSQL + PostGIS tables in the production database"},
-		"existing_local":          {"Local Files", "📁", "Data files stored locally (CSV, Parquet, Shapefile, GeoJSON, etc.)"},
-		"potential_v6":            {"v6 Job Definitions", "⚙️", "YAML job definitions for the future automation pipeline"},
-		"external_api":            {"External APIs", "🔌", "Third-party API endpoints we integrate with"},
-		"external_government":     {"Government Data", "🏛️", "Government open data portals and official statistics"},
-		"external_news":           {"News & Media", "📰", "News APIs, RSS feeds, and media monitoring sources"},
-		"external_news_active":    {"Active News Sources", "📡", "News sources actively collected via GNews API"},
-		"external_academic":       {"Academic Data", "🎓", "Academic datasets and research institution data"},
-		"external_download":       {"Downloadable Datasets", "⬇️", "Datasets available for direct download"},
-		"external_other":          {"Other External Sources", "🌐", "Other external data sources"},
-		"infrastructure":          {"Infrastructure", "⚙️", "AWS infrastructure components (Lambda, RDS, S3, etc.)"},
-		"internal_tool":           {"Internal Tools", "🛠️", "Internal tools and utilities for development"},
-		"verb_app":                {"Verb Applications", "🔤", "Verb-based applications (explore, curate, chronicle, etc.)"},
-		"team_member":             {"Team Members", "👥", "Team members and their roles"},
-		"claude_projects":         {"Claude Projects", "💬", "Claude Projects used in development"},
-		"historical_agent":        {"Historical Agents", "📦", "Agents that are no longer active but kept for reference"},
-		"automation_bot":          {"Automation Bots", "🔧", "Bots that automate repetitive tasks and workflows"},
-		"data_inspection_bot":     {"Data Inspection Bots", "🔍", "Bots that introspect and validate data quality"},
-		"catalog_management_bot":  {"Catalog Management Bots", "📋", "Bots that maintain and update the catalog"},
-		"operational_service":     {"Operational Services", "⚡", "Running services powering the GRO platform"},
-		"api_service":             {"API Services", "🔌", "API endpoints for data access and integration"},
-	}
-
-	meta := collectionMetadata[collectionName]
-	if meta.Name == "" {
-		meta.Name = collectionName
-		meta.Emoji = "📦"
-		meta.Description = "Resources in this collection"
+	// Collection metadata, stored in the catalogue
+	meta := metadata.Collection{
+		Identifier:  collectionName,
+		Title:       collectionName,
+		Icon:        "📦",
+		Description: "Resources in this collection",
+	}
+	if a.cat != nil {
+		if collection, err := a.cat.GetCollection(r.Context(), collectionName); err == nil {
+			meta.Title = collection.DisplayTitle()
+			if collection.Icon != "" {
+				meta.Icon = collection.Icon
+			}
+			if collection.Description != "" {
+				meta.Description = collection.Description
+			}
+		} else if !errors.Is(err, repository.ErrCollectionNotFound) {
+			log.Printf("Error loading collection %s: %v", collectionName, err)
+		}
 	}
 
 	pageData := CollectionDetailPageData{
 		CollectionCode:        collectionName,
-		CollectionName:        meta.Name,
-		CollectionEmoji:       meta.Emoji,
+		CollectionName:        meta.Title,
+		CollectionEmoji:       meta.Icon,
 		CollectionDescription: meta.Description,
 		TotalCount:            len(records),
 		Records:               records,